@@ -1,8 +1,23 @@
 package c
 
+import "time"
+
 const (
 	EnvProduction              = "production"
 	MySQLErrDuplicateEntryCode = 1062
 	Success                    = "OK"
 	LockKey                    = "lock"
+	LockExpiration             = 60 * time.Second
+	ReminderLockKey            = "reminder"
+	RecurrenceLockKey          = "recurrence"
+	MaxTaskDepth               = 10
+	HeaderLastWrite            = "X-Last-Write"
+	HeaderApiKey               = "X-API-Key"
+	ContextKeyPrincipal        = "principal"
+	ContextKeyTenant           = "tenant"
+	ContextKeyCalendarFeed     = "calendar_feed"
+	HeaderTenantID             = "X-Tenant-ID"
+	DefaultTenant              = "default"
+	HeaderRequestID            = "X-Request-ID"
+	ContextKeyRequestID        = "request_id"
 )
@@ -3,3 +3,21 @@ package c
 const (
 	DriverMysql = "mysql"
 )
+
+const (
+	CacheModeStandalone = "standalone"
+	CacheModeSentinel   = "sentinel"
+	CacheModeCluster    = "cluster"
+)
+
+const (
+	RateLimitKeyByApiKey = "api_key"
+	RateLimitKeyByIP     = "ip"
+	RateLimitKeyByTenant = "tenant"
+)
+
+const (
+	EventSinkLog     = "log"
+	EventSinkWebhook = "webhook"
+	EventSinkSSE     = "sse"
+)
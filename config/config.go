@@ -29,10 +29,23 @@ type Config struct {
 	LogLevel     string   `mapstructure:"LOG_LEVEL"`
 	LogFile      []string `mapstructure:"LOG_FILE"`
 	ErrorLogFile []string `mapstructure:"ERROR_LOG_FILE"`
+	// AccessLog 是否為每個請求寫一行 access log
+	AccessLog bool `mapstructure:"ACCESS_LOG"`
 
 	Database          DatabaseOption `mapstructure:"DATABASE"`
-	Cache             DatabaseOption `mapstructure:"CACHE"`
+	Cache             CacheOption    `mapstructure:"CACHE"`
 	MigrationFilePath string         `mapstructure:"MIGRATION_FILE_PATH"`
+
+	WriteBehind WriteBehindOption `mapstructure:"WRITE_BEHIND"`
+	Auth        AuthOption        `mapstructure:"AUTH"`
+	RateLimit   RateLimitOption   `mapstructure:"RATE_LIMIT"`
+	Metrics     MetricsOption     `mapstructure:"METRICS"`
+	Tracing     TracingOption     `mapstructure:"TRACING"`
+	Health      HealthOption      `mapstructure:"HEALTH"`
+	Reminder    ReminderOption    `mapstructure:"REMINDER"`
+	Recurrence  RecurrenceOption  `mapstructure:"RECURRENCE"`
+	Attachment  AttachmentOption  `mapstructure:"ATTACHMENT"`
+	Import      ImportOption      `mapstructure:"IMPORT"`
 }
 
 type DatabaseOption struct {
@@ -48,6 +61,59 @@ type DatabaseOption struct {
 	Timeout      time.Duration `mapstructure:"TIMEOUT"`
 	ReadTimeout  time.Duration `mapstructure:"READ_TIMEOUT"`
 	WriteTimeout time.Duration `mapstructure:"WRITE_TIMEOUT"`
+
+	// Replicas 唯讀副本，未設定的欄位沿用主庫設定
+	Replicas []DatabaseOption `mapstructure:"REPLICAS"`
+	// ReplicaMaxLag 副本延遲超過此值即暫停讀取，ReplicaCheckInterval 為健康檢查間隔
+	ReplicaMaxLag        time.Duration `mapstructure:"REPLICA_MAX_LAG"`
+	ReplicaCheckInterval time.Duration `mapstructure:"REPLICA_CHECK_INTERVAL"`
+	// PrimaryStickiness 寫入後同一 client 的讀取導向主庫的時間
+	PrimaryStickiness time.Duration `mapstructure:"PRIMARY_STICKINESS"`
+	// PrimaryStickinessSecret 簽署寫入時間（X-Last-Write header 與 cookie）的 HMAC 金鑰，多個服務副本需設定相同的值
+	PrimaryStickinessSecret string `mapstructure:"PRIMARY_STICKINESS_SECRET"`
+}
+
+// CacheOption Redis 連線設定，MODE 可為 standalone、sentinel 或 cluster
+type CacheOption struct {
+	Mode string `mapstructure:"MODE"`
+	Host string `mapstructure:"HOST"`
+	Port uint16 `mapstructure:"PORT"`
+	// Addrs 為 sentinel 或 cluster 節點位址，未設定時使用 HOST 與 PORT
+	Addrs      []string `mapstructure:"ADDRS"`
+	MasterName string   `mapstructure:"MASTER_NAME"`
+	DB         int      `mapstructure:"DB"`
+
+	Username         string `mapstructure:"USERNAME"`
+	Password         string `mapstructure:"PASSWORD"`
+	SentinelUsername string `mapstructure:"SENTINEL_USERNAME"`
+	SentinelPassword string `mapstructure:"SENTINEL_PASSWORD"`
+
+	PoolSize     int           `mapstructure:"POOL_SIZE"`
+	MinIdleConns int           `mapstructure:"MIN_IDLE_CONNS"`
+	DialTimeout  time.Duration `mapstructure:"DIAL_TIMEOUT"`
+	ReadTimeout  time.Duration `mapstructure:"READ_TIMEOUT"`
+	WriteTimeout time.Duration `mapstructure:"WRITE_TIMEOUT"`
+
+	TLS TLSOption `mapstructure:"TLS"`
+
+	CircuitBreaker CircuitBreakerOption `mapstructure:"CIRCUIT_BREAKER"`
+}
+
+// CircuitBreakerOption Redis 斷路器設定，連續失敗 FAILURE_THRESHOLD 次後略過 Redis，
+// 之後每 PROBE_INTERVAL 檢查一次，恢復後清除快取再重新啟用
+type CircuitBreakerOption struct {
+	FailureThreshold int           `mapstructure:"FAILURE_THRESHOLD"`
+	ProbeInterval    time.Duration `mapstructure:"PROBE_INTERVAL"`
+}
+
+// TLSOption TLS 連線設定
+type TLSOption struct {
+	Enable             bool   `mapstructure:"ENABLE"`
+	CAFile             string `mapstructure:"CA_FILE"`
+	CertFile           string `mapstructure:"CERT_FILE"`
+	KeyFile            string `mapstructure:"KEY_FILE"`
+	ServerName         string `mapstructure:"SERVER_NAME"`
+	InsecureSkipVerify bool   `mapstructure:"INSECURE_SKIP_VERIFY"`
 }
 
 type Service struct {
@@ -55,3 +121,147 @@ type Service struct {
 	Host string `mapstructure:"HOST"`
 	Port string `mapstructure:"PORT"`
 }
+
+// WriteBehindOption 任務更新的 write-behind 設定
+type WriteBehindOption struct {
+	Enable bool `mapstructure:"ENABLE"`
+	// Stream 與 Group 為 Redis Stream 的 key 與 consumer group 名稱
+	Stream   string `mapstructure:"STREAM"`
+	Group    string `mapstructure:"GROUP"`
+	Consumer string `mapstructure:"CONSUMER"`
+	// BatchSize 與 FlushInterval 決定寫回 MySQL 的批次大小與最長間隔
+	BatchSize     int           `mapstructure:"BATCH_SIZE"`
+	FlushInterval time.Duration `mapstructure:"FLUSH_INTERVAL"`
+	// MaxPending 為 stream 中尚未寫回的上限，超過時等待 BackPressureTimeout 後拒絕
+	MaxPending          int64         `mapstructure:"MAX_PENDING"`
+	BackPressureTimeout time.Duration `mapstructure:"BACK_PRESSURE_TIMEOUT"`
+	// ClaimIdle 為其他 consumer 未 ack 的訊息閒置多久後接手處理
+	ClaimIdle time.Duration `mapstructure:"CLAIM_IDLE"`
+	// MinReplicas 大於 0 時，append 後以 WAIT 確認寫入至少多少個 replica，不足時只記錄警告
+	MinReplicas int `mapstructure:"MIN_REPLICAS"`
+	// StopTimeout 為關閉服務時等待 stream 寫回 MySQL 的上限，預設 4s
+	StopTimeout time.Duration `mapstructure:"STOP_TIMEOUT"`
+}
+
+// AuthOption API 驗證設定，JWT 可使用 HMAC secret 或本地 JWKS 檔案驗證
+type AuthOption struct {
+	Enable       bool   `mapstructure:"ENABLE"`
+	JWTSecret    string `mapstructure:"JWT_SECRET"`
+	JWKSFile     string `mapstructure:"JWKS_FILE"`
+	Issuer       string `mapstructure:"ISSUER"`
+	Audience     string `mapstructure:"AUDIENCE"`
+	ApiKeyHeader string `mapstructure:"API_KEY_HEADER"`
+}
+
+// RateLimitOption 限流設定，計數存放於 Redis，ALGORITHM 可為 token_bucket 或 sliding_window
+type RateLimitOption struct {
+	Enable    bool   `mapstructure:"ENABLE"`
+	Algorithm string `mapstructure:"ALGORITHM"`
+	// KeyBy 限流對象：api_key（依驗證身分，匿名時依 IP）、ip 或 tenant
+	KeyBy string `mapstructure:"KEY_BY"`
+	// Limit 為每個 Period 允許的請求數，Burst 為 token bucket 容量，未設定時等於 Limit
+	Limit  int           `mapstructure:"LIMIT"`
+	Period time.Duration `mapstructure:"PERIOD"`
+	Burst  int           `mapstructure:"BURST"`
+	// Routes 個別路由的限流設定，未設定的欄位沿用上方設定
+	Routes []RouteRateLimitOption `mapstructure:"ROUTES"`
+}
+
+// RouteRateLimitOption 單一路由的限流設定，METHOD 未設定時套用所有 method
+type RouteRateLimitOption struct {
+	Method string        `mapstructure:"METHOD"`
+	Path   string        `mapstructure:"PATH"`
+	KeyBy  string        `mapstructure:"KEY_BY"`
+	Limit  int           `mapstructure:"LIMIT"`
+	Period time.Duration `mapstructure:"PERIOD"`
+	Burst  int           `mapstructure:"BURST"`
+}
+
+// MetricsOption Prometheus 指標設定
+type MetricsOption struct {
+	Enable bool   `mapstructure:"ENABLE"`
+	Path   string `mapstructure:"PATH"`
+	// StatusRefreshInterval 各狀態任務數的重新統計間隔
+	StatusRefreshInterval time.Duration `mapstructure:"STATUS_REFRESH_INTERVAL"`
+}
+
+// TracingOption OpenTelemetry tracing 設定，EXPORTER 可為 otlp、stdout 或 file
+type TracingOption struct {
+	Enable   bool   `mapstructure:"ENABLE"`
+	Exporter string `mapstructure:"EXPORTER"`
+	// Endpoint 為 OTLP HTTP collector 位址，例如 localhost:4318
+	Endpoint string `mapstructure:"ENDPOINT"`
+	Insecure bool   `mapstructure:"INSECURE"`
+	// FilePath 為 file exporter 的輸出檔案
+	FilePath string `mapstructure:"FILE_PATH"`
+	// SampleRatio 為新 trace 的取樣比例，上游已取樣的 trace 一律保留
+	SampleRatio float64 `mapstructure:"SAMPLE_RATIO"`
+}
+
+// HealthOption readiness 檢查設定
+type HealthOption struct {
+	// Timeout 為每個相依服務檢查的逾時時間
+	Timeout time.Duration `mapstructure:"TIMEOUT"`
+	// DrainDelay 為關閉服務時 readiness 失敗後，等待流量移轉再關閉 http server 的時間
+	DrainDelay time.Duration `mapstructure:"DRAIN_DELAY"`
+}
+
+// ReminderOption 提醒與逾期事件排程設定，SINKS 可為 log、webhook 或 sse
+type ReminderOption struct {
+	Enable bool `mapstructure:"ENABLE"`
+	// Interval 為掃描到期提醒與逾期任務的間隔，BatchSize 為每次掃描的上限
+	Interval  time.Duration `mapstructure:"INTERVAL"`
+	BatchSize int           `mapstructure:"BATCH_SIZE"`
+	Sinks     []string      `mapstructure:"SINKS"`
+	Webhook   WebhookOption `mapstructure:"WEBHOOK"`
+	// Channel 為 sse 事件在各節點間轉送使用的 Redis channel
+	Channel string `mapstructure:"CHANNEL"`
+}
+
+// WebhookOption 事件 webhook 設定，設定 SECRET 時以 HMAC-SHA256 簽署內容
+type WebhookOption struct {
+	URL     string        `mapstructure:"URL"`
+	Secret  string        `mapstructure:"SECRET"`
+	Timeout time.Duration `mapstructure:"TIMEOUT"`
+}
+
+// RecurrenceOption 週期任務排程設定
+type RecurrenceOption struct {
+	Enable bool `mapstructure:"ENABLE"`
+	// Interval 為檢查週期任務下一次發生時間的間隔，BatchSize 為每次處理的週期任務上限
+	Interval  time.Duration `mapstructure:"INTERVAL"`
+	BatchSize int           `mapstructure:"BATCH_SIZE"`
+}
+
+// AttachmentOption 任務附件設定，STORE 可為 local 或 s3
+type AttachmentOption struct {
+	Enable bool   `mapstructure:"ENABLE"`
+	Store  string `mapstructure:"STORE"`
+	// LocalDir 為 local store 存放檔案的目錄
+	LocalDir string `mapstructure:"LOCAL_DIR"`
+	// MaxSize 為單一附件的大小上限（bytes）
+	MaxSize int64 `mapstructure:"MAX_SIZE"`
+	// AllowedTypes 為允許的 MIME type，依檔案內容判斷，可使用 image/* 表示同一類型
+	AllowedTypes []string `mapstructure:"ALLOWED_TYPES"`
+	S3           S3Option `mapstructure:"S3"`
+}
+
+// ImportOption 任務匯入設定
+type ImportOption struct {
+	// MaxSize 為單次匯入內容的大小上限（bytes），MaxRows 為單次匯入的筆數上限
+	MaxSize int64 `mapstructure:"MAX_SIZE"`
+	MaxRows int   `mapstructure:"MAX_ROWS"`
+}
+
+// S3Option S3 相容儲存設定，PATH_STYLE 為 true 時以 ENDPOINT/BUCKET/key 存取（例如 MinIO）
+type S3Option struct {
+	Endpoint  string `mapstructure:"ENDPOINT"`
+	Region    string `mapstructure:"REGION"`
+	Bucket    string `mapstructure:"BUCKET"`
+	AccessKey string `mapstructure:"ACCESS_KEY"`
+	SecretKey string `mapstructure:"SECRET_KEY"`
+	PathStyle bool   `mapstructure:"PATH_STYLE"`
+	// Prefix 加在每個物件 key 之前
+	Prefix  string        `mapstructure:"PREFIX"`
+	Timeout time.Duration `mapstructure:"TIMEOUT"`
+}
@@ -9,9 +9,12 @@ import (
 	"sync"
 	"task_service/c"
 	"task_service/config"
+	"task_service/pkg/database"
+	"task_service/pkg/health"
 	"time"
 
 	"github.com/RediSearch/redisearch-go/redisearch"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -26,10 +29,13 @@ type Application struct {
 	config       *config.Config
 	addr         string
 	db           *sql.DB
+	replicas     []database.Replica
 	gormClient   *gorm.DB
-	cacheClient  *redis.Client
+	cacheClient  redis.UniversalClient
 	searchClient *redisearch.Client
 	redis        *redis.Client
+	metrics      *prometheus.Registry
+	health       *health.Checker
 	// Init and destroy hooks
 	initHooks    []ApplicationHook
 	destroyHooks []ApplicationHook
@@ -97,6 +103,17 @@ func (app *Application) Shutdown() {
 		app.logger.Warn("shutdowning")
 	}
 
+	// fail readiness first and give load balancers time to stop routing here
+	if app.health != nil {
+		app.health.SetDraining()
+		if delay := app.config.Health.DrainDelay; delay > 0 {
+			if app.logger != nil {
+				app.logger.Info("draining traffic for ", delay)
+			}
+			time.Sleep(delay)
+		}
+	}
+
 	c, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := app.srv.Shutdown(c); err != nil {
@@ -154,6 +171,12 @@ func (app *Application) SetSrv(handler http.Handler) {
 	app.srv.Handler = handler
 }
 
+// RegisterOnShutdown registers f to run when the http server starts shutting
+// down, e.g. to end long-lived responses.
+func (app *Application) RegisterOnShutdown(f func()) {
+	app.srv.RegisterOnShutdown(f)
+}
+
 func (app *Application) SetAddr(addr string) {
 	app.addr = addr
 }
@@ -176,6 +199,31 @@ func (app *Application) GetDatabase() *sql.DB {
 	return app.db
 }
 
+func (app *Application) SetReplicas(replicas []database.Replica) {
+	app.replicas = replicas
+}
+
+func (app *Application) GetReplicas() []database.Replica {
+	return app.replicas
+}
+
+func (app *Application) SetMetricsRegistry(registry *prometheus.Registry) {
+	app.metrics = registry
+}
+
+// GetMetricsRegistry returns nil when metrics are disabled.
+func (app *Application) GetMetricsRegistry() *prometheus.Registry {
+	return app.metrics
+}
+
+func (app *Application) SetHealthChecker(checker *health.Checker) {
+	app.health = checker
+}
+
+func (app *Application) GetHealthChecker() *health.Checker {
+	return app.health
+}
+
 func (app *Application) SetGormClient(db *gorm.DB) {
 	app.gormClient = db
 }
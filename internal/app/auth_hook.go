@@ -0,0 +1,26 @@
+package app
+
+import (
+	"fmt"
+	"task_service/internal/data"
+	"task_service/internal/service/middleware"
+	"task_service/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// initAuth builds the authentication middleware from the AUTH config.
+func initAuth(app *Application, apiKeyMgr data.ApiKeyManager) (gin.HandlerFunc, error) {
+	option := app.GetConfig().Auth
+
+	var verifier *auth.Verifier
+	if option.Enable {
+		var err error
+		verifier, err = auth.NewVerifier(option.JWTSecret, option.JWKSFile, option.Issuer, option.Audience)
+		if err != nil {
+			return nil, fmt.Errorf("initAuth: %v", err)
+		}
+	}
+
+	return middleware.Authenticate(option, verifier, apiKeyMgr), nil
+}
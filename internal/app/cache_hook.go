@@ -1,32 +1,27 @@
 package app
 
 import (
-	"context"
 	"fmt"
 	"task_service/config"
+	"task_service/pkg/database"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 )
 
 func InitCacheHook(app *Application) error {
 	cacheConfig := config.GetConfig().Cache
 
-	addr := fmt.Sprintf("%s:%v", cacheConfig.Host, cacheConfig.Port)
-
-	redisOpt := &redis.Options{
-		Addr: addr, // Redis 伺服器位址
-	}
-
-	if cacheConfig.Password != "" {
-		redisOpt.Password = cacheConfig.Password
-	}
-
-	rdb := redis.NewClient(redisOpt)
-	_, err := rdb.Ping(context.Background()).Result()
+	rdb, err := database.OpenRedisClient(&cacheConfig)
 	if err != nil {
 		return fmt.Errorf("InitCacheHook: %v", err)
 	}
 
+	if config.GetConfig().Tracing.Enable {
+		if err := redisotel.InstrumentTracing(rdb); err != nil {
+			return fmt.Errorf("InitCacheHook: %v", err)
+		}
+	}
+
 	app.cacheClient = rdb
 	return nil
 }
@@ -18,6 +18,12 @@ func InitDatabaseHook(app *Application) error {
 
 	app.SetDatabase(db)
 
+	replicas, err := database.OpenMysqlReplicas(&app.GetConfig().Database)
+	if err != nil {
+		return fmt.Errorf("InitDatabaseHook: %s", err)
+	}
+	app.SetReplicas(replicas)
+
 	if err := migration(app); err != nil {
 		return fmt.Errorf("InitDatabaseHook: %s", err)
 	}
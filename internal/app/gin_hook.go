@@ -1,34 +1,204 @@
 package app
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"slices"
 	"task_service/internal/data"
 	"task_service/internal/service/controller"
+	"task_service/internal/service/middleware"
+	"task_service/pkg/blob"
 	"task_service/pkg/database"
+	"task_service/pkg/tracing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-var ctrl *controller.Controller
+var (
+	ctrl              *controller.Controller
+	breakerMgr        *data.BreakerCacheMgr
+	stopReplicaChecks context.CancelFunc
+)
 
 func initCtrl(app *Application, r *gin.Engine) error {
 
 	gormCli, err := database.InitGormClient(app.GetDatabase())
 	if err != nil {
-		fmt.Errorf("initCtrl: %s", err.Error())
+		return fmt.Errorf("initCtrl: %s", err.Error())
+	}
+
+	dbOption := &app.GetConfig().Database
+	replicaCtx, cancel := context.WithCancel(context.Background())
+	stopReplicaChecks = cancel
+	if err := database.UseReplicas(replicaCtx, gormCli, app.GetDatabase(), app.GetReplicas(), dbOption); err != nil {
+		return fmt.Errorf("initCtrl: %s", err.Error())
+	}
+
+	tracingEnabled := app.GetConfig().Tracing.Enable
+	if tracingEnabled {
+		if err := gormCli.Use(tracing.NewGormPlugin()); err != nil {
+			return fmt.Errorf("initCtrl: %s", err.Error())
+		}
+	}
+
+	dataMgr, err := initWriteBehind(app, data.NewDataManager(gormCli))
+	if err != nil {
+		return fmt.Errorf("initCtrl: %s", err.Error())
+	}
+	breakerMgr = data.NewBreakerCacheMgr(app.cacheClient, app.GetConfig().Cache.CircuitBreaker)
+	var cacheMgr data.DataManager = breakerMgr
+	if tracingEnabled {
+		dataMgr = data.WithTracing(dataMgr, "mysql")
+		cacheMgr = data.WithTracing(cacheMgr, "cache")
+	}
+
+	apiKeyMgr := data.NewApiKeyManager(gormCli)
+	tenantMgr := data.NewTenantManager(gormCli)
+	seriesMgr := data.NewSeriesManager(gormCli)
+	hierarchyMgr := data.NewHierarchyManager(gormCli)
+	dependencyMgr := data.NewDependencyManager(gormCli)
+	if writeBehindMgr != nil {
+		// blockers completed a moment ago may not be in MySQL yet
+		dependencyMgr = writeBehindMgr.WithPendingUpdates(dependencyMgr)
+	}
+	tagMgr := data.NewTagManager(gormCli)
+	commentMgr := data.NewCommentManager(gormCli)
+	rankMgr := data.NewRankManager(gormCli)
+	fieldMgr := data.NewFieldManager(gormCli)
+	templateMgr := data.NewTemplateManager(gormCli)
+	calendarFeedMgr := data.NewCalendarFeedManager(gormCli)
+	importOption := app.GetConfig().Import
+
+	eventSink, broker, err := initReminder(app, gormCli, cacheMgr, dataMgr)
+	if err != nil {
+		return fmt.Errorf("initCtrl: %s", err.Error())
 	}
 
-	dataMgr := data.NewDataManager(gormCli)
-	cacheMgr := data.NewDataManager(app.cacheClient)
+	opts := []controller.Option{
+		controller.WithPrimaryStickiness(dbOption.PrimaryStickiness, dbOption.PrimaryStickinessSecret),
+		controller.WithApiKeyManager(apiKeyMgr),
+		controller.WithTenantManager(tenantMgr),
+		controller.WithHealthChecker(app.GetHealthChecker()),
+		controller.WithEventBroker(broker),
+		controller.WithEventSink(eventSink),
+		controller.WithSeriesManager(seriesMgr),
+		controller.WithHierarchyManager(hierarchyMgr),
+		controller.WithDependencyManager(dependencyMgr),
+		controller.WithTagManager(tagMgr),
+		controller.WithCommentManager(commentMgr),
+		controller.WithRankManager(rankMgr),
+		controller.WithFieldManager(fieldMgr),
+		controller.WithTemplateManager(templateMgr),
+		controller.WithCalendarFeedManager(calendarFeedMgr),
+		controller.WithImports(data.NewImportManager(gormCli), importOption.MaxSize, importOption.MaxRows),
+	}
+	if attachmentOption := app.GetConfig().Attachment; attachmentOption.Enable {
+		store, err := blob.NewStore(attachmentOption)
+		if err != nil {
+			return fmt.Errorf("initCtrl: %s", err.Error())
+		}
+		opts = append(opts, controller.WithAttachments(data.NewAttachmentManager(gormCli), store,
+			attachmentOption.MaxSize, attachmentOption.AllowedTypes))
+	}
+	ctrl = controller.NewController(dataMgr, cacheMgr, opts...)
+	// the cache was flushed while redis was down, refill it from mysql
+	breakerMgr.OnRecover(ctrl.ResetCache)
+	breakerMgr.Start()
+	initRecurrence(app, seriesMgr, cacheMgr, ctrl.ResetCache, dataMgr)
 
-	ctrl := controller.NewController(dataMgr, cacheMgr)
+	authenticate, err := initAuth(app, apiKeyMgr)
+	if err != nil {
+		return fmt.Errorf("initCtrl: %s", err.Error())
+	}
+
+	rateLimit, err := initRateLimit(app)
+	if err != nil {
+		return fmt.Errorf("initCtrl: %s", err.Error())
+	}
+
+	r.GET("/healthz", ctrl.Healthz)
+	r.GET("/readyz", ctrl.Readyz)
 
 	v1Group := r.Group("task-service/api/v1")
-	v1Group.GET("/tasks/:taskId", ctrl.GetTask)
-	v1Group.GET("/tasks", ctrl.ListTask)
-	v1Group.POST("/tasks", ctrl.CreateTask)
-	v1Group.PUT("/tasks/:taskId", ctrl.UpdateTask)
-	v1Group.DELETE("/tasks/:taskId", ctrl.DeleteTask)
+	v1Group.Use(authenticate)
+
+	// tenant admin routes are not scoped to a tenant
+	v1Group.POST("/tenants", ctrl.CreateTenant)
+	v1Group.GET("/tenants", ctrl.ListTenant)
+	v1Group.GET("/tenants/:tenantId", ctrl.GetTenant)
+	v1Group.PUT("/tenants/:tenantId", ctrl.UpdateTenant)
+	v1Group.POST("/tenants/:tenantId/suspend", ctrl.SuspendTenant)
+	v1Group.POST("/tenants/:tenantId/resume", ctrl.ResumeTenant)
+
+	resolveTenant := middleware.ResolveTenant(tenantMgr)
+	tenantGroup := v1Group.Group("", resolveTenant)
+	if rateLimit != nil {
+		tenantGroup.Use(rateLimit)
+	}
+	tenantGroup.GET("/tasks/export", ctrl.ExportTasks)
+	tenantGroup.POST("/tasks/import", ctrl.ImportTasks)
+	tenantGroup.GET("/tasks/import/:jobId", ctrl.GetImportJob)
+	tenantGroup.GET("/tasks/:taskId", ctrl.GetTask)
+	tenantGroup.GET("/tasks/:taskId/children", ctrl.ListChildren)
+	tenantGroup.GET("/tasks/:taskId/graph", ctrl.GetTaskGraph)
+	tenantGroup.POST("/tasks/:taskId/dependencies", ctrl.AddDependency)
+	tenantGroup.DELETE("/tasks/:taskId/dependencies/:blockerId", ctrl.RemoveDependency)
+	tenantGroup.POST("/tasks/:taskId/move", ctrl.MoveTask)
+	tenantGroup.GET("/tasks/:taskId/comments", ctrl.ListComments)
+	tenantGroup.POST("/tasks/:taskId/comments", ctrl.CreateComment)
+	tenantGroup.PUT("/tasks/:taskId/comments/:commentId", ctrl.UpdateComment)
+	tenantGroup.DELETE("/tasks/:taskId/comments/:commentId", ctrl.DeleteComment)
+	tenantGroup.GET("/tasks/:taskId/comments/:commentId/revisions", ctrl.ListCommentRevisions)
+	if app.GetConfig().Attachment.Enable {
+		tenantGroup.GET("/tasks/:taskId/attachments", ctrl.ListAttachments)
+		tenantGroup.POST("/tasks/:taskId/attachments", ctrl.UploadAttachment)
+		tenantGroup.GET("/tasks/:taskId/attachments/:attachmentId", ctrl.DownloadAttachment)
+		tenantGroup.DELETE("/tasks/:taskId/attachments/:attachmentId", ctrl.DeleteAttachment)
+	}
+	tenantGroup.GET("/tasks", ctrl.ListTask)
+	tenantGroup.POST("/tasks", ctrl.CreateTask)
+	tenantGroup.PUT("/tasks/:taskId", ctrl.UpdateTask)
+	tenantGroup.DELETE("/tasks/:taskId", ctrl.DeleteTask)
+	tenantGroup.GET("/tags", ctrl.ListTags)
+	tenantGroup.POST("/tags", ctrl.CreateTag)
+	tenantGroup.GET("/tags/:tagId", ctrl.GetTag)
+	tenantGroup.PUT("/tags/:tagId", ctrl.UpdateTag)
+	tenantGroup.DELETE("/tags/:tagId", ctrl.DeleteTag)
+	tenantGroup.POST("/tags/:tagId/merge", ctrl.MergeTag)
+	tenantGroup.GET("/fields", ctrl.ListFields)
+	tenantGroup.POST("/fields", ctrl.CreateField)
+	tenantGroup.GET("/fields/:fieldId", ctrl.GetField)
+	tenantGroup.PUT("/fields/:fieldId", ctrl.UpdateField)
+	tenantGroup.DELETE("/fields/:fieldId", ctrl.DeleteField)
+	tenantGroup.GET("/templates", ctrl.ListTemplates)
+	tenantGroup.POST("/templates", ctrl.CreateTemplate)
+	tenantGroup.GET("/templates/:templateId", ctrl.GetTemplate)
+	tenantGroup.PUT("/templates/:templateId", ctrl.UpdateTemplate)
+	tenantGroup.DELETE("/templates/:templateId", ctrl.DeleteTemplate)
+	tenantGroup.POST("/templates/:templateId/instantiate", ctrl.InstantiateTemplate)
+	tenantGroup.GET("/series/:seriesId", ctrl.GetSeries)
+	tenantGroup.POST("/series/:seriesId/exceptions", ctrl.AddSeriesException)
+	tenantGroup.DELETE("/series/:seriesId/exceptions", ctrl.RemoveSeriesException)
+	if broker != nil {
+		tenantGroup.GET("/events", ctrl.StreamEvents)
+	}
+
+	tenantGroup.POST("/api-keys", ctrl.CreateApiKey)
+	tenantGroup.GET("/api-keys", ctrl.ListApiKey)
+	tenantGroup.DELETE("/api-keys/:keyId", ctrl.RevokeApiKey)
+	tenantGroup.POST("/calendar-feeds", ctrl.CreateCalendarFeed)
+	tenantGroup.GET("/calendar-feeds", ctrl.ListCalendarFeeds)
+	tenantGroup.DELETE("/calendar-feeds/:feedId", ctrl.RevokeCalendarFeed)
+
+	// calendar apps authenticate with the token of the feed in the URL
+	feedGroup := r.Group("task-service/api/v1", middleware.AuthenticateCalendarFeed(calendarFeedMgr), resolveTenant)
+	if rateLimit != nil {
+		feedGroup.Use(rateLimit)
+	}
+	feedGroup.GET("/tasks/calendar.ics", ctrl.CalendarFeed)
 
 	return nil
 }
@@ -41,9 +211,35 @@ func InitGinApplicationHook(app *Application) error {
 	gin.EnableJsonDecoderUseNumber()
 
 	r := gin.New()
+	// Let handlers read values such as the principal from the request context
+	// when the gin.Context is passed on as a context.Context.
+	r.ContextWithFallback = true
+
+	metricsPath := app.GetConfig().Metrics.Path
+	if metricsPath == "" {
+		metricsPath = "/metrics"
+	}
+	// scrapes and probes would only add noise to the logs and traces
+	probePaths := []string{metricsPath, "/healthz", "/readyz"}
+
+	// the request logger runs first so the access log also covers panics
+	r.Use(middleware.RequestLogger(app.GetConfig().AccessLog, probePaths...))
 	r.Use(gin.Recovery())
+	if app.GetConfig().Tracing.Enable {
+		r.Use(otelgin.Middleware(app.GetConfig().Service.Name,
+			otelgin.WithFilter(func(req *http.Request) bool {
+				return !slices.Contains(probePaths, req.URL.Path)
+			})))
+	}
 
-	initCtrl(app, r)
+	if registry := app.GetMetricsRegistry(); registry != nil {
+		r.Use(middleware.Metrics())
+		r.GET(metricsPath, gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})))
+	}
+
+	if err := initCtrl(app, r); err != nil {
+		return fmt.Errorf("InitGinApplicationHook: %s", err.Error())
+	}
 	addr := fmt.Sprintf("%s:%s", app.GetConfig().Service.Host, app.GetConfig().Service.Port)
 
 	app.SetAddr(addr)
@@ -53,6 +249,12 @@ func InitGinApplicationHook(app *Application) error {
 }
 
 func DestroyGinApplicationHook(app *Application) error {
+	if breakerMgr != nil {
+		breakerMgr.Stop()
+	}
+	if stopReplicaChecks != nil {
+		stopReplicaChecks()
+	}
 	ctrl.Shutdown()
 	return nil
 }
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"task_service/pkg/database"
+	"task_service/pkg/health"
+)
+
+// InitHealthHook builds the readiness checks of MySQL, its replicas, the
+// schema version and Redis. Only MySQL and the schema are critical, without
+// Redis the service runs degraded. It must run after the database and cache
+// hooks.
+func InitHealthHook(app *Application) error {
+	expectedVersion, err := database.LatestMigrationVersion(app.GetConfig().MigrationFilePath)
+	if err != nil {
+		return fmt.Errorf("InitHealthHook: %v", err)
+	}
+
+	db := app.GetDatabase()
+	checks := []health.Check{
+		{
+			Name:     "mysql",
+			Critical: true,
+			Probe:    db.PingContext,
+		},
+		{
+			Name:     "migration",
+			Critical: true,
+			Probe: func(ctx context.Context) error {
+				return database.CheckMigrationVersion(ctx, db, expectedVersion)
+			},
+		},
+		{
+			Name: "redis",
+			Probe: func(ctx context.Context) error {
+				return app.cacheClient.Ping(ctx).Err()
+			},
+		},
+	}
+	// reads fall back to the primary, so a replica is never critical
+	for _, replica := range app.GetReplicas() {
+		checks = append(checks, health.Check{
+			Name:  "mysql_replica:" + replica.Addr,
+			Probe: replica.DB.PingContext,
+		})
+	}
+
+	app.SetHealthChecker(health.NewChecker(app.GetConfig().Health.Timeout, checks...))
+	return nil
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"fmt"
+	"task_service/internal/data"
+	"task_service/pkg/database"
+	"task_service/pkg/metrics"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const defaultStatusRefreshInterval = 30 * time.Second
+
+// InitMetricsHook registers the collectors of the service, the gin hook
+// serves them when the registry is set. It must run after the database hook.
+func InitMetricsHook(app *Application) error {
+	option := app.GetConfig().Metrics
+	if !option.Enable {
+		return nil
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(app.GetDatabase(), "primary"),
+	)
+	for _, replica := range app.GetReplicas() {
+		if err := registry.Register(collectors.NewDBStatsCollector(replica.DB, replica.Addr)); err != nil {
+			return fmt.Errorf("InitMetricsHook: %v", err)
+		}
+	}
+	if err := metrics.Register(registry); err != nil {
+		return fmt.Errorf("InitMetricsHook: %v", err)
+	}
+
+	gormCli, err := database.InitGormClient(app.GetDatabase())
+	if err != nil {
+		return fmt.Errorf("InitMetricsHook: %v", err)
+	}
+	refreshInterval := option.StatusRefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = defaultStatusRefreshInterval
+	}
+	statsMgr := data.NewStatsManager(gormCli)
+	if err := registry.Register(metrics.NewTaskStatusCollector(statsMgr.CountTaskByStatus, refreshInterval)); err != nil {
+		return fmt.Errorf("InitMetricsHook: %v", err)
+	}
+
+	app.SetMetricsRegistry(registry)
+	return nil
+}
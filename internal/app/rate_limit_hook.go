@@ -0,0 +1,31 @@
+package app
+
+import (
+	"fmt"
+	"task_service/internal/service/middleware"
+	"task_service/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+const rateLimitKeyPrefix = "ratelimit:"
+
+// initRateLimit builds the rate limit middleware from the RATE_LIMIT config,
+// it returns nil when rate limiting is disabled.
+func initRateLimit(app *Application) (gin.HandlerFunc, error) {
+	option := app.GetConfig().RateLimit
+	if !option.Enable {
+		return nil, nil
+	}
+
+	limiter, err := ratelimit.NewLimiter(app.cacheClient, option.Algorithm, rateLimitKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("initRateLimit: %v", err)
+	}
+
+	rateLimit, err := middleware.RateLimit(option, limiter)
+	if err != nil {
+		return nil, fmt.Errorf("initRateLimit: %v", err)
+	}
+	return rateLimit, nil
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"task_service/internal/data"
+	"task_service/internal/service/occurrence"
+)
+
+var occurrenceScheduler *occurrence.Scheduler
+
+// initRecurrence starts the scheduler creating the occurrences of recurring
+// tasks from the RECURRENCE config.
+func initRecurrence(app *Application, store data.SeriesManager, cacheMgr data.DataManager, onCacheError func(), mysqlMgr data.DataManager) {
+	option := app.GetConfig().Recurrence
+	if !option.Enable {
+		return
+	}
+	occurrenceScheduler = occurrence.NewScheduler(store, cacheMgr, onCacheError, option, mysqlMgr)
+	occurrenceScheduler.Start()
+}
+
+// DestroyRecurrenceHook stops the occurrence scheduler.
+func DestroyRecurrenceHook(app *Application) error {
+	if occurrenceScheduler != nil {
+		occurrenceScheduler.Stop()
+	}
+	return nil
+}
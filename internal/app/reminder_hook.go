@@ -0,0 +1,104 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/internal/service/reminder"
+	"task_service/pkg/events"
+	"task_service/pkg/logger"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultEventChannel = "task:events"
+	relayRetryInterval  = 2 * time.Second
+)
+
+var (
+	reminderScheduler *reminder.Scheduler
+	stopEventRelay    context.CancelFunc
+)
+
+// initReminder starts the reminder scheduler from the REMINDER config and
+// returns the sink other task events are published to. With the sse sink it
+// also returns the broker feeding the event streams of this replica, nil
+// otherwise.
+func initReminder(app *Application, gormCli *gorm.DB, cacheMgr, mysqlMgr data.DataManager) (events.Sink, *events.Broker, error) {
+	option := app.GetConfig().Reminder
+	if !option.Enable {
+		return nil, nil, nil
+	}
+
+	channel := option.Channel
+	if channel == "" {
+		channel = defaultEventChannel
+	}
+
+	var (
+		sinks  []events.Sink
+		broker *events.Broker
+	)
+	for _, name := range option.Sinks {
+		switch name {
+		case c.EventSinkLog:
+			sinks = append(sinks, events.NewLogSink())
+		case c.EventSinkWebhook:
+			if option.Webhook.URL == "" {
+				return nil, nil, fmt.Errorf("initReminder: webhook sink requires REMINDER.WEBHOOK.URL")
+			}
+			sinks = append(sinks, events.NewWebhookSink(option.Webhook.URL, option.Webhook.Secret, option.Webhook.Timeout))
+		case c.EventSinkSSE:
+			// the scheduler runs on one replica, the clients are spread over
+			// all of them
+			sinks = append(sinks, events.NewRedisSink(app.cacheClient, channel))
+			broker = events.NewBroker(0)
+		default:
+			return nil, nil, fmt.Errorf("initReminder: unknown sink %q", name)
+		}
+	}
+
+	if broker != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		stopEventRelay = cancel
+		go relayEvents(ctx, app, channel, broker)
+		app.RegisterOnShutdown(broker.Close)
+	}
+
+	bus := events.NewBus(sinks...)
+	reminderScheduler = reminder.NewScheduler(data.NewReminderManager(gormCli), bus, option, cacheMgr, mysqlMgr)
+	reminderScheduler.Start()
+	return bus, broker, nil
+}
+
+// relayEvents feeds broker from the redis channel, resubscribing until ctx
+// is cancelled.
+func relayEvents(ctx context.Context, app *Application, channel string, broker *events.Broker) {
+	for {
+		if err := events.Relay(ctx, app.cacheClient, channel, broker, nil); err != nil {
+			logger.GetLoggerWithKeys(map[string]interface{}{
+				"error": err,
+			}).Error("relayEvents fail")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(relayRetryInterval):
+		}
+	}
+}
+
+// DestroyReminderHook stops the scheduler and the event relay.
+func DestroyReminderHook(app *Application) error {
+	if stopEventRelay != nil {
+		stopEventRelay()
+	}
+	if reminderScheduler != nil {
+		reminderScheduler.Stop()
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"task_service/pkg/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var tracerProvider *sdktrace.TracerProvider
+
+// InitTracingHook installs the global tracer provider and the W3C trace
+// context propagator. It must run before the hooks that instrument clients.
+func InitTracingHook(app *Application) error {
+	option := app.GetConfig().Tracing
+	if !option.Enable {
+		return nil
+	}
+
+	provider, err := tracing.NewTracerProvider(context.Background(), option, app.GetConfig().Service.Name)
+	if err != nil {
+		return fmt.Errorf("InitTracingHook: %v", err)
+	}
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	tracerProvider = provider
+	return nil
+}
+
+// DestroyTracingHook exports the buffered spans, it should be the last
+// destroy hook to run.
+func DestroyTracingHook(app *Application) error {
+	if tracerProvider == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		return fmt.Errorf("DestroyTracingHook: %v", err)
+	}
+	return nil
+}
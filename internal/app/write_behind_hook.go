@@ -0,0 +1,42 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"task_service/internal/data"
+)
+
+var writeBehindMgr *data.WriteBehindMgr
+
+// initWriteBehind wraps the MySQL manager with a write-behind manager when
+// WRITE_BEHIND.ENABLE is set, otherwise it returns the manager unchanged.
+func initWriteBehind(app *Application, mysqlMgr data.DataManager) (data.DataManager, error) {
+	option := app.GetConfig().WriteBehind
+	if !option.Enable {
+		return mysqlMgr, nil
+	}
+
+	mgr := data.NewWriteBehindMgr(mysqlMgr, app.cacheClient, option)
+	if err := mgr.Start(context.Background()); err != nil {
+		return nil, fmt.Errorf("initWriteBehind: %v", err)
+	}
+
+	writeBehindMgr = mgr
+	return mgr, nil
+}
+
+// DestroyWriteBehindHook flushes every buffered update to MySQL before exit.
+func DestroyWriteBehindHook(app *Application) error {
+	if writeBehindMgr == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeBehindMgr.StopTimeout())
+	defer cancel()
+	if err := writeBehindMgr.Stop(ctx); err != nil {
+		return fmt.Errorf("DestroyWriteBehindHook: %v", err)
+	}
+
+	app.GetLogger().Info("WriteBehind: pending updates flushed")
+	return nil
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"task_service/pkg/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ApiKeyManager stores the hashed API keys accepted by the auth middleware.
+type ApiKeyManager interface {
+	CreateApiKey(ctx context.Context, key *models.ApiKey) error
+	GetApiKeyByHash(ctx context.Context, hash string) (models.ApiKey, error)
+	ListApiKey(ctx context.Context, ownerId string) ([]models.ApiKey, error)
+	RevokeApiKey(ctx context.Context, ownerId string, keyId uint64) error
+}
+
+func NewApiKeyManager(client *gorm.DB) ApiKeyManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) CreateApiKey(ctx context.Context, key *models.ApiKey) error {
+	key.TenantID = TenantFromContext(ctx)
+	if err := mgr.client.WithContext(ctx).Create(key).Error; err != nil {
+		return fmt.Errorf("CreateApiKey: %s", err.Error())
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) GetApiKeyByHash(ctx context.Context, hash string) (models.ApiKey, error) {
+	key := models.ApiKey{}
+	// Read from the primary so that a revoked key stops working at once.
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).
+		Where("key_hash = ?", hash).First(&key).Error; err != nil {
+		return models.ApiKey{}, fmt.Errorf("GetApiKeyByHash: %s", err.Error())
+	}
+	return key, nil
+}
+
+func (mgr *MysqlMgr) ListApiKey(ctx context.Context, ownerId string) ([]models.ApiKey, error) {
+	var keys []models.ApiKey
+	if err := mgr.reader(ctx).Scopes(tenantScope(ctx)).
+		Where("owner_id = ?", ownerId).Order("id").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("ListApiKey: %s", err.Error())
+	}
+	return keys, nil
+}
+
+func (mgr *MysqlMgr) RevokeApiKey(ctx context.Context, ownerId string, keyId uint64) error {
+	result := mgr.client.WithContext(ctx).Model(&models.ApiKey{}).Scopes(tenantScope(ctx)).
+		Where("id = ? AND owner_id = ? AND revoked_at IS NULL", keyId, ownerId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("RevokeApiKey: %s", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("RevokeApiKey: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"task_service/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// AttachmentManager stores the metadata of task attachments, their content
+// is kept in a blob.Store. Reads deciding a write go to the primary.
+type AttachmentManager interface {
+	CreateAttachment(ctx context.Context, attachment *models.Attachment) error
+	ListAttachments(ctx context.Context, taskId uint64) ([]models.Attachment, error)
+	GetAttachment(ctx context.Context, taskId, attachmentId uint64) (models.Attachment, error)
+	// FindAttachment returns the attachment of a task having the content
+	// digest sha256.
+	FindAttachment(ctx context.Context, taskId uint64, sha256 string) (models.Attachment, error)
+	DeleteAttachment(ctx context.Context, taskId, attachmentId uint64) error
+	// CountBlobReferences returns the number of attachments of the tenant
+	// sharing the blob of sha256.
+	CountBlobReferences(ctx context.Context, sha256 string) (int64, error)
+}
+
+func NewAttachmentManager(client *gorm.DB) AttachmentManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) CreateAttachment(ctx context.Context, attachment *models.Attachment) error {
+	attachment.TenantID = TenantFromContext(ctx)
+	if err := mgr.client.WithContext(ctx).Create(attachment).Error; err != nil {
+		return fmt.Errorf("CreateAttachment: %s", err.Error())
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) ListAttachments(ctx context.Context, taskId uint64) ([]models.Attachment, error) {
+	attachments := []models.Attachment{}
+	if err := mgr.reader(ctx).Scopes(tenantScope(ctx)).
+		Where("task_id = ?", taskId).Order("id").
+		Find(&attachments).Error; err != nil {
+		return nil, fmt.Errorf("ListAttachments: %s", err.Error())
+	}
+	return attachments, nil
+}
+
+func (mgr *MysqlMgr) GetAttachment(ctx context.Context, taskId, attachmentId uint64) (models.Attachment, error) {
+	attachment := models.Attachment{}
+	if err := mgr.reader(ctx).Scopes(tenantScope(ctx)).
+		First(&attachment, "task_id = ? AND id = ?", taskId, attachmentId).Error; err != nil {
+		return models.Attachment{}, fmt.Errorf("GetAttachment: %w", err)
+	}
+	return attachment, nil
+}
+
+func (mgr *MysqlMgr) FindAttachment(ctx context.Context, taskId uint64, sha256 string) (models.Attachment, error) {
+	attachment := models.Attachment{}
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Scopes(tenantScope(ctx)).
+		First(&attachment, "task_id = ? AND sha256 = ?", taskId, sha256).Error; err != nil {
+		return models.Attachment{}, fmt.Errorf("FindAttachment: %w", err)
+	}
+	return attachment, nil
+}
+
+func (mgr *MysqlMgr) DeleteAttachment(ctx context.Context, taskId, attachmentId uint64) error {
+	result := mgr.client.WithContext(ctx).Scopes(tenantScope(ctx)).
+		Delete(&models.Attachment{}, "task_id = ? AND id = ?", taskId, attachmentId)
+	if result.Error != nil {
+		return fmt.Errorf("DeleteAttachment: %s", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("DeleteAttachment: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) CountBlobReferences(ctx context.Context, sha256 string) (int64, error) {
+	var count int64
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Model(&models.Attachment{}).
+		Scopes(tenantScope(ctx)).Where("sha256 = ?", sha256).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("CountBlobReferences: %s", err.Error())
+	}
+	return count, nil
+}
@@ -0,0 +1,242 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"task_service/config"
+	"task_service/pkg/breaker"
+	"task_service/pkg/lock"
+	"task_service/pkg/logger"
+	"task_service/pkg/metrics"
+	"task_service/pkg/models"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultProbeInterval = 2 * time.Second
+
+// ErrCacheUnavailable is returned without calling redis while the cache
+// circuit breaker is open.
+var ErrCacheUnavailable = errors.New("cache is unavailable")
+
+// BreakerCacheMgr guards a CacheMgr with a circuit breaker. Once redis
+// fails repeatedly every call fails fast with ErrCacheUnavailable, so
+// callers fall back to MySQL without waiting for timeouts. A prober pings
+// redis while the breaker is open and closes it once redis answers and the
+// cache, which missed the writes of the outage, has been flushed.
+type BreakerCacheMgr struct {
+	cache         *CacheMgr
+	client        redis.UniversalClient
+	breaker       *breaker.Breaker
+	probeInterval time.Duration
+
+	mu           sync.Mutex
+	recoverHooks []func()
+	// recoveredAt is when the prober last closed the breaker
+	recoveredAt time.Time
+
+	stopOnce sync.Once
+	stopc    chan struct{}
+	donec    chan struct{}
+}
+
+func NewBreakerCacheMgr(client redis.UniversalClient, option config.CircuitBreakerOption) *BreakerCacheMgr {
+	probeInterval := option.ProbeInterval
+	if probeInterval <= 0 {
+		probeInterval = defaultProbeInterval
+	}
+
+	mgr := &BreakerCacheMgr{
+		cache:         newCacheMgr(client),
+		client:        client,
+		breaker:       breaker.New(option.FailureThreshold),
+		probeInterval: probeInterval,
+		stopc:         make(chan struct{}),
+		donec:         make(chan struct{}),
+	}
+	mgr.breaker.OnStateChange(func(from, to breaker.State) {
+		logger.GetLoggerWithKeys(map[string]interface{}{
+			"from": from.String(),
+			"to":   to.String(),
+		}).Warn("cache circuit breaker state changed")
+		if to == breaker.StateOpen {
+			metrics.CacheBreakerOpen.Set(1)
+		} else {
+			metrics.CacheBreakerOpen.Set(0)
+		}
+	})
+	return mgr
+}
+
+// OnRecover registers fn to run after the cache has been flushed and
+// enabled again.
+func (mgr *BreakerCacheMgr) OnRecover(fn func()) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	mgr.recoverHooks = append(mgr.recoverHooks, fn)
+}
+
+// Available reports whether calls currently go to redis.
+func (mgr *BreakerCacheMgr) Available() bool {
+	return mgr.breaker.Allow()
+}
+
+// lockBackends reports where locks held up to expiration are taken. The
+// breakers of the instances close at different times, up to a probe
+// interval apart, and a lock taken from MySQL before is held up to
+// expiration longer. Until then locks are taken from both redis and MySQL,
+// so they exclude the locks of the instances still on either.
+func (mgr *BreakerCacheMgr) lockBackends(expiration time.Duration) (cache, mysql bool) {
+	if !mgr.breaker.Allow() {
+		return false, true
+	}
+	mgr.mu.Lock()
+	recoveredAt := mgr.recoveredAt
+	mgr.mu.Unlock()
+	return true, !recoveredAt.IsZero() && time.Since(recoveredAt) < mgr.probeInterval+expiration
+}
+
+// Start runs the prober until Stop.
+func (mgr *BreakerCacheMgr) Start() {
+	go mgr.run()
+}
+
+func (mgr *BreakerCacheMgr) Stop() {
+	mgr.stopOnce.Do(func() {
+		close(mgr.stopc)
+	})
+	<-mgr.donec
+}
+
+func (mgr *BreakerCacheMgr) run() {
+	defer close(mgr.donec)
+
+	ticker := time.NewTicker(mgr.probeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-mgr.stopc:
+			return
+		case <-ticker.C:
+			if mgr.breaker.State() == breaker.StateOpen {
+				mgr.probe()
+			}
+		}
+	}
+}
+
+func (mgr *BreakerCacheMgr) probe() {
+	ctx, cancel := context.WithTimeout(context.Background(), mgr.probeInterval)
+	defer cancel()
+
+	if err := mgr.client.Ping(ctx).Err(); err != nil {
+		return
+	}
+	if err := mgr.cache.Flush(ctx); err != nil {
+		logger.GetLoggerWithKeys(map[string]interface{}{
+			"error": err,
+		}).Error("cache probe: flush fail")
+		return
+	}
+
+	mgr.breaker.Close()
+
+	mgr.mu.Lock()
+	mgr.recoveredAt = time.Now()
+	hooks := mgr.recoverHooks
+	mgr.mu.Unlock()
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// call runs fn when the breaker is closed and records its outcome.
+func (mgr *BreakerCacheMgr) call(ctx context.Context, fn func() error) error {
+	if !mgr.breaker.Allow() {
+		return ErrCacheUnavailable
+	}
+
+	err := fn()
+	switch {
+	case err == nil:
+		mgr.breaker.Success()
+	case ctx.Err() != nil:
+		// the caller gave up, that says nothing about redis
+	default:
+		mgr.breaker.Failure()
+	}
+	return err
+}
+
+func (mgr *BreakerCacheMgr) ListTask(ctx context.Context, limit, offset int, order string, filter models.TaskFilter) ([]models.Task, error) {
+	var tasks []models.Task
+	err := mgr.call(ctx, func() (err error) {
+		tasks, err = mgr.cache.ListTask(ctx, limit, offset, order, filter)
+		return err
+	})
+	return tasks, err
+}
+
+func (mgr *BreakerCacheMgr) GetTaskById(ctx context.Context, taskId uint64) (models.Task, error) {
+	var task models.Task
+	err := mgr.call(ctx, func() (err error) {
+		task, err = mgr.cache.GetTaskById(ctx, taskId)
+		return err
+	})
+	return task, err
+}
+
+func (mgr *BreakerCacheMgr) ListSubtree(ctx context.Context, taskId uint64, depth int) ([]models.Task, error) {
+	var tasks []models.Task
+	err := mgr.call(ctx, func() (err error) {
+		tasks, err = mgr.cache.ListSubtree(ctx, taskId, depth)
+		return err
+	})
+	return tasks, err
+}
+
+func (mgr *BreakerCacheMgr) CheckTaskExist(ctx context.Context, condition map[string]interface{}, task *models.Task) error {
+	return mgr.call(ctx, func() error {
+		return mgr.cache.CheckTaskExist(ctx, condition, task)
+	})
+}
+
+func (mgr *BreakerCacheMgr) CreateTask(ctx context.Context, tasks []models.Task) error {
+	return mgr.call(ctx, func() error {
+		return mgr.cache.CreateTask(ctx, tasks)
+	})
+}
+
+func (mgr *BreakerCacheMgr) DeleteTask(ctx context.Context, taskId uint64) error {
+	return mgr.call(ctx, func() error {
+		return mgr.cache.DeleteTask(ctx, taskId)
+	})
+}
+
+func (mgr *BreakerCacheMgr) UpdateTask(ctx context.Context, task *models.Task) error {
+	return mgr.call(ctx, func() error {
+		return mgr.cache.UpdateTask(ctx, task)
+	})
+}
+
+func (mgr *BreakerCacheMgr) Lock(ctx context.Context, lockKey string, expiration time.Duration) (lock.Lease, bool, error) {
+	var lease lock.Lease
+	var locked bool
+	err := mgr.call(ctx, func() (err error) {
+		lease, locked, err = mgr.cache.Lock(ctx, lockKey, expiration)
+		return err
+	})
+	return lease, locked, err
+}
+
+// ReleaseLock always goes to redis, a lock taken before the breaker opened
+// should still be released if redis answers.
+func (mgr *BreakerCacheMgr) ReleaseLock(ctx context.Context, lease lock.Lease) {
+	mgr.cache.ReleaseLock(ctx, lease)
+}
+
+func (mgr *BreakerCacheMgr) Close(ctx context.Context) {
+	mgr.cache.Close(ctx)
+}
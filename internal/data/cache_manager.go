@@ -2,9 +2,12 @@ package data
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"task_service/pkg/lock"
 	"task_service/pkg/logger"
+	"task_service/pkg/metrics"
 	"task_service/pkg/models"
 	"task_service/pkg/utils"
 	"time"
@@ -13,52 +16,38 @@ import (
 )
 
 type CacheMgr struct {
-	client *redis.Client
+	client redis.UniversalClient
+	locker lock.Locker
 }
 
-func newCacheMgr(client *redis.Client) *CacheMgr {
+func newCacheMgr(client redis.UniversalClient) *CacheMgr {
 	return &CacheMgr{
 		client: client,
+		locker: lock.NewRedisLocker(client),
 	}
 }
 
-func (mgr *CacheMgr) ListTask(ctx context.Context, limit, offset int, order string) ([]models.Task, error) {
+func (mgr *CacheMgr) ListTask(ctx context.Context, limit, offset int, order string, filter models.TaskFilter) ([]models.Task, error) {
 	orderSlice := strings.Split(order, " ")
 	desc := false
 	if len(orderSlice) == 2 && orderSlice[1] == "desc" {
 		desc = true
 	}
 
-	keys, err := mgr.client.Keys(ctx, "task:*").Result()
-	if err != nil {
-		return nil, fmt.Errorf("ListTask:%v", err)
-	}
-
-	var tasks []models.Task
-	tx := mgr.client.TxPipeline()
-
-	for _, key := range keys {
-		tx.HGetAll(ctx, key).Result()
-	}
-
-	cmds, err := tx.Exec(ctx)
+	all, err := mgr.getAllTasks(ctx)
 	if err != nil {
+		metrics.ObserveCache("list", metrics.CacheResultError)
 		return nil, fmt.Errorf("ListTask: %v", err)
 	}
 
-	var results []map[string]string
-	for _, cmd := range cmds {
-		result, err := cmd.(*redis.MapStringStringCmd).Result()
-		if err != nil {
-			return nil, fmt.Errorf("ListTask: %v", err)
-		}
-		results = append(results, result)
+	tasks := []models.Task{}
+	if len(all) == 0 {
+		metrics.ObserveCache("list", metrics.CacheResultMiss)
+		return tasks, nil
 	}
-
-	for _, result := range results {
-		task, err := utils.ConvertTask(result)
-		if err != nil {
-			return nil, fmt.Errorf("ListTask: %v", err)
+	for _, task := range all {
+		if !filter.Match(task) {
+			continue
 		}
 		tasks = append(tasks, task)
 	}
@@ -73,75 +62,81 @@ func (mgr *CacheMgr) ListTask(ctx context.Context, limit, offset int, order stri
 	if limit+offset > len(tasks) {
 		limit = len(tasks) - offset
 	}
+	if limit == 0 {
+		metrics.ObserveCache("list", metrics.CacheResultMiss)
+	} else {
+		metrics.ObserveCache("list", metrics.CacheResultHit)
+	}
 	return tasks[offset : offset+limit], nil
 }
 
 func (mgr *CacheMgr) GetTaskById(ctx context.Context, taskId uint64) (models.Task, error) {
-	key := getKey(taskId)
+	key := getKey(TenantFromContext(ctx), taskId)
 
 	result, err := mgr.client.HGetAll(ctx, key).Result()
 	if err != nil {
+		metrics.ObserveCache("get", metrics.CacheResultError)
 		return models.Task{}, fmt.Errorf("GetTaskById: %v", err)
 	}
+	if len(result) == 0 {
+		metrics.ObserveCache("get", metrics.CacheResultMiss)
+	} else {
+		metrics.ObserveCache("get", metrics.CacheResultHit)
+	}
 
 	task, err := utils.ConvertTask(result)
 	return task, nil
 }
 
+// ListSubtree walks the cached tasks of the tenant, the cache has no index
+// of the children of a task.
+func (mgr *CacheMgr) ListSubtree(ctx context.Context, taskId uint64, depth int) ([]models.Task, error) {
+	all, err := mgr.getAllTasks(ctx)
+	if err != nil {
+		metrics.ObserveCache("subtree", metrics.CacheResultError)
+		return nil, fmt.Errorf("ListSubtree: %v", err)
+	}
+	if len(all) == 0 {
+		metrics.ObserveCache("subtree", metrics.CacheResultMiss)
+	} else {
+		metrics.ObserveCache("subtree", metrics.CacheResultHit)
+	}
+	return utils.Subtree(all, taskId, depth), nil
+}
+
 func (mgr *CacheMgr) CheckTaskExist(ctx context.Context, condition map[string]interface{}, task *models.Task) error {
 	return nil
 }
 
 func (mgr *CacheMgr) CreateTask(ctx context.Context, tasks []models.Task) error {
 	tx := mgr.client.TxPipeline()
-	for _, task := range tasks {
-		key := getKey(task.ID)
-
-		tx.HSet(ctx, key, "id", task.ID)
-		tx.HSet(ctx, key, "name", task.Name)
-		tx.HSet(ctx, key, "content", task.Content)
-		tx.HSet(ctx, key, "tag", task.Tag)
-		tx.HSet(ctx, key, "status", task.Status)
-		tx.HSet(ctx, key, "version", task.Version)
-		tx.HSet(ctx, key, "created_at", task.CreatedAt)
-		tx.HSet(ctx, key, "updated_at", task.UpdatedAt)
+	for i := range tasks {
+		setTask(ctx, tx, &tasks[i])
 	}
 
 	if _, err := tx.Exec(ctx); err != nil {
-		return fmt.Errorf("UpdateTask: %v", err)
+		return fmt.Errorf("CreateTask: %v", err)
 	}
 
 	return nil
 }
 
 func (mgr *CacheMgr) DeleteTask(ctx context.Context, taskId uint64) error {
-	key := getKey(taskId)
+	tenantId := TenantFromContext(ctx)
+	tx := mgr.client.TxPipeline()
+	tx.Del(ctx, getKey(tenantId, taskId))
+	tx.SRem(ctx, getIndexKey(tenantId), taskId)
 
-	keys, err := mgr.client.HKeys(ctx, key).Result()
-	if err != nil {
+	if _, err := tx.Exec(ctx); err != nil {
 		return fmt.Errorf("DeleteTask:%v", err)
 	}
-	for _, key := range keys {
-		if err := mgr.client.HDel(ctx, key).Err(); err != nil {
-			return fmt.Errorf("DeleteTask:%v", err)
-		}
-	}
 
 	return nil
 }
 
 func (mgr *CacheMgr) UpdateTask(ctx context.Context, task *models.Task) error {
-	key := getKey(task.ID)
-
 	tx := mgr.client.TxPipeline()
-	tx.HSet(ctx, key, "id", task.ID)
-	tx.HSet(ctx, key, "name", task.Name)
-	tx.HSet(ctx, key, "content", task.Content)
-	tx.HSet(ctx, key, "tag", task.Tag)
-	tx.HSet(ctx, key, "status", task.Status)
-	tx.HSet(ctx, key, "version", task.Version)
-	tx.HSet(ctx, key, "created_at", task.CreatedAt)
-	tx.HSet(ctx, key, "updated_at", task.UpdatedAt)
+	setTask(ctx, tx, task)
 
 	if _, err := tx.Exec(ctx); err != nil {
 		return fmt.Errorf("UpdateTask: %v", err)
@@ -150,17 +145,24 @@ func (mgr *CacheMgr) UpdateTask(ctx context.Context, task *models.Task) error {
 	return nil
 }
 
-func (mgr *CacheMgr) Lock(ctx context.Context, lockKey string, expiration time.Duration) (bool, error) {
-	success, err := mgr.client.SetNX(ctx, lockKey, "locked", expiration).Result()
+func (mgr *CacheMgr) Lock(ctx context.Context, lockKey string, expiration time.Duration) (lock.Lease, bool, error) {
+	start := time.Now()
+	lease, success, err := mgr.locker.Acquire(ctx, lockKey, expiration)
 	if err != nil {
-		return false, fmt.Errorf("LockTask: %v", err)
+		metrics.ObserveLock(lockKey, metrics.LockResultError, time.Since(start))
+		return lock.Lease{}, false, fmt.Errorf("LockTask: %v", err)
+	}
+	if !success {
+		metrics.ObserveLock(lockKey, metrics.LockResultHeld, time.Since(start))
+		return lock.Lease{}, false, nil
 	}
-	return success, nil
+	metrics.ObserveLock(lockKey, metrics.LockResultAcquired, time.Since(start))
+	return lease, true, nil
 }
 
-func (mgr *CacheMgr) ReleaseLock(ctx context.Context, lockKey string) {
-	if _, err := mgr.client.Del(ctx, lockKey).Result(); err != nil {
-		logger.GetLoggerWithKeys(map[string]interface{}{
+func (mgr *CacheMgr) ReleaseLock(ctx context.Context, lease lock.Lease) {
+	if err := mgr.locker.Release(ctx, lease); err != nil {
+		logger.GetLoggerWithContext(ctx, map[string]interface{}{
 			"error": err,
 		}).Error("ReleaseLock Fail")
 	}
@@ -172,6 +174,143 @@ func (mgr *CacheMgr) Close(ctx context.Context) {
 	}
 }
 
-func getKey(taskId uint64) string {
-	return fmt.Sprintf("task:%d", taskId)
+// Flush removes every cached task of every tenant. It is used when the cache
+// may have missed writes, e.g. after an outage.
+func (mgr *CacheMgr) Flush(ctx context.Context) error {
+	if cluster, ok := mgr.client.(*redis.ClusterClient); ok {
+		if err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+			return mgr.flushNode(ctx, node)
+		}); err != nil {
+			return fmt.Errorf("Flush: %v", err)
+		}
+		return nil
+	}
+
+	if err := mgr.flushNode(ctx, mgr.client); err != nil {
+		return fmt.Errorf("Flush: %v", err)
+	}
+	return nil
+}
+
+// flushNode deletes the tasks of the tenant indexes stored on node.
+func (mgr *CacheMgr) flushNode(ctx context.Context, node redis.Cmdable) error {
+	iter := node.Scan(ctx, 0, getIndexKey("*"), 100).Iterator()
+	for iter.Next(ctx) {
+		indexKey := iter.Val()
+		ids, err := mgr.client.SMembers(ctx, indexKey).Result()
+		if err != nil {
+			return err
+		}
+
+		// the keys of a tenant share the hash tag of its index
+		prefix := strings.TrimSuffix(indexKey, "ids")
+		keys := make([]string, 0, len(ids)+1)
+		for _, id := range ids {
+			keys = append(keys, prefix+id)
+		}
+		keys = append(keys, indexKey)
+		if err := mgr.client.Del(ctx, keys...).Err(); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
+
+// getAllTasks returns every cached task of the tenant of ctx.
+func (mgr *CacheMgr) getAllTasks(ctx context.Context) ([]models.Task, error) {
+	tenantId := TenantFromContext(ctx)
+	ids, err := mgr.client.SMembers(ctx, getIndexKey(tenantId)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	tx := mgr.client.TxPipeline()
+	for _, id := range ids {
+		tx.HGetAll(ctx, fmt.Sprintf("task:{%s}:%s", tenantId, id))
+	}
+	cmds, err := tx.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]models.Task, 0, len(cmds))
+	for _, cmd := range cmds {
+		result, err := cmd.(*redis.MapStringStringCmd).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(result) == 0 {
+			continue
+		}
+		task, err := utils.ConvertTask(result)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// setTask queues the writes storing task as a hash and indexing its id.
+func setTask(ctx context.Context, tx redis.Pipeliner, task *models.Task) {
+	tenantId := TenantFromContext(ctx)
+	key := getKey(tenantId, task.ID)
+
+	tx.HSet(ctx, key, "id", task.ID)
+	tx.HSet(ctx, key, "tenant_id", tenantId)
+	tx.HSet(ctx, key, "name", task.Name)
+	tx.HSet(ctx, key, "content", task.Content)
+	tx.HSet(ctx, key, "priority", task.Priority)
+	tx.HSet(ctx, key, "rank", task.Rank)
+	// marshalling a string slice can not fail
+	tags, _ := json.Marshal(task.Tags)
+	tx.HSet(ctx, key, "tags", tags)
+	// nor can marshalling the checked custom field values
+	fields, _ := json.Marshal(task.Fields)
+	tx.HSet(ctx, key, "fields", fields)
+	tx.HSet(ctx, key, "status", task.Status)
+	tx.HSet(ctx, key, "owner_id", task.OwnerID)
+	tx.HSet(ctx, key, "assignee_id", task.AssigneeID)
+	tx.HSet(ctx, key, "version", task.Version)
+	tx.HSet(ctx, key, "created_at", task.CreatedAt)
+	tx.HSet(ctx, key, "updated_at", task.UpdatedAt)
+	tx.HSet(ctx, key, "due_at", formatOptionalTime(task.DueAt))
+	tx.HSet(ctx, key, "remind_at", formatOptionalTime(task.RemindAt))
+	tx.HSet(ctx, key, "occurrence_at", formatOptionalTime(task.OccurrenceAt))
+	if task.SeriesID != nil {
+		tx.HSet(ctx, key, "series_id", *task.SeriesID)
+	} else {
+		tx.HDel(ctx, key, "series_id")
+	}
+	if task.ParentID != nil {
+		tx.HSet(ctx, key, "parent_id", *task.ParentID)
+	} else {
+		tx.HDel(ctx, key, "parent_id")
+	}
+	tx.HSet(ctx, key, "progress", task.Progress)
+	tx.HSet(ctx, key, "comment_count", task.CommentCount)
+	tx.SAdd(ctx, getIndexKey(tenantId), task.ID)
+}
+
+// formatOptionalTime stores a missing time as an empty string.
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// getKey returns the key of a cached task. The tenant is the hash tag, so all
+// keys of a tenant share one slot and the multi-key transactions above stay
+// valid on Redis Cluster.
+func getKey(tenantId string, taskId uint64) string {
+	return fmt.Sprintf("task:{%s}:%d", tenantId, taskId)
+}
+
+// getIndexKey returns the set holding the ids of the cached tasks of a tenant.
+func getIndexKey(tenantId string) string {
+	return fmt.Sprintf("task:{%s}:ids", tenantId)
 }
@@ -0,0 +1,78 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"task_service/pkg/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// CalendarFeedManager stores the calendar feeds, their tokens are hashed
+// like API keys.
+type CalendarFeedManager interface {
+	CreateCalendarFeed(ctx context.Context, feed *models.CalendarFeed) error
+	GetCalendarFeedByHash(ctx context.Context, hash string) (models.CalendarFeed, error)
+	ListCalendarFeeds(ctx context.Context, ownerId string) ([]models.CalendarFeed, error)
+	RevokeCalendarFeed(ctx context.Context, ownerId string, feedId uint64) error
+	// UpdateCalendarFeedETag records the ETag of the content last served
+	// and when it changed.
+	UpdateCalendarFeedETag(ctx context.Context, feedId uint64, etag string, modifiedAt time.Time) error
+}
+
+func NewCalendarFeedManager(client *gorm.DB) CalendarFeedManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) CreateCalendarFeed(ctx context.Context, feed *models.CalendarFeed) error {
+	feed.TenantID = TenantFromContext(ctx)
+	if err := mgr.client.WithContext(ctx).Create(feed).Error; err != nil {
+		return fmt.Errorf("CreateCalendarFeed: %s", err.Error())
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) GetCalendarFeedByHash(ctx context.Context, hash string) (models.CalendarFeed, error) {
+	feed := models.CalendarFeed{}
+	// Read from the primary so that a revoked feed stops working at once.
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).
+		Where("token_hash = ?", hash).First(&feed).Error; err != nil {
+		return models.CalendarFeed{}, fmt.Errorf("GetCalendarFeedByHash: %w", err)
+	}
+	return feed, nil
+}
+
+func (mgr *MysqlMgr) ListCalendarFeeds(ctx context.Context, ownerId string) ([]models.CalendarFeed, error) {
+	var feeds []models.CalendarFeed
+	if err := mgr.reader(ctx).Scopes(tenantScope(ctx)).
+		Where("owner_id = ?", ownerId).Order("id").Find(&feeds).Error; err != nil {
+		return nil, fmt.Errorf("ListCalendarFeeds: %s", err.Error())
+	}
+	return feeds, nil
+}
+
+func (mgr *MysqlMgr) RevokeCalendarFeed(ctx context.Context, ownerId string, feedId uint64) error {
+	result := mgr.client.WithContext(ctx).Model(&models.CalendarFeed{}).Scopes(tenantScope(ctx)).
+		Where("id = ? AND owner_id = ? AND revoked_at IS NULL", feedId, ownerId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("RevokeCalendarFeed: %s", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("RevokeCalendarFeed: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) UpdateCalendarFeedETag(ctx context.Context, feedId uint64, etag string, modifiedAt time.Time) error {
+	if err := mgr.client.WithContext(ctx).Model(&models.CalendarFeed{}).Scopes(tenantScope(ctx)).
+		Where("id = ?", feedId).
+		Updates(map[string]interface{}{"etag": etag, "modified_at": modifiedAt}).Error; err != nil {
+		return fmt.Errorf("UpdateCalendarFeedETag: %s", err.Error())
+	}
+	return nil
+}
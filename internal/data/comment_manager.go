@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"task_service/pkg/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// CommentManager stores the discussion threads of tasks. The comment_count
+// of a task is kept in step with its comments.
+type CommentManager interface {
+	CreateComment(ctx context.Context, comment *models.Comment) error
+	// ListComments returns the comments of a task, oldest first.
+	ListComments(ctx context.Context, taskId uint64, limit, offset int) ([]models.Comment, error)
+	GetComment(ctx context.Context, taskId, commentId uint64) (models.Comment, error)
+	// UpdateComment saves the body and mentions of comment, keeping the
+	// previous body as a revision edited by editorId.
+	UpdateComment(ctx context.Context, comment *models.Comment, editorId string) error
+	DeleteComment(ctx context.Context, taskId, commentId uint64) error
+	// ListCommentRevisions returns the previous bodies of a comment, oldest
+	// first.
+	ListCommentRevisions(ctx context.Context, commentId uint64) ([]models.CommentRevision, error)
+	// ResolvePrincipals returns the ids among ids the tenant knows, as the
+	// owner of an API key, the owner or assignee of a task or the author of
+	// a comment.
+	ResolvePrincipals(ctx context.Context, ids []string) ([]string, error)
+}
+
+func NewCommentManager(client *gorm.DB) CommentManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) CreateComment(ctx context.Context, comment *models.Comment) error {
+	comment.TenantID = TenantFromContext(ctx)
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		return countComments(tx, comment.TenantID, comment.TaskID, 1)
+	})
+	if err != nil {
+		return fmt.Errorf("CreateComment: %s", err.Error())
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) ListComments(ctx context.Context, taskId uint64, limit, offset int) ([]models.Comment, error) {
+	comments := []models.Comment{}
+	if err := mgr.reader(ctx).Scopes(tenantScope(ctx)).
+		Where("task_id = ?", taskId).Order("id").
+		Offset(offset).Limit(limit).
+		Find(&comments).Error; err != nil {
+		return nil, fmt.Errorf("ListComments: %s", err.Error())
+	}
+	return comments, nil
+}
+
+func (mgr *MysqlMgr) GetComment(ctx context.Context, taskId, commentId uint64) (models.Comment, error) {
+	comment := models.Comment{}
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Scopes(tenantScope(ctx)).
+		First(&comment, "task_id = ? AND id = ?", taskId, commentId).Error; err != nil {
+		return models.Comment{}, fmt.Errorf("GetComment: %w", err)
+	}
+	return comment, nil
+}
+
+func (mgr *MysqlMgr) UpdateComment(ctx context.Context, comment *models.Comment, editorId string) error {
+	tenantId := TenantFromContext(ctx)
+	now := time.Now()
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		old := models.Comment{}
+		if err := tx.Where("tenant_id = ? AND task_id = ? AND id = ?", tenantId, comment.TaskID, comment.ID).
+			First(&old).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.CommentRevision{
+			CommentID: old.ID,
+			Body:      old.Body,
+			EditedBy:  editorId,
+			EditedAt:  now,
+		}).Error; err != nil {
+			return err
+		}
+
+		comment.TenantID = tenantId
+		comment.EditedAt = &now
+		return tx.Model(comment).Select("body", "mentions", "edited_at", "updated_at").Updates(comment).Error
+	})
+	if err != nil {
+		return fmt.Errorf("UpdateComment: %w", err)
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) DeleteComment(ctx context.Context, taskId, commentId uint64) error {
+	tenantId := TenantFromContext(ctx)
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Comment{}, "tenant_id = ? AND task_id = ? AND id = ?", tenantId, taskId, commentId)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Delete(&models.CommentRevision{}, "comment_id = ?", commentId).Error; err != nil {
+			return err
+		}
+		return countComments(tx, tenantId, taskId, -1)
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteComment: %w", err)
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) ListCommentRevisions(ctx context.Context, commentId uint64) ([]models.CommentRevision, error) {
+	revisions := []models.CommentRevision{}
+	if err := mgr.reader(ctx).Where("comment_id = ?", commentId).Order("id").
+		Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("ListCommentRevisions: %s", err.Error())
+	}
+	return revisions, nil
+}
+
+func (mgr *MysqlMgr) ResolvePrincipals(ctx context.Context, ids []string) ([]string, error) {
+	known := []string{}
+	if len(ids) == 0 {
+		return known, nil
+	}
+	tenantId := TenantFromContext(ctx)
+	if err := mgr.reader(ctx).Raw(`
+SELECT owner_id FROM ApiKey WHERE tenant_id = ? AND owner_id IN ?
+UNION SELECT owner_id FROM Task WHERE tenant_id = ? AND owner_id IN ?
+UNION SELECT assignee_id FROM Task WHERE tenant_id = ? AND assignee_id IN ?
+UNION SELECT author_id FROM Comment WHERE tenant_id = ? AND author_id IN ?`,
+		tenantId, ids, tenantId, ids, tenantId, ids, tenantId, ids).
+		Scan(&known).Error; err != nil {
+		return nil, fmt.Errorf("ResolvePrincipals: %s", err.Error())
+	}
+	slices.Sort(known)
+	return known, nil
+}
+
+// countComments adds delta to the comment_count of a task.
+func countComments(tx *gorm.DB, tenantId string, taskId uint64, delta int) error {
+	return tx.Model(&models.Task{}).Where("tenant_id = ? AND id = ?", tenantId, taskId).
+		UpdateColumn("comment_count", gorm.Expr("comment_count + ?", delta)).Error
+}
+
+// deleteTaskComments removes the comments of tasks with their revisions.
+func deleteTaskComments(tx *gorm.DB, tenantId string, taskIds ...uint64) error {
+	if err := tx.Exec(`DELETE CommentRevision FROM CommentRevision
+JOIN Comment ON Comment.id = CommentRevision.comment_id
+WHERE Comment.tenant_id = ? AND Comment.task_id IN ?`, tenantId, taskIds).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&models.Comment{}, "tenant_id = ? AND task_id IN ?", tenantId, taskIds).Error
+}
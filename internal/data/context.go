@@ -0,0 +1,47 @@
+package data
+
+import (
+	"context"
+	"task_service/c"
+)
+
+type primaryCtxKey struct{}
+
+type tenantCtxKey struct{}
+
+type writeThroughCtxKey struct{}
+
+// WithPrimary returns a context whose MySQL reads skip the replicas.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryCtxKey{}, true)
+}
+
+func usePrimary(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryCtxKey{}).(bool)
+	return pinned
+}
+
+// WithWriteThrough returns a context whose task updates reach MySQL before
+// UpdateTask returns, also when write-behind is enabled. Updates followed by
+// work reading MySQL, such as the progress roll-up, need it.
+func WithWriteThrough(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writeThroughCtxKey{}, true)
+}
+
+func writeThrough(ctx context.Context) bool {
+	through, _ := ctx.Value(writeThroughCtxKey{}).(bool)
+	return through
+}
+
+// WithTenant returns a context whose task queries are scoped to tenantId.
+func WithTenant(ctx context.Context, tenantId string) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantId)
+}
+
+// TenantFromContext returns the tenant set by WithTenant, or the default tenant.
+func TenantFromContext(ctx context.Context) string {
+	if tenantId, ok := ctx.Value(tenantCtxKey{}).(string); ok && tenantId != "" {
+		return tenantId
+	}
+	return c.DefaultTenant
+}
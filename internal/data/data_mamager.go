@@ -2,6 +2,7 @@ package data
 
 import (
 	"context"
+	"task_service/pkg/lock"
 	"task_service/pkg/models"
 	"time"
 
@@ -10,15 +11,20 @@ import (
 )
 
 type DataManager interface {
-	ListTask(ctx context.Context, limit, offset int, order string) ([]models.Task, error)
+	ListTask(ctx context.Context, limit, offset int, order string, filter models.TaskFilter) ([]models.Task, error)
 	GetTaskById(ctx context.Context, taskId uint64) (models.Task, error)
+	// ListSubtree returns the descendants of a task down to depth levels,
+	// depth 1 being its children, level by level and by id within a level.
+	ListSubtree(ctx context.Context, taskId uint64, depth int) ([]models.Task, error)
 	CheckTaskExist(ctx context.Context, condition map[string]interface{}, task *models.Task) error
 	CreateTask(ctx context.Context, task []models.Task) error
 	DeleteTask(ctx context.Context, taskId uint64) error
 	UpdateTask(ctx context.Context, task *models.Task) error
 
-	Lock(ctx context.Context, lockKey string, expiration time.Duration) (bool, error)
-	ReleaseLock(ctx context.Context, lockKey string)
+	// Lock tries once to take lockKey. ReleaseLock gives up the returned
+	// lease, so a holder whose lease expired cannot release the next one.
+	Lock(ctx context.Context, lockKey string, expiration time.Duration) (lock.Lease, bool, error)
+	ReleaseLock(ctx context.Context, lease lock.Lease)
 	Close(context.Context)
 }
 
@@ -26,8 +32,8 @@ func NewDataManager(client interface{}) DataManager {
 	switch client.(type) {
 	case *gorm.DB:
 		return newMysqlManager(client.(*gorm.DB))
-	case *redis.Client:
-		return newCacheMgr(client.(*redis.Client))
+	case redis.UniversalClient:
+		return newCacheMgr(client.(redis.UniversalClient))
 	}
 	return nil
 }
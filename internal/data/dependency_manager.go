@@ -0,0 +1,122 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task_service/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ErrDependencyExists is returned by AddDependency when the task already
+// depends on the blocker.
+var ErrDependencyExists = errors.New("dependency already exists")
+
+// DependencyManager stores the dependencies between tasks. Reads go to the
+// primary, they decide whether a write is allowed.
+type DependencyManager interface {
+	AddDependency(ctx context.Context, dependency *models.TaskDependency) error
+	RemoveDependency(ctx context.Context, taskId, blockerId uint64) error
+	// ListBlockers returns the tasks taskId directly depends on.
+	ListBlockers(ctx context.Context, taskId uint64) ([]models.Task, error)
+	// ListUpstream returns the dependencies reachable from taskId by
+	// following tasks to their blockers, ListDownstream by following
+	// blockers to their tasks.
+	ListUpstream(ctx context.Context, taskId uint64) ([]models.TaskDependency, error)
+	ListDownstream(ctx context.Context, taskId uint64) ([]models.TaskDependency, error)
+	ListTasksByIds(ctx context.Context, ids []uint64) ([]models.Task, error)
+}
+
+func NewDependencyManager(client *gorm.DB) DependencyManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) AddDependency(ctx context.Context, dependency *models.TaskDependency) error {
+	dependency.TenantID = TenantFromContext(ctx)
+	if err := mgr.client.WithContext(ctx).Create(dependency).Error; err != nil {
+		if isDuplicateEntry(err) {
+			return ErrDependencyExists
+		}
+		return fmt.Errorf("AddDependency: %s", err.Error())
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) RemoveDependency(ctx context.Context, taskId, blockerId uint64) error {
+	result := mgr.client.WithContext(ctx).Scopes(tenantScope(ctx)).
+		Delete(&models.TaskDependency{}, "task_id = ? AND blocker_id = ?", taskId, blockerId)
+	if result.Error != nil {
+		return fmt.Errorf("RemoveDependency: %s", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("RemoveDependency: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) ListBlockers(ctx context.Context, taskId uint64) ([]models.Task, error) {
+	var tasks []models.Task
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Scopes(tenantScope(ctx)).
+		Where("id IN (?)", mgr.client.Model(&models.TaskDependency{}).Select("blocker_id").
+			Where("tenant_id = ? AND task_id = ?", TenantFromContext(ctx), taskId)).
+		Order("id").
+		Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("ListBlockers: %s", err.Error())
+	}
+	return tasks, nil
+}
+
+func (mgr *MysqlMgr) ListUpstream(ctx context.Context, taskId uint64) ([]models.TaskDependency, error) {
+	dependencies, err := mgr.walkDependencies(ctx, taskId, "task_id", "blocker_id")
+	if err != nil {
+		return nil, fmt.Errorf("ListUpstream: %s", err.Error())
+	}
+	return dependencies, nil
+}
+
+func (mgr *MysqlMgr) ListDownstream(ctx context.Context, taskId uint64) ([]models.TaskDependency, error) {
+	dependencies, err := mgr.walkDependencies(ctx, taskId, "blocker_id", "task_id")
+	if err != nil {
+		return nil, fmt.Errorf("ListDownstream: %s", err.Error())
+	}
+	return dependencies, nil
+}
+
+// walkDependencies returns the dependencies reachable from taskId, entering
+// each dependency through column from and leaving through column to. UNION
+// drops the rows already seen, so the walk ends even on a cycle.
+func (mgr *MysqlMgr) walkDependencies(ctx context.Context, taskId uint64, from, to string) ([]models.TaskDependency, error) {
+	tenantId := TenantFromContext(ctx)
+	dependencies := []models.TaskDependency{}
+	err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Raw(fmt.Sprintf(`
+WITH RECURSIVE walk (id, next_id) AS (
+	SELECT id, %[2]s FROM TaskDependency WHERE tenant_id = ? AND %[1]s = ?
+	UNION
+	SELECT TaskDependency.id, TaskDependency.%[2]s FROM TaskDependency JOIN walk ON TaskDependency.%[1]s = walk.next_id
+	WHERE TaskDependency.tenant_id = ?
+)
+SELECT TaskDependency.* FROM TaskDependency JOIN walk ON TaskDependency.id = walk.id ORDER BY TaskDependency.id`, from, to),
+		tenantId, taskId, tenantId).
+		Scan(&dependencies).Error
+	return dependencies, err
+}
+
+func (mgr *MysqlMgr) ListTasksByIds(ctx context.Context, ids []uint64) ([]models.Task, error) {
+	tasks := []models.Task{}
+	if len(ids) == 0 {
+		return tasks, nil
+	}
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Scopes(tenantScope(ctx)).
+		Where("id IN ?", ids).Order("id").
+		Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("ListTasksByIds: %s", err.Error())
+	}
+	if err := loadTags(mgr.client.WithContext(ctx).Clauses(dbresolver.Write), tasks); err != nil {
+		return nil, fmt.Errorf("ListTasksByIds: %s", err.Error())
+	}
+	return tasks, nil
+}
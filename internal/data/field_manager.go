@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"task_service/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ErrFieldExists is returned when a custom field is created with the key of
+// another field of the tenant.
+var ErrFieldExists = errors.New("field already exists")
+
+// FieldManager maintains the custom field definitions of a tenant, see
+// models.CustomField. The values live in the fields column of the tasks.
+type FieldManager interface {
+	CreateField(ctx context.Context, field *models.CustomField) error
+	ListFields(ctx context.Context) ([]models.CustomField, error)
+	GetField(ctx context.Context, fieldId uint64) (models.CustomField, error)
+	// UpdateField saves the name, options and required flag of field.
+	UpdateField(ctx context.Context, field *models.CustomField) error
+	// DeleteField removes the field and its values from every task and
+	// recurring task template.
+	DeleteField(ctx context.Context, fieldId uint64) error
+}
+
+func NewFieldManager(client *gorm.DB) FieldManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) CreateField(ctx context.Context, field *models.CustomField) error {
+	field.TenantID = TenantFromContext(ctx)
+	if err := mgr.client.WithContext(ctx).Create(field).Error; err != nil {
+		if isDuplicateEntry(err) {
+			return ErrFieldExists
+		}
+		return fmt.Errorf("CreateField: %s", err.Error())
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) ListFields(ctx context.Context) ([]models.CustomField, error) {
+	fields := []models.CustomField{}
+	if err := mgr.reader(ctx).Scopes(tenantScope(ctx)).Order("id").Find(&fields).Error; err != nil {
+		return nil, fmt.Errorf("ListFields: %s", err.Error())
+	}
+	return fields, nil
+}
+
+func (mgr *MysqlMgr) GetField(ctx context.Context, fieldId uint64) (models.CustomField, error) {
+	field := models.CustomField{}
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Scopes(tenantScope(ctx)).
+		First(&field, "id = ?", fieldId).Error; err != nil {
+		return models.CustomField{}, fmt.Errorf("GetField: %w", err)
+	}
+	return field, nil
+}
+
+func (mgr *MysqlMgr) UpdateField(ctx context.Context, field *models.CustomField) error {
+	field.TenantID = TenantFromContext(ctx)
+	result := mgr.client.WithContext(ctx).Model(field).Scopes(tenantScope(ctx)).
+		Select("name", "options", "required").Updates(field)
+	if result.Error != nil {
+		return fmt.Errorf("UpdateField: %s", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("UpdateField: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) DeleteField(ctx context.Context, fieldId uint64) error {
+	tenantId := TenantFromContext(ctx)
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		field := models.CustomField{}
+		if err := tx.Where("tenant_id = ? AND id = ?", tenantId, fieldId).First(&field).Error; err != nil {
+			return err
+		}
+		path := fieldPath(field.Key)
+		for _, table := range []string{"Task", "TaskSeries"} {
+			if err := tx.Exec("UPDATE "+table+" SET `fields` = JSON_REMOVE(`fields`, ?) "+
+				"WHERE tenant_id = ? AND JSON_CONTAINS_PATH(`fields`, 'one', ?)",
+				path, tenantId, path).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&field).Error
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteField: %w", err)
+	}
+	return nil
+}
+
+// fieldPath returns the JSON path of a custom field in the fields column.
+func fieldPath(key string) string {
+	return `$."` + key + `"`
+}
+
+// fieldOrder translates the order field.<key> [desc] of ListTask, tasks
+// without the field sort last the way the cache does. It returns false for
+// any other order.
+func fieldOrder(order string) (string, bool) {
+	field, direction, _ := strings.Cut(order, " ")
+	key, ok := strings.CutPrefix(field, "field.")
+	if !ok || !models.ValidFieldKey(key) {
+		return "", false
+	}
+	if direction != "desc" {
+		direction = "asc"
+	}
+	value := "JSON_EXTRACT(`fields`, '" + fieldPath(key) + "')"
+	return value + " IS NULL, " + value + " " + direction, true
+}
@@ -0,0 +1,86 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"task_service/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// HierarchyManager reads and maintains the parent links of tasks. It always
+// reads the primary, the links are checked before they are written.
+type HierarchyManager interface {
+	ListChildren(ctx context.Context, parentId uint64) ([]models.Task, error)
+	// ListAncestors returns the ancestors of a task, its parent first.
+	ListAncestors(ctx context.Context, taskId uint64) ([]models.Task, error)
+	// ReparentChildren moves the children of a task to parentId.
+	ReparentChildren(ctx context.Context, taskId uint64, parentId *uint64) error
+	// DeleteSubtree deletes the descendants taskIds of a task in one
+	// transaction, with what DeleteTask drops along with a task.
+	DeleteSubtree(ctx context.Context, taskIds []uint64) error
+}
+
+func NewHierarchyManager(client *gorm.DB) HierarchyManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) ListChildren(ctx context.Context, parentId uint64) ([]models.Task, error) {
+	var tasks []models.Task
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Scopes(tenantScope(ctx)).
+		Where("parent_id = ?", parentId).Order("id").
+		Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("ListChildren: %s", err.Error())
+	}
+	if err := loadTags(mgr.client.WithContext(ctx).Clauses(dbresolver.Write), tasks); err != nil {
+		return nil, fmt.Errorf("ListChildren: %s", err.Error())
+	}
+	return tasks, nil
+}
+
+func (mgr *MysqlMgr) ListAncestors(ctx context.Context, taskId uint64) ([]models.Task, error) {
+	tenantId := TenantFromContext(ctx)
+	tasks := []models.Task{}
+	// the depth bound stops the walk should the links ever form a cycle
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Raw(`
+WITH RECURSIVE ancestors (id, depth) AS (
+	SELECT parent_id, 1 FROM Task WHERE tenant_id = ? AND id = ? AND parent_id IS NOT NULL
+	UNION ALL
+	SELECT Task.parent_id, ancestors.depth + 1 FROM Task JOIN ancestors ON Task.id = ancestors.id
+	WHERE Task.tenant_id = ? AND Task.parent_id IS NOT NULL AND ancestors.depth < 1000
+)
+SELECT Task.* FROM Task JOIN ancestors ON Task.id = ancestors.id ORDER BY ancestors.depth`,
+		tenantId, taskId, tenantId).
+		Scan(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("ListAncestors: %s", err.Error())
+	}
+	return tasks, nil
+}
+
+func (mgr *MysqlMgr) ReparentChildren(ctx context.Context, taskId uint64, parentId *uint64) error {
+	if err := mgr.client.WithContext(ctx).Model(&models.Task{}).Scopes(tenantScope(ctx)).
+		Where("parent_id = ?", taskId).
+		UpdateColumns(map[string]interface{}{
+			"parent_id": parentId,
+			"version":   gorm.Expr("version + 1"),
+		}).Error; err != nil {
+		return fmt.Errorf("ReparentChildren: %s", err.Error())
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) DeleteSubtree(ctx context.Context, taskIds []uint64) error {
+	if len(taskIds) == 0 {
+		return nil
+	}
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteTasks(tx, TenantFromContext(ctx), taskIds...)
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteSubtree: %s", err.Error())
+	}
+	return nil
+}
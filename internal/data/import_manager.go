@@ -0,0 +1,53 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"task_service/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ImportManager stores the progress of the task imports of a tenant, so it
+// can be read from any instance while the import runs on one of them.
+type ImportManager interface {
+	CreateImportJob(ctx context.Context, job *models.ImportJob) error
+	GetImportJob(ctx context.Context, jobId uint64) (models.ImportJob, error)
+	UpdateImportJob(ctx context.Context, job *models.ImportJob) error
+}
+
+func NewImportManager(client *gorm.DB) ImportManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) CreateImportJob(ctx context.Context, job *models.ImportJob) error {
+	job.TenantID = TenantFromContext(ctx)
+	if err := mgr.client.WithContext(ctx).Create(job).Error; err != nil {
+		return fmt.Errorf("CreateImportJob: %s", err.Error())
+	}
+	return nil
+}
+
+// GetImportJob reads from the primary, the progress on a replica lags behind.
+func (mgr *MysqlMgr) GetImportJob(ctx context.Context, jobId uint64) (models.ImportJob, error) {
+	job := models.ImportJob{}
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Scopes(tenantScope(ctx)).
+		First(&job, "id = ?", jobId).Error; err != nil {
+		return models.ImportJob{}, fmt.Errorf("GetImportJob: %w", err)
+	}
+	return job, nil
+}
+
+func (mgr *MysqlMgr) UpdateImportJob(ctx context.Context, job *models.ImportJob) error {
+	// RowsAffected is not checked, an update within the same second may not
+	// change the row
+	if err := mgr.client.WithContext(ctx).Model(job).Scopes(tenantScope(ctx)).
+		Select("*").Omit("id", "tenant_id", "owner_id", "format", "dry_run", "created_at").
+		Updates(job).Error; err != nil {
+		return fmt.Errorf("UpdateImportJob: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// TryLock takes lockKey where every instance takes it given the state of the
+// cache circuit breaker, see BreakerCacheMgr.lockBackends: from cacheMgr
+// while the breaker is closed, from mysqlMgr while it is open, and from both
+// for a while after the cache recovered. It never falls back from one to the
+// other because a call failed. ok is false when the lock is held elsewhere.
+// release gives up the leases taken here only.
+func TryLock(ctx context.Context, lockKey string, expiration time.Duration, cacheMgr, mysqlMgr DataManager) (release func(), ok bool, err error) {
+	var mgrs []DataManager
+	useCache, useMysql := lockBackends(cacheMgr, expiration)
+	if useCache {
+		mgrs = append(mgrs, cacheMgr)
+	}
+	if useMysql {
+		mgrs = append(mgrs, mysqlMgr)
+	}
+
+	var releases []func()
+	release = func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	for _, mgr := range mgrs {
+		mgr := mgr
+		lease, locked, err := mgr.Lock(ctx, lockKey, expiration)
+		if err != nil || !locked {
+			release()
+			return nil, false, err
+		}
+		releases = append(releases, func() { mgr.ReleaseLock(context.Background(), lease) })
+	}
+	return release, true, nil
+}
+
+// lockBackends reports whether the locks are taken from the cache cacheMgr,
+// from MySQL, or both. Only a cache behind a circuit breaker ever sends them
+// to MySQL.
+func lockBackends(cacheMgr DataManager, expiration time.Duration) (cache, mysql bool) {
+	switch mgr := cacheMgr.(type) {
+	case *BreakerCacheMgr:
+		return mgr.lockBackends(expiration)
+	case *tracedDataManager:
+		return lockBackends(mgr.DataManager, expiration)
+	}
+	return true, false
+}
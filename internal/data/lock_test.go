@@ -0,0 +1,170 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"task_service/config"
+	"task_service/pkg/lock"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseExpiredLease(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	mgr := newCacheMgr(client)
+	ctx := context.Background()
+
+	stale, ok, err := mgr.Lock(ctx, "task", time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+	server.FastForward(2 * time.Second)
+
+	current, ok, err := mgr.Lock(ctx, "task", time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Greater(t, current.Token, stale.Token)
+
+	// the holder whose lease expired leaves the current lease alone
+	mgr.ReleaseLock(ctx, stale)
+	_, ok, err = mgr.Lock(ctx, "task", time.Second)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	mgr.ReleaseLock(ctx, current)
+	_, ok, err = mgr.Lock(ctx, "task", time.Second)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestTryLockReleasesItsLease(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	mgr := newCacheMgr(client)
+	ctx := context.Background()
+
+	release, ok, err := TryLock(ctx, "task", time.Second, mgr, nil)
+	require.NoError(t, err)
+	require.True(t, ok)
+	server.FastForward(2 * time.Second)
+
+	_, ok, err = mgr.Lock(ctx, "task", time.Second)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	release()
+	_, ok, err = TryLock(ctx, "task", time.Second, mgr, nil)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// fakeLockStore holds locks in memory in place of the MySQL lease table.
+type fakeLockStore struct {
+	DataManager
+
+	mu    sync.Mutex
+	locks map[string]bool
+}
+
+func (store *fakeLockStore) Lock(ctx context.Context, lockKey string, expiration time.Duration) (lock.Lease, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.locks[lockKey] {
+		return lock.Lease{}, false, nil
+	}
+	store.locks[lockKey] = true
+	return lock.Lease{Key: lockKey}, true, nil
+}
+
+func (store *fakeLockStore) ReleaseLock(ctx context.Context, lease lock.Lease) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.locks, lease.Key)
+}
+
+func (store *fakeLockStore) held(lockKey string) bool {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.locks[lockKey]
+}
+
+func TestTryLockFollowsBreaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(mgr *BreakerCacheMgr, server *miniredis.Miniredis)
+		wantErr   bool
+		wantCache bool
+		wantMysql bool
+	}{
+		{
+			name:      "closed breaker takes the cache lock",
+			setup:     func(mgr *BreakerCacheMgr, server *miniredis.Miniredis) {},
+			wantCache: true,
+		},
+		{
+			name: "open breaker takes the mysql lease",
+			setup: func(mgr *BreakerCacheMgr, server *miniredis.Miniredis) {
+				mgr.breaker.Open()
+			},
+			wantMysql: true,
+		},
+		{
+			name: "recovered breaker takes both",
+			setup: func(mgr *BreakerCacheMgr, server *miniredis.Miniredis) {
+				mgr.recoveredAt = time.Now()
+			},
+			wantCache: true,
+			wantMysql: true,
+		},
+		{
+			name: "recovered long ago takes the cache lock",
+			setup: func(mgr *BreakerCacheMgr, server *miniredis.Miniredis) {
+				mgr.recoveredAt = time.Now().Add(-time.Hour)
+			},
+			wantCache: true,
+		},
+		{
+			name: "failing redis does not fall back to mysql",
+			setup: func(mgr *BreakerCacheMgr, server *miniredis.Miniredis) {
+				server.SetError("READONLY")
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+			t.Cleanup(func() { client.Close() })
+			cacheMgr := NewBreakerCacheMgr(client, config.CircuitBreakerOption{})
+			mysqlMgr := &fakeLockStore{locks: map[string]bool{}}
+			tt.setup(cacheMgr, server)
+			ctx := context.Background()
+
+			release, ok, err := TryLock(ctx, "task", time.Second, cacheMgr, mysqlMgr)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.False(t, mysqlMgr.held("task"))
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, tt.wantCache, server.Exists("lock:{task}"))
+			assert.Equal(t, tt.wantMysql, mysqlMgr.held("task"))
+
+			release()
+			assert.False(t, server.Exists("lock:{task}"))
+			assert.False(t, mysqlMgr.held("task"))
+		})
+	}
+}
@@ -3,31 +3,52 @@ package data
 import (
 	"context"
 	"fmt"
+	"strings"
+	"task_service/pkg/lock"
 	"task_service/pkg/logger"
 	"task_service/pkg/models"
+	"task_service/pkg/utils"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
+// managedColumns are only written by the reminder scheduler, the comment
+// manager and the rank manager. Task updates skip them so an update racing
+// with the scheduler cannot re-arm a fired event, nor one racing with a
+// comment or a move reset the count or the rank.
+var managedColumns = []string{"fired_due_at", "fired_remind_at", "comment_count", "rank"}
+
 type MysqlMgr struct {
 	client *gorm.DB
+	locker lock.Locker
 }
 
 func newMysqlManager(gormClient *gorm.DB) DataManager {
 	return &MysqlMgr{
 		client: gormClient,
+		locker: lock.NewMysqlLocker(gormClient, lock.DefaultTable),
 	}
 }
-func (mgr *MysqlMgr) ListTask(ctx context.Context, limit, offset int, order string) ([]models.Task, error) {
+func (mgr *MysqlMgr) ListTask(ctx context.Context, limit, offset int, order string, filter models.TaskFilter) ([]models.Task, error) {
+	// order ends up in the ORDER BY clause as is
+	order, err := utils.ParseOrder(order)
+	if err != nil {
+		return nil, fmt.Errorf("ListTask: %s", err.Error())
+	}
 	var tasks []models.Task
-	if err := mgr.client.
-		Order(order).
+	if err := mgr.reader(ctx).
+		Scopes(tenantScope(ctx), taskFilterScope(filter)).
+		Order(nullsLast(order)).
 		Offset(offset).Limit(limit).
 		Find(&tasks).
 		Error; err != nil {
 		return nil, fmt.Errorf("ListTask: %s", err.Error())
 	}
+	if err := loadTags(mgr.reader(ctx), tasks); err != nil {
+		return nil, fmt.Errorf("ListTask: %s", err.Error())
+	}
 	return tasks, nil
 }
 
@@ -35,46 +56,259 @@ func (mgr *MysqlMgr) GetTaskById(ctx context.Context, taskId uint64) (models.Tas
 	task := models.Task{
 		ID: taskId,
 	}
-	if err := mgr.client.First(&task).Error; err != nil {
+	if err := mgr.reader(ctx).Scopes(tenantScope(ctx)).First(&task).Error; err != nil {
+		return models.Task{}, fmt.Errorf("GetTaskById: %s", err.Error())
+	}
+	tasks := []models.Task{task}
+	if err := loadTags(mgr.reader(ctx), tasks); err != nil {
 		return models.Task{}, fmt.Errorf("GetTaskById: %s", err.Error())
 	}
-	return task, nil
+	return tasks[0], nil
+}
+
+func (mgr *MysqlMgr) ListSubtree(ctx context.Context, taskId uint64, depth int) ([]models.Task, error) {
+	tenantId := TenantFromContext(ctx)
+	tasks := []models.Task{}
+	if err := mgr.reader(ctx).Raw(`
+WITH RECURSIVE subtree (id, depth) AS (
+	SELECT id, 1 FROM Task WHERE tenant_id = ? AND parent_id = ?
+	UNION ALL
+	SELECT Task.id, subtree.depth + 1 FROM Task JOIN subtree ON Task.parent_id = subtree.id
+	WHERE Task.tenant_id = ? AND subtree.depth < ?
+)
+SELECT Task.* FROM Task JOIN subtree ON Task.id = subtree.id ORDER BY subtree.depth, Task.id`,
+		tenantId, taskId, tenantId, depth).
+		Scan(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("ListSubtree: %s", err.Error())
+	}
+	if err := loadTags(mgr.reader(ctx), tasks); err != nil {
+		return nil, fmt.Errorf("ListSubtree: %s", err.Error())
+	}
+	return tasks, nil
 }
 
 func (mgr *MysqlMgr) CheckTaskExist(ctx context.Context, condition map[string]interface{}, task *models.Task) error {
-	if err := mgr.client.Where(condition).First(task).Error; err != nil && err != gorm.ErrRecordNotFound {
+	// Uniqueness is always checked against the primary.
+	db := mgr.client.WithContext(ctx).Clauses(dbresolver.Write)
+	err := db.Scopes(tenantScope(ctx)).Where(condition).First(task).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
 		return fmt.Errorf("CheckTaskExist: %s", err.Error())
 	}
 
+	tasks := []models.Task{*task}
+	if err := loadTags(db, tasks); err != nil {
+		return fmt.Errorf("CheckTaskExist: %s", err.Error())
+	}
+	*task = tasks[0]
 	return nil
 }
 
 func (mgr *MysqlMgr) CreateTask(ctx context.Context, tasks []models.Task) error {
-	if err := mgr.client.Create(&tasks).Error; err != nil {
+	tenantId := TenantFromContext(ctx)
+	for i := range tasks {
+		tasks[i].TenantID = tenantId
+	}
+
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createTasks(tx, tenantId, tasks)
+	})
+	if err != nil {
 		return fmt.Errorf("CreateTask: %s", err.Error())
 	}
 	return nil
 }
 
+// createTasks inserts tasks with their tags, then their subtasks as their
+// children.
+func createTasks(tx *gorm.DB, tenantId string, tasks []models.Task) error {
+	if err := assignRanks(tx, tenantId, tasks); err != nil {
+		return err
+	}
+	if err := tx.Create(&tasks).Error; err != nil {
+		return err
+	}
+	for i := range tasks {
+		if err := saveTags(tx, tenantId, &tasks[i]); err != nil {
+			return err
+		}
+	}
+	for i := range tasks {
+		if len(tasks[i].Subtasks) == 0 {
+			continue
+		}
+		for j := range tasks[i].Subtasks {
+			parentId := tasks[i].ID
+			tasks[i].Subtasks[j].TenantID = tenantId
+			tasks[i].Subtasks[j].ParentID = &parentId
+		}
+		if err := createTasks(tx, tenantId, tasks[i].Subtasks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteTask also drops the tags, comments and attachment metadata of the
+// task and its dependencies in either direction. The caller removes the
+// attachment blobs no other attachment refers to.
 func (mgr *MysqlMgr) DeleteTask(ctx context.Context, taskId uint64) error {
-	if err := mgr.client.Delete(&models.Task{}, "id = ?", taskId).Error; err != nil {
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteTasks(tx, TenantFromContext(ctx), taskId)
+	})
+	if err != nil {
 		return fmt.Errorf("DeleteTask: %s", err.Error())
 	}
 	return nil
 }
 
+// deleteTasks deletes tasks of tenantId, see DeleteTask.
+func deleteTasks(tx *gorm.DB, tenantId string, taskIds ...uint64) error {
+	if err := tx.Where("tenant_id = ?", tenantId).
+		Delete(&models.TaskDependency{}, "(task_id IN ? OR blocker_id IN ?)", taskIds, taskIds).Error; err != nil {
+		return err
+	}
+	if err := deleteTaskTags(tx, tenantId, taskIds...); err != nil {
+		return err
+	}
+	if err := deleteTaskComments(tx, tenantId, taskIds...); err != nil {
+		return err
+	}
+	if err := tx.Where("tenant_id = ?", tenantId).Delete(&models.Attachment{}, "task_id IN ?", taskIds).Error; err != nil {
+		return err
+	}
+	return tx.Where("tenant_id = ?", tenantId).Delete(&models.Task{}, "id IN ?", taskIds).Error
+}
+
 func (mgr *MysqlMgr) UpdateTask(ctx context.Context, task *models.Task) error {
-	if err := mgr.client.Where("id=?", task.ID).Save(task).Error; err != nil {
+	task.TenantID = TenantFromContext(ctx)
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(task).Scopes(tenantScope(ctx)).
+			Select("*").Omit(managedColumns...).Updates(task).Error; err != nil {
+			return err
+		}
+		return saveTags(tx, task.TenantID, task)
+	})
+	if err != nil {
 		return fmt.Errorf("UpdateTask: %s", err.Error())
 	}
 	return nil
 }
 
-func (mgr *MysqlMgr) Lock(ctx context.Context, lockKey string, expiration time.Duration) (bool, error) {
-	return false, nil
+// BatchUpdateTask saves the tasks in one transaction. A row whose stored
+// version is already newer than the given task is left untouched.
+func (mgr *MysqlMgr) BatchUpdateTask(ctx context.Context, tasks []models.Task) error {
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range tasks {
+			result := tx.Model(&models.Task{}).
+				Where("id = ? AND tenant_id = ? AND version <= ?", tasks[i].ID, tasks[i].TenantID, tasks[i].Version).
+				Select("*").Omit(append([]string{"id", "created_at"}, managedColumns...)...).
+				Updates(&tasks[i])
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+			if err := saveTags(tx, tasks[i].TenantID, &tasks[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("BatchUpdateTask: %s", err.Error())
+	}
+	return nil
+}
+
+// Lock takes a lease in the TaskLock table, see lock.NewMysqlLocker.
+func (mgr *MysqlMgr) Lock(ctx context.Context, lockKey string, expiration time.Duration) (lock.Lease, bool, error) {
+	lease, locked, err := mgr.locker.Acquire(ctx, lockKey, expiration)
+	if err != nil {
+		return lock.Lease{}, false, fmt.Errorf("Lock: %v", err)
+	}
+	return lease, locked, nil
+}
+
+func (mgr *MysqlMgr) ReleaseLock(ctx context.Context, lease lock.Lease) {
+	if err := mgr.locker.Release(ctx, lease); err != nil {
+		logger.GetLoggerWithContext(ctx, map[string]interface{}{
+			"error": err,
+		}).Error("ReleaseLock Fail")
+	}
+}
+
+// tenantScope restricts a query to the tenant of ctx.
+func tenantScope(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	tenantId := TenantFromContext(ctx)
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("tenant_id = ?", tenantId)
+	}
+}
+
+// taskFilterScope translates filter into the conditions of a Task query.
+func taskFilterScope(filter models.TaskFilter) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		if filter.Name != "" {
+			tx = tx.Where("name = ?", filter.Name)
+		}
+		if filter.VisibleTo != "" {
+			tx = tx.Where("(owner_id = ? OR assignee_id = ?)", filter.VisibleTo, filter.VisibleTo)
+		}
+		if filter.DueBefore != nil {
+			tx = tx.Where("due_at < ?", *filter.DueBefore)
+		}
+		if filter.DueAfter != nil {
+			tx = tx.Where("due_at >= ?", *filter.DueAfter)
+		}
+		if filter.OverdueAt != nil {
+			tx = tx.Where("due_at <= ? AND status = ?", *filter.OverdueAt, models.TaskStatusOpen)
+		}
+		if len(filter.Tags) > 0 {
+			tagged := "SELECT TaskTag.task_id FROM TaskTag JOIN Tag ON Tag.id = TaskTag.tag_id WHERE Tag.name IN ?"
+			if filter.AnyTag {
+				tx = tx.Where("id IN ("+tagged+")", filter.Tags)
+			} else {
+				tx = tx.Where("id IN ("+tagged+" GROUP BY TaskTag.task_id HAVING COUNT(DISTINCT Tag.name) = ?)",
+					filter.Tags, len(filter.Tags))
+			}
+		}
+		for key, value := range filter.Fields {
+			tx = tx.Where("JSON_UNQUOTE(JSON_EXTRACT(`fields`, ?)) = ?", fieldPath(key), models.FieldText(value))
+		}
+		return tx
+	}
 }
-func (mgr *MysqlMgr) ReleaseLock(ctx context.Context, lockKey string) {
 
+// nullsLast sorts tasks without a due or remind time after the others, the
+// way the cache does, instead of first as MySQL does for ascending orders.
+// It also quotes rank, a reserved word of MySQL, and sorts by the value of
+// a custom field for field.<key>.
+func nullsLast(order string) string {
+	if clause, ok := fieldOrder(order); ok {
+		return clause
+	}
+	field, direction, _ := strings.Cut(order, " ")
+	switch field {
+	case "due_at", "remind_at":
+		return field + " IS NULL, " + order
+	case "rank":
+		return strings.TrimSpace("`rank` " + direction)
+	}
+	return order
+}
+
+// reader returns the session used for reads, which goes to a replica unless
+// ctx was marked by WithPrimary.
+func (mgr *MysqlMgr) reader(ctx context.Context) *gorm.DB {
+	tx := mgr.client.WithContext(ctx)
+	if usePrimary(ctx) {
+		tx = tx.Clauses(dbresolver.Write)
+	}
+	return tx
 }
 
 func (mgr *MysqlMgr) Close(ctx context.Context) {
@@ -0,0 +1,132 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"task_service/pkg/models"
+	"task_service/pkg/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// RankManager keeps the board order of the tasks of a tenant, see
+// utils.RankBetween. Reads go to the primary, they decide the new rank of a
+// task.
+type RankManager interface {
+	// NeighborRank returns the rank right after rank, or right before it
+	// without after, skipping the task excludeId. It is empty when there is
+	// no such task.
+	NeighborRank(ctx context.Context, rank string, after bool, excludeId uint64) (string, error)
+	// SetRank moves a task to rank.
+	SetRank(ctx context.Context, taskId uint64, rank string) error
+	// SpreadRanks gives the tasks of the tenant evenly spaced ranks, keeping
+	// their order.
+	SpreadRanks(ctx context.Context) error
+}
+
+// spreadRanksBatchSize is the number of tasks SpreadRanks updates per
+// statement.
+const spreadRanksBatchSize = 500
+
+func NewRankManager(client *gorm.DB) RankManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) NeighborRank(ctx context.Context, rank string, after bool, excludeId uint64) (string, error) {
+	tx := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Model(&models.Task{}).
+		Scopes(tenantScope(ctx)).Where("id <> ?", excludeId)
+	if after {
+		tx = tx.Where("`rank` > ?", rank).Order("`rank`")
+	} else {
+		tx = tx.Where("`rank` < ?", rank).Order("`rank` desc")
+	}
+
+	ranks := []string{}
+	if err := tx.Limit(1).Pluck("rank", &ranks).Error; err != nil {
+		return "", fmt.Errorf("NeighborRank: %s", err.Error())
+	}
+	if len(ranks) == 0 {
+		return "", nil
+	}
+	return ranks[0], nil
+}
+
+func (mgr *MysqlMgr) SetRank(ctx context.Context, taskId uint64, rank string) error {
+	result := mgr.client.WithContext(ctx).Model(&models.Task{}).Scopes(tenantScope(ctx)).
+		Where("id = ?", taskId).Update("rank", rank)
+	if result.Error != nil {
+		return fmt.Errorf("SetRank: %s", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("SetRank: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) SpreadRanks(ctx context.Context) error {
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		ids := []uint64{}
+		if err := tx.Model(&models.Task{}).Scopes(tenantScope(ctx)).
+			Order("`rank`").Order("id").Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		ranks := utils.SpreadRanks(len(ids))
+		for start := 0; start < len(ids); start += spreadRanksBatchSize {
+			end := min(start+spreadRanksBatchSize, len(ids))
+			if err := setRanks(tx, TenantFromContext(ctx), ids[start:end], ranks[start:end]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("SpreadRanks: %s", err.Error())
+	}
+	return nil
+}
+
+// setRanks gives the task ids[i] the rank ranks[i] in one UPDATE.
+func setRanks(tx *gorm.DB, tenantId string, ids []uint64, ranks []string) error {
+	expr := strings.Builder{}
+	args := make([]interface{}, 0, 2*len(ids))
+	expr.WriteString("CASE id")
+	for i := range ids {
+		expr.WriteString(" WHEN ? THEN ?")
+		args = append(args, ids[i], ranks[i])
+	}
+	expr.WriteString(" END")
+	return tx.Model(&models.Task{}).Where("tenant_id = ? AND id IN ?", tenantId, ids).
+		Update("rank", gorm.Expr(expr.String(), args...)).Error
+}
+
+// assignRanks appends the tasks without a rank after the last task of the
+// tenant, in the order given.
+func assignRanks(tx *gorm.DB, tenantId string, tasks []models.Task) error {
+	last := ""
+	found := false
+	for i := range tasks {
+		if tasks[i].Rank != "" {
+			continue
+		}
+		if !found {
+			ranks := []string{}
+			if err := tx.Model(&models.Task{}).Where("tenant_id = ?", tenantId).
+				Order("`rank` desc").Limit(1).Pluck("rank", &ranks).Error; err != nil {
+				return err
+			}
+			if len(ranks) > 0 {
+				last = ranks[0]
+			}
+			found = true
+		}
+		// a rank past MaxRankLength still sorts right, the next move
+		// spreads the ranks again
+		tasks[i].Rank, _ = utils.RankBetween(last, "")
+		last = tasks[i].Rank
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"task_service/pkg/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ReminderManager finds the tasks of every tenant whose reminder or overdue
+// event is due and claims them, so each event fires once across replicas.
+type ReminderManager interface {
+	ListDueReminders(ctx context.Context, now time.Time, limit int) ([]models.Task, error)
+	ListNewlyOverdue(ctx context.Context, now time.Time, limit int) ([]models.Task, error)
+	// ClaimReminder and ClaimOverdue report false when the event was already
+	// fired for the current remind / due time.
+	ClaimReminder(ctx context.Context, task models.Task) (bool, error)
+	ClaimOverdue(ctx context.Context, task models.Task) (bool, error)
+}
+
+func NewReminderManager(client *gorm.DB) ReminderManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) ListDueReminders(ctx context.Context, now time.Time, limit int) ([]models.Task, error) {
+	var tasks []models.Task
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).
+		Where("remind_at <= ? AND status = ?", now, models.TaskStatusOpen).
+		Where("(fired_remind_at IS NULL OR fired_remind_at <> remind_at)").
+		Order("remind_at").Limit(limit).
+		Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("ListDueReminders: %s", err.Error())
+	}
+	if err := loadTags(mgr.client.WithContext(ctx).Clauses(dbresolver.Write), tasks); err != nil {
+		return nil, fmt.Errorf("ListDueReminders: %s", err.Error())
+	}
+	return tasks, nil
+}
+
+func (mgr *MysqlMgr) ListNewlyOverdue(ctx context.Context, now time.Time, limit int) ([]models.Task, error) {
+	var tasks []models.Task
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).
+		Where("due_at <= ? AND status = ?", now, models.TaskStatusOpen).
+		Where("(fired_due_at IS NULL OR fired_due_at <> due_at)").
+		Order("due_at").Limit(limit).
+		Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("ListNewlyOverdue: %s", err.Error())
+	}
+	if err := loadTags(mgr.client.WithContext(ctx).Clauses(dbresolver.Write), tasks); err != nil {
+		return nil, fmt.Errorf("ListNewlyOverdue: %s", err.Error())
+	}
+	return tasks, nil
+}
+
+func (mgr *MysqlMgr) ClaimReminder(ctx context.Context, task models.Task) (bool, error) {
+	claimed, err := mgr.claim(ctx, task.ID, "remind_at", "fired_remind_at", task.RemindAt)
+	if err != nil {
+		return false, fmt.Errorf("ClaimReminder: %s", err.Error())
+	}
+	return claimed, nil
+}
+
+func (mgr *MysqlMgr) ClaimOverdue(ctx context.Context, task models.Task) (bool, error) {
+	claimed, err := mgr.claim(ctx, task.ID, "due_at", "fired_due_at", task.DueAt)
+	if err != nil {
+		return false, fmt.Errorf("ClaimOverdue: %s", err.Error())
+	}
+	return claimed, nil
+}
+
+// claim copies column into fired when it still holds at and has not been
+// fired yet. updated_at is kept so the claim does not look like an edit.
+func (mgr *MysqlMgr) claim(ctx context.Context, taskId uint64, column, fired string, at *time.Time) (bool, error) {
+	if at == nil {
+		return false, nil
+	}
+	result := mgr.client.WithContext(ctx).Model(&models.Task{}).
+		Where("id = ?", taskId).
+		Where(fmt.Sprintf("%s = ? AND (%s IS NULL OR %s <> %s)", column, fired, fired, column), *at).
+		UpdateColumns(map[string]interface{}{
+			fired:        gorm.Expr(column),
+			"updated_at": gorm.Expr("updated_at"),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
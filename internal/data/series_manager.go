@@ -0,0 +1,159 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task_service/pkg/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// errSeriesMoved aborts Materialize when the series was advanced by someone
+// else.
+var errSeriesMoved = errors.New("series moved")
+
+// ErrSeriesConflict is returned by UpdateSeries when the series was changed
+// since it was read.
+var ErrSeriesConflict = errors.New("series was modified concurrently")
+
+// SeriesManager stores recurring task series and creates their occurrences.
+type SeriesManager interface {
+	// CreateSeries stores series and its first occurrence.
+	CreateSeries(ctx context.Context, series *models.TaskSeries, first *models.Task) error
+	GetSeries(ctx context.Context, seriesId uint64) (models.TaskSeries, error)
+	// UpdateSeries saves series if its version is unchanged and bumps it.
+	UpdateSeries(ctx context.Context, series *models.TaskSeries) error
+	// ListDueSeries returns the series of every tenant whose next occurrence
+	// starts at or before now.
+	ListDueSeries(ctx context.Context, now time.Time, limit int) ([]models.TaskSeries, error)
+	// Materialize creates task, the occurrence at series.NextAt, and moves
+	// NextAt on to next. It reports false when the series was advanced
+	// concurrently.
+	Materialize(ctx context.Context, series models.TaskSeries, task *models.Task, next *time.Time) (bool, error)
+	CountOpenOccurrences(ctx context.Context, seriesId uint64) (int64, error)
+	// ListOccurrences returns the occurrences starting after after.
+	ListOccurrences(ctx context.Context, seriesId uint64, after time.Time) ([]models.Task, error)
+}
+
+func NewSeriesManager(client *gorm.DB) SeriesManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) CreateSeries(ctx context.Context, series *models.TaskSeries, first *models.Task) error {
+	series.TenantID = TenantFromContext(ctx)
+	first.TenantID = series.TenantID
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(series).Error; err != nil {
+			return err
+		}
+		first.SeriesID = &series.ID
+		if err := tx.Create(first).Error; err != nil {
+			return err
+		}
+		return saveTags(tx, series.TenantID, first)
+	})
+	if err != nil {
+		return fmt.Errorf("CreateSeries: %s", err.Error())
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) GetSeries(ctx context.Context, seriesId uint64) (models.TaskSeries, error) {
+	series := models.TaskSeries{}
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Scopes(tenantScope(ctx)).
+		First(&series, "id = ?", seriesId).Error; err != nil {
+		return models.TaskSeries{}, fmt.Errorf("GetSeries: %w", err)
+	}
+	return series, nil
+}
+
+func (mgr *MysqlMgr) UpdateSeries(ctx context.Context, series *models.TaskSeries) error {
+	series.TenantID = TenantFromContext(ctx)
+	version := series.Version
+	series.Version++
+	result := mgr.client.WithContext(ctx).Model(&models.TaskSeries{}).Scopes(tenantScope(ctx)).
+		Where("id = ? AND version = ?", series.ID, version).
+		Select("*").Omit("id", "created_at").Updates(series)
+	if result.Error != nil {
+		series.Version = version
+		return fmt.Errorf("UpdateSeries: %s", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		series.Version = version
+		return ErrSeriesConflict
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) ListDueSeries(ctx context.Context, now time.Time, limit int) ([]models.TaskSeries, error) {
+	var series []models.TaskSeries
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).
+		Where("next_at <= ?", now).
+		Order("next_at").Limit(limit).
+		Find(&series).Error; err != nil {
+		return nil, fmt.Errorf("ListDueSeries: %s", err.Error())
+	}
+	return series, nil
+}
+
+func (mgr *MysqlMgr) Materialize(ctx context.Context, series models.TaskSeries, task *models.Task, next *time.Time) (bool, error) {
+	if series.NextAt == nil {
+		return false, nil
+	}
+	task.TenantID = series.TenantID
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.TaskSeries{}).
+			Where("id = ? AND next_at = ?", series.ID, *series.NextAt).
+			UpdateColumns(map[string]interface{}{
+				"next_at": next,
+				"version": gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errSeriesMoved
+		}
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+		return saveTags(tx, task.TenantID, task)
+	})
+	if errors.Is(err, errSeriesMoved) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Materialize: %s", err.Error())
+	}
+	return true, nil
+}
+
+func (mgr *MysqlMgr) CountOpenOccurrences(ctx context.Context, seriesId uint64) (int64, error) {
+	var count int64
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Model(&models.Task{}).
+		Scopes(tenantScope(ctx)).
+		Where("series_id = ? AND status = ?", seriesId, models.TaskStatusOpen).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("CountOpenOccurrences: %s", err.Error())
+	}
+	return count, nil
+}
+
+func (mgr *MysqlMgr) ListOccurrences(ctx context.Context, seriesId uint64, after time.Time) ([]models.Task, error) {
+	var tasks []models.Task
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Scopes(tenantScope(ctx)).
+		Where("series_id = ? AND occurrence_at > ?", seriesId, after).
+		Order("occurrence_at").
+		Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("ListOccurrences: %s", err.Error())
+	}
+	if err := loadTags(mgr.client.WithContext(ctx).Clauses(dbresolver.Write), tasks); err != nil {
+		return nil, fmt.Errorf("ListOccurrences: %s", err.Error())
+	}
+	return tasks, nil
+}
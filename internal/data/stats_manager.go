@@ -0,0 +1,37 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"task_service/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// StatsManager reports aggregates over the tasks of every tenant.
+type StatsManager interface {
+	CountTaskByStatus(ctx context.Context) (map[int]int64, error)
+}
+
+func NewStatsManager(client *gorm.DB) StatsManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) CountTaskByStatus(ctx context.Context) (map[int]int64, error) {
+	var rows []struct {
+		Status int
+		Count  int64
+	}
+	if err := mgr.reader(ctx).Model(&models.Task{}).
+		Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("CountTaskByStatus: %s", err.Error())
+	}
+
+	counts := make(map[int]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,296 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"task_service/c"
+	"task_service/pkg/models"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ErrTagExists is returned when a tag is created or renamed to the name of
+// another tag of the tenant.
+var ErrTagExists = errors.New("tag already exists")
+
+// TagManager maintains the tag catalog of a tenant. Renaming, merging and
+// deleting a tag changes the tags of its tasks, ListTaskIdsByTag and
+// ListTasksByIds let the caller refresh them in the cache.
+type TagManager interface {
+	CreateTag(ctx context.Context, tag *models.Tag) error
+	// ListTags returns the tags of the tenant with their task counts.
+	ListTags(ctx context.Context) ([]models.Tag, error)
+	GetTag(ctx context.Context, tagId uint64) (models.Tag, error)
+	// UpdateTag saves the name, color and description of tag, a new name
+	// is applied to the recurring task templates as well.
+	UpdateTag(ctx context.Context, tag *models.Tag) error
+	// DeleteTag removes the tag from every task.
+	DeleteTag(ctx context.Context, tagId uint64) error
+	// MergeTag moves the tasks of tag sourceId to tag targetId and deletes
+	// the source.
+	MergeTag(ctx context.Context, sourceId, targetId uint64) error
+	ListTaskIdsByTag(ctx context.Context, tagId uint64) ([]uint64, error)
+	ListTasksByIds(ctx context.Context, ids []uint64) ([]models.Task, error)
+}
+
+func NewTagManager(client *gorm.DB) TagManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) CreateTag(ctx context.Context, tag *models.Tag) error {
+	tag.TenantID = TenantFromContext(ctx)
+	if err := mgr.client.WithContext(ctx).Create(tag).Error; err != nil {
+		if isDuplicateEntry(err) {
+			return ErrTagExists
+		}
+		return fmt.Errorf("CreateTag: %s", err.Error())
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) ListTags(ctx context.Context) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	if err := mgr.reader(ctx).Scopes(tenantScope(ctx)).Order("name").Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("ListTags: %s", err.Error())
+	}
+	if err := mgr.countTagTasks(mgr.reader(ctx), tags); err != nil {
+		return nil, fmt.Errorf("ListTags: %s", err.Error())
+	}
+	return tags, nil
+}
+
+func (mgr *MysqlMgr) GetTag(ctx context.Context, tagId uint64) (models.Tag, error) {
+	tag := models.Tag{}
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Scopes(tenantScope(ctx)).
+		First(&tag, "id = ?", tagId).Error; err != nil {
+		return models.Tag{}, fmt.Errorf("GetTag: %w", err)
+	}
+	tags := []models.Tag{tag}
+	if err := mgr.countTagTasks(mgr.client.WithContext(ctx).Clauses(dbresolver.Write), tags); err != nil {
+		return models.Tag{}, fmt.Errorf("GetTag: %s", err.Error())
+	}
+	return tags[0], nil
+}
+
+func (mgr *MysqlMgr) UpdateTag(ctx context.Context, tag *models.Tag) error {
+	tenantId := TenantFromContext(ctx)
+	tag.TenantID = tenantId
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		old := models.Tag{}
+		if err := tx.Where("tenant_id = ? AND id = ?", tenantId, tag.ID).First(&old).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(tag).Select("name", "color", "description").Updates(tag).Error; err != nil {
+			return err
+		}
+		if old.Name == tag.Name {
+			return nil
+		}
+		return replaceSeriesTag(tx, tenantId, old.Name, tag.Name)
+	})
+	if isDuplicateEntry(err) {
+		return ErrTagExists
+	}
+	if err != nil {
+		return fmt.Errorf("UpdateTag: %w", err)
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) DeleteTag(ctx context.Context, tagId uint64) error {
+	tenantId := TenantFromContext(ctx)
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tag := models.Tag{}
+		if err := tx.Where("tenant_id = ? AND id = ?", tenantId, tagId).First(&tag).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.TaskTag{}, "tag_id = ?", tagId).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&tag).Error; err != nil {
+			return err
+		}
+		return replaceSeriesTag(tx, tenantId, tag.Name, "")
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteTag: %w", err)
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) MergeTag(ctx context.Context, sourceId, targetId uint64) error {
+	tenantId := TenantFromContext(ctx)
+	err := mgr.client.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var source, target models.Tag
+		if err := tx.Where("tenant_id = ? AND id = ?", tenantId, sourceId).First(&source).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tenant_id = ? AND id = ?", tenantId, targetId).First(&target).Error; err != nil {
+			return err
+		}
+		// tasks having both tags keep a single link to the target
+		if err := tx.Exec("INSERT IGNORE INTO TaskTag (task_id, tag_id) SELECT task_id, ? FROM TaskTag WHERE tag_id = ?",
+			targetId, sourceId).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.TaskTag{}, "tag_id = ?", sourceId).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&source).Error; err != nil {
+			return err
+		}
+		return replaceSeriesTag(tx, tenantId, source.Name, target.Name)
+	})
+	if err != nil {
+		return fmt.Errorf("MergeTag: %w", err)
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) ListTaskIdsByTag(ctx context.Context, tagId uint64) ([]uint64, error) {
+	var ids []uint64
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Model(&models.TaskTag{}).
+		Where("tag_id = ?", tagId).Order("task_id").
+		Pluck("task_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("ListTaskIdsByTag: %s", err.Error())
+	}
+	return ids, nil
+}
+
+// countTagTasks sets the TaskCount of tags.
+func (mgr *MysqlMgr) countTagTasks(db *gorm.DB, tags []models.Tag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	ids := make([]uint64, len(tags))
+	for i := range tags {
+		ids[i] = tags[i].ID
+	}
+
+	var counts []struct {
+		TagID uint64
+		Count int64
+	}
+	if err := db.Model(&models.TaskTag{}).Select("tag_id, COUNT(*) AS count").
+		Where("tag_id IN ?", ids).Group("tag_id").
+		Scan(&counts).Error; err != nil {
+		return err
+	}
+	for _, count := range counts {
+		for i := range tags {
+			if tags[i].ID == count.TagID {
+				tags[i].TaskCount = count.Count
+			}
+		}
+	}
+	return nil
+}
+
+// loadTags sets the Tags of tasks, reading with db.
+func loadTags(db *gorm.DB, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	index := make(map[uint64]int, len(tasks))
+	ids := make([]uint64, len(tasks))
+	for i := range tasks {
+		tasks[i].Tags = []string{}
+		index[tasks[i].ID] = i
+		ids[i] = tasks[i].ID
+	}
+
+	var rows []struct {
+		TaskID uint64
+		Name   string
+	}
+	if err := db.Model(&models.TaskTag{}).Select("TaskTag.task_id, Tag.name").
+		Joins("JOIN Tag ON Tag.id = TaskTag.tag_id").
+		Where("TaskTag.task_id IN ?", ids).Order("Tag.name").
+		Scan(&rows).Error; err != nil {
+		return fmt.Errorf("loadTags: %s", err.Error())
+	}
+	for _, row := range rows {
+		i := index[row.TaskID]
+		tasks[i].Tags = append(tasks[i].Tags, row.Name)
+	}
+	return nil
+}
+
+// saveTags replaces the tags of task with task.Tags inside tx, adding the
+// missing ones to the catalog of the tenant.
+func saveTags(tx *gorm.DB, tenantId string, task *models.Task) error {
+	if err := deleteTaskTags(tx, tenantId, task.ID); err != nil {
+		return fmt.Errorf("saveTags: %s", err.Error())
+	}
+	if len(task.Tags) == 0 {
+		return nil
+	}
+
+	tags := make([]models.Tag, len(task.Tags))
+	for i, name := range task.Tags {
+		tags[i] = models.Tag{TenantID: tenantId, Name: name}
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return fmt.Errorf("saveTags: %s", err.Error())
+	}
+
+	var tagIds []uint64
+	if err := tx.Model(&models.Tag{}).Where("tenant_id = ? AND name IN ?", tenantId, task.Tags).
+		Pluck("id", &tagIds).Error; err != nil {
+		return fmt.Errorf("saveTags: %s", err.Error())
+	}
+	links := make([]models.TaskTag, len(tagIds))
+	for i, tagId := range tagIds {
+		links[i] = models.TaskTag{TaskID: task.ID, TagID: tagId}
+	}
+	if err := tx.Create(&links).Error; err != nil {
+		return fmt.Errorf("saveTags: %s", err.Error())
+	}
+	return nil
+}
+
+// deleteTaskTags drops the tag links of tasks. TaskTag has no tenant, the
+// links are only dropped while their task belongs to tenantId.
+func deleteTaskTags(tx *gorm.DB, tenantId string, taskIds ...uint64) error {
+	return tx.Delete(&models.TaskTag{}, "task_id IN ? AND task_id IN (SELECT id FROM Task WHERE tenant_id = ?)", taskIds, tenantId).Error
+}
+
+// replaceSeriesTag renames tag old to new in the templates of the recurring
+// tasks of the tenant, or drops it when new is empty.
+func replaceSeriesTag(tx *gorm.DB, tenantId, old, new string) error {
+	var series []models.TaskSeries
+	if err := tx.Where("tenant_id = ? AND JSON_CONTAINS(tags, JSON_QUOTE(?))", tenantId, old).
+		Find(&series).Error; err != nil {
+		return err
+	}
+	for i := range series {
+		tags := slices.DeleteFunc(series[i].Tags, func(tag string) bool { return tag == old })
+		if new != "" && !slices.Contains(tags, new) {
+			tags = append(tags, new)
+			slices.Sort(tags)
+		}
+		// marshalling a string slice can not fail
+		encoded, _ := json.Marshal(tags)
+		if err := tx.Model(&series[i]).
+			UpdateColumns(map[string]interface{}{
+				"tags":    string(encoded),
+				"version": gorm.Expr("version + 1"),
+			}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == c.MySQLErrDuplicateEntryCode
+}
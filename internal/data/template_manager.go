@@ -0,0 +1,89 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task_service/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// ErrTemplateExists is returned when a template is created or renamed to
+// the name of another template of the tenant.
+var ErrTemplateExists = errors.New("template already exists")
+
+// TemplateManager stores the task templates of a tenant. The tasks created
+// from a template go through DataManager.CreateTask.
+type TemplateManager interface {
+	CreateTemplate(ctx context.Context, template *models.TaskTemplate) error
+	ListTemplates(ctx context.Context, limit, offset int) ([]models.TaskTemplate, error)
+	GetTemplate(ctx context.Context, templateId uint64) (models.TaskTemplate, error)
+	UpdateTemplate(ctx context.Context, template *models.TaskTemplate) error
+	DeleteTemplate(ctx context.Context, templateId uint64) error
+}
+
+func NewTemplateManager(client *gorm.DB) TemplateManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) CreateTemplate(ctx context.Context, template *models.TaskTemplate) error {
+	template.TenantID = TenantFromContext(ctx)
+	if err := mgr.client.WithContext(ctx).Create(template).Error; err != nil {
+		if isDuplicateEntry(err) {
+			return ErrTemplateExists
+		}
+		return fmt.Errorf("CreateTemplate: %s", err.Error())
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) ListTemplates(ctx context.Context, limit, offset int) ([]models.TaskTemplate, error) {
+	templates := []models.TaskTemplate{}
+	if err := mgr.reader(ctx).Scopes(tenantScope(ctx)).Order("name").
+		Offset(offset).Limit(limit).
+		Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("ListTemplates: %s", err.Error())
+	}
+	return templates, nil
+}
+
+func (mgr *MysqlMgr) GetTemplate(ctx context.Context, templateId uint64) (models.TaskTemplate, error) {
+	template := models.TaskTemplate{}
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).Scopes(tenantScope(ctx)).
+		First(&template, "id = ?", templateId).Error; err != nil {
+		return models.TaskTemplate{}, fmt.Errorf("GetTemplate: %w", err)
+	}
+	return template, nil
+}
+
+func (mgr *MysqlMgr) UpdateTemplate(ctx context.Context, template *models.TaskTemplate) error {
+	template.TenantID = TenantFromContext(ctx)
+	result := mgr.client.WithContext(ctx).Model(template).Scopes(tenantScope(ctx)).
+		Select("*").Omit("id", "tenant_id", "owner_id", "created_at").Updates(template)
+	if isDuplicateEntry(result.Error) {
+		return ErrTemplateExists
+	}
+	if result.Error != nil {
+		return fmt.Errorf("UpdateTemplate: %s", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("UpdateTemplate: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) DeleteTemplate(ctx context.Context, templateId uint64) error {
+	result := mgr.client.WithContext(ctx).Scopes(tenantScope(ctx)).
+		Delete(&models.TaskTemplate{}, "id = ?", templateId)
+	if result.Error != nil {
+		return fmt.Errorf("DeleteTemplate: %s", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("DeleteTemplate: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
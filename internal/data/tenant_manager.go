@@ -0,0 +1,66 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"task_service/pkg/models"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// TenantManager stores the tenants and reports their usage.
+type TenantManager interface {
+	ListTenant(ctx context.Context, limit, offset int) ([]models.Tenant, error)
+	GetTenantById(ctx context.Context, tenantId string) (models.Tenant, error)
+	CreateTenant(ctx context.Context, tenant *models.Tenant) error
+	UpdateTenant(ctx context.Context, tenant *models.Tenant) error
+	CountTenantTask(ctx context.Context, tenantId string) (int64, error)
+}
+
+func NewTenantManager(client *gorm.DB) TenantManager {
+	return &MysqlMgr{
+		client: client,
+	}
+}
+
+func (mgr *MysqlMgr) ListTenant(ctx context.Context, limit, offset int) ([]models.Tenant, error) {
+	var tenants []models.Tenant
+	if err := mgr.reader(ctx).Order("id").Offset(offset).Limit(limit).Find(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("ListTenant: %s", err.Error())
+	}
+	return tenants, nil
+}
+
+func (mgr *MysqlMgr) GetTenantById(ctx context.Context, tenantId string) (models.Tenant, error) {
+	tenant := models.Tenant{}
+	if err := mgr.reader(ctx).Where("id = ?", tenantId).First(&tenant).Error; err != nil {
+		return models.Tenant{}, fmt.Errorf("GetTenantById: %w", err)
+	}
+	return tenant, nil
+}
+
+func (mgr *MysqlMgr) CreateTenant(ctx context.Context, tenant *models.Tenant) error {
+	if err := mgr.client.WithContext(ctx).Create(tenant).Error; err != nil {
+		return fmt.Errorf("CreateTenant: %w", err)
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) UpdateTenant(ctx context.Context, tenant *models.Tenant) error {
+	if err := mgr.client.WithContext(ctx).Model(tenant).
+		Select("name", "status", "max_tasks").Updates(tenant).Error; err != nil {
+		return fmt.Errorf("UpdateTenant: %s", err.Error())
+	}
+	return nil
+}
+
+func (mgr *MysqlMgr) CountTenantTask(ctx context.Context, tenantId string) (int64, error) {
+	var count int64
+	// Quotas are enforced against the primary to avoid counting stale rows.
+	if err := mgr.client.WithContext(ctx).Clauses(dbresolver.Write).
+		Model(&models.Task{}).Where("tenant_id = ?", tenantId).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("CountTenantTask: %s", err.Error())
+	}
+	return count, nil
+}
@@ -0,0 +1,112 @@
+package data
+
+import (
+	"context"
+	"task_service/pkg/lock"
+	"task_service/pkg/models"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "task_service/internal/data"
+
+// tracedDataManager starts a span around every call of the wrapped manager,
+// the GORM and redis spans of the call become its children.
+type tracedDataManager struct {
+	DataManager
+	tracer trace.Tracer
+	name   string
+}
+
+// WithTracing wraps mgr so its calls show up as "<name>.<method>" spans.
+func WithTracing(mgr DataManager, name string) DataManager {
+	return &tracedDataManager{
+		DataManager: mgr,
+		tracer:      otel.Tracer(tracerName),
+		name:        name,
+	}
+}
+
+func (mgr *tracedDataManager) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return mgr.tracer.Start(ctx, mgr.name+"."+method,
+		trace.WithAttributes(append(attrs, attribute.String("tenant.id", TenantFromContext(ctx)))...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (mgr *tracedDataManager) ListTask(ctx context.Context, limit, offset int, order string, filter models.TaskFilter) ([]models.Task, error) {
+	ctx, span := mgr.start(ctx, "ListTask",
+		attribute.Int("limit", limit), attribute.Int("offset", offset), attribute.String("order", order))
+	tasks, err := mgr.DataManager.ListTask(ctx, limit, offset, order, filter)
+	span.SetAttributes(attribute.Int("tasks", len(tasks)))
+	endSpan(span, err)
+	return tasks, err
+}
+
+func (mgr *tracedDataManager) GetTaskById(ctx context.Context, taskId uint64) (models.Task, error) {
+	ctx, span := mgr.start(ctx, "GetTaskById", attribute.Int64("task.id", int64(taskId)))
+	task, err := mgr.DataManager.GetTaskById(ctx, taskId)
+	endSpan(span, err)
+	return task, err
+}
+
+func (mgr *tracedDataManager) ListSubtree(ctx context.Context, taskId uint64, depth int) ([]models.Task, error) {
+	ctx, span := mgr.start(ctx, "ListSubtree",
+		attribute.Int64("task.id", int64(taskId)), attribute.Int("depth", depth))
+	tasks, err := mgr.DataManager.ListSubtree(ctx, taskId, depth)
+	span.SetAttributes(attribute.Int("tasks", len(tasks)))
+	endSpan(span, err)
+	return tasks, err
+}
+
+func (mgr *tracedDataManager) CheckTaskExist(ctx context.Context, condition map[string]interface{}, task *models.Task) error {
+	ctx, span := mgr.start(ctx, "CheckTaskExist")
+	err := mgr.DataManager.CheckTaskExist(ctx, condition, task)
+	endSpan(span, err)
+	return err
+}
+
+func (mgr *tracedDataManager) CreateTask(ctx context.Context, tasks []models.Task) error {
+	ctx, span := mgr.start(ctx, "CreateTask", attribute.Int("tasks", len(tasks)))
+	err := mgr.DataManager.CreateTask(ctx, tasks)
+	endSpan(span, err)
+	return err
+}
+
+func (mgr *tracedDataManager) DeleteTask(ctx context.Context, taskId uint64) error {
+	ctx, span := mgr.start(ctx, "DeleteTask", attribute.Int64("task.id", int64(taskId)))
+	err := mgr.DataManager.DeleteTask(ctx, taskId)
+	endSpan(span, err)
+	return err
+}
+
+func (mgr *tracedDataManager) UpdateTask(ctx context.Context, task *models.Task) error {
+	ctx, span := mgr.start(ctx, "UpdateTask", attribute.Int64("task.id", int64(task.ID)))
+	err := mgr.DataManager.UpdateTask(ctx, task)
+	endSpan(span, err)
+	return err
+}
+
+func (mgr *tracedDataManager) Lock(ctx context.Context, lockKey string, expiration time.Duration) (lock.Lease, bool, error) {
+	ctx, span := mgr.start(ctx, "Lock", attribute.String("lock.key", lockKey))
+	lease, locked, err := mgr.DataManager.Lock(ctx, lockKey, expiration)
+	span.SetAttributes(attribute.Bool("lock.acquired", locked))
+	endSpan(span, err)
+	return lease, locked, err
+}
+
+func (mgr *tracedDataManager) ReleaseLock(ctx context.Context, lease lock.Lease) {
+	ctx, span := mgr.start(ctx, "ReleaseLock", attribute.String("lock.key", lease.Key))
+	mgr.DataManager.ReleaseLock(ctx, lease)
+	span.End()
+}
@@ -0,0 +1,591 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"task_service/config"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"task_service/pkg/utils"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrWriteBehindFull is returned when the stream holds more unflushed updates
+// than WriteBehindOption.MaxPending allows for longer than the back-pressure timeout.
+var ErrWriteBehindFull = errors.New("write-behind queue is full")
+
+const (
+	defaultWriteBehindStream      = "task:{writebehind}"
+	defaultWriteBehindGroup       = "task-flusher"
+	defaultWriteBehindBatchSize   = 100
+	defaultWriteBehindInterval    = time.Second
+	defaultWriteBehindClaimIdle   = time.Minute
+	defaultWriteBehindStopTimeout = 4 * time.Second
+	writeBehindRetryInterval      = time.Second
+)
+
+// appendUpdateScript appends an update to the stream and records it as the
+// latest pending snapshot of the task in one atomic step.
+var appendUpdateScript = redis.NewScript(`
+redis.call("XADD", KEYS[1], "*", "id", ARGV[1], "task", ARGV[2])
+return redis.call("HSET", KEYS[2], ARGV[1], ARGV[2])
+`)
+
+// releasePendingScript drops the pending snapshot of a task only if it still
+// holds the payload that was flushed, so a newer update is never lost.
+var releasePendingScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call("HDEL", KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// batchUpdater is implemented by managers able to persist many tasks at once.
+type batchUpdater interface {
+	BatchUpdateTask(ctx context.Context, tasks []models.Task) error
+}
+
+// WriteBehindMgr acknowledges UpdateTask once the update is appended to a
+// Redis Stream and flushes coalesced updates to the wrapped manager in batches.
+// All other calls go straight to the wrapped manager.
+type WriteBehindMgr struct {
+	DataManager
+	client redis.UniversalClient
+	option config.WriteBehindOption
+
+	pendingKey string
+	stopc      chan struct{}
+	donec      chan struct{}
+	startOnce  sync.Once
+	stopOnce   sync.Once
+}
+
+func NewWriteBehindMgr(mgr DataManager, client redis.UniversalClient, option config.WriteBehindOption) *WriteBehindMgr {
+	if option.Stream == "" {
+		option.Stream = defaultWriteBehindStream
+	}
+	if !strings.Contains(option.Stream, "{") {
+		// The stream and its pending hash are written in one transaction,
+		// which Redis Cluster only allows when both share a hash tag.
+		option.Stream = "{" + option.Stream + "}"
+	}
+	if option.Group == "" {
+		option.Group = defaultWriteBehindGroup
+	}
+	if option.Consumer == "" {
+		hostname, _ := os.Hostname()
+		option.Consumer = fmt.Sprintf("%s-%d", hostname, os.Getpid())
+	}
+	if option.BatchSize <= 0 {
+		option.BatchSize = defaultWriteBehindBatchSize
+	}
+	if option.FlushInterval <= 0 {
+		option.FlushInterval = defaultWriteBehindInterval
+	}
+	if option.ClaimIdle <= 0 {
+		option.ClaimIdle = defaultWriteBehindClaimIdle
+	}
+	if option.StopTimeout <= 0 {
+		option.StopTimeout = defaultWriteBehindStopTimeout
+	}
+
+	return &WriteBehindMgr{
+		DataManager: mgr,
+		client:      client,
+		option:      option,
+		pendingKey:  option.Stream + ":pending",
+		stopc:       make(chan struct{}),
+		donec:       make(chan struct{}),
+	}
+}
+
+// Start creates the consumer group and runs the flusher in the background.
+// Updates left unacknowledged by a previous run are flushed first.
+func (mgr *WriteBehindMgr) Start(ctx context.Context) error {
+	err := mgr.client.XGroupCreateMkStream(ctx, mgr.option.Stream, mgr.option.Group, "0").Err()
+	if err != nil && !redis.HasErrorPrefix(err, "BUSYGROUP") {
+		return fmt.Errorf("WriteBehind Start: %v", err)
+	}
+
+	mgr.startOnce.Do(func() {
+		go mgr.run()
+	})
+	return nil
+}
+
+// StopTimeout is how long Stop may take to drain the stream on shutdown.
+func (mgr *WriteBehindMgr) StopTimeout() time.Duration {
+	return mgr.option.StopTimeout
+}
+
+// Stop stops the flusher after draining every update already in the stream.
+func (mgr *WriteBehindMgr) Stop(ctx context.Context) error {
+	mgr.stopOnce.Do(func() {
+		close(mgr.stopc)
+	})
+	// Nothing to drain when the flusher was never started.
+	mgr.startOnce.Do(func() {
+		close(mgr.donec)
+	})
+
+	select {
+	case <-mgr.donec:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("WriteBehind Stop: %v", ctx.Err())
+	}
+}
+
+func (mgr *WriteBehindMgr) UpdateTask(ctx context.Context, task *models.Task) error {
+	task.TenantID = TenantFromContext(ctx)
+	if writeThrough(ctx) {
+		return mgr.updateThrough(ctx, task)
+	}
+
+	if err := mgr.waitForCapacity(ctx); err != nil {
+		return fmt.Errorf("UpdateTask: %w", err)
+	}
+
+	payload, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("UpdateTask: %v", err)
+	}
+
+	node, err := mgr.streamNode(ctx)
+	if err != nil {
+		return fmt.Errorf("UpdateTask: %v", err)
+	}
+
+	// WAIT only counts writes made on its own connection, so the append and
+	// the WAIT are sent in one pipeline.
+	pipe := node.Pipeline()
+	appended := appendUpdateScript.Eval(ctx, pipe, []string{mgr.option.Stream, mgr.pendingKey}, strconv.FormatUint(task.ID, 10), payload)
+	var wait *redis.Cmd
+	if mgr.option.MinReplicas > 0 {
+		wait = pipe.Do(ctx, "WAIT", mgr.option.MinReplicas, mgr.option.FlushInterval.Milliseconds())
+	}
+	_, _ = pipe.Exec(ctx)
+	if err := appended.Err(); err != nil {
+		return fmt.Errorf("UpdateTask: %v", err)
+	}
+
+	// The update is in the stream and will be flushed whatever WAIT says, so
+	// too few replicas is not a failure of the update.
+	if wait != nil {
+		if acked, err := wait.Int64(); err != nil || acked < int64(mgr.option.MinReplicas) {
+			logger.GetLoggerWithContext(ctx, map[string]interface{}{
+				"error":       err,
+				"taskId":      task.ID,
+				"replicas":    acked,
+				"minReplicas": mgr.option.MinReplicas,
+			}).Warn("WriteBehind: update not replicated to enough replicas")
+		}
+	}
+
+	return nil
+}
+
+// updateThrough writes task to the wrapped manager at once. Older updates
+// of the task still in the stream are skipped by the version check of
+// BatchUpdateTask when they are flushed, its pending snapshot is dropped so
+// reads see the new row.
+func (mgr *WriteBehindMgr) updateThrough(ctx context.Context, task *models.Task) error {
+	if err := mgr.persist(ctx, []models.Task{*task}); err != nil {
+		return fmt.Errorf("UpdateTask: %v", err)
+	}
+	if err := mgr.client.HDel(ctx, mgr.pendingKey, strconv.FormatUint(task.ID, 10)).Err(); err != nil {
+		// the snapshot is released when its own update is flushed
+		logger.GetLoggerWithContext(ctx, map[string]interface{}{
+			"error":  err,
+			"taskId": task.ID,
+		}).Warn("WriteBehind: drop pending task fail")
+	}
+	return nil
+}
+
+// streamNode returns the client of the node owning the stream.
+func (mgr *WriteBehindMgr) streamNode(ctx context.Context) (redis.Cmdable, error) {
+	if cluster, ok := mgr.client.(*redis.ClusterClient); ok {
+		return cluster.MasterForKey(ctx, mgr.option.Stream)
+	}
+	return mgr.client, nil
+}
+
+func (mgr *WriteBehindMgr) GetTaskById(ctx context.Context, taskId uint64) (models.Task, error) {
+	payload, err := mgr.client.HGet(ctx, mgr.pendingKey, strconv.FormatUint(taskId, 10)).Result()
+	if err == nil {
+		task := models.Task{}
+		if err := json.Unmarshal([]byte(payload), &task); err == nil && task.TenantID == TenantFromContext(ctx) {
+			return task, nil
+		}
+	} else if err != redis.Nil {
+		logger.GetLoggerWithContext(ctx, map[string]interface{}{
+			"error":  err,
+			"taskId": taskId,
+		}).Warn("WriteBehind: read pending task fail")
+	}
+
+	return mgr.DataManager.GetTaskById(ctx, taskId)
+}
+
+// ListTask overlays the pending updates of the tenant on the MySQL rows. A
+// pending update may move a task in or out of the filter or along the order,
+// so the rows are read from the start of the list with room for every
+// pending task, then filtered, sorted and paged again.
+func (mgr *WriteBehindMgr) ListTask(ctx context.Context, limit, offset int, order string, filter models.TaskFilter) ([]models.Task, error) {
+	pending, err := mgr.pendingTasks(ctx)
+	if err != nil {
+		logger.GetLoggerWithContext(ctx, map[string]interface{}{
+			"error": err,
+		}).Warn("WriteBehind: read pending tasks fail")
+	}
+	if len(pending) == 0 {
+		return mgr.DataManager.ListTask(ctx, limit, offset, order, filter)
+	}
+
+	rows, err := mgr.DataManager.ListTask(ctx, offset+limit+len(pending), 0, order, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := make([]models.Task, 0, len(rows)+len(pending))
+	for _, task := range rows {
+		if _, ok := pending[task.ID]; !ok {
+			tasks = append(tasks, task)
+		}
+	}
+	ids := make([]uint64, 0, len(pending))
+	for id := range pending {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		if filter.Match(pending[id]) {
+			tasks = append(tasks, pending[id])
+		}
+	}
+
+	field, direction, _ := strings.Cut(order, " ")
+	utils.SortByField(tasks, field, direction == "desc")
+	if offset > len(tasks) {
+		offset = len(tasks)
+	}
+	return tasks[offset:min(offset+limit, len(tasks))], nil
+}
+
+// overlay replaces the tasks read from MySQL which have a pending update of
+// the tenant of ctx by their pending snapshot.
+func (mgr *WriteBehindMgr) overlay(ctx context.Context, tasks []models.Task) []models.Task {
+	if len(tasks) == 0 {
+		return tasks
+	}
+	fields := make([]string, len(tasks))
+	for i, task := range tasks {
+		fields[i] = strconv.FormatUint(task.ID, 10)
+	}
+	payloads, err := mgr.client.HMGet(ctx, mgr.pendingKey, fields...).Result()
+	if err != nil {
+		logger.GetLoggerWithContext(ctx, map[string]interface{}{
+			"error": err,
+		}).Warn("WriteBehind: read pending tasks fail")
+		return tasks
+	}
+
+	tenantId := TenantFromContext(ctx)
+	for i, payload := range payloads {
+		s, ok := payload.(string)
+		if !ok {
+			continue
+		}
+		task := models.Task{}
+		if err := json.Unmarshal([]byte(s), &task); err == nil && task.TenantID == tenantId {
+			tasks[i] = task
+		}
+	}
+	return tasks
+}
+
+// pendingDependencyMgr reads the tasks of a DependencyManager with the
+// pending updates of a WriteBehindMgr.
+type pendingDependencyMgr struct {
+	DependencyManager
+	writeBehind *WriteBehindMgr
+}
+
+// WithPendingUpdates returns dependencyMgr reading the tasks with the updates
+// mgr has not flushed to MySQL yet, so a task completing its last blocker a
+// moment ago is not blocked.
+func (mgr *WriteBehindMgr) WithPendingUpdates(dependencyMgr DependencyManager) DependencyManager {
+	return &pendingDependencyMgr{
+		DependencyManager: dependencyMgr,
+		writeBehind:       mgr,
+	}
+}
+
+func (mgr *pendingDependencyMgr) ListBlockers(ctx context.Context, taskId uint64) ([]models.Task, error) {
+	tasks, err := mgr.DependencyManager.ListBlockers(ctx, taskId)
+	if err != nil {
+		return nil, err
+	}
+	return mgr.writeBehind.overlay(ctx, tasks), nil
+}
+
+func (mgr *pendingDependencyMgr) ListTasksByIds(ctx context.Context, ids []uint64) ([]models.Task, error) {
+	tasks, err := mgr.DependencyManager.ListTasksByIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return mgr.writeBehind.overlay(ctx, tasks), nil
+}
+
+// pendingTasks returns the pending snapshots of the tenant of ctx by id.
+func (mgr *WriteBehindMgr) pendingTasks(ctx context.Context) (map[uint64]models.Task, error) {
+	payloads, err := mgr.client.HGetAll(ctx, mgr.pendingKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	tenantId := TenantFromContext(ctx)
+	tasks := map[uint64]models.Task{}
+	for _, payload := range payloads {
+		task := models.Task{}
+		if err := json.Unmarshal([]byte(payload), &task); err == nil && task.TenantID == tenantId {
+			tasks[task.ID] = task
+		}
+	}
+	return tasks, nil
+}
+
+func (mgr *WriteBehindMgr) DeleteTask(ctx context.Context, taskId uint64) error {
+	if err := mgr.DataManager.DeleteTask(ctx, taskId); err != nil {
+		return err
+	}
+
+	if err := mgr.client.HDel(ctx, mgr.pendingKey, strconv.FormatUint(taskId, 10)).Err(); err != nil {
+		return fmt.Errorf("DeleteTask: %v", err)
+	}
+	return nil
+}
+
+// Close flushes pending updates before closing the wrapped manager.
+func (mgr *WriteBehindMgr) Close(ctx context.Context) {
+	if err := mgr.Stop(ctx); err != nil {
+		logger.Errorf("Close WriteBehind : %s", err.Error())
+	}
+	mgr.DataManager.Close(ctx)
+}
+
+func (mgr *WriteBehindMgr) waitForCapacity(ctx context.Context) error {
+	if mgr.option.MaxPending <= 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(mgr.option.BackPressureTimeout)
+	for {
+		// Flushed entries are deleted, so the stream length is the backlog.
+		length, err := mgr.client.XLen(ctx, mgr.option.Stream).Result()
+		if err != nil {
+			return err
+		}
+		if length < mgr.option.MaxPending {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return ErrWriteBehindFull
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(mgr.option.FlushInterval / 10):
+		}
+	}
+}
+
+func (mgr *WriteBehindMgr) run() {
+	defer close(mgr.donec)
+
+	ctx := context.Background()
+	for {
+		select {
+		case <-mgr.stopc:
+			mgr.drain(ctx)
+			return
+		default:
+		}
+
+		if err := mgr.claimStale(ctx); err != nil {
+			logger.GetLoggerWithContext(ctx, map[string]interface{}{
+				"error": err,
+			}).Error("WriteBehind: claim stale updates fail")
+		}
+
+		// Unacknowledged entries of this consumer are retried before new ones.
+		flushed, err := mgr.flushNext(ctx, "0", -1)
+		if err == nil && flushed == 0 {
+			_, err = mgr.flushNext(ctx, ">", mgr.option.FlushInterval)
+		}
+		if err != nil {
+			logger.GetLoggerWithContext(ctx, map[string]interface{}{
+				"error": err,
+			}).Error("WriteBehind: flush fail")
+
+			select {
+			case <-mgr.stopc:
+			case <-time.After(writeBehindRetryInterval):
+			}
+		}
+	}
+}
+
+// drain flushes everything left in the stream, giving up on the first error
+// so shutdown is never blocked by an unavailable database.
+func (mgr *WriteBehindMgr) drain(ctx context.Context) {
+	for _, start := range []string{"0", ">"} {
+		for {
+			flushed, err := mgr.flushNext(ctx, start, -1)
+			if err != nil {
+				logger.GetLoggerWithContext(ctx, map[string]interface{}{
+					"error": err,
+				}).Error("WriteBehind: drain fail")
+				return
+			}
+			if flushed == 0 {
+				break
+			}
+		}
+	}
+}
+
+func (mgr *WriteBehindMgr) claimStale(ctx context.Context) error {
+	_, _, err := mgr.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   mgr.option.Stream,
+		Group:    mgr.option.Group,
+		Consumer: mgr.option.Consumer,
+		MinIdle:  mgr.option.ClaimIdle,
+		Start:    "0-0",
+		Count:    int64(mgr.option.BatchSize),
+	}).Result()
+	return err
+}
+
+// flushNext reads one batch starting at start, writes it to the wrapped
+// manager and acknowledges it. It returns how many entries were handled.
+func (mgr *WriteBehindMgr) flushNext(ctx context.Context, start string, block time.Duration) (int, error) {
+	streams, err := mgr.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    mgr.option.Group,
+		Consumer: mgr.option.Consumer,
+		Streams:  []string{mgr.option.Stream, start},
+		Count:    int64(mgr.option.BatchSize),
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("flushNext: %v", err)
+	}
+
+	var messages []redis.XMessage
+	for _, stream := range streams {
+		messages = append(messages, stream.Messages...)
+	}
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	tasks, payloads := coalesceTaskUpdates(messages)
+	if err := mgr.persist(ctx, tasks); err != nil {
+		return 0, fmt.Errorf("flushNext: %v", err)
+	}
+
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+
+	tx := mgr.client.TxPipeline()
+	tx.XAck(ctx, mgr.option.Stream, mgr.option.Group, ids...)
+	tx.XDel(ctx, mgr.option.Stream, ids...)
+	if _, err := tx.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("flushNext: %v", err)
+	}
+
+	for id, payload := range payloads {
+		if err := releasePendingScript.Run(ctx, mgr.client, []string{mgr.pendingKey}, id, payload).Err(); err != nil {
+			logger.GetLoggerWithContext(ctx, map[string]interface{}{
+				"error":  err,
+				"taskId": id,
+			}).Warn("WriteBehind: release pending task fail")
+		}
+	}
+
+	return len(messages), nil
+}
+
+func (mgr *WriteBehindMgr) persist(ctx context.Context, tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	if updater, ok := mgr.DataManager.(batchUpdater); ok {
+		return updater.BatchUpdateTask(ctx, tasks)
+	}
+
+	for i := range tasks {
+		if err := mgr.DataManager.UpdateTask(WithTenant(ctx, tasks[i].TenantID), &tasks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// coalesceTaskUpdates keeps the newest update per task, preferring the higher
+// version and, for equal versions, the entry appended last.
+func coalesceTaskUpdates(messages []redis.XMessage) ([]models.Task, map[string]string) {
+	latest := map[string]models.Task{}
+	payloads := map[string]string{}
+	var order []string
+
+	for _, message := range messages {
+		id, _ := message.Values["id"].(string)
+		payload, _ := message.Values["task"].(string)
+		if id == "" || payload == "" {
+			// Entries deleted from the stream come back without values.
+			continue
+		}
+
+		task := models.Task{}
+		if err := json.Unmarshal([]byte(payload), &task); err != nil {
+			logger.GetLoggerWithKeys(map[string]interface{}{
+				"error":     err,
+				"messageId": message.ID,
+			}).Error("WriteBehind: drop malformed update")
+			continue
+		}
+
+		current, ok := latest[id]
+		if !ok {
+			order = append(order, id)
+		} else if current.Version > task.Version {
+			continue
+		}
+		latest[id] = task
+		payloads[id] = payload
+	}
+
+	tasks := make([]models.Task, 0, len(order))
+	for _, id := range order {
+		tasks = append(tasks, latest[id])
+	}
+	return tasks, payloads
+}
@@ -0,0 +1,441 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"task_service/config"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"task_service/pkg/utils"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.SetLogger(zap.NewNop())
+	os.Exit(m.Run())
+}
+
+// fakeTaskStore keeps tasks in memory. Like the version check of the MySQL
+// BatchUpdateTask, it never replaces a task with an older version.
+type fakeTaskStore struct {
+	DataManager
+
+	mu      sync.Mutex
+	tasks   map[uint64]models.Task
+	batches [][]models.Task
+	err     error
+}
+
+func newFakeTaskStore(tasks ...models.Task) *fakeTaskStore {
+	store := &fakeTaskStore{tasks: map[uint64]models.Task{}}
+	for _, task := range tasks {
+		store.tasks[task.ID] = task
+	}
+	return store
+}
+
+func (store *fakeTaskStore) ListTask(ctx context.Context, limit, offset int, order string, filter models.TaskFilter) ([]models.Task, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	tasks := []models.Task{}
+	for _, task := range store.tasks {
+		if task.TenantID == TenantFromContext(ctx) && filter.Match(task) {
+			tasks = append(tasks, task)
+		}
+	}
+	slices.SortFunc(tasks, func(a, b models.Task) int { return int(a.ID) - int(b.ID) })
+	field, direction, _ := strings.Cut(order, " ")
+	utils.SortByField(tasks, field, direction == "desc")
+
+	offset = min(offset, len(tasks))
+	return tasks[offset:min(offset+limit, len(tasks))], nil
+}
+
+func (store *fakeTaskStore) GetTaskById(ctx context.Context, taskId uint64) (models.Task, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	task, ok := store.tasks[taskId]
+	if !ok || task.TenantID != TenantFromContext(ctx) {
+		return models.Task{}, fmt.Errorf("task %d not found", taskId)
+	}
+	return task, nil
+}
+
+func (store *fakeTaskStore) BatchUpdateTask(ctx context.Context, tasks []models.Task) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.err != nil {
+		return store.err
+	}
+	store.batches = append(store.batches, tasks)
+	for _, task := range tasks {
+		if current, ok := store.tasks[task.ID]; ok && current.Version > task.Version {
+			continue
+		}
+		store.tasks[task.ID] = task
+	}
+	return nil
+}
+
+func (store *fakeTaskStore) setErr(err error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.err = err
+}
+
+func (store *fakeTaskStore) get(taskId uint64) models.Task {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return store.tasks[taskId]
+}
+
+// newTestWriteBehind returns a WriteBehindMgr on a fake Redis with its
+// consumer group created, without running the flusher.
+func newTestWriteBehind(t *testing.T, store DataManager, option config.WriteBehindOption) (*WriteBehindMgr, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	if option.Consumer == "" {
+		option.Consumer = "a"
+	}
+	mgr := NewWriteBehindMgr(store, client, option)
+	require.NoError(t, client.XGroupCreateMkStream(context.Background(), mgr.option.Stream, mgr.option.Group, "0").Err())
+	return mgr, server
+}
+
+func updateMessage(t *testing.T, messageId string, task models.Task) redis.XMessage {
+	payload, err := json.Marshal(task)
+	require.NoError(t, err)
+	return redis.XMessage{
+		ID:     messageId,
+		Values: map[string]interface{}{"id": fmt.Sprint(task.ID), "task": string(payload)},
+	}
+}
+
+func TestCoalesceTaskUpdates(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages func(t *testing.T) []redis.XMessage
+		expected []models.Task
+	}{
+		{
+			name: "newest version wins",
+			messages: func(t *testing.T) []redis.XMessage {
+				return []redis.XMessage{
+					updateMessage(t, "1-0", models.Task{ID: 1, Name: "v1", Version: 1}),
+					updateMessage(t, "2-0", models.Task{ID: 2, Name: "other", Version: 1}),
+					updateMessage(t, "3-0", models.Task{ID: 1, Name: "v2", Version: 2}),
+				}
+			},
+			expected: []models.Task{
+				{ID: 1, Name: "v2", Version: 2},
+				{ID: 2, Name: "other", Version: 1},
+			},
+		},
+		{
+			name: "higher version appended first wins",
+			messages: func(t *testing.T) []redis.XMessage {
+				return []redis.XMessage{
+					updateMessage(t, "1-0", models.Task{ID: 1, Name: "v3", Version: 3}),
+					updateMessage(t, "2-0", models.Task{ID: 1, Name: "v2", Version: 2}),
+				}
+			},
+			expected: []models.Task{{ID: 1, Name: "v3", Version: 3}},
+		},
+		{
+			name: "equal versions keep the last appended",
+			messages: func(t *testing.T) []redis.XMessage {
+				return []redis.XMessage{
+					updateMessage(t, "1-0", models.Task{ID: 1, Name: "first", Version: 2}),
+					updateMessage(t, "2-0", models.Task{ID: 1, Name: "last", Version: 2}),
+				}
+			},
+			expected: []models.Task{{ID: 1, Name: "last", Version: 2}},
+		},
+		{
+			name: "deleted and malformed entries are skipped",
+			messages: func(t *testing.T) []redis.XMessage {
+				return []redis.XMessage{
+					{ID: "1-0"},
+					{ID: "2-0", Values: map[string]interface{}{"id": "1", "task": "{"}},
+					updateMessage(t, "3-0", models.Task{ID: 2, Name: "kept", Version: 1}),
+				}
+			},
+			expected: []models.Task{{ID: 2, Name: "kept", Version: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, payloads := coalesceTaskUpdates(tt.messages(t))
+			assert.Equal(t, tt.expected, tasks)
+			assert.Len(t, payloads, len(tt.expected))
+		})
+	}
+}
+
+func TestWriteBehindFlush(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+	store := newFakeTaskStore()
+	mgr, _ := newTestWriteBehind(t, store, config.WriteBehindOption{})
+
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 1, Name: "v1", Version: 1}))
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 1, Name: "v2", Version: 2}))
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 2, Name: "other", Version: 1}))
+
+	// pending updates are read back before they are flushed
+	task, err := mgr.GetTaskById(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", task.Name)
+
+	flushed, err := mgr.flushNext(ctx, ">", -1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, flushed)
+	require.Len(t, store.batches, 1)
+	assert.Len(t, store.batches[0], 2)
+	assert.Equal(t, "v2", store.get(1).Name)
+	assert.Equal(t, "acme", store.get(1).TenantID)
+
+	length, err := mgr.client.XLen(ctx, mgr.option.Stream).Result()
+	require.NoError(t, err)
+	assert.Zero(t, length)
+	pending, err := mgr.client.HLen(ctx, mgr.pendingKey).Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending)
+}
+
+func TestWriteBehindFlushRetry(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+	store := newFakeTaskStore()
+	mgr, _ := newTestWriteBehind(t, store, config.WriteBehindOption{})
+
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 1, Name: "v1", Version: 1}))
+
+	store.setErr(errors.New("database down"))
+	_, err := mgr.flushNext(ctx, ">", -1)
+	require.Error(t, err)
+
+	// the update is neither acknowledged nor released
+	length, err := mgr.client.XLen(ctx, mgr.option.Stream).Result()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, length)
+	task, err := mgr.GetTaskById(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", task.Name)
+
+	// nothing new, the unacknowledged update is retried from the start
+	store.setErr(nil)
+	flushed, err := mgr.flushNext(ctx, ">", -1)
+	require.NoError(t, err)
+	assert.Zero(t, flushed)
+	flushed, err = mgr.flushNext(ctx, "0", -1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, flushed)
+	assert.Equal(t, "v1", store.get(1).Name)
+}
+
+func TestWriteBehindClaimStale(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+	store := newFakeTaskStore()
+	option := config.WriteBehindOption{ClaimIdle: time.Minute}
+	first, server := newTestWriteBehind(t, store, option)
+	option.Consumer = "b"
+	second := NewWriteBehindMgr(store, first.client, option)
+
+	now := time.Now()
+	server.SetTime(now)
+	require.NoError(t, first.UpdateTask(ctx, &models.Task{ID: 1, Name: "v1", Version: 1}))
+	store.setErr(errors.New("database down"))
+	_, err := first.flushNext(ctx, ">", -1)
+	require.Error(t, err)
+	store.setErr(nil)
+
+	// the update is not idle long enough to be taken over
+	require.NoError(t, second.claimStale(ctx))
+	flushed, err := second.flushNext(ctx, "0", -1)
+	require.NoError(t, err)
+	assert.Zero(t, flushed)
+
+	server.SetTime(now.Add(2 * time.Minute))
+	require.NoError(t, second.claimStale(ctx))
+	flushed, err = second.flushNext(ctx, "0", -1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, flushed)
+	assert.Equal(t, "v1", store.get(1).Name)
+
+	// the first consumer has nothing left to retry
+	flushed, err = first.flushNext(ctx, "0", -1)
+	require.NoError(t, err)
+	assert.Zero(t, flushed)
+}
+
+func TestWriteBehindBackPressure(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+	store := newFakeTaskStore()
+	mgr, _ := newTestWriteBehind(t, store, config.WriteBehindOption{
+		MaxPending:          2,
+		BackPressureTimeout: 50 * time.Millisecond,
+		FlushInterval:       100 * time.Millisecond,
+	})
+
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 1, Version: 1}))
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 2, Version: 1}))
+
+	err := mgr.UpdateTask(ctx, &models.Task{ID: 3, Version: 1})
+	assert.ErrorIs(t, err, ErrWriteBehindFull)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	err = mgr.UpdateTask(cancelled, &models.Task{ID: 3, Version: 1})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// a flush makes room again
+	_, err = mgr.flushNext(ctx, ">", -1)
+	require.NoError(t, err)
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 3, Version: 1}))
+}
+
+func TestWriteBehindListOverlay(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+	store := newFakeTaskStore(
+		models.Task{ID: 1, TenantID: "acme", Name: "a", OwnerID: "alice", Version: 1},
+		models.Task{ID: 2, TenantID: "acme", Name: "b", OwnerID: "alice", Version: 1},
+		models.Task{ID: 3, TenantID: "acme", Name: "c", OwnerID: "alice", Version: 1},
+		models.Task{ID: 4, TenantID: "acme", Name: "d", OwnerID: "alice", Version: 1},
+		models.Task{ID: 5, TenantID: "acme", Name: "e", OwnerID: "bob", Version: 1},
+	)
+	mgr, _ := newTestWriteBehind(t, store, config.WriteBehindOption{})
+
+	// 1 moves to the end of the order, 2 out of the filter and 5 into it
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 1, Name: "z", OwnerID: "alice", Version: 2}))
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 2, Name: "b", OwnerID: "bob", Version: 2}))
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 5, Name: "e", OwnerID: "alice", Version: 2}))
+	// pending updates of other tenants are never listed
+	require.NoError(t, mgr.UpdateTask(WithTenant(ctx, "other"), &models.Task{ID: 6, Name: "0", OwnerID: "alice", Version: 1}))
+
+	ids := func(tasks []models.Task) []uint64 {
+		result := []uint64{}
+		for _, task := range tasks {
+			result = append(result, task.ID)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		limit    int
+		offset   int
+		order    string
+		expected []uint64
+	}{
+		{name: "first page", limit: 2, offset: 0, order: "name", expected: []uint64{3, 4}},
+		{name: "second page", limit: 2, offset: 2, order: "name", expected: []uint64{5, 1}},
+		{name: "descending", limit: 3, offset: 0, order: "name desc", expected: []uint64{1, 5, 4}},
+		{name: "past the end", limit: 2, offset: 10, order: "name", expected: []uint64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := mgr.ListTask(ctx, tt.limit, tt.offset, tt.order, models.TaskFilter{VisibleTo: "alice"})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ids(tasks))
+		})
+	}
+}
+
+// fakeDependencyStore returns the tasks of a store as the blockers of any
+// task.
+type fakeDependencyStore struct {
+	DependencyManager
+
+	store *fakeTaskStore
+}
+
+func (deps *fakeDependencyStore) ListBlockers(ctx context.Context, taskId uint64) ([]models.Task, error) {
+	return deps.store.ListTask(ctx, 100, 0, "id", models.TaskFilter{})
+}
+
+func (deps *fakeDependencyStore) ListTasksByIds(ctx context.Context, ids []uint64) ([]models.Task, error) {
+	tasks, err := deps.store.ListTask(ctx, 100, 0, "id", models.TaskFilter{})
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(tasks, func(task models.Task) bool { return !slices.Contains(ids, task.ID) }), nil
+}
+
+func TestWriteBehindPendingDependencies(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+	store := newFakeTaskStore(
+		models.Task{ID: 1, TenantID: "acme", Name: "a", Status: models.TaskStatusOpen, Version: 1},
+		models.Task{ID: 2, TenantID: "acme", Name: "b", Status: models.TaskStatusOpen, Version: 1},
+	)
+	mgr, _ := newTestWriteBehind(t, store, config.WriteBehindOption{})
+	deps := mgr.WithPendingUpdates(&fakeDependencyStore{store: store})
+
+	// 1 is done in the buffer only, the pending update of another tenant
+	// with the same id is ignored
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 1, Name: "a", Status: models.TaskStatusDone, Version: 2}))
+	require.NoError(t, mgr.UpdateTask(WithTenant(ctx, "other"), &models.Task{ID: 2, Name: "b", Status: models.TaskStatusDone, Version: 2}))
+	require.Equal(t, models.TaskStatusOpen, store.get(1).Status)
+
+	blockers, err := deps.ListBlockers(ctx, 3)
+	require.NoError(t, err)
+	require.Len(t, blockers, 2)
+	assert.Equal(t, models.TaskStatusDone, blockers[0].Status)
+	assert.Equal(t, models.TaskStatusOpen, blockers[1].Status)
+
+	tasks, err := deps.ListTasksByIds(ctx, []uint64{1})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, models.TaskStatusDone, tasks[0].Status)
+}
+
+func TestWriteBehindWriteThrough(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+	store := newFakeTaskStore(models.Task{ID: 1, TenantID: "acme", Name: "v0"})
+	mgr, _ := newTestWriteBehind(t, store, config.WriteBehindOption{})
+
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 1, Name: "v1", Version: 1}))
+	require.NoError(t, mgr.UpdateTask(WithWriteThrough(ctx), &models.Task{ID: 1, Name: "v2", Version: 2}))
+
+	// the update is in the store at once and no older snapshot shadows it
+	assert.Equal(t, "v2", store.get(1).Name)
+	task, err := mgr.GetTaskById(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", task.Name)
+
+	// flushing the older update from the stream does not bring it back
+	_, err = mgr.flushNext(ctx, ">", -1)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", store.get(1).Name)
+}
+
+func TestWriteBehindMinReplicas(t *testing.T) {
+	ctx := WithTenant(context.Background(), "acme")
+	store := newFakeTaskStore()
+	// the fake Redis has no replicas to acknowledge the update
+	mgr, _ := newTestWriteBehind(t, store, config.WriteBehindOption{MinReplicas: 1})
+
+	require.NoError(t, mgr.UpdateTask(ctx, &models.Task{ID: 1, Name: "v1", Version: 1}))
+
+	task, err := mgr.GetTaskById(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", task.Name)
+}
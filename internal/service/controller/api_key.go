@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"gorm.io/gorm"
+)
+
+// WithApiKeyManager enables the API key endpoints.
+func WithApiKeyManager(apiKeyMgr data.ApiKeyManager) Option {
+	return func(ctrl *Controller) {
+		ctrl.apiKeyMgr = apiKeyMgr
+	}
+}
+
+// @Summary create api key with the given roles, viewer by default, the key is only returned in this response
+// @router /task-service/api/v1/api-keys [post]
+// @param params body models.ApiKey true "api key"
+// @Success 200 {object} models.ApiKeyResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) CreateApiKey(ginc *gin.Context) {
+	principal := ctrl.principal(ginc)
+
+	req := models.ApiKey{}
+	if err := ginc.BindJSON(&req); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if req.Name == "" {
+		ctrl.handleError(ginc, fmt.Errorf("name is required"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		ctrl.handleError(ginc, fmt.Errorf("expires_at must be in the future"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	// a key gets the least privileged role unless asked for more, and
+	// never more than the caller holds
+	if len(req.Roles) == 0 {
+		req.Roles = []string{auth.RoleViewer}
+	}
+	for _, role := range req.Roles {
+		if !principal.CanGrant(role) {
+			ctrl.handleError(ginc, fmt.Errorf("role %q can not be granted", role), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+			return
+		}
+	}
+
+	key, hash, err := auth.GenerateApiKey()
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	apiKey := models.ApiKey{
+		Name:      req.Name,
+		Prefix:    key[:auth.ApiKeyDisplayLength],
+		KeyHash:   hash,
+		OwnerID:   principal.ID,
+		Roles:     req.Roles,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := ctrl.apiKeyMgr.CreateApiKey(ginc, &apiKey); err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("CreateApiKey fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+	apiKey.Key = key
+
+	ginc.JSON(http.StatusOK, models.ApiKeyResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.ApiKey{apiKey},
+	})
+}
+
+// @Summary list api keys of the caller
+// @router /task-service/api/v1/api-keys [get]
+// @Success 200 {object} models.ApiKeyResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) ListApiKey(ginc *gin.Context) {
+	keys, err := ctrl.apiKeyMgr.ListApiKey(ginc, ctrl.principal(ginc).ID)
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("ListApiKey fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.ApiKeyResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    keys,
+	})
+}
+
+// @Summary revoke api key
+// @router /task-service/api/v1/api-keys/{keyId} [delete]
+// @Param keyId path int true "api key ID"
+// @Success 200 {object} models.ApiKeyResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) RevokeApiKey(ginc *gin.Context) {
+	keyId, err := strconv.ParseUint(ginc.Param("keyId"), 10, 64)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	if err := ctrl.apiKeyMgr.RevokeApiKey(ginc, ctrl.principal(ginc).ID, keyId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctrl.handleError(ginc, err, http.StatusNotFound, code.Code_NOT_FOUND)
+			return
+		}
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.ApiKeyResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+	})
+}
+
+// principal returns the caller resolved by the auth middleware.
+func (ctrl *Controller) principal(ginc *gin.Context) *auth.Principal {
+	if principal, ok := auth.FromContext(ginc); ok {
+		return principal
+	}
+	return auth.Anonymous()
+}
@@ -0,0 +1,363 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/blob"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"gorm.io/gorm"
+)
+
+const (
+	// attachmentFormField is the multipart field holding the uploaded file.
+	attachmentFormField = "file"
+	// multipartOverhead bounds the headers and boundaries around the file.
+	multipartOverhead = 64 << 10
+	// maxAttachmentName is the size of the name column of the Attachment table.
+	maxAttachmentName = 255
+)
+
+// WithAttachments enables task attachments, with the content kept in store.
+// Uploads larger than maxSize bytes or whose sniffed type is not one of
+// allowedTypes are refused.
+func WithAttachments(attachmentMgr data.AttachmentManager, store blob.Store, maxSize int64, allowedTypes []string) Option {
+	return func(ctrl *Controller) {
+		if maxSize <= 0 {
+			maxSize = blob.DefaultMaxSize
+		}
+		ctrl.attachmentMgr = attachmentMgr
+		ctrl.blobStore = store
+		ctrl.attachmentMaxSize = maxSize
+		ctrl.attachmentTypes = allowedTypes
+	}
+}
+
+// @Summary list the attachments of a task
+// @router /task-service/api/v1/tasks/{taskId}/attachments [get]
+// @Param taskId path int true "task ID"
+// @Success 200 {object} models.AttachmentResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) ListAttachments(ginc *gin.Context) {
+	task, ok := ctrl.loadTask(ginc, auth.ActionRead)
+	if !ok {
+		return
+	}
+
+	attachments, err := ctrl.attachmentMgr.ListAttachments(ctrl.readContext(ginc), task.ID)
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("ListAttachments fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.AttachmentResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    attachments,
+	})
+}
+
+// @Summary attach a file to a task, uploading the same content twice returns the existing attachment
+// @router /task-service/api/v1/tasks/{taskId}/attachments [post]
+// @Accept multipart/form-data
+// @Param taskId path int true "task ID"
+// @Param file formData file true "file"
+// @Success 200 {object} models.AttachmentResponse
+// @Failure 400 {object} models.HttpError
+// @Failure 413 {object} models.HttpError
+// @Failure 415 {object} models.HttpError
+func (ctrl *Controller) UploadAttachment(ginc *gin.Context) {
+	if _, ok := ctrl.loadTask(ginc, auth.ActionUpdate); !ok {
+		return
+	}
+
+	// the upload is spooled before taking the lock, slow clients must not
+	// hold up other writes
+	upload, name, ok := ctrl.receiveUpload(ginc)
+	if !ok {
+		return
+	}
+	defer upload.Close()
+
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	// the task may have been deleted while the upload was received
+	task, ok := ctrl.loadTask(ginc, auth.ActionUpdate)
+	if !ok {
+		return
+	}
+	existing, err := ctrl.attachmentMgr.FindAttachment(ginc, task.ID, upload.SHA256)
+	if err == nil {
+		ctrl.respondAttachment(ginc, existing)
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	if !ctrl.storeBlob(ginc, upload) {
+		return
+	}
+	attachment := models.Attachment{
+		TaskID:      task.ID,
+		Name:        name,
+		ContentType: upload.ContentType,
+		Size:        upload.Size,
+		SHA256:      upload.SHA256,
+		UploaderID:  ctrl.principal(ginc).ID,
+	}
+	if err := ctrl.attachmentMgr.CreateAttachment(ginc, &attachment); err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("CreateAttachment fail")
+		ctrl.removeOrphanBlob(ginc, upload.SHA256)
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	ctrl.respondAttachment(ginc, attachment)
+}
+
+// @Summary download an attachment
+// @router /task-service/api/v1/tasks/{taskId}/attachments/{attachmentId} [get]
+// @Produce octet-stream
+// @Param taskId path int true "task ID"
+// @Param attachmentId path int true "attachment ID"
+// @Success 200 {file} file
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) DownloadAttachment(ginc *gin.Context) {
+	attachment, ok := ctrl.loadAttachment(ginc, auth.ActionRead)
+	if !ok {
+		return
+	}
+
+	content, err := ctrl.blobStore.Get(ginc, blobKey(attachment.TenantID, attachment.SHA256))
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error":        err,
+			"attachmentId": attachment.ID,
+		}).Error("read attachment blob fail")
+		if errors.Is(err, blob.ErrNotFound) {
+			ctrl.handleError(ginc, err, http.StatusNotFound, code.Code_NOT_FOUND)
+			return
+		}
+		ctrl.handleError(ginc, err, http.StatusBadGateway, code.Code_UNAVAILABLE)
+		return
+	}
+	defer content.Close()
+
+	ginc.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+// @Summary delete an attachment, its content is removed once no attachment refers to it
+// @router /task-service/api/v1/tasks/{taskId}/attachments/{attachmentId} [delete]
+// @Param taskId path int true "task ID"
+// @Param attachmentId path int true "attachment ID"
+// @Success 200 {object} models.AttachmentResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) DeleteAttachment(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	attachment, ok := ctrl.loadAttachment(ginc, auth.ActionUpdate)
+	if !ok {
+		return
+	}
+	if err := ctrl.attachmentMgr.DeleteAttachment(ginc, attachment.TaskID, attachment.ID); err != nil {
+		ctrl.handleAttachmentError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	ctrl.removeOrphanBlob(ginc, attachment.SHA256)
+
+	ginc.JSON(http.StatusOK, models.AttachmentResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+	})
+}
+
+// receiveUpload spools the file field of the multipart request, responding
+// with 413 when it is too large and 415 when its type is not allowed.
+func (ctrl *Controller) receiveUpload(ginc *gin.Context) (*blob.Upload, string, bool) {
+	ginc.Request.Body = http.MaxBytesReader(ginc.Writer, ginc.Request.Body, ctrl.attachmentMaxSize+multipartOverhead)
+	reader, err := ginc.Request.MultipartReader()
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return nil, "", false
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			ctrl.handleError(ginc, fmt.Errorf("multipart field %q is required", attachmentFormField), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+			return nil, "", false
+		}
+		if err != nil {
+			ctrl.handleUploadError(ginc, err)
+			return nil, "", false
+		}
+		if part.FormName() != attachmentFormField {
+			continue
+		}
+
+		upload, err := blob.Spool(part, ctrl.attachmentMaxSize)
+		if err != nil {
+			ctrl.handleUploadError(ginc, err)
+			return nil, "", false
+		}
+		if !blob.TypeAllowed(upload.ContentType, ctrl.attachmentTypes) {
+			upload.Close()
+			ctrl.handleError(ginc, fmt.Errorf("content type %s is not allowed", upload.ContentType), http.StatusUnsupportedMediaType, code.Code_INVALID_ARGUMENT)
+			return nil, "", false
+		}
+		return upload, attachmentName(part.FileName()), true
+	}
+}
+
+// storeBlob puts the content of upload in the blob store unless another
+// attachment of the tenant already has the same content.
+func (ctrl *Controller) storeBlob(ginc *gin.Context, upload *blob.Upload) bool {
+	references, err := ctrl.attachmentMgr.CountBlobReferences(ginc, upload.SHA256)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return false
+	}
+	if references > 0 {
+		return true
+	}
+
+	content, err := upload.Reader()
+	if err == nil {
+		err = ctrl.blobStore.Put(ginc, blobKey(data.TenantFromContext(ginc), upload.SHA256), content, upload.Size, upload.ContentType)
+	}
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("store attachment blob fail")
+		ctrl.handleError(ginc, err, http.StatusBadGateway, code.Code_UNAVAILABLE)
+		return false
+	}
+	return true
+}
+
+// removeOrphanBlob deletes the blob of sha256 once no attachment of the
+// tenant refers to it. A failure leaves the blob behind and is only logged.
+func (ctrl *Controller) removeOrphanBlob(ginc *gin.Context, sha256 string) {
+	references, err := ctrl.attachmentMgr.CountBlobReferences(ginc, sha256)
+	if err == nil && references == 0 {
+		err = ctrl.blobStore.Delete(ginc, blobKey(data.TenantFromContext(ginc), sha256))
+	}
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error":  err,
+			"sha256": sha256,
+		}).Error("remove attachment blob fail")
+	}
+}
+
+// deleteStoredTask deletes a task from MySQL together with the attachment
+// blobs only it referred to.
+func (ctrl *Controller) deleteStoredTask(ginc *gin.Context, taskId uint64) error {
+	var attachments []models.Attachment
+	if ctrl.attachmentMgr != nil {
+		var err error
+		if attachments, err = ctrl.attachmentMgr.ListAttachments(data.WithPrimary(ginc), taskId); err != nil {
+			return err
+		}
+	}
+	if err := ctrl.mysqlMgr.DeleteTask(ginc, taskId); err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		ctrl.removeOrphanBlob(ginc, attachment.SHA256)
+	}
+	return nil
+}
+
+// loadAttachment reads the attachment of the attachmentId path parameter
+// after checking the caller may perform action on its task.
+func (ctrl *Controller) loadAttachment(ginc *gin.Context, action auth.Action) (models.Attachment, bool) {
+	attachmentId, err := strconv.ParseUint(ginc.Param("attachmentId"), 10, 64)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return models.Attachment{}, false
+	}
+	task, ok := ctrl.loadTask(ginc, action)
+	if !ok {
+		return models.Attachment{}, false
+	}
+	attachment, err := ctrl.attachmentMgr.GetAttachment(data.WithPrimary(ginc), task.ID, attachmentId)
+	if err != nil {
+		ctrl.handleAttachmentError(ginc, err)
+		return models.Attachment{}, false
+	}
+	return attachment, true
+}
+
+func (ctrl *Controller) handleUploadError(ginc *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, blob.ErrTooLarge) || errors.As(err, &maxBytesErr) {
+		ctrl.handleError(ginc, fmt.Errorf("attachment is larger than %d bytes", ctrl.attachmentMaxSize), http.StatusRequestEntityTooLarge, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+}
+
+func (ctrl *Controller) handleAttachmentError(ginc *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctrl.handleError(ginc, err, http.StatusNotFound, code.Code_NOT_FOUND)
+		return
+	}
+	ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+}
+
+func (ctrl *Controller) respondAttachment(ginc *gin.Context, attachment models.Attachment) {
+	ginc.JSON(http.StatusOK, models.AttachmentResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.Attachment{attachment},
+	})
+}
+
+// blobKey returns the key of a blob, blobs are only shared within a tenant.
+func blobKey(tenantId, sha256 string) string {
+	return tenantId + "/" + sha256
+}
+
+// attachmentName keeps the base name of an uploaded file name.
+func attachmentName(fileName string) string {
+	name := path.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	if name == "." || name == "/" {
+		return "attachment"
+	}
+	if runes := []rune(name); len(runes) > maxAttachmentName {
+		name = string(runes[:maxAttachmentName])
+	}
+	return name
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"task_service/internal/data"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+)
+
+// cacheWarmPageSize is the number of tasks read from MySQL at a time when
+// loading the tasks of a tenant into the cache.
+const cacheWarmPageSize = 1000
+
+// cachedTenants is the set of tenants whose tasks are all in the cache. Only
+// their lists are served from the cache, which filters, sorts and pages
+// tasks itself and cannot tell which ones it misses.
+type cachedTenants struct {
+	mu      sync.Mutex
+	tenants map[string]bool
+	// generation changes with every reset, a load started before a reset
+	// does not mark its tenant
+	generation uint64
+}
+
+func (s *cachedTenants) has(tenantId string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tenants[tenantId]
+}
+
+// begin returns the generation a load of the tasks of a tenant starts in.
+func (s *cachedTenants) begin() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generation
+}
+
+// add marks tenantId unless the set was reset since generation.
+func (s *cachedTenants) add(tenantId string, generation uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if generation != s.generation {
+		return false
+	}
+	if s.tenants == nil {
+		s.tenants = map[string]bool{}
+	}
+	s.tenants[tenantId] = true
+	return true
+}
+
+func (s *cachedTenants) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tenants = nil
+	s.generation++
+}
+
+// warmListCache loads every task of the tenant of ctx from the primary into
+// the cache, then serves the lists of the tenant from the cache. The caller
+// holds the task lock, so no write can be missed meanwhile.
+func (ctrl *Controller) warmListCache(ctx context.Context) {
+	tenantId := data.TenantFromContext(ctx)
+	generation := ctrl.listCacheWarmed.begin()
+	primary := data.WithPrimary(ctx)
+
+	for offset := 0; ; offset += cacheWarmPageSize {
+		tasks, err := ctrl.mysqlMgr.ListTask(primary, cacheWarmPageSize, offset, "id", models.TaskFilter{})
+		if err != nil {
+			logger.GetLoggerWithContext(ctx, map[string]interface{}{
+				"error": err,
+			}).Error("warmListCache fail")
+			return
+		}
+		if err := ctrl.cacheMgr.CreateTask(ctx, tasks); err != nil {
+			logger.GetLoggerWithContext(ctx, map[string]interface{}{
+				"error": err,
+			}).Error("warmListCache fail")
+			return
+		}
+		if len(tasks) < cacheWarmPageSize {
+			break
+		}
+	}
+
+	if ctrl.listCacheWarmed.add(tenantId, generation) {
+		ctrl.enableGetCache.Store(true)
+	}
+}
@@ -0,0 +1,252 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/ical"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"gorm.io/gorm"
+)
+
+const (
+	// calendarOrder lists the tasks with a due date first, the feed stops
+	// at the first task without one.
+	calendarOrder    = "due_at desc"
+	calendarPageSize = 500
+	// maxCalendarTasks caps the size of a feed, the tasks due last are kept
+	maxCalendarTasks = 5000
+	maxCalendarQuery = 1000
+)
+
+// WithCalendarFeedManager enables the calendar feeds.
+func WithCalendarFeedManager(calendarFeedMgr data.CalendarFeedManager) Option {
+	return func(ctrl *Controller) {
+		ctrl.calendarFeedMgr = calendarFeedMgr
+	}
+}
+
+// @Summary create calendar feed of the tasks of the caller, the token is only returned in this response
+// @router /task-service/api/v1/calendar-feeds [post]
+// @param params body models.CalendarFeed true "calendar feed, query takes the filter parameters of GET /tasks"
+// @Success 200 {object} models.CalendarFeedResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) CreateCalendarFeed(ginc *gin.Context) {
+	principal := ctrl.principal(ginc)
+
+	req := models.CalendarFeed{}
+	if err := ginc.BindJSON(&req); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if req.Name == "" {
+		ctrl.handleError(ginc, fmt.Errorf("name is required"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if req.Component == "" {
+		req.Component = ical.ComponentEvent
+	}
+	if !ical.ValidComponent(req.Component) {
+		ctrl.handleError(ginc, fmt.Errorf("invalid component %q, expected %s or %s", req.Component, ical.ComponentEvent, ical.ComponentTodo),
+			http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	query, err := url.ParseQuery(req.Query)
+	if err != nil {
+		ctrl.handleError(ginc, fmt.Errorf("invalid query: %v", err), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if _, err := ctrl.parseTaskFilter(ctrl.readContext(ginc), principal, query, calendarOrder); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if len(query.Encode()) > maxCalendarQuery {
+		ctrl.handleError(ginc, fmt.Errorf("query is longer than %d characters", maxCalendarQuery), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	token, hash, err := auth.GenerateCalendarToken()
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	feed := models.CalendarFeed{
+		Name:      req.Name,
+		Prefix:    token[:auth.ApiKeyDisplayLength],
+		TokenHash: hash,
+		OwnerID:   principal.ID,
+		Query:     query.Encode(),
+		Component: req.Component,
+	}
+	if err := ctrl.calendarFeedMgr.CreateCalendarFeed(ginc, &feed); err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("CreateCalendarFeed fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+	feed.Token = token
+
+	ginc.JSON(http.StatusOK, models.CalendarFeedResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.CalendarFeed{feed},
+	})
+}
+
+// @Summary list calendar feeds of the caller
+// @router /task-service/api/v1/calendar-feeds [get]
+// @Success 200 {object} models.CalendarFeedResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) ListCalendarFeeds(ginc *gin.Context) {
+	feeds, err := ctrl.calendarFeedMgr.ListCalendarFeeds(ginc, ctrl.principal(ginc).ID)
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("ListCalendarFeeds fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.CalendarFeedResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    feeds,
+	})
+}
+
+// @Summary revoke calendar feed, its token stops working at once
+// @router /task-service/api/v1/calendar-feeds/{feedId} [delete]
+// @Param feedId path int true "calendar feed ID"
+// @Success 200 {object} models.CalendarFeedResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) RevokeCalendarFeed(ginc *gin.Context) {
+	feedId, err := strconv.ParseUint(ginc.Param("feedId"), 10, 64)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	if err := ctrl.calendarFeedMgr.RevokeCalendarFeed(ginc, ctrl.principal(ginc).ID, feedId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctrl.handleError(ginc, err, http.StatusNotFound, code.Code_NOT_FOUND)
+			return
+		}
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.CalendarFeedResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+	})
+}
+
+// @Summary iCalendar feed of the tasks with a due date, authenticated by the token of the feed
+// @router /task-service/api/v1/tasks/calendar.ics [get]
+// @Param token query string true "calendar feed token"
+// @Success 200 {string} string "text/calendar"
+// @Success 304
+// @Failure 401 {object} models.HttpError
+func (ctrl *Controller) CalendarFeed(ginc *gin.Context) {
+	feed := ginc.MustGet(c.ContextKeyCalendarFeed).(models.CalendarFeed)
+	ctx := ctrl.readContext(ginc)
+
+	query, err := url.ParseQuery(feed.Query)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	// the filter may have become invalid since, e.g. when a custom field is
+	// deleted
+	filter, err := ctrl.parseTaskFilter(ctx, ctrl.principal(ginc), query, calendarOrder)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	cal := ical.Calendar{
+		Name:      feed.Name,
+		Component: feed.Component,
+		Series:    map[uint64]models.TaskSeries{},
+	}
+	for offset := 0; offset < maxCalendarTasks; offset += calendarPageSize {
+		tasks, err := ctrl.mysqlMgr.ListTask(ctx, calendarPageSize, offset, calendarOrder, filter)
+		if err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+			}).Error("CalendarFeed fail")
+			ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+			return
+		}
+		cal.Tasks = append(cal.Tasks, tasks...)
+		if len(tasks) < calendarPageSize || tasks[len(tasks)-1].DueAt == nil {
+			break
+		}
+	}
+
+	for _, task := range cal.Tasks {
+		if task.SeriesID == nil || task.DueAt == nil || ctrl.seriesMgr == nil {
+			continue
+		}
+		if _, ok := cal.Series[*task.SeriesID]; ok {
+			continue
+		}
+		series, err := ctrl.seriesMgr.GetSeries(ctx, *task.SeriesID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// the occurrence is rendered on its own
+			continue
+		}
+		if err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error":    err,
+				"seriesId": *task.SeriesID,
+			}).Error("CalendarFeed fail")
+			ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+			return
+		}
+		cal.Series[series.ID] = series
+	}
+
+	body := &bytes.Buffer{}
+	if err := ical.Render(body, cal); err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	// the content is the same for the same tasks, so its ETag is stable
+	// across requests and replicas. Last-Modified is when the ETag last
+	// changed.
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	modifiedAt := time.Now().Truncate(time.Second)
+	if feed.ModifiedAt != nil && feed.ETag == etag {
+		modifiedAt = *feed.ModifiedAt
+	} else if err := ctrl.calendarFeedMgr.UpdateCalendarFeedETag(ginc, feed.ID, etag, modifiedAt); err != nil {
+		// the next request tries again, the feed is still served
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error":  err,
+			"feedId": feed.ID,
+		}).Warn("CalendarFeed fail")
+	}
+
+	ginc.Header("Content-Type", "text/calendar; charset=utf-8")
+	ginc.Header("ETag", etag)
+	// the URL holds a secret, shared caches must not keep the feed
+	ginc.Header("Cache-Control", "private, no-cache")
+	http.ServeContent(ginc.Writer, ginc.Request, "calendar.ics", modifiedAt, bytes.NewReader(body.Bytes()))
+}
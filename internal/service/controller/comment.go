@@ -0,0 +1,298 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/events"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"task_service/pkg/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"gorm.io/gorm"
+)
+
+// maxCommentLength is the longest comment body accepted, in characters.
+const maxCommentLength = 10000
+
+// WithCommentManager enables the comment threads of tasks.
+func WithCommentManager(commentMgr data.CommentManager) Option {
+	return func(ctrl *Controller) {
+		ctrl.commentMgr = commentMgr
+	}
+}
+
+// CommentRequest is the body of the create and update comment endpoints.
+type CommentRequest struct {
+	// Body is markdown, @id mentions a principal
+	Body string `json:"body"`
+}
+
+// @Summary list the comments of a task, oldest first
+// @router /task-service/api/v1/tasks/{taskId}/comments [get]
+// @Param taskId path int true "task ID"
+// @Param limit query int false "limit"
+// @Param offset query int false "offset"
+// @Success 200 {object} models.CommentResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) ListComments(ginc *gin.Context) {
+	task, ok := ctrl.loadTask(ginc, auth.ActionRead)
+	if !ok {
+		return
+	}
+	limit, offset := ctrl.extractPaginationParams(ginc)
+
+	comments, err := ctrl.commentMgr.ListComments(ctrl.readContext(ginc), task.ID, limit, offset)
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("ListComments fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.CommentResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    comments,
+	})
+}
+
+// @Summary comment on a task, anyone who can read the task may comment
+// @router /task-service/api/v1/tasks/{taskId}/comments [post]
+// @Param taskId path int true "task ID"
+// @param params body CommentRequest true "comment"
+// @Success 200 {object} models.CommentResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) CreateComment(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	request := CommentRequest{}
+	if err := ginc.BindJSON(&request); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	task, ok := ctrl.loadTask(ginc, auth.ActionRead)
+	if !ok {
+		return
+	}
+	comment := models.Comment{
+		TaskID:   task.ID,
+		AuthorID: ctrl.principal(ginc).ID,
+	}
+	if !ctrl.setCommentBody(ginc, &comment, request.Body) {
+		return
+	}
+
+	if err := ctrl.commentMgr.CreateComment(ginc, &comment); err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("CreateComment fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+
+	if task, ok := ctrl.refreshCommentCount(ginc, task.ID); ok {
+		ctrl.publishEvent(ginc, events.NewCommentEvent(task, comment, time.Now()))
+	}
+	ctrl.respondComment(ginc, comment)
+}
+
+// @Summary edit a comment, only its author or an admin may edit it
+// @router /task-service/api/v1/tasks/{taskId}/comments/{commentId} [put]
+// @Param taskId path int true "task ID"
+// @Param commentId path int true "comment ID"
+// @param params body CommentRequest true "comment"
+// @Success 200 {object} models.CommentResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) UpdateComment(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	request := CommentRequest{}
+	if err := ginc.BindJSON(&request); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	comment, ok := ctrl.loadComment(ginc, true)
+	if !ok {
+		return
+	}
+	if !ctrl.setCommentBody(ginc, &comment, request.Body) {
+		return
+	}
+
+	if err := ctrl.commentMgr.UpdateComment(ginc, &comment, ctrl.principal(ginc).ID); err != nil {
+		ctrl.handleCommentError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	ctrl.respondComment(ginc, comment)
+}
+
+// @Summary delete a comment, only its author or an admin may delete it
+// @router /task-service/api/v1/tasks/{taskId}/comments/{commentId} [delete]
+// @Param taskId path int true "task ID"
+// @Param commentId path int true "comment ID"
+// @Success 200 {object} models.CommentResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) DeleteComment(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	comment, ok := ctrl.loadComment(ginc, true)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.commentMgr.DeleteComment(ginc, comment.TaskID, comment.ID); err != nil {
+		ctrl.handleCommentError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	ctrl.refreshCommentCount(ginc, comment.TaskID)
+
+	ginc.JSON(http.StatusOK, models.CommentResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+	})
+}
+
+// @Summary list the previous bodies of an edited comment, oldest first
+// @router /task-service/api/v1/tasks/{taskId}/comments/{commentId}/revisions [get]
+// @Param taskId path int true "task ID"
+// @Param commentId path int true "comment ID"
+// @Success 200 {object} models.CommentRevisionResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) ListCommentRevisions(ginc *gin.Context) {
+	comment, ok := ctrl.loadComment(ginc, false)
+	if !ok {
+		return
+	}
+
+	revisions, err := ctrl.commentMgr.ListCommentRevisions(ctrl.readContext(ginc), comment.ID)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.CommentRevisionResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    revisions,
+	})
+}
+
+// setCommentBody sets the body of comment and the principals it mentions,
+// responding with 400 when the body is empty or too long.
+func (ctrl *Controller) setCommentBody(ginc *gin.Context, comment *models.Comment, body string) bool {
+	if strings.TrimSpace(body) == "" {
+		ctrl.handleError(ginc, fmt.Errorf("comment body is required"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+	if len([]rune(body)) > maxCommentLength {
+		ctrl.handleError(ginc, fmt.Errorf("comment body is longer than %d characters", maxCommentLength), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+
+	// mentions of unknown principals stay in the body as plain text
+	mentions, err := ctrl.commentMgr.ResolvePrincipals(data.WithPrimary(ginc), utils.ParseMentions(body))
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return false
+	}
+	comment.Body = body
+	comment.Mentions = mentions
+	return true
+}
+
+// loadComment reads the comment of the commentId path parameter after
+// checking the caller may read its task. With modify, only the author of the
+// comment or an admin passes.
+func (ctrl *Controller) loadComment(ginc *gin.Context, modify bool) (models.Comment, bool) {
+	commentId, err := strconv.ParseUint(ginc.Param("commentId"), 10, 64)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return models.Comment{}, false
+	}
+	task, ok := ctrl.loadTask(ginc, auth.ActionRead)
+	if !ok {
+		return models.Comment{}, false
+	}
+	comment, err := ctrl.commentMgr.GetComment(ginc, task.ID, commentId)
+	if err != nil {
+		ctrl.handleCommentError(ginc, err)
+		return models.Comment{}, false
+	}
+
+	principal := ctrl.principal(ginc)
+	if modify && comment.AuthorID != principal.ID && !principal.IsAdmin() {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"principal": principal.ID,
+			"commentId": comment.ID,
+		}).Warn("permission denied")
+		ctrl.handleError(ginc, fmt.Errorf("permission denied: only the author may change a comment"), http.StatusForbidden, code.Code_PERMISSION_DENIED)
+		return models.Comment{}, false
+	}
+	return comment, true
+}
+
+// refreshCommentCount writes the task with its new comment count to the
+// cache and returns it.
+func (ctrl *Controller) refreshCommentCount(ginc *gin.Context, taskId uint64) (models.Task, bool) {
+	task, err := ctrl.mysqlMgr.GetTaskById(data.WithPrimary(ginc), taskId)
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error":  err,
+			"taskId": taskId,
+		}).Error("reload commented task fail")
+		ctrl.ResetCache()
+		return models.Task{}, false
+	}
+	if err := ctrl.cacheMgr.UpdateTask(ginc, &task); err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error":  err,
+			"taskId": taskId,
+		}).Error("update commented task in cache fail")
+		ctrl.ResetCache()
+	}
+	return task, true
+}
+
+func (ctrl *Controller) handleCommentError(ginc *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctrl.handleError(ginc, err, http.StatusNotFound, code.Code_NOT_FOUND)
+		return
+	}
+	logger.GetLoggerWithContext(ginc, map[string]interface{}{
+		"error": err,
+	}).Error("comment operation fail")
+	ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+}
+
+func (ctrl *Controller) respondComment(ginc *gin.Context, comment models.Comment) {
+	ginc.JSON(http.StatusOK, models.CommentResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.Comment{comment},
+	})
+}
@@ -2,14 +2,24 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"task_service/c"
 	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/blob"
+	"task_service/pkg/events"
+	"task_service/pkg/health"
 	"task_service/pkg/logger"
+	"task_service/pkg/metrics"
 	"task_service/pkg/models"
+	"task_service/pkg/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"google.golang.org/genproto/googleapis/rpc/code"
@@ -18,19 +28,53 @@ import (
 type Controller struct {
 	mysqlMgr        data.DataManager
 	cacheMgr        data.DataManager
-	enableListCache bool
-	enableGetCache  bool
+	listCacheWarmed cachedTenants
+	enableGetCache  atomic.Bool
 	shuntDownOnce   sync.Once
+	primaryPins     *primaryPins
+	apiKeyMgr       data.ApiKeyManager
+	tenantMgr       data.TenantManager
+	healthChecker   *health.Checker
+	eventBroker     *events.Broker
+	eventSink       events.Sink
+	seriesMgr       data.SeriesManager
+	hierarchyMgr    data.HierarchyManager
+	dependencyMgr   data.DependencyManager
+	tagMgr          data.TagManager
+	commentMgr      data.CommentManager
+	rankMgr         data.RankManager
+	fieldMgr        data.FieldManager
+	templateMgr     data.TemplateManager
+	attachmentMgr   data.AttachmentManager
+	blobStore       blob.Store
+	// attachmentMaxSize and attachmentTypes limit what may be uploaded
+	attachmentMaxSize int64
+	attachmentTypes   []string
+	calendarFeedMgr   data.CalendarFeedManager
+	importMgr         data.ImportManager
+	importMaxSize     int64
+	importMaxRows     int
+	// importCtx is cancelled by Shutdown to interrupt the running imports,
+	// imports tracks them
+	importCtx   context.Context
+	stopImports context.CancelFunc
+	imports     sync.WaitGroup
 }
 
-func NewController(mysqlMgr, cacheMgr data.DataManager) *Controller {
-	return &Controller{
-		mysqlMgr:        mysqlMgr,
-		cacheMgr:        cacheMgr,
-		enableListCache: false,
-		enableGetCache:  false,
-		shuntDownOnce:   sync.Once{},
+// Option configures optional behaviour of the Controller
+type Option func(ctrl *Controller)
+
+func NewController(mysqlMgr, cacheMgr data.DataManager, opts ...Option) *Controller {
+	ctrl := &Controller{
+		mysqlMgr:      mysqlMgr,
+		cacheMgr:      cacheMgr,
+		shuntDownOnce: sync.Once{},
+		primaryPins:   newPrimaryPins(0, ""),
+	}
+	for _, opt := range opts {
+		opt(ctrl)
 	}
+	return ctrl
 }
 
 // @Summary list tasks
@@ -38,29 +82,44 @@ func NewController(mysqlMgr, cacheMgr data.DataManager) *Controller {
 // @Param limit query int false "limit"
 // @Param offset query int false "offset"
 // @Param order query string false "order"
+// @Param due_before query string false "only tasks due before this RFC3339 time"
+// @Param due_after query string false "only tasks due at or after this RFC3339 time"
+// @Param overdue query bool false "only open tasks past their due date"
+// @Param tags query string false "comma separated tag names"
+// @Param tag_mode query string false "all (default) or any of the tags"
+// @Param field.{key} query string false "only tasks whose custom field key has this value"
 // @Success 200 {object} models.Response
 // @Failure 400 {object} models.HttpError
 func (ctrl *Controller) ListTask(ginc *gin.Context) {
 
-	if lock, err := ctrl.cacheMgr.Lock(ginc, c.LockKey, 60); err != nil || lock != true {
-		ctrl.handleError(ginc, err, http.StatusLocked, code.Code_INTERNAL)
+	release, ok := ctrl.lock(ginc)
+	if !ok {
 		return
 	}
-	defer ctrl.cacheMgr.ReleaseLock(ginc, c.LockKey)
+	defer release()
 
-	limit, offset, order := ctrl.extractPaginationParams(ginc)
+	limit, offset := ctrl.extractPaginationParams(ginc)
+	order, err := ctrl.extractOrder(ginc)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	filter, err := ctrl.extractTaskFilter(ginc, order)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
 
-	if ctrl.enableListCache {
-		tasks, err := ctrl.cacheMgr.ListTask(ginc, limit, offset, order)
+	tenantId := data.TenantFromContext(ginc)
+	if ctrl.listCacheWarmed.has(tenantId) {
+		tasks, err := ctrl.cacheMgr.ListTask(ginc, limit, offset, order, filter)
 		if err != nil {
-			logger.GetLoggerWithKeys(map[string]interface{}{
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
 				"error": err,
-			}).Error("ListTask fail")
-			ctrl.handleError(ginc, err, http.StatusLocked, code.Code_INTERNAL)
-			return
+			}).Error("ListTask from cache fail")
 		}
 
-		if len(tasks) != 0 {
+		if err == nil && len(tasks) != 0 {
 			ginc.JSON(http.StatusOK, models.Response{
 				Code:    code.Code_OK,
 				Message: c.Success,
@@ -70,20 +129,19 @@ func (ctrl *Controller) ListTask(ginc *gin.Context) {
 		}
 	}
 
-	tasks, err := ctrl.mysqlMgr.ListTask(ginc, limit, offset, order)
+	tasks, err := ctrl.mysqlMgr.ListTask(ctrl.readContext(ginc), limit, offset, order, filter)
 	if err != nil {
-		logger.GetLoggerWithKeys(map[string]interface{}{
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
 			"error": err,
 		}).Error("ListTask fail")
 		ctrl.handleError(ginc, err, http.StatusLocked, code.Code_INTERNAL)
 		return
 	}
 
-	if !ctrl.enableListCache {
-		if err := ctrl.cacheMgr.CreateTask(ginc, tasks); err == nil {
-			ctrl.enableListCache = true
-			ctrl.enableGetCache = true
-		}
+	// the page is filtered and paginated, the cache is only filled with
+	// all the tasks of the tenant
+	if !ctrl.listCacheWarmed.has(tenantId) {
+		ctrl.warmListCache(ginc)
 	}
 
 	ginc.JSON(http.StatusOK, models.Response{
@@ -100,32 +158,35 @@ func (ctrl *Controller) ListTask(ginc *gin.Context) {
 // @Failure 400 {object} models.HttpError
 func (ctrl *Controller) GetTask(ginc *gin.Context) {
 
-	if lock, err := ctrl.cacheMgr.Lock(ginc, c.LockKey, 60); err != nil || lock != true {
-		ctrl.handleError(ginc, err, http.StatusLocked, code.Code_INTERNAL)
+	release, ok := ctrl.lock(ginc)
+	if !ok {
 		return
 	}
-	defer ctrl.cacheMgr.ReleaseLock(ginc, c.LockKey)
+	defer release()
 
 	taskIdStr := ginc.Param("taskId")
 
 	taskId, err := strconv.ParseUint(taskIdStr, 10, 64)
 	if err != nil {
-		logger.GetLoggerWithKeys(map[string]interface{}{
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
 			"error": err,
 		}).Error("GetTask fail")
 		ctrl.handleError(ginc, err, http.StatusLocked, code.Code_INTERNAL)
 		return
 	}
 
-	if ctrl.enableGetCache {
+	if ctrl.enableGetCache.Load() {
 		task, err := ctrl.cacheMgr.GetTaskById(ginc, taskId)
 		if err != nil {
-			logger.GetLoggerWithKeys(map[string]interface{}{
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
 				"error": err,
 			}).Error("GetTask fail")
 		}
 
 		if task.ID != 0 {
+			if !ctrl.authorize(ginc, auth.ActionRead, &task) {
+				return
+			}
 			ginc.JSON(http.StatusOK, models.Response{
 				Code:    code.Code_OK,
 				Message: c.Success,
@@ -135,21 +196,25 @@ func (ctrl *Controller) GetTask(ginc *gin.Context) {
 		}
 	}
 
-	task, err := ctrl.mysqlMgr.GetTaskById(ginc, taskId)
+	task, err := ctrl.mysqlMgr.GetTaskById(ctrl.readContext(ginc), taskId)
 	if err != nil {
-		logger.GetLoggerWithKeys(map[string]interface{}{
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
 			"error": err,
 		}).Error("GetTask fail")
 		ctrl.handleError(ginc, err, http.StatusLocked, code.Code_INTERNAL)
 		return
 	}
 
+	if !ctrl.authorize(ginc, auth.ActionRead, &task) {
+		return
+	}
+
 	if err := ctrl.cacheMgr.CreateTask(ginc, []models.Task{task}); err != nil {
-		logger.GetLoggerWithKeys(map[string]interface{}{
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
 			"error": err,
 		}).Error("insert task into cache fail")
 	} else {
-		ctrl.checkTaskVersion(ginc, task.ID, task.Version)
+		ctrl.checkTaskVersion(ginc, task)
 	}
 
 	ginc.JSON(http.StatusOK, models.Response{
@@ -167,11 +232,11 @@ func (ctrl *Controller) GetTask(ginc *gin.Context) {
 // @Failure 400 {object} models.HttpError
 func (ctrl *Controller) CreateTask(ginc *gin.Context) {
 
-	if lock, err := ctrl.cacheMgr.Lock(ginc, c.LockKey, 60); err != nil || lock != true {
-		ctrl.handleError(ginc, err, http.StatusLocked, code.Code_INTERNAL)
+	release, ok := ctrl.lock(ginc)
+	if !ok {
 		return
 	}
-	defer ctrl.cacheMgr.ReleaseLock(ginc, c.LockKey)
+	defer release()
 
 	task := models.Task{}
 	if err := ginc.BindJSON(&task); err != nil {
@@ -179,51 +244,100 @@ func (ctrl *Controller) CreateTask(ginc *gin.Context) {
 		return
 	}
 
-	condition := map[string]interface{}{
-		"name": task.Name,
+	principal := ctrl.principal(ginc)
+	if !ctrl.authorize(ginc, auth.ActionCreate, nil) {
+		return
 	}
-	if task.Tag != "" {
-		condition["tag"] = task.Tag
+	if !ctrl.checkTaskQuota(ginc, 1) {
+		return
 	}
-
-	if err := ctrl.mysqlMgr.CheckTaskExist(ginc, condition, &task); err != nil {
-		logger.GetLoggerWithKeys(map[string]interface{}{
-			"error": err,
-		}).Error("ListTask fail")
-		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INTERNAL)
+	// Only admins may create tasks on behalf of another owner.
+	if task.OwnerID == "" || !principal.IsAdmin() {
+		task.OwnerID = principal.ID
+	}
+	if task.ParentID != nil {
+		if ctrl.hierarchyMgr == nil || task.Recurrence != nil {
+			ctrl.handleError(ginc, fmt.Errorf("subtasks are not supported"), http.StatusBadRequest, code.Code_UNIMPLEMENTED)
+			return
+		}
+		if !ctrl.checkParent(ginc, task, *task.ParentID) {
+			return
+		}
+	}
+	task.Progress = utils.Progress(task.Status, nil)
+	// the rank is only set by moving the task
+	task.Rank = ""
+	// only the occurrence scheduler links a task to a series
+	task.SeriesID = nil
+	task.OccurrenceAt = nil
+	// a new task has no comments yet
+	task.CommentCount = 0
+	if !ctrl.checkPriority(ginc, task.Priority) {
 		return
 	}
 
-	if task.ID != 0 {
-		ctrl.handleError(ginc, fmt.Errorf("task is exist"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+	if task.Tags, ok = ctrl.checkTags(ginc, task.Tags); !ok {
 		return
 	}
-
-	if err := ctrl.mysqlMgr.CreateTask(ginc, []models.Task{task}); err != nil {
-		logger.GetLoggerWithKeys(map[string]interface{}{
-			"error": err,
-		}).Error("ListTask fail")
-		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+	if task.Fields, ok = ctrl.checkFields(ginc, task.Fields); !ok {
 		return
 	}
 
-	if err := ctrl.mysqlMgr.CheckTaskExist(ginc, condition, &task); err != nil {
-		logger.GetLoggerWithKeys(map[string]interface{}{
+	existing, err := ctrl.findTask(ginc, task.Name, task.Tags)
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
 			"error": err,
 		}).Error("ListTask fail")
 		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INTERNAL)
 		return
 	}
 
+	if existing.ID != 0 {
+		ctrl.handleError(ginc, fmt.Errorf("task is exist"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	if task.Recurrence != nil {
+		if ctrl.seriesMgr == nil {
+			ctrl.handleError(ginc, fmt.Errorf("recurring tasks are not enabled"), http.StatusBadRequest, code.Code_UNIMPLEMENTED)
+			return
+		}
+		if err := ctrl.createTaskSeries(ginc, &task); err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+			}).Error("create task series fail")
+			ctrl.handleSeriesError(ginc, err)
+			return
+		}
+		ctrl.pinPrimary(ginc)
+	} else {
+		if err := ctrl.mysqlMgr.CreateTask(ginc, []models.Task{task}); err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+			}).Error("ListTask fail")
+			ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+			return
+		}
+		ctrl.pinPrimary(ginc)
+
+		if task, err = ctrl.findTask(ginc, task.Name, task.Tags); err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+			}).Error("ListTask fail")
+			ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INTERNAL)
+			return
+		}
+	}
+
 	if err := ctrl.cacheMgr.CreateTask(ginc, []models.Task{task}); err != nil {
-		logger.GetLoggerWithKeys(map[string]interface{}{
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
 			"error": err,
 		}).Error("insert task into cache fail")
-		ctrl.enableGetCache = false
-		ctrl.enableListCache = false
+		ctrl.ResetCache()
 	} else {
-		ctrl.checkTaskVersion(ginc, task.ID, task.Version)
+		ctrl.checkTaskVersion(ginc, task)
 	}
+	ctrl.rollupProgress(ginc, task.ParentID)
 
 	ginc.JSON(http.StatusOK, models.Response{
 		Code:    code.Code_OK,
@@ -235,15 +349,17 @@ func (ctrl *Controller) CreateTask(ginc *gin.Context) {
 // @Summary delete task
 // @router /task-service/api/v1/tasks/{taskId} [delete]
 // @Param taskId path int true "task ID"
+// @Param scope query string false "this (default) or future occurrences of a recurring task"
+// @Param children query string false "reparent (default) moves the subtasks to the parent, cascade deletes them"
 // @Success 200 {object} models.Response
 // @Failure 400 {object} models.HttpError
 func (ctrl *Controller) DeleteTask(ginc *gin.Context) {
 
-	if lock, err := ctrl.cacheMgr.Lock(ginc, c.LockKey, 60); err != nil || lock != true {
-		ctrl.handleError(ginc, err, http.StatusLocked, code.Code_INTERNAL)
+	release, ok := ctrl.lock(ginc)
+	if !ok {
 		return
 	}
-	defer ctrl.cacheMgr.ReleaseLock(ginc, c.LockKey)
+	defer release()
 
 	taskIdStr := ginc.Param("taskId")
 
@@ -253,18 +369,53 @@ func (ctrl *Controller) DeleteTask(ginc *gin.Context) {
 		return
 	}
 
+	targetTask, err := ctrl.mysqlMgr.GetTaskById(data.WithPrimary(ginc), taskId)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INTERNAL)
+		return
+	}
+	if !ctrl.authorize(ginc, auth.ActionDelete, &targetTask) {
+		return
+	}
+	scope, ok := ctrl.editScope(ginc, targetTask)
+	if !ok {
+		return
+	}
+	mode, ok := ctrl.childrenMode(ginc)
+	if !ok {
+		return
+	}
+	if ctrl.hierarchyMgr != nil && !ctrl.detachChildren(ginc, targetTask, mode) {
+		return
+	}
+	if targetTask.SeriesID != nil && ctrl.seriesMgr != nil {
+		// a deleted occurrence is skipped rather than created again
+		if scope == scopeFuture {
+			err = ctrl.endSeriesAt(ginc, targetTask)
+		} else {
+			err = ctrl.skipOccurrence(ginc, targetTask)
+		}
+		if err != nil {
+			ctrl.handleSeriesError(ginc, err)
+			return
+		}
+	}
+
 	if err := ctrl.cacheMgr.DeleteTask(ginc, taskId); err != nil {
-		logger.GetLoggerWithKeys(map[string]interface{}{
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
 			"error": err,
 		}).Error("delete task from cache fail")
-		ctrl.enableGetCache = false
-		ctrl.enableListCache = false
+		ctrl.ResetCache()
 	}
 
-	if err := ctrl.mysqlMgr.DeleteTask(ginc, taskId); err != nil {
+	if err := ctrl.deleteStoredTask(ginc, taskId); err != nil {
 		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
 		return
 	}
+	ctrl.pinPrimary(ginc)
+	if ctrl.hierarchyMgr != nil {
+		ctrl.rollupProgress(ginc, targetTask.ParentID)
+	}
 
 	ginc.JSON(http.StatusOK, models.Response{
 		Code:    code.Code_OK,
@@ -275,16 +426,17 @@ func (ctrl *Controller) DeleteTask(ginc *gin.Context) {
 // @Summary update task
 // @router /task-service/api/v1/tasks/{taskId} [put]
 // @Param taskId path int true "task ID"
+// @Param scope query string false "this (default) or future occurrences of a recurring task"
 // @param params body models.Task true "task"
 // @Success 200 {object} models.Response
 // @Failure 400 {object} models.HttpError
 func (ctrl *Controller) UpdateTask(ginc *gin.Context) {
 
-	if lock, err := ctrl.cacheMgr.Lock(ginc, c.LockKey, 60); err != nil || lock != true {
-		ctrl.handleError(ginc, err, http.StatusLocked, code.Code_INTERNAL)
+	release, ok := ctrl.lock(ginc)
+	if !ok {
 		return
 	}
-	defer ctrl.cacheMgr.ReleaseLock(ginc, c.LockKey)
+	defer release()
 
 	taskIdStr := ginc.Param("taskId")
 
@@ -301,31 +453,109 @@ func (ctrl *Controller) UpdateTask(ginc *gin.Context) {
 		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INTERNAL)
 		return
 	}
-	targetTask, err := ctrl.mysqlMgr.GetTaskById(ginc, taskId)
+	targetTask, err := ctrl.mysqlMgr.GetTaskById(data.WithPrimary(ginc), taskId)
 	if err != nil {
 		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INTERNAL)
 		return
 	}
 
+	if !ctrl.authorize(ginc, auth.ActionUpdate, &targetTask) {
+		return
+	}
+	scope, ok := ctrl.editScope(ginc, targetTask)
+	if !ok {
+		return
+	}
+	if scope == scopeFuture && ctrl.seriesMgr == nil {
+		ctrl.handleError(ginc, fmt.Errorf("recurring tasks are not enabled"), http.StatusBadRequest, code.Code_UNIMPLEMENTED)
+		return
+	}
+	wasOpen := targetTask.Status == models.TaskStatusOpen
+	if ctrl.dependencyMgr != nil && !ctrl.checkBlockers(ginc, targetTask, task.Status) {
+		return
+	}
+	if task.OwnerID != "" && task.OwnerID != targetTask.OwnerID {
+		if !ctrl.authorize(ginc, auth.ActionChangeOwner, &targetTask) {
+			return
+		}
+		targetTask.OwnerID = task.OwnerID
+	}
+
+	targetTask.AssigneeID = task.AssigneeID
 	targetTask.Name = task.Name
 	targetTask.Content = task.Content
-	targetTask.Tag = task.Tag
+	if !ctrl.checkPriority(ginc, task.Priority) {
+		return
+	}
+	targetTask.Priority = task.Priority
+	if targetTask.Tags, ok = ctrl.checkTags(ginc, task.Tags); !ok {
+		return
+	}
+	if targetTask.Fields, ok = ctrl.checkFields(ginc, task.Fields); !ok {
+		return
+	}
 	targetTask.Version += 1
 	targetTask.Status = task.Status
+	targetTask.DueAt = task.DueAt
+	targetTask.RemindAt = task.RemindAt
+
+	oldParentId := targetTask.ParentID
+	parentChanged := !equalId(task.ParentID, targetTask.ParentID)
+	if parentChanged && task.ParentID != nil {
+		if ctrl.hierarchyMgr == nil {
+			ctrl.handleError(ginc, fmt.Errorf("subtasks are not supported"), http.StatusBadRequest, code.Code_UNIMPLEMENTED)
+			return
+		}
+		if !ctrl.checkParent(ginc, targetTask, *task.ParentID) {
+			return
+		}
+	}
+	targetTask.ParentID = task.ParentID
+	if ctrl.hierarchyMgr != nil {
+		children, err := ctrl.hierarchyMgr.ListChildren(ginc, targetTask.ID)
+		if err != nil {
+			ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+			return
+		}
+		targetTask.Progress = utils.Progress(targetTask.Status, children)
+	}
 
-	if err := ctrl.mysqlMgr.UpdateTask(ginc, &targetTask); err != nil {
+	if err := ctrl.mysqlMgr.UpdateTask(updateContext(ginc, targetTask, oldParentId), &targetTask); err != nil {
+		if errors.Is(err, data.ErrWriteBehindFull) {
+			ctrl.handleError(ginc, err, http.StatusServiceUnavailable, code.Code_UNAVAILABLE)
+			return
+		}
 		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
 		return
 	}
+	ctrl.pinPrimary(ginc)
 
 	if err := ctrl.cacheMgr.UpdateTask(ginc, &targetTask); err != nil {
-		logger.GetLoggerWithKeys(map[string]interface{}{
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
 			"error": err,
 		}).Error("update task from cache fail")
-		ctrl.enableGetCache = false
-		ctrl.enableListCache = false
+		ctrl.ResetCache()
 	} else {
-		ctrl.checkTaskVersion(ginc, task.ID, task.Version)
+		ctrl.checkTaskVersion(ginc, targetTask)
+	}
+
+	if scope == scopeFuture {
+		if err := ctrl.updateFutureOccurrences(ginc, &targetTask, task.Recurrence); err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+			}).Error("update future occurrences fail")
+			ctrl.handleSeriesError(ginc, err)
+			return
+		}
+	}
+	if ctrl.hierarchyMgr != nil {
+		ctrl.rollupProgress(ginc, targetTask.ParentID)
+		if parentChanged {
+			ctrl.rollupProgress(ginc, oldParentId)
+		}
+	}
+	if targetTask.SeriesID != nil && ctrl.seriesMgr != nil && wasOpen && targetTask.Status != models.TaskStatusOpen {
+		ctrl.materializeAfterClose(ginc, targetTask)
 	}
 
 	ginc.JSON(http.StatusOK, models.Response{
@@ -336,7 +566,40 @@ func (ctrl *Controller) UpdateTask(ginc *gin.Context) {
 
 }
 
-func (ctrl *Controller) Shutdown() {}
+// Shutdown interrupts the running imports and waits for them to save their
+// progress.
+func (ctrl *Controller) Shutdown() {
+	if ctrl.stopImports != nil {
+		ctrl.stopImports()
+		ctrl.imports.Wait()
+	}
+}
+
+// ResetCache stops serving reads from the cache until it has been refilled
+// from MySQL.
+func (ctrl *Controller) ResetCache() {
+	ctrl.enableGetCache.Store(false)
+	ctrl.listCacheWarmed.reset()
+}
+
+// lock takes the global task lock from the cache, or from MySQL while the
+// cache circuit breaker is open, see data.TryLock. It writes the error
+// response itself when the lock cannot be taken.
+func (ctrl *Controller) lock(ginc *gin.Context) (release func(), ok bool) {
+	release, locked, err := data.TryLock(ginc, c.LockKey, c.LockExpiration, ctrl.cacheMgr, ctrl.mysqlMgr)
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Warn("take task lock fail")
+		ctrl.handleError(ginc, err, http.StatusServiceUnavailable, code.Code_UNAVAILABLE)
+		return nil, false
+	}
+	if !locked {
+		ctrl.handleError(ginc, fmt.Errorf("task is locked by another request"), http.StatusLocked, code.Code_ABORTED)
+		return nil, false
+	}
+	return release, true
+}
 
 func (ctrl *Controller) handleError(ginc *gin.Context, err error, httpCode int, errorCode code.Code) {
 	ginc.JSON(httpCode, models.HttpError{
@@ -345,32 +608,32 @@ func (ctrl *Controller) handleError(ginc *gin.Context, err error, httpCode int,
 	})
 }
 
-func (ctrl *Controller) checkTaskVersion(ctx context.Context, taskId uint64, version int) {
-	task, err := ctrl.cacheMgr.GetTaskById(ctx, taskId)
-	if err == nil && version == task.Version {
+// checkTaskVersion checks the cache holds task as just written. A stale
+// cached task is replaced by task, the lists of its tenant stay in the cache.
+func (ctrl *Controller) checkTaskVersion(ctx context.Context, task models.Task) {
+	cached, err := ctrl.cacheMgr.GetTaskById(ctx, task.ID)
+	if err == nil && cached.Version == task.Version {
 		return
 	}
 
-	logger.GetLoggerWithKeys(map[string]interface{}{
+	metrics.TaskVersionMismatches.Inc()
+	logger.GetLoggerWithContext(ctx, map[string]interface{}{
 		"error":  err,
-		"taskId": taskId,
+		"taskId": task.ID,
 	}).Error("checkTaskVersion: version not match")
 
-	if err := ctrl.cacheMgr.DeleteTask(ctx, taskId); err != nil {
-		logger.GetLoggerWithKeys(map[string]interface{}{
+	if err := ctrl.cacheMgr.UpdateTask(ctx, &task); err != nil {
+		logger.GetLoggerWithContext(ctx, map[string]interface{}{
 			"error":  err,
-			"taskId": taskId,
-		}).Error("checkTaskVersion: delete cache task fail")
-		ctrl.enableGetCache = false
+			"taskId": task.ID,
+		}).Error("checkTaskVersion: update cache task fail")
+		ctrl.ResetCache()
 	}
-	ctrl.enableListCache = false
-
 }
 
-func (ctrl *Controller) extractPaginationParams(ginc *gin.Context) (limit, offset int, order string) {
+func (ctrl *Controller) extractPaginationParams(ginc *gin.Context) (limit, offset int) {
 	limitStr := ginc.Query("limit")
 	offsetStr := ginc.Query("offset")
-	order = ginc.Query("order")
 
 	defaultLimit := 20
 	defaultOffset := 0
@@ -385,9 +648,77 @@ func (ctrl *Controller) extractPaginationParams(ginc *gin.Context) (limit, offse
 		offset = defaultOffset
 	}
 
+	return limit, offset
+}
+
+// extractOrder returns the order query parameter, id desc by default. Only
+// the fields of utils.ParseOrder are accepted, the order goes into the
+// ORDER BY clause of MySQL.
+func (ctrl *Controller) extractOrder(ginc *gin.Context) (string, error) {
+	order := ginc.Query("order")
 	if order == "" {
-		order = "id desc"
+		return "id desc", nil
+	}
+	return utils.ParseOrder(order)
+}
+
+// extractTaskFilter returns the filter of the tasks visible to the caller
+// narrowed by the due, tag and custom field query parameters.
+func (ctrl *Controller) extractTaskFilter(ginc *gin.Context, order string) (models.TaskFilter, error) {
+	return ctrl.parseTaskFilter(ctrl.readContext(ginc), ctrl.principal(ginc), ginc.Request.URL.Query(), order)
+}
+
+// parseTaskFilter returns the filter of the tasks visible to principal
+// narrowed by the due, tag and custom field parameters of query.
+func (ctrl *Controller) parseTaskFilter(ctx context.Context, principal *auth.Principal, query url.Values, order string) (models.TaskFilter, error) {
+	filter := auth.VisibleFilter(principal)
+	if err := ctrl.extractDueFilter(query, &filter); err != nil {
+		return models.TaskFilter{}, err
+	}
+	if err := ctrl.extractTagFilter(query, &filter); err != nil {
+		return models.TaskFilter{}, err
+	}
+	if err := ctrl.extractFieldFilter(ctx, query, order, &filter); err != nil {
+		return models.TaskFilter{}, err
+	}
+	return filter, nil
+}
+
+// extractDueFilter adds the due_before, due_after and overdue query
+// parameters to filter.
+func (ctrl *Controller) extractDueFilter(query url.Values, filter *models.TaskFilter) error {
+	for param, target := range map[string]**time.Time{
+		"due_before": &filter.DueBefore,
+		"due_after":  &filter.DueAfter,
+	} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", param, err)
+		}
+		*target = &t
 	}
 
-	return limit, offset, order
+	if value := query.Get("overdue"); value != "" {
+		overdue, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid overdue: %v", err)
+		}
+		if overdue {
+			now := time.Now()
+			filter.OverdueAt = &now
+		}
+	}
+	return nil
+}
+
+// equalId reports whether two optional ids are the same.
+func equalId(a, b *uint64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
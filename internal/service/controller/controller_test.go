@@ -0,0 +1,443 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/internal/service/middleware"
+	"task_service/pkg/auth"
+	"task_service/pkg/lock"
+	"task_service/pkg/logger"
+	"task_service/pkg/metrics"
+	"task_service/pkg/models"
+	"task_service/pkg/utils"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	logger.SetLogger(zap.NewNop())
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// fakeTaskStore keeps the tasks of every tenant in memory in place of MySQL.
+type fakeTaskStore struct {
+	data.DataManager
+
+	mu    sync.Mutex
+	tasks map[uint64]models.Task
+	locks map[string]bool
+}
+
+func newFakeTaskStore(tasks ...models.Task) *fakeTaskStore {
+	store := &fakeTaskStore{
+		tasks: map[uint64]models.Task{},
+		locks: map[string]bool{},
+	}
+	for _, task := range tasks {
+		store.tasks[task.ID] = task
+	}
+	return store
+}
+
+func (store *fakeTaskStore) ListTask(ctx context.Context, limit, offset int, order string, filter models.TaskFilter) ([]models.Task, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	tasks := []models.Task{}
+	for _, task := range store.tasks {
+		if task.TenantID == data.TenantFromContext(ctx) && filter.Match(task) {
+			tasks = append(tasks, task)
+		}
+	}
+	slices.SortFunc(tasks, func(a, b models.Task) int { return int(a.ID) - int(b.ID) })
+	field, direction, _ := strings.Cut(order, " ")
+	utils.SortByField(tasks, field, direction == "desc")
+
+	offset = min(offset, len(tasks))
+	return tasks[offset:min(offset+limit, len(tasks))], nil
+}
+
+func (store *fakeTaskStore) GetTaskById(ctx context.Context, taskId uint64) (models.Task, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	task, ok := store.tasks[taskId]
+	if !ok || task.TenantID != data.TenantFromContext(ctx) {
+		return models.Task{}, fmt.Errorf("task %d not found", taskId)
+	}
+	return task, nil
+}
+
+func (store *fakeTaskStore) CreateTask(ctx context.Context, tasks []models.Task) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, task := range tasks {
+		task.ID = uint64(len(store.tasks) + 1)
+		task.TenantID = data.TenantFromContext(ctx)
+		store.tasks[task.ID] = task
+	}
+	return nil
+}
+
+func (store *fakeTaskStore) UpdateTask(ctx context.Context, task *models.Task) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.tasks[task.ID] = *task
+	return nil
+}
+
+func (store *fakeTaskStore) Lock(ctx context.Context, lockKey string, expiration time.Duration) (lock.Lease, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if store.locks[lockKey] {
+		return lock.Lease{}, false, nil
+	}
+	store.locks[lockKey] = true
+	return lock.Lease{Key: lockKey}, true, nil
+}
+
+func (store *fakeTaskStore) ReleaseLock(ctx context.Context, lease lock.Lease) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.locks, lease.Key)
+}
+
+// fakeTenantStore knows a fixed set of tenants.
+type fakeTenantStore struct {
+	data.TenantManager
+
+	tenants map[string]models.Tenant
+}
+
+func (store *fakeTenantStore) GetTenantById(ctx context.Context, tenantId string) (models.Tenant, error) {
+	tenant, ok := store.tenants[tenantId]
+	if !ok {
+		return models.Tenant{}, gorm.ErrRecordNotFound
+	}
+	return tenant, nil
+}
+
+// newTestRouter serves the task routes of ctrl to requests acting as
+// principal, with the tenant resolved like in the service.
+func newTestRouter(ctrl *Controller, principal *auth.Principal) *gin.Engine {
+	tenants := &fakeTenantStore{tenants: map[string]models.Tenant{
+		c.DefaultTenant: {ID: c.DefaultTenant, Status: models.TenantStatusActive},
+		"acme":          {ID: "acme", Status: models.TenantStatusActive},
+	}}
+
+	r := gin.New()
+	r.ContextWithFallback = true
+	group := r.Group("task-service/api/v1")
+	group.Use(func(ginc *gin.Context) {
+		ginc.Set(c.ContextKeyPrincipal, principal)
+		ginc.Request = ginc.Request.WithContext(auth.NewContext(ginc.Request.Context(), principal))
+		ginc.Next()
+	})
+	group.Use(middleware.ResolveTenant(tenants))
+	group.GET("/tasks", ctrl.ListTask)
+	group.GET("/tasks/:taskId", ctrl.GetTask)
+	group.POST("/tasks", ctrl.CreateTask)
+	group.PUT("/tasks/:taskId", ctrl.UpdateTask)
+	return r
+}
+
+func newTestController(t *testing.T, store *fakeTaskStore) *Controller {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return NewController(store, data.NewDataManager(client))
+}
+
+func serve(t *testing.T, r http.Handler, method, path string, body interface{}, header map[string]string) (*httptest.ResponseRecorder, models.Response) {
+	var reader bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&reader).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp models.Response
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	}
+	return w, resp
+}
+
+func testTasks() []models.Task {
+	return []models.Task{
+		{ID: 1, TenantID: c.DefaultTenant, Name: "mine", OwnerID: "alice", Status: models.TaskStatusOpen, Version: 1},
+		{ID: 2, TenantID: c.DefaultTenant, Name: "assigned", OwnerID: "bob", AssigneeID: "alice", Status: models.TaskStatusOpen, Version: 1},
+		{ID: 3, TenantID: c.DefaultTenant, Name: "other", OwnerID: "bob", Status: models.TaskStatusOpen, Version: 1},
+		{ID: 4, TenantID: "acme", Name: "foreign", OwnerID: "alice", Status: models.TaskStatusOpen, Version: 1},
+	}
+}
+
+func TestUpdateTaskPermission(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		taskId    uint64
+		wantCode  int
+	}{
+		{
+			name:      "viewer may not update an assigned task",
+			principal: &auth.Principal{ID: "alice", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleViewer}},
+			taskId:    2,
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "editor may not update the task of another user",
+			principal: &auth.Principal{ID: "alice", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleEditor}},
+			taskId:    3,
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "editor updates an assigned task",
+			principal: &auth.Principal{ID: "alice", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleEditor}},
+			taskId:    2,
+			wantCode:  http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeTaskStore(testTasks()...)
+			r := newTestRouter(newTestController(t, store), tt.principal)
+
+			before := store.tasks[tt.taskId]
+			update := before
+			update.Name = "renamed"
+			w, _ := serve(t, r, http.MethodPut, fmt.Sprintf("/task-service/api/v1/tasks/%d", tt.taskId), update, nil)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode != http.StatusOK {
+				assert.Equal(t, before, store.tasks[tt.taskId])
+			} else {
+				assert.Equal(t, "renamed", store.tasks[tt.taskId].Name)
+			}
+		})
+	}
+}
+
+func TestTenantHeader(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		tenant    string
+		wantCode  int
+		wantTasks []uint64
+	}{
+		{
+			name:      "admin may not read another tenant",
+			principal: &auth.Principal{ID: "alice", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleAdmin}},
+			tenant:    "acme",
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "admin may not read an unknown tenant",
+			principal: &auth.Principal{ID: "alice", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleAdmin}},
+			tenant:    "unknown",
+			wantCode:  http.StatusForbidden,
+		},
+		{
+			name:      "superadmin reads another tenant",
+			principal: &auth.Principal{ID: "root", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleSuperAdmin}},
+			tenant:    "acme",
+			wantCode:  http.StatusOK,
+			wantTasks: []uint64{4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeTaskStore(testTasks()...)
+			r := newTestRouter(newTestController(t, store), tt.principal)
+
+			w, resp := serve(t, r, http.MethodGet, "/task-service/api/v1/tasks?order=id", nil,
+				map[string]string{c.HeaderTenantID: tt.tenant})
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, tt.wantTasks, taskIds(resp.Data))
+			}
+		})
+	}
+}
+
+func TestTenantHeaderGetTask(t *testing.T) {
+	store := newFakeTaskStore(testTasks()...)
+	principal := &auth.Principal{ID: "alice", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleAdmin}}
+	r := newTestRouter(newTestController(t, store), principal)
+
+	w, _ := serve(t, r, http.MethodGet, "/task-service/api/v1/tasks/4", nil,
+		map[string]string{c.HeaderTenantID: "acme"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// without the header the task of the other tenant is not found
+	w, _ = serve(t, r, http.MethodGet, "/task-service/api/v1/tasks/4", nil, nil)
+	assert.NotEqual(t, http.StatusOK, w.Code)
+}
+
+func TestListTaskVisibility(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		wantTasks []uint64
+	}{
+		{
+			name:      "viewer lists the tasks it owns or is assigned",
+			principal: &auth.Principal{ID: "alice", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleViewer}},
+			wantTasks: []uint64{1, 2},
+		},
+		{
+			name:      "editor lists the tasks it owns or is assigned",
+			principal: &auth.Principal{ID: "bob", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleEditor}},
+			wantTasks: []uint64{2, 3},
+		},
+		{
+			name:      "admin lists every task of its tenant",
+			principal: &auth.Principal{ID: "carol", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleAdmin}},
+			wantTasks: []uint64{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeTaskStore(testTasks()...)
+			r := newTestRouter(newTestController(t, store), tt.principal)
+
+			// the first list reads MySQL and warms the cache, the second
+			// one is served from the cache
+			for _, source := range []string{"mysql", "cache"} {
+				w, resp := serve(t, r, http.MethodGet, "/task-service/api/v1/tasks?order=id", nil, nil)
+				require.Equal(t, http.StatusOK, w.Code, source)
+				assert.Equal(t, tt.wantTasks, taskIds(resp.Data), source)
+			}
+		})
+	}
+}
+
+func taskIds(tasks []models.Task) []uint64 {
+	ids := []uint64{}
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
+
+func TestUpdateTaskVersionMismatches(t *testing.T) {
+	store := newFakeTaskStore(testTasks()...)
+	ctrl := newTestController(t, store)
+	principal := &auth.Principal{ID: "alice", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleEditor}}
+	r := newTestRouter(ctrl, principal)
+
+	// warm the cache so that the update is checked against it
+	w, _ := serve(t, r, http.MethodGet, "/task-service/api/v1/tasks", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	before := testutil.ToFloat64(metrics.TaskVersionMismatches)
+	update := store.tasks[1]
+	update.Name = "renamed"
+	w, resp := serve(t, r, http.MethodPut, "/task-service/api/v1/tasks/1", update, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, resp.Data[0].Version)
+	assert.Equal(t, before, testutil.ToFloat64(metrics.TaskVersionMismatches))
+
+	// a stale cached task is counted and repaired
+	stale := store.tasks[1]
+	stale.Version = 1
+	require.NoError(t, ctrl.cacheMgr.UpdateTask(data.WithTenant(context.Background(), c.DefaultTenant), &stale))
+	ctrl.checkTaskVersion(data.WithTenant(context.Background(), c.DefaultTenant), store.tasks[1])
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.TaskVersionMismatches))
+	cached, err := ctrl.cacheMgr.GetTaskById(data.WithTenant(context.Background(), c.DefaultTenant), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, cached.Version)
+}
+
+func TestListTaskOrder(t *testing.T) {
+	tests := []struct {
+		order     string
+		wantCode  int
+		wantTasks []uint64
+	}{
+		{"", http.StatusOK, []uint64{3, 2, 1}},
+		{"name", http.StatusOK, []uint64{2, 1, 3}},
+		{"id desc", http.StatusOK, []uint64{3, 2, 1}},
+		{"owner_id", http.StatusBadRequest, nil},
+		{"tag", http.StatusBadRequest, nil},
+		{"id desc, (SELECT SLEEP(1))", http.StatusBadRequest, nil},
+		{"id sideways", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			store := newFakeTaskStore(testTasks()...)
+			principal := &auth.Principal{ID: "carol", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleAdmin}}
+			r := newTestRouter(newTestController(t, store), principal)
+
+			w, resp := serve(t, r, http.MethodGet, "/task-service/api/v1/tasks?order="+url.QueryEscape(tt.order), nil, nil)
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, tt.wantTasks, taskIds(resp.Data))
+			}
+		})
+	}
+}
+
+func TestCreateTaskNameAndTags(t *testing.T) {
+	store := newFakeTaskStore(
+		models.Task{ID: 1, TenantID: c.DefaultTenant, Name: "report", Tags: []string{"finance"}, OwnerID: "alice", Status: models.TaskStatusOpen, Version: 1},
+	)
+	principal := &auth.Principal{ID: "alice", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleEditor}}
+	r := newTestRouter(newTestController(t, store), principal)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantTags []string
+	}{
+		{"same name and tags", `{"name":"report","tags":[" finance"]}`, http.StatusBadRequest, nil},
+		{"same name and the tag alias", `{"name":"report","tag":"finance"}`, http.StatusBadRequest, nil},
+		{"same name with other tags", `{"name":"report","tags":["finance","q3"]}`, http.StatusOK, []string{"finance", "q3"}},
+		{"tag alias", `{"name":"legacy","tag":"ops","tags":["infra"]}`, http.StatusOK, []string{"infra", "ops"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, resp := serve(t, r, http.MethodPost, "/task-service/api/v1/tasks", json.RawMessage(tt.body), nil)
+			require.Equal(t, tt.wantCode, w.Code, w.Body.String())
+			if tt.wantCode == http.StatusOK {
+				require.Len(t, resp.Data, 1)
+				assert.Equal(t, tt.wantTags, resp.Data[0].Tags)
+				assert.NotZero(t, resp.Data[0].ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"task_service/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"gorm.io/gorm"
+)
+
+// WithDependencyManager enables dependencies between tasks.
+func WithDependencyManager(dependencyMgr data.DependencyManager) Option {
+	return func(ctrl *Controller) {
+		ctrl.dependencyMgr = dependencyMgr
+	}
+}
+
+// DependencyRequest is the body of the add dependency endpoint.
+type DependencyRequest struct {
+	BlockerID uint64 `json:"blocker_id"`
+}
+
+// @Summary make a task depend on another, which must be done before it can start
+// @router /task-service/api/v1/tasks/{taskId}/dependencies [post]
+// @Param taskId path int true "task ID"
+// @param params body DependencyRequest true "blocking task"
+// @Success 200 {object} models.TaskDependencyResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) AddDependency(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	request := DependencyRequest{}
+	if err := ginc.BindJSON(&request); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	task, ok := ctrl.loadTask(ginc, auth.ActionUpdate)
+	if !ok {
+		return
+	}
+	if request.BlockerID == task.ID {
+		ctrl.handleError(ginc, fmt.Errorf("a task can not depend on itself"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	blocker, err := ctrl.mysqlMgr.GetTaskById(data.WithPrimary(ginc), request.BlockerID)
+	if err != nil {
+		ctrl.handleError(ginc, fmt.Errorf("blocker task %d: %v", request.BlockerID, err), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if !ctrl.authorize(ginc, auth.ActionRead, &blocker) {
+		return
+	}
+
+	// the new dependency closes a cycle when the blocker already depends,
+	// directly or not, on the task
+	upstream, err := ctrl.dependencyMgr.ListUpstream(ginc, blocker.ID)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+	dependency := models.TaskDependency{TaskID: task.ID, BlockerID: blocker.ID}
+	if _, cycle := utils.TopologicalOrder(nil, append(upstream, dependency)); cycle != nil {
+		ctrl.handleError(ginc, fmt.Errorf("dependency would create a cycle: %s", joinIds(cycle, " -> ")), http.StatusConflict, code.Code_FAILED_PRECONDITION)
+		return
+	}
+
+	if err := ctrl.dependencyMgr.AddDependency(ginc, &dependency); err != nil {
+		if errors.Is(err, data.ErrDependencyExists) {
+			ctrl.handleError(ginc, err, http.StatusConflict, code.Code_ALREADY_EXISTS)
+			return
+		}
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("AddDependency fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.TaskDependencyResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.TaskDependency{dependency},
+	})
+}
+
+// @Summary remove a dependency of a task
+// @router /task-service/api/v1/tasks/{taskId}/dependencies/{blockerId} [delete]
+// @Param taskId path int true "task ID"
+// @Param blockerId path int true "blocking task ID"
+// @Success 200 {object} models.TaskDependencyResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) RemoveDependency(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	blockerId, err := strconv.ParseUint(ginc.Param("blockerId"), 10, 64)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	task, ok := ctrl.loadTask(ginc, auth.ActionUpdate)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.dependencyMgr.RemoveDependency(ginc, task.ID, blockerId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctrl.handleError(ginc, err, http.StatusNotFound, code.Code_NOT_FOUND)
+			return
+		}
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.TaskDependencyResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+	})
+}
+
+// @Summary get the tasks a task depends on and the tasks depending on it, in topological order
+// @router /task-service/api/v1/tasks/{taskId}/graph [get]
+// @Param taskId path int true "task ID"
+// @Success 200 {object} models.TaskGraphResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) GetTaskGraph(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	task, ok := ctrl.loadTask(ginc, auth.ActionRead)
+	if !ok {
+		return
+	}
+
+	upstream, err := ctrl.dependencyMgr.ListUpstream(ginc, task.ID)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+	downstream, err := ctrl.dependencyMgr.ListDownstream(ginc, task.ID)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	edges := append(upstream, downstream...)
+	ids := []uint64{task.ID}
+	for _, edge := range edges {
+		ids = append(ids, edge.TaskID, edge.BlockerID)
+	}
+	tasks, err := ctrl.dependencyMgr.ListTasksByIds(ginc, ids)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	// only the visible tasks and the dependencies between them are returned
+	visible := auth.VisibleFilter(ctrl.principal(ginc))
+	graph := models.TaskGraph{Tasks: []models.Task{}, Edges: []models.TaskDependency{}}
+	visibleIds := make(map[uint64]bool)
+	var nodes []uint64
+	for _, t := range tasks {
+		if visible.Match(t) {
+			graph.Tasks = append(graph.Tasks, t)
+			visibleIds[t.ID] = true
+			nodes = append(nodes, t.ID)
+		}
+	}
+	for _, edge := range edges {
+		if visibleIds[edge.TaskID] && visibleIds[edge.BlockerID] {
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+
+	order, cycle := utils.TopologicalOrder(nodes, graph.Edges)
+	if cycle != nil {
+		ctrl.handleError(ginc, fmt.Errorf("dependency cycle: %s", joinIds(cycle, " -> ")), http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+	graph.Order = order
+
+	ginc.JSON(http.StatusOK, models.TaskGraphResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.TaskGraph{graph},
+	})
+}
+
+// checkBlockers responds with 409 when task is moved to in progress or done
+// while a task it depends on is not done.
+func (ctrl *Controller) checkBlockers(ginc *gin.Context, task models.Task, status int) bool {
+	if err := ctrl.blockedBy(ginc, task, status); err != nil {
+		if errors.Is(err, errTaskBlocked) {
+			ctrl.handleError(ginc, err, http.StatusConflict, code.Code_FAILED_PRECONDITION)
+			return false
+		}
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return false
+	}
+	return true
+}
+
+// errTaskBlocked is returned by blockedBy when a task it depends on is not
+// done.
+var errTaskBlocked = errors.New("task is blocked by unfinished tasks")
+
+// blockedBy fails with errTaskBlocked when task is moved to in progress or
+// done while a task it depends on is not done.
+func (ctrl *Controller) blockedBy(ctx context.Context, task models.Task, status int) error {
+	if status == task.Status || (status != models.TaskStatusInProgress && status != models.TaskStatusDone) {
+		return nil
+	}
+
+	blockers, err := ctrl.dependencyMgr.ListBlockers(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+	var open []uint64
+	for _, blocker := range blockers {
+		if blocker.Status != models.TaskStatusDone {
+			open = append(open, blocker.ID)
+		}
+	}
+	if len(open) > 0 {
+		return fmt.Errorf("%w %s", errTaskBlocked, joinIds(open, ", "))
+	}
+	return nil
+}
+
+// loadTask reads the task of the taskId path parameter from the primary and
+// checks the caller may perform action on it.
+func (ctrl *Controller) loadTask(ginc *gin.Context, action auth.Action) (models.Task, bool) {
+	taskId, err := strconv.ParseUint(ginc.Param("taskId"), 10, 64)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return models.Task{}, false
+	}
+	task, err := ctrl.mysqlMgr.GetTaskById(data.WithPrimary(ginc), taskId)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INTERNAL)
+		return models.Task{}, false
+	}
+	if !ctrl.authorize(ginc, action, &task) {
+		return models.Task{}, false
+	}
+	return task, true
+}
+
+func joinIds(ids []uint64, sep string) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(id, 10)
+	}
+	return strings.Join(parts, sep)
+}
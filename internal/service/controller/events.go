@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/events"
+	"task_service/pkg/logger"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// eventHeartbeat keeps idle streams from being closed by proxies.
+const eventHeartbeat = 15 * time.Second
+
+// WithEventBroker enables the server-sent event stream.
+func WithEventBroker(broker *events.Broker) Option {
+	return func(ctrl *Controller) {
+		ctrl.eventBroker = broker
+	}
+}
+
+// WithEventSink publishes the task events raised by requests, such as new
+// comments, to sink.
+func WithEventSink(sink events.Sink) Option {
+	return func(ctrl *Controller) {
+		ctrl.eventSink = sink
+	}
+}
+
+// @Summary stream reminder, overdue and comment events of the visible tasks as server-sent events
+// @router /task-service/api/v1/events [get]
+// @Success 200 {object} events.Event
+func (ctrl *Controller) StreamEvents(ginc *gin.Context) {
+	tenantId := data.TenantFromContext(ginc)
+	visible := auth.VisibleFilter(ctrl.principal(ginc))
+	eventc, cancel := ctrl.eventBroker.Subscribe(func(event events.Event) bool {
+		return event.TenantID == tenantId && visible.Match(event.Task)
+	})
+	defer cancel()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	ginc.Header("Cache-Control", "no-cache")
+	ginc.Header("X-Accel-Buffering", "no")
+	ginc.Status(http.StatusOK)
+	ginc.Stream(func(w io.Writer) bool {
+		select {
+		case <-ginc.Request.Context().Done():
+			return false
+		case event, ok := <-eventc:
+			if !ok {
+				return false
+			}
+			ginc.Render(-1, sse.Event{Id: event.ID, Event: event.Type, Data: event})
+			return true
+		case <-heartbeat.C:
+			io.WriteString(w, ": ping\n\n")
+			return true
+		}
+	})
+}
+
+// publishEvent hands event to the event sink without holding up the request,
+// a failure is only logged.
+func (ctrl *Controller) publishEvent(ginc *gin.Context, event events.Event) {
+	if ctrl.eventSink == nil {
+		return
+	}
+	ctx := context.WithoutCancel(ginc.Request.Context())
+	go func() {
+		if err := ctrl.eventSink.Publish(ctx, event); err != nil {
+			logger.GetLoggerWithContext(ctx, map[string]interface{}{
+				"error":      err,
+				"event_id":   event.ID,
+				"event_type": event.Type,
+			}).Error("publish event fail")
+		}
+	}()
+}
@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"gorm.io/gorm"
+)
+
+// fieldParamPrefix starts the list task query parameters and orders that
+// refer to a custom field, e.g. field.env=prod or order=field.points desc.
+const fieldParamPrefix = "field."
+
+// WithFieldManager enables the custom fields of tasks.
+func WithFieldManager(fieldMgr data.FieldManager) Option {
+	return func(ctrl *Controller) {
+		ctrl.fieldMgr = fieldMgr
+	}
+}
+
+// @Summary list the custom fields of the tenant
+// @router /task-service/api/v1/fields [get]
+// @Success 200 {object} models.CustomFieldResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) ListFields(ginc *gin.Context) {
+	fields, err := ctrl.fieldMgr.ListFields(ctrl.readContext(ginc))
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("ListFields fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.CustomFieldResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    fields,
+	})
+}
+
+// @Summary get a custom field
+// @router /task-service/api/v1/fields/{fieldId} [get]
+// @Param fieldId path int true "field ID"
+// @Success 200 {object} models.CustomFieldResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) GetField(ginc *gin.Context) {
+	field, ok := ctrl.loadField(ginc)
+	if !ok {
+		return
+	}
+	ctrl.respondField(ginc, field)
+}
+
+// @Summary define a custom field, only admins may define fields
+// @router /task-service/api/v1/fields [post]
+// @param params body models.CustomField true "field"
+// @Success 200 {object} models.CustomFieldResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) CreateField(ginc *gin.Context) {
+	field := models.CustomField{}
+	if err := ginc.BindJSON(&field); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if !ctrl.authorize(ginc, auth.ActionManageFields, nil) {
+		return
+	}
+	field.Name = strings.TrimSpace(field.Name)
+	if err := field.Check(); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	field.ID = 0
+	if err := ctrl.fieldMgr.CreateField(ginc, &field); err != nil {
+		ctrl.handleFieldError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	ctrl.respondField(ginc, field)
+}
+
+// @Summary change the name, enum options or required flag of a custom field, its key and type can not change
+// @router /task-service/api/v1/fields/{fieldId} [put]
+// @Param fieldId path int true "field ID"
+// @param params body models.CustomField true "field"
+// @Success 200 {object} models.CustomFieldResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) UpdateField(ginc *gin.Context) {
+	request := models.CustomField{}
+	if err := ginc.BindJSON(&request); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if !ctrl.authorize(ginc, auth.ActionManageFields, nil) {
+		return
+	}
+	field, ok := ctrl.loadField(ginc)
+	if !ok {
+		return
+	}
+	if request.Key != "" && request.Key != field.Key || request.Type != "" && request.Type != field.Type {
+		ctrl.handleError(ginc, fmt.Errorf("the key and type of a field can not be changed"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	// values already stored are only checked against the new definition
+	// when their task is saved again
+	field.Name = strings.TrimSpace(request.Name)
+	field.Options = request.Options
+	field.Required = request.Required
+	if err := field.Check(); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if err := ctrl.fieldMgr.UpdateField(ginc, &field); err != nil {
+		ctrl.handleFieldError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	ctrl.respondField(ginc, field)
+}
+
+// @Summary delete a custom field and its values on every task
+// @router /task-service/api/v1/fields/{fieldId} [delete]
+// @Param fieldId path int true "field ID"
+// @Success 200 {object} models.CustomFieldResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) DeleteField(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	if !ctrl.authorize(ginc, auth.ActionManageFields, nil) {
+		return
+	}
+	field, ok := ctrl.loadField(ginc)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.fieldMgr.DeleteField(ginc, field.ID); err != nil {
+		ctrl.handleFieldError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	// any task of the tenant may have had a value, refill the cache
+	ctrl.ResetCache()
+
+	ginc.JSON(http.StatusOK, models.CustomFieldResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+	})
+}
+
+// checkFields validates the custom field values of a task, responding with
+// 400 when one is unknown, of the wrong type or a required one is missing.
+func (ctrl *Controller) checkFields(ginc *gin.Context, values map[string]interface{}) (map[string]interface{}, bool) {
+	if ctrl.fieldMgr == nil {
+		if len(values) > 0 {
+			ctrl.handleError(ginc, fmt.Errorf("custom fields are not enabled"), http.StatusBadRequest, code.Code_UNIMPLEMENTED)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	fields, err := ctrl.fieldMgr.ListFields(data.WithPrimary(ginc))
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return nil, false
+	}
+	checked, err := models.CheckFields(fields, values)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return nil, false
+	}
+	return checked, true
+}
+
+// extractFieldFilter adds the field.<key>=value query parameters to filter
+// and checks that an order by field.<key> names a field of the tenant.
+func (ctrl *Controller) extractFieldFilter(ctx context.Context, query url.Values, order string, filter *models.TaskFilter) error {
+	orderField, _, _ := strings.Cut(order, " ")
+	orderKey, byField := strings.CutPrefix(orderField, fieldParamPrefix)
+	params := map[string]string{}
+	for param := range query {
+		if key, ok := strings.CutPrefix(param, fieldParamPrefix); ok {
+			params[key] = query.Get(param)
+		}
+	}
+	if len(params) == 0 && !byField {
+		return nil
+	}
+	if ctrl.fieldMgr == nil {
+		return fmt.Errorf("custom fields are not enabled")
+	}
+
+	fields, err := ctrl.fieldMgr.ListFields(ctx)
+	if err != nil {
+		return err
+	}
+	byKey := map[string]models.CustomField{}
+	for _, field := range fields {
+		byKey[field.Key] = field
+	}
+	if _, ok := byKey[orderKey]; byField && !ok {
+		return fmt.Errorf("invalid order: unknown field %s", orderKey)
+	}
+	for key, text := range params {
+		field, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("unknown field %s", key)
+		}
+		value, err := field.ParseValue(text)
+		if err != nil {
+			return err
+		}
+		if filter.Fields == nil {
+			filter.Fields = map[string]interface{}{}
+		}
+		filter.Fields[key] = value
+	}
+	return nil
+}
+
+// loadField reads the custom field of the fieldId path parameter from the
+// primary.
+func (ctrl *Controller) loadField(ginc *gin.Context) (models.CustomField, bool) {
+	fieldId, err := strconv.ParseUint(ginc.Param("fieldId"), 10, 64)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return models.CustomField{}, false
+	}
+	field, err := ctrl.fieldMgr.GetField(ginc, fieldId)
+	if err != nil {
+		ctrl.handleFieldError(ginc, err)
+		return models.CustomField{}, false
+	}
+	return field, true
+}
+
+func (ctrl *Controller) handleFieldError(ginc *gin.Context, err error) {
+	switch {
+	case errors.Is(err, data.ErrFieldExists):
+		ctrl.handleError(ginc, err, http.StatusConflict, code.Code_ALREADY_EXISTS)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctrl.handleError(ginc, err, http.StatusNotFound, code.Code_NOT_FOUND)
+	default:
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("field operation fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+	}
+}
+
+func (ctrl *Controller) respondField(ginc *gin.Context, field models.CustomField) {
+	ginc.JSON(http.StatusOK, models.CustomFieldResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.CustomField{field},
+	})
+}
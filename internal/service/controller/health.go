@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"net/http"
+	"task_service/pkg/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WithHealthChecker enables the readiness endpoint.
+func WithHealthChecker(checker *health.Checker) Option {
+	return func(ctrl *Controller) {
+		ctrl.healthChecker = checker
+	}
+}
+
+// @Summary liveness probe, ok as long as the process serves requests
+// @router /healthz [get]
+// @Success 200 {object} health.Report
+func (ctrl *Controller) Healthz(ginc *gin.Context) {
+	ginc.JSON(http.StatusOK, health.Report{
+		Status:     health.StatusUp,
+		Components: map[string]health.ComponentStatus{},
+	})
+}
+
+// @Summary readiness probe with the status of every dependency
+// @Description status is degraded when the cache is down but MySQL is up, and down while shutting down
+// @router /readyz [get]
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+func (ctrl *Controller) Readyz(ginc *gin.Context) {
+	if ctrl.healthChecker == nil {
+		ctrl.Healthz(ginc)
+		return
+	}
+
+	report := ctrl.healthChecker.Check(ginc)
+	if !report.Ready() {
+		ginc.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	ginc.JSON(http.StatusOK, report)
+}
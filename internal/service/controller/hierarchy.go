@@ -0,0 +1,296 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"task_service/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// What happens to the subtasks of a deleted task.
+const (
+	childrenReparent = "reparent"
+	childrenCascade  = "cascade"
+)
+
+// WithHierarchyManager enables subtasks.
+func WithHierarchyManager(hierarchyMgr data.HierarchyManager) Option {
+	return func(ctrl *Controller) {
+		ctrl.hierarchyMgr = hierarchyMgr
+	}
+}
+
+// @Summary list the subtasks of a task, level by level
+// @router /task-service/api/v1/tasks/{taskId}/children [get]
+// @Param taskId path int true "task ID"
+// @Param depth query int false "levels of subtasks to return, default 1"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) ListChildren(ginc *gin.Context) {
+
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	taskId, err := strconv.ParseUint(ginc.Param("taskId"), 10, 64)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	depth := 1
+	if value := ginc.Query("depth"); value != "" {
+		depth, err = strconv.Atoi(value)
+		if err != nil || depth < 1 {
+			ctrl.handleError(ginc, fmt.Errorf("invalid depth %q", value), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+			return
+		}
+	}
+	depth = min(depth, c.MaxTaskDepth)
+
+	task, err := ctrl.mysqlMgr.GetTaskById(ctrl.readContext(ginc), taskId)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INTERNAL)
+		return
+	}
+	if !ctrl.authorize(ginc, auth.ActionRead, &task) {
+		return
+	}
+
+	var tasks []models.Task
+	if ctrl.listCacheWarmed.has(data.TenantFromContext(ginc)) {
+		tasks, err = ctrl.cacheMgr.ListSubtree(ginc, taskId, depth)
+		if err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+			}).Error("ListSubtree from cache fail")
+		}
+	}
+	if len(tasks) == 0 {
+		tasks, err = ctrl.mysqlMgr.ListSubtree(ctrl.readContext(ginc), taskId, depth)
+		if err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+			}).Error("ListSubtree fail")
+			ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+			return
+		}
+	}
+
+	visible := auth.VisibleFilter(ctrl.principal(ginc))
+	children := []models.Task{}
+	for _, child := range tasks {
+		if visible.Match(child) {
+			children = append(children, child)
+		}
+	}
+
+	ginc.JSON(http.StatusOK, models.Response{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    children,
+	})
+}
+
+// checkParent responds with 400 unless task may become a child of parentId:
+// the parent must exist and be editable by the caller, and the move must
+// neither close a cycle nor nest the subtree of task deeper than
+// c.MaxTaskDepth. task.ID is 0 for a new task.
+func (ctrl *Controller) checkParent(ginc *gin.Context, task models.Task, parentId uint64) bool {
+	if parentId == task.ID {
+		ctrl.handleError(ginc, fmt.Errorf("a task can not be its own parent"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+
+	parent, err := ctrl.mysqlMgr.GetTaskById(data.WithPrimary(ginc), parentId)
+	if err != nil {
+		ctrl.handleError(ginc, fmt.Errorf("parent task %d: %v", parentId, err), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+	if !ctrl.authorize(ginc, auth.ActionUpdate, &parent) {
+		return false
+	}
+
+	ancestors, err := ctrl.hierarchyMgr.ListAncestors(ginc, parentId)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return false
+	}
+	if task.ID != 0 && slices.ContainsFunc(ancestors, func(ancestor models.Task) bool { return ancestor.ID == task.ID }) {
+		ctrl.handleError(ginc, fmt.Errorf("task %d is an ancestor of task %d", task.ID, parentId), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+
+	height := 0
+	if task.ID != 0 {
+		subtree, err := ctrl.mysqlMgr.ListSubtree(data.WithPrimary(ginc), task.ID, c.MaxTaskDepth)
+		if err != nil {
+			ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+			return false
+		}
+		height = utils.TreeHeight(task.ID, subtree)
+	}
+	// the parent is at level len(ancestors)+1, task one below it
+	if len(ancestors)+2+height > c.MaxTaskDepth {
+		ctrl.handleError(ginc, fmt.Errorf("subtasks can not be nested deeper than %d levels", c.MaxTaskDepth), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+	return true
+}
+
+// childrenMode returns the children query parameter of a delete, writing the
+// error response when it is invalid.
+func (ctrl *Controller) childrenMode(ginc *gin.Context) (string, bool) {
+	mode := ginc.DefaultQuery("children", childrenReparent)
+	if mode != childrenReparent && mode != childrenCascade {
+		ctrl.handleError(ginc, fmt.Errorf("invalid children %q", mode), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return "", false
+	}
+	return mode, true
+}
+
+// detachChildren deletes the subtasks of task with the cascade mode, or
+// moves them to the parent of task, before task itself is deleted.
+func (ctrl *Controller) detachChildren(ginc *gin.Context, task models.Task, mode string) bool {
+	if mode == childrenCascade {
+		subtree, err := ctrl.mysqlMgr.ListSubtree(data.WithPrimary(ginc), task.ID, c.MaxTaskDepth)
+		if err != nil {
+			ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+			return false
+		}
+		taskIds := make([]uint64, len(subtree))
+		for i := range subtree {
+			if !ctrl.authorize(ginc, auth.ActionDelete, &subtree[i]) {
+				return false
+			}
+			taskIds[i] = subtree[i].ID
+		}
+		if err := ctrl.deleteStoredSubtree(ginc, taskIds); err != nil {
+			ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+			return false
+		}
+		return true
+	}
+
+	children, err := ctrl.hierarchyMgr.ListChildren(ginc, task.ID)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return false
+	}
+	if len(children) == 0 {
+		return true
+	}
+	if err := ctrl.hierarchyMgr.ReparentChildren(ginc, task.ID, task.ParentID); err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return false
+	}
+	for i := range children {
+		children[i].ParentID = task.ParentID
+		children[i].Version += 1
+		if err := ctrl.cacheMgr.UpdateTask(ginc, &children[i]); err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+			}).Error("update task from cache fail")
+			ctrl.ResetCache()
+		}
+	}
+	return true
+}
+
+// deleteStoredSubtree deletes the descendants taskIds of a task from MySQL
+// at once. Only once they are gone are they dropped from the cache and the
+// attachment blobs only they referred to removed. Subtasks are written
+// through, see updateContext, so none has a write-behind update pending.
+func (ctrl *Controller) deleteStoredSubtree(ginc *gin.Context, taskIds []uint64) error {
+	var attachments []models.Attachment
+	if ctrl.attachmentMgr != nil {
+		for _, taskId := range taskIds {
+			taskAttachments, err := ctrl.attachmentMgr.ListAttachments(data.WithPrimary(ginc), taskId)
+			if err != nil {
+				return err
+			}
+			attachments = append(attachments, taskAttachments...)
+		}
+	}
+	if err := ctrl.hierarchyMgr.DeleteSubtree(ginc, taskIds); err != nil {
+		return err
+	}
+
+	for _, taskId := range taskIds {
+		if err := ctrl.cacheMgr.DeleteTask(ginc, taskId); err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+			}).Error("delete task from cache fail")
+			ctrl.ResetCache()
+			break
+		}
+	}
+	for _, attachment := range attachments {
+		ctrl.removeOrphanBlob(ginc, attachment.SHA256)
+	}
+	return nil
+}
+
+// rollupProgress recomputes the progress of parentId from its children and
+// carries the change up to its ancestors. Failures are only logged, the
+// change of the child itself is already saved.
+func (ctrl *Controller) rollupProgress(ctx context.Context, parentId *uint64) {
+	for parentId != nil {
+		parent, err := ctrl.mysqlMgr.GetTaskById(data.WithPrimary(ctx), *parentId)
+		if err != nil {
+			ctrl.logRollupError(ctx, *parentId, err)
+			return
+		}
+		children, err := ctrl.hierarchyMgr.ListChildren(ctx, parent.ID)
+		if err != nil {
+			ctrl.logRollupError(ctx, parent.ID, err)
+			return
+		}
+
+		progress := utils.Progress(parent.Status, children)
+		if progress == parent.Progress {
+			return
+		}
+		// the parent is written through like its children, a pending
+		// update of its own would otherwise bring back the old progress
+		parent.Progress = progress
+		parent.Version += 1
+		if err := ctrl.mysqlMgr.UpdateTask(data.WithWriteThrough(ctx), &parent); err != nil {
+			ctrl.logRollupError(ctx, parent.ID, err)
+			return
+		}
+		if err := ctrl.cacheMgr.UpdateTask(ctx, &parent); err != nil {
+			ctrl.ResetCache()
+		}
+		parentId = parent.ParentID
+	}
+}
+
+// updateContext returns the context of an update of task. Tasks in a
+// hierarchy or a series are written through the write-behind buffer: the
+// progress roll-up and the series scheduling following their updates read
+// them back from MySQL.
+func updateContext(ctx context.Context, task models.Task, oldParentId *uint64) context.Context {
+	if task.ParentID != nil || oldParentId != nil || task.SeriesID != nil {
+		return data.WithWriteThrough(ctx)
+	}
+	return ctx
+}
+
+func (ctrl *Controller) logRollupError(ctx context.Context, taskId uint64, err error) {
+	logger.GetLoggerWithContext(ctx, map[string]interface{}{
+		"error":  err,
+		"taskId": taskId,
+	}).Error("roll up progress fail")
+}
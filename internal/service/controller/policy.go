@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// authorize checks the caller against the task policy and responds with 403
+// when the action is denied. task is nil for actions not bound to a task.
+func (ctrl *Controller) authorize(ginc *gin.Context, action auth.Action, task *models.Task) bool {
+	principal := ctrl.principal(ginc)
+	if auth.Authorize(principal, action, task) {
+		return true
+	}
+
+	keys := map[string]interface{}{
+		"principal": principal.ID,
+		"action":    action,
+	}
+	if task != nil {
+		keys["taskId"] = task.ID
+	}
+	logger.GetLoggerWithContext(ginc, keys).Warn("permission denied")
+
+	ctrl.handleError(ginc, fmt.Errorf("permission denied: %s", action), http.StatusForbidden, code.Code_PERMISSION_DENIED)
+	return false
+}
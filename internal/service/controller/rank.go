@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"task_service/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// WithRankManager enables moving tasks on the board.
+func WithRankManager(rankMgr data.RankManager) Option {
+	return func(ctrl *Controller) {
+		ctrl.rankMgr = rankMgr
+	}
+}
+
+// MoveTaskRequest is the body of the move task endpoint. At least one of
+// the anchors is required, with both the task is placed between them.
+type MoveTaskRequest struct {
+	// After places the task right after this task
+	After *uint64 `json:"after,omitempty"`
+	// Before places the task right before this task
+	Before *uint64 `json:"before,omitempty"`
+}
+
+// @Summary move a task on the board, list tasks with order=rank to get the board order
+// @router /task-service/api/v1/tasks/{taskId}/move [post]
+// @Param taskId path int true "task ID"
+// @param params body MoveTaskRequest true "anchors"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) MoveTask(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	request := MoveTaskRequest{}
+	if err := ginc.BindJSON(&request); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if request.After == nil && request.Before == nil {
+		ctrl.handleError(ginc, fmt.Errorf("after or before is required"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	task, ok := ctrl.loadTask(ginc, auth.ActionUpdate)
+	if !ok {
+		return
+	}
+
+	lower, upper, ok := ctrl.moveBounds(ginc, task, request)
+	if !ok {
+		return
+	}
+	rank, fits := utils.RankBetween(lower, upper)
+	spread := false
+	if !fits {
+		// the anchors are too close, spread the ranks of the tenant and place
+		// the task between the anchors at their new ranks
+		if err := ctrl.rankMgr.SpreadRanks(ginc); err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+			}).Error("SpreadRanks fail")
+			ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+			return
+		}
+		spread = true
+		if lower, upper, ok = ctrl.moveBounds(ginc, task, request); !ok {
+			return
+		}
+		if rank, fits = utils.RankBetween(lower, upper); !fits {
+			ctrl.handleError(ginc, fmt.Errorf("no rank left between the anchors"), http.StatusInternalServerError, code.Code_INTERNAL)
+			return
+		}
+	}
+
+	if err := ctrl.rankMgr.SetRank(ginc, task.ID, rank); err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	task.Rank = rank
+
+	if spread {
+		// every task of the tenant has a new rank
+		ctrl.ResetCache()
+	} else if err := ctrl.cacheMgr.UpdateTask(ginc, &task); err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("update moved task in cache fail")
+		ctrl.ResetCache()
+	}
+
+	ginc.JSON(http.StatusOK, models.Response{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.Task{task},
+	})
+}
+
+// moveBounds returns the ranks the moved task must sort between, an empty
+// rank leaves that side open. The anchors are read again every time, their
+// ranks change when the ranks are spread.
+func (ctrl *Controller) moveBounds(ginc *gin.Context, task models.Task, request MoveTaskRequest) (lower, upper string, ok bool) {
+	var after, before models.Task
+	if request.After != nil {
+		if after, ok = ctrl.loadAnchor(ginc, task, *request.After); !ok {
+			return "", "", false
+		}
+	}
+	if request.Before != nil {
+		if before, ok = ctrl.loadAnchor(ginc, task, *request.Before); !ok {
+			return "", "", false
+		}
+	}
+
+	var err error
+	switch {
+	case request.After != nil && request.Before != nil:
+		if after.Rank > before.Rank {
+			ctrl.handleError(ginc, fmt.Errorf("task %d comes after task %d on the board", after.ID, before.ID), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+			return "", "", false
+		}
+		lower, upper = after.Rank, before.Rank
+	case request.After != nil:
+		lower = after.Rank
+		upper, err = ctrl.rankMgr.NeighborRank(ginc, after.Rank, true, task.ID)
+	default:
+		upper = before.Rank
+		lower, err = ctrl.rankMgr.NeighborRank(ginc, before.Rank, false, task.ID)
+	}
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return "", "", false
+	}
+	return lower, upper, true
+}
+
+// loadAnchor reads the task the moved task is placed next to.
+func (ctrl *Controller) loadAnchor(ginc *gin.Context, task models.Task, anchorId uint64) (models.Task, bool) {
+	if anchorId == task.ID {
+		ctrl.handleError(ginc, fmt.Errorf("a task can not be moved next to itself"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return models.Task{}, false
+	}
+	anchor, err := ctrl.mysqlMgr.GetTaskById(data.WithPrimary(ginc), anchorId)
+	if err != nil {
+		ctrl.handleError(ginc, fmt.Errorf("anchor task %d: %v", anchorId, err), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return models.Task{}, false
+	}
+	if !ctrl.authorize(ginc, auth.ActionRead, &anchor) {
+		return models.Task{}, false
+	}
+	return anchor, true
+}
+
+// checkPriority responds with 400 when priority is not a task priority.
+func (ctrl *Controller) checkPriority(ginc *gin.Context, priority int) bool {
+	if !models.ValidPriority(priority) {
+		ctrl.handleError(ginc, fmt.Errorf("invalid priority: %d", priority), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,393 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/internal/service/occurrence"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"task_service/pkg/recurrence"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"gorm.io/gorm"
+)
+
+// Edit scopes of an occurrence of a recurring task.
+const (
+	scopeThis   = "this"
+	scopeFuture = "future"
+)
+
+// WithSeriesManager enables recurring tasks.
+func WithSeriesManager(seriesMgr data.SeriesManager) Option {
+	return func(ctrl *Controller) {
+		ctrl.seriesMgr = seriesMgr
+	}
+}
+
+// SeriesException is the body of the series exception endpoints.
+type SeriesException struct {
+	OccurrenceAt time.Time `json:"occurrence_at"`
+}
+
+// @Summary get the rule, template and skipped occurrences of a recurring task
+// @router /task-service/api/v1/series/{seriesId} [get]
+// @Param seriesId path int true "series ID"
+// @Success 200 {object} models.TaskSeriesResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) GetSeries(ginc *gin.Context) {
+	series, ok := ctrl.loadSeries(ginc, auth.ActionRead)
+	if !ok {
+		return
+	}
+	ctrl.respondSeries(ginc, series)
+}
+
+// @Summary skip an occurrence of a recurring task
+// @router /task-service/api/v1/series/{seriesId}/exceptions [post]
+// @Param seriesId path int true "series ID"
+// @param params body SeriesException true "occurrence to skip"
+// @Success 200 {object} models.TaskSeriesResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) AddSeriesException(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	exception := SeriesException{}
+	if err := ginc.BindJSON(&exception); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	series, ok := ctrl.loadSeries(ginc, auth.ActionUpdate)
+	if !ok {
+		return
+	}
+
+	rule, err := recurrence.Parse(series.RRule, series.Timezone, series.DTStart)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+	at := exception.OccurrenceAt
+	if next, ok := rule.Next(at.Add(-time.Second), nil); !ok || !next.Equal(at) {
+		ctrl.handleError(ginc, fmt.Errorf("%s is not an occurrence of the series", at.Format(time.RFC3339)), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	if !slices.ContainsFunc(series.Exdates, at.Equal) {
+		series.Exdates = append(series.Exdates, at)
+	}
+	// the occurrence may be the next one to be created
+	if series.NextAt != nil && series.NextAt.Equal(at) {
+		series.NextAt = nil
+		if next, ok := rule.Next(at, series.Exdates); ok {
+			series.NextAt = &next
+		}
+	}
+	ctrl.saveSeries(ginc, series)
+}
+
+// @Summary restore a skipped occurrence of a recurring task
+// @router /task-service/api/v1/series/{seriesId}/exceptions [delete]
+// @Param seriesId path int true "series ID"
+// @Param occurrence_at query string true "RFC3339 start of the skipped occurrence"
+// @Success 200 {object} models.TaskSeriesResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) RemoveSeriesException(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	at, err := time.Parse(time.RFC3339, ginc.Query("occurrence_at"))
+	if err != nil {
+		ctrl.handleError(ginc, fmt.Errorf("invalid occurrence_at: %v", err), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	series, ok := ctrl.loadSeries(ginc, auth.ActionUpdate)
+	if !ok {
+		return
+	}
+
+	index := slices.IndexFunc(series.Exdates, at.Equal)
+	if index < 0 {
+		ctrl.handleError(ginc, fmt.Errorf("%s is not skipped", at.Format(time.RFC3339)), http.StatusNotFound, code.Code_NOT_FOUND)
+		return
+	}
+	series.Exdates = slices.Delete(series.Exdates, index, index+1)
+
+	// bring the occurrence back if the series already moved past it without
+	// creating anything later
+	if series.NextAt != nil && at.Before(*series.NextAt) {
+		later, err := ctrl.seriesMgr.ListOccurrences(ginc, series.ID, at)
+		if err != nil {
+			ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+			return
+		}
+		if len(later) == 0 {
+			series.NextAt = &at
+		}
+	}
+	ctrl.saveSeries(ginc, series)
+}
+
+// createTaskSeries stores task as the first occurrence of a new series built
+// from task.Recurrence.
+func (ctrl *Controller) createTaskSeries(ginc *gin.Context, task *models.Task) error {
+	start := time.Now()
+	if task.Recurrence.StartAt != nil {
+		start = *task.Recurrence.StartAt
+	}
+	start = start.Truncate(time.Second)
+
+	rule, err := recurrence.Parse(task.Recurrence.RRule, task.Recurrence.Timezone, start)
+	if err != nil {
+		return err
+	}
+	first, ok := rule.First(nil)
+	if !ok {
+		return fmt.Errorf("%w: the rule has no occurrence", recurrence.ErrInvalidRule)
+	}
+
+	timezone := task.Recurrence.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	series := models.TaskSeries{
+		RRule:    rule.String(),
+		Timezone: timezone,
+		DTStart:  start,
+		Exdates:  []time.Time{},
+	}
+	series.SetTemplate(*task, first)
+	if next, ok := rule.Next(first, nil); ok {
+		series.NextAt = &next
+	}
+
+	*task = series.Occurrence(first)
+	return ctrl.seriesMgr.CreateSeries(ginc, &series, task)
+}
+
+// updateFutureOccurrences applies the edit of task, an occurrence, to the
+// series and to its open occurrences created after it. A new rule restarts
+// the series at task, dropping the open occurrences the old rule created.
+func (ctrl *Controller) updateFutureOccurrences(ginc *gin.Context, task *models.Task, recur *models.Recurrence) error {
+	series, err := ctrl.seriesMgr.GetSeries(ginc, *task.SeriesID)
+	if err != nil {
+		return err
+	}
+	start := *task.OccurrenceAt
+	series.SetTemplate(*task, start)
+
+	ruleChanged := recur != nil && recur.RRule != ""
+	if ruleChanged {
+		rule, err := recurrence.Parse(recur.RRule, recur.Timezone, start)
+		if err != nil {
+			return err
+		}
+		series.RRule = rule.String()
+		series.Timezone = recur.Timezone
+		if series.Timezone == "" {
+			series.Timezone = "UTC"
+		}
+		series.DTStart = start
+		series.NextAt = nil
+		if next, ok := rule.Next(start, series.Exdates); ok {
+			series.NextAt = &next
+		}
+	}
+
+	later, err := ctrl.seriesMgr.ListOccurrences(ginc, series.ID, start)
+	if err != nil {
+		return err
+	}
+	for _, occurrence := range later {
+		if occurrence.Status != models.TaskStatusOpen {
+			continue
+		}
+		if ruleChanged {
+			if err := ctrl.deleteStoredTask(ginc, occurrence.ID); err != nil {
+				return err
+			}
+			if err := ctrl.cacheMgr.DeleteTask(ginc, occurrence.ID); err != nil {
+				ctrl.ResetCache()
+			}
+			continue
+		}
+
+		updated := series.Occurrence(*occurrence.OccurrenceAt)
+		updated.ID = occurrence.ID
+		updated.Status = occurrence.Status
+		updated.Version = occurrence.Version + 1
+		updated.CreatedAt = occurrence.CreatedAt
+		updated.Rank = occurrence.Rank
+		updated.CommentCount = occurrence.CommentCount
+		if err := ctrl.mysqlMgr.UpdateTask(updateContext(ginc, updated, nil), &updated); err != nil {
+			return err
+		}
+		if err := ctrl.cacheMgr.UpdateTask(ginc, &updated); err != nil {
+			ctrl.ResetCache()
+		}
+	}
+
+	return ctrl.seriesMgr.UpdateSeries(ginc, &series)
+}
+
+// endSeriesAt deletes task, an occurrence, and every occurrence after it and
+// stops the series from creating more.
+func (ctrl *Controller) endSeriesAt(ginc *gin.Context, task models.Task) error {
+	series, err := ctrl.seriesMgr.GetSeries(ginc, *task.SeriesID)
+	if err != nil {
+		return err
+	}
+	later, err := ctrl.seriesMgr.ListOccurrences(ginc, series.ID, *task.OccurrenceAt)
+	if err != nil {
+		return err
+	}
+
+	series.NextAt = nil
+	if err := ctrl.seriesMgr.UpdateSeries(ginc, &series); err != nil {
+		return err
+	}
+	for _, occurrence := range later {
+		if err := ctrl.deleteStoredTask(ginc, occurrence.ID); err != nil {
+			return err
+		}
+		if err := ctrl.cacheMgr.DeleteTask(ginc, occurrence.ID); err != nil {
+			ctrl.ResetCache()
+		}
+	}
+	return nil
+}
+
+// skipOccurrence records task, a deleted occurrence, as an exception of its
+// series.
+func (ctrl *Controller) skipOccurrence(ginc *gin.Context, task models.Task) error {
+	series, err := ctrl.seriesMgr.GetSeries(ginc, *task.SeriesID)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(series.Exdates, task.OccurrenceAt.Equal) {
+		return nil
+	}
+	series.Exdates = append(series.Exdates, *task.OccurrenceAt)
+	return ctrl.seriesMgr.UpdateSeries(ginc, &series)
+}
+
+// materializeAfterClose creates the next occurrence of the series of task,
+// which was just closed, unless another occurrence is still open.
+func (ctrl *Controller) materializeAfterClose(ctx context.Context, task models.Task) {
+	logError := func(err error) {
+		logger.GetLoggerWithContext(ctx, map[string]interface{}{
+			"error":    err,
+			"seriesId": *task.SeriesID,
+		}).Error("materialize next occurrence fail")
+	}
+
+	open, err := ctrl.seriesMgr.CountOpenOccurrences(ctx, *task.SeriesID)
+	if err != nil {
+		logError(err)
+		return
+	}
+	if open > 0 {
+		return
+	}
+	series, err := ctrl.seriesMgr.GetSeries(ctx, *task.SeriesID)
+	if err != nil {
+		logError(err)
+		return
+	}
+
+	next, created, err := occurrence.Materialize(ctx, ctrl.seriesMgr, series)
+	if err != nil {
+		logError(err)
+		return
+	}
+	if !created {
+		return
+	}
+	if err := ctrl.cacheMgr.CreateTask(ctx, []models.Task{next}); err != nil {
+		ctrl.ResetCache()
+	}
+}
+
+// editScope returns the scope query parameter of an edit of task, writing
+// the error response when it is invalid.
+func (ctrl *Controller) editScope(ginc *gin.Context, task models.Task) (string, bool) {
+	scope := ginc.DefaultQuery("scope", scopeThis)
+	switch {
+	case scope != scopeThis && scope != scopeFuture:
+		ctrl.handleError(ginc, fmt.Errorf("invalid scope %q", scope), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return "", false
+	case scope == scopeFuture && task.SeriesID == nil:
+		ctrl.handleError(ginc, fmt.Errorf("task is not recurring"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return "", false
+	}
+	return scope, true
+}
+
+// handleSeriesError maps the errors of the series helpers to a response.
+func (ctrl *Controller) handleSeriesError(ginc *gin.Context, err error) {
+	switch {
+	case errors.Is(err, recurrence.ErrInvalidRule):
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+	case errors.Is(err, data.ErrSeriesConflict):
+		ctrl.handleError(ginc, err, http.StatusConflict, code.Code_ABORTED)
+	default:
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+	}
+}
+
+func (ctrl *Controller) loadSeries(ginc *gin.Context, action auth.Action) (models.TaskSeries, bool) {
+	seriesId, err := strconv.ParseUint(ginc.Param("seriesId"), 10, 64)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return models.TaskSeries{}, false
+	}
+
+	series, err := ctrl.seriesMgr.GetSeries(ginc, seriesId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctrl.handleError(ginc, fmt.Errorf("series not found"), http.StatusNotFound, code.Code_NOT_FOUND)
+		return models.TaskSeries{}, false
+	}
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return models.TaskSeries{}, false
+	}
+
+	// the series is authorized like any of its occurrences
+	template := series.Occurrence(series.DTStart)
+	if !ctrl.authorize(ginc, action, &template) {
+		return models.TaskSeries{}, false
+	}
+	return series, true
+}
+
+func (ctrl *Controller) saveSeries(ginc *gin.Context, series models.TaskSeries) {
+	if err := ctrl.seriesMgr.UpdateSeries(ginc, &series); err != nil {
+		ctrl.handleSeriesError(ginc, err)
+		return
+	}
+	ctrl.respondSeries(ginc, series)
+}
+
+func (ctrl *Controller) respondSeries(ginc *gin.Context, series models.TaskSeries) {
+	ginc.JSON(http.StatusOK, models.TaskSeriesResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.TaskSeries{series},
+	})
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"task_service/c"
+	"task_service/internal/data"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPrimaryStickiness = 5 * time.Second
+	// lastWriteCookie holds the same signed pin as the X-Last-Write header.
+	lastWriteCookie = "task_last_write"
+)
+
+// WithPrimaryStickiness sets how long reads of a client go to the primary
+// after it wrote, so that it always sees its own writes. The time of the
+// write is handed to the client signed with secret, so every instance of the
+// service sharing secret honours it. Without a secret a random one is used
+// and pins only hold on the instance that wrote.
+func WithPrimaryStickiness(window time.Duration, secret string) Option {
+	return func(ctrl *Controller) {
+		ctrl.primaryPins = newPrimaryPins(window, secret)
+	}
+}
+
+// primaryPins signs and checks the times of writes sent back by clients.
+type primaryPins struct {
+	window time.Duration
+	secret []byte
+}
+
+func newPrimaryPins(window time.Duration, secret string) *primaryPins {
+	if window <= 0 {
+		window = defaultPrimaryStickiness
+	}
+	key := []byte(secret)
+	if len(key) == 0 {
+		key = make([]byte, sha256.Size)
+		_, _ = rand.Read(key)
+	}
+	return &primaryPins{
+		window: window,
+		secret: key,
+	}
+}
+
+// token returns the pin of a write at now, its time in milliseconds and the
+// signature of that time.
+func (p *primaryPins) token(now time.Time) string {
+	at := strconv.FormatInt(now.UnixMilli(), 10)
+	return at + "." + p.sign(at)
+}
+
+func (p *primaryPins) sign(at string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(at))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// pinned reports whether token is a pin signed by p whose window is still
+// open at now.
+func (p *primaryPins) pinned(token string, now time.Time) bool {
+	at, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(p.sign(at))) {
+		return false
+	}
+	millis, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return false
+	}
+	return now.Before(time.UnixMilli(millis).Add(p.window))
+}
+
+// lastWrite returns the pin sent by the client in the X-Last-Write header,
+// or in the cookie set with it.
+func lastWrite(ginc *gin.Context) string {
+	if token := ginc.GetHeader(c.HeaderLastWrite); token != "" {
+		return token
+	}
+	token, _ := ginc.Cookie(lastWriteCookie)
+	return token
+}
+
+// pinPrimary hands the client the pin of its write, before the response is
+// written.
+func (ctrl *Controller) pinPrimary(ginc *gin.Context) {
+	token := ctrl.primaryPins.token(time.Now())
+	ginc.Header(c.HeaderLastWrite, token)
+	http.SetCookie(ginc.Writer, &http.Cookie{
+		Name:     lastWriteCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(math.Ceil(ctrl.primaryPins.window.Seconds())),
+		Secure:   ginc.Request.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// readContext returns the context for MySQL reads, pinned to the primary
+// while the last write of the client is inside the stickiness window.
+func (ctrl *Controller) readContext(ginc *gin.Context) context.Context {
+	if ctrl.primaryPins.pinned(lastWrite(ginc), time.Now()) {
+		return data.WithPrimary(ginc)
+	}
+	return ginc
+}
@@ -0,0 +1,386 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"task_service/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxTagLength is the size of the name column of the Tag table.
+	maxTagLength = 50
+	// findTaskPageSize is the number of tasks sharing a name findTask reads
+	// at once.
+	findTaskPageSize = 100
+)
+
+// WithTagManager enables the tag catalog endpoints.
+func WithTagManager(tagMgr data.TagManager) Option {
+	return func(ctrl *Controller) {
+		ctrl.tagMgr = tagMgr
+	}
+}
+
+// MergeTagRequest is the body of the merge tag endpoint.
+type MergeTagRequest struct {
+	Into uint64 `json:"into"`
+}
+
+// @Summary list the tags of the tenant with their task counts
+// @router /task-service/api/v1/tags [get]
+// @Success 200 {object} models.TagResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) ListTags(ginc *gin.Context) {
+	tags, err := ctrl.tagMgr.ListTags(ctrl.readContext(ginc))
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("ListTags fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.TagResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    tags,
+	})
+}
+
+// @Summary get a tag with its task count
+// @router /task-service/api/v1/tags/{tagId} [get]
+// @Param tagId path int true "tag ID"
+// @Success 200 {object} models.TagResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) GetTag(ginc *gin.Context) {
+	tag, ok := ctrl.loadTag(ginc, ginc.Param("tagId"))
+	if !ok {
+		return
+	}
+	ctrl.respondTag(ginc, tag)
+}
+
+// @Summary add a tag to the catalog
+// @router /task-service/api/v1/tags [post]
+// @param params body models.Tag true "tag"
+// @Success 200 {object} models.TagResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) CreateTag(ginc *gin.Context) {
+	tag := models.Tag{}
+	if err := ginc.BindJSON(&tag); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if !ctrl.authorize(ginc, auth.ActionCreate, nil) {
+		return
+	}
+	if !ctrl.checkTagName(ginc, &tag) {
+		return
+	}
+
+	tag.ID = 0
+	if err := ctrl.tagMgr.CreateTag(ginc, &tag); err != nil {
+		ctrl.handleTagError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	ctrl.respondTag(ginc, tag)
+}
+
+// @Summary rename a tag or change its color and description, the tasks having it are updated
+// @router /task-service/api/v1/tags/{tagId} [put]
+// @Param tagId path int true "tag ID"
+// @param params body models.Tag true "tag"
+// @Success 200 {object} models.TagResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) UpdateTag(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	tag := models.Tag{}
+	if err := ginc.BindJSON(&tag); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if !ctrl.authorize(ginc, auth.ActionManageTags, nil) {
+		return
+	}
+	if !ctrl.checkTagName(ginc, &tag) {
+		return
+	}
+	current, ok := ctrl.loadTag(ginc, ginc.Param("tagId"))
+	if !ok {
+		return
+	}
+	taskIds, ok := ctrl.listTaggedTasks(ginc, current.ID)
+	if !ok {
+		return
+	}
+
+	tag.ID = current.ID
+	if err := ctrl.tagMgr.UpdateTag(ginc, &tag); err != nil {
+		ctrl.handleTagError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	if current.Name != tag.Name {
+		ctrl.refreshTaggedTasks(ginc, taskIds)
+	}
+
+	updated, ok := ctrl.loadTag(ginc, ginc.Param("tagId"))
+	if !ok {
+		return
+	}
+	ctrl.respondTag(ginc, updated)
+}
+
+// @Summary delete a tag, removing it from its tasks
+// @router /task-service/api/v1/tags/{tagId} [delete]
+// @Param tagId path int true "tag ID"
+// @Success 200 {object} models.TagResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) DeleteTag(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	if !ctrl.authorize(ginc, auth.ActionManageTags, nil) {
+		return
+	}
+	tag, ok := ctrl.loadTag(ginc, ginc.Param("tagId"))
+	if !ok {
+		return
+	}
+	taskIds, ok := ctrl.listTaggedTasks(ginc, tag.ID)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.tagMgr.DeleteTag(ginc, tag.ID); err != nil {
+		ctrl.handleTagError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	ctrl.refreshTaggedTasks(ginc, taskIds)
+
+	ginc.JSON(http.StatusOK, models.TagResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+	})
+}
+
+// @Summary merge a tag into another one, its tasks get the other tag and the tag is deleted
+// @router /task-service/api/v1/tags/{tagId}/merge [post]
+// @Param tagId path int true "ID of the tag to merge"
+// @param params body MergeTagRequest true "tag to merge into"
+// @Success 200 {object} models.TagResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) MergeTag(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	request := MergeTagRequest{}
+	if err := ginc.BindJSON(&request); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if !ctrl.authorize(ginc, auth.ActionManageTags, nil) {
+		return
+	}
+	source, ok := ctrl.loadTag(ginc, ginc.Param("tagId"))
+	if !ok {
+		return
+	}
+	if request.Into == source.ID {
+		ctrl.handleError(ginc, fmt.Errorf("a tag can not be merged into itself"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	target, ok := ctrl.loadTag(ginc, strconv.FormatUint(request.Into, 10))
+	if !ok {
+		return
+	}
+	taskIds, ok := ctrl.listTaggedTasks(ginc, source.ID)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.tagMgr.MergeTag(ginc, source.ID, target.ID); err != nil {
+		ctrl.handleTagError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	ctrl.refreshTaggedTasks(ginc, taskIds)
+
+	merged, ok := ctrl.loadTag(ginc, strconv.FormatUint(target.ID, 10))
+	if !ok {
+		return
+	}
+	ctrl.respondTag(ginc, merged)
+}
+
+// findTask returns the task of the tenant of ctx named name with exactly
+// tags, or a zero task when there is none. A name and a tag set identify a
+// task within its tenant.
+func (ctrl *Controller) findTask(ctx context.Context, name string, tags []string) (models.Task, error) {
+	tags = utils.NormalizeTags(tags)
+	filter := models.TaskFilter{Name: name, Tags: tags}
+	for offset := 0; ; offset += findTaskPageSize {
+		tasks, err := ctrl.mysqlMgr.ListTask(data.WithPrimary(ctx), findTaskPageSize, offset, "id", filter)
+		if err != nil {
+			return models.Task{}, err
+		}
+		for _, task := range tasks {
+			if slices.Equal(utils.NormalizeTags(task.Tags), tags) {
+				return task, nil
+			}
+		}
+		if len(tasks) < findTaskPageSize {
+			return models.Task{}, nil
+		}
+	}
+}
+
+// checkTags normalizes the tags of a task and responds with 400 when one of
+// them is too long.
+func (ctrl *Controller) checkTags(ginc *gin.Context, tags []string) ([]string, bool) {
+	tags, err := normalizeTags(tags)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return nil, false
+	}
+	return tags, true
+}
+
+// normalizeTags normalizes the tags of a task and fails when one of them is
+// too long.
+func normalizeTags(tags []string) ([]string, error) {
+	tags = utils.NormalizeTags(tags)
+	for _, tag := range tags {
+		if len([]rune(tag)) > maxTagLength {
+			return nil, fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+	}
+	return tags, nil
+}
+
+// checkTagName trims the name of tag and responds with 400 when it is empty
+// or too long.
+func (ctrl *Controller) checkTagName(ginc *gin.Context, tag *models.Tag) bool {
+	tag.Name = strings.TrimSpace(tag.Name)
+	if tag.Name == "" {
+		ctrl.handleError(ginc, fmt.Errorf("tag name is required"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+	_, ok := ctrl.checkTags(ginc, []string{tag.Name})
+	return ok
+}
+
+// extractTagFilter adds the tags and tag_mode query parameters to filter.
+func (ctrl *Controller) extractTagFilter(query url.Values, filter *models.TaskFilter) error {
+	filter.Tags = utils.NormalizeTags(strings.Split(query.Get("tags"), ","))
+	switch mode := query.Get("tag_mode"); mode {
+	case "", "all":
+	case "any":
+		filter.AnyTag = true
+	default:
+		return fmt.Errorf("invalid tag_mode: %s", mode)
+	}
+	return nil
+}
+
+// loadTag reads the tag with id tagId from the primary.
+func (ctrl *Controller) loadTag(ginc *gin.Context, tagId string) (models.Tag, bool) {
+	id, err := strconv.ParseUint(tagId, 10, 64)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return models.Tag{}, false
+	}
+	tag, err := ctrl.tagMgr.GetTag(ginc, id)
+	if err != nil {
+		ctrl.handleTagError(ginc, err)
+		return models.Tag{}, false
+	}
+	return tag, true
+}
+
+// listTaggedTasks returns the ids of the tasks having the tag, read before
+// the tag changes so they can be refreshed in the cache afterwards.
+func (ctrl *Controller) listTaggedTasks(ginc *gin.Context, tagId uint64) ([]uint64, bool) {
+	ids, err := ctrl.tagMgr.ListTaskIdsByTag(ginc, tagId)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return nil, false
+	}
+	return ids, true
+}
+
+// refreshTaggedTasks writes the tasks with the given ids to the cache again
+// after their tags changed.
+func (ctrl *Controller) refreshTaggedTasks(ginc *gin.Context, taskIds []uint64) {
+	if len(taskIds) == 0 {
+		return
+	}
+	tasks, err := ctrl.tagMgr.ListTasksByIds(ginc, taskIds)
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("reload tagged tasks fail")
+		ctrl.ResetCache()
+		return
+	}
+	for i := range tasks {
+		if err := ctrl.cacheMgr.UpdateTask(ginc, &tasks[i]); err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error":  err,
+				"taskId": tasks[i].ID,
+			}).Error("update tagged task in cache fail")
+			ctrl.ResetCache()
+			return
+		}
+	}
+}
+
+func (ctrl *Controller) handleTagError(ginc *gin.Context, err error) {
+	switch {
+	case errors.Is(err, data.ErrTagExists):
+		ctrl.handleError(ginc, err, http.StatusConflict, code.Code_ALREADY_EXISTS)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctrl.handleError(ginc, err, http.StatusNotFound, code.Code_NOT_FOUND)
+	default:
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("tag operation fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+	}
+}
+
+func (ctrl *Controller) respondTag(ginc *gin.Context, tag models.Tag) {
+	ginc.JSON(http.StatusOK, models.TagResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.Tag{tag},
+	})
+}
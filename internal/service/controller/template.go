@@ -0,0 +1,425 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"task_service/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxTemplateSubtasks bounds the tasks one instantiation creates.
+	maxTemplateSubtasks = 50
+	// maxTaskName and maxTaskContent are the sizes of the name and content
+	// columns of the Task table.
+	maxTaskName    = 200
+	maxTaskContent = 500
+)
+
+// WithTemplateManager enables task templates.
+func WithTemplateManager(templateMgr data.TemplateManager) Option {
+	return func(ctrl *Controller) {
+		ctrl.templateMgr = templateMgr
+	}
+}
+
+// InstantiateRequest is the body of the instantiate template endpoint.
+type InstantiateRequest struct {
+	// Variables are the values of the {{variable}} placeholders
+	Variables map[string]string `json:"variables"`
+	// AssigneeID is given to the task and its subtasks
+	AssigneeID string `json:"assignee_id"`
+}
+
+// @Summary list the task templates of the tenant
+// @router /task-service/api/v1/templates [get]
+// @Param limit query int false "limit"
+// @Param offset query int false "offset"
+// @Success 200 {object} models.TaskTemplateResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) ListTemplates(ginc *gin.Context) {
+	limit, offset := ctrl.extractPaginationParams(ginc)
+
+	templates, err := ctrl.templateMgr.ListTemplates(ctrl.readContext(ginc), limit, offset)
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("ListTemplates fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.TaskTemplateResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    templates,
+	})
+}
+
+// @Summary get a task template
+// @router /task-service/api/v1/templates/{templateId} [get]
+// @Param templateId path int true "template ID"
+// @Success 200 {object} models.TaskTemplateResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) GetTemplate(ginc *gin.Context) {
+	template, ok := ctrl.loadTemplate(ginc, false)
+	if !ok {
+		return
+	}
+	ctrl.respondTemplate(ginc, template)
+}
+
+// @Summary add a task template, anyone who can create tasks may add one
+// @router /task-service/api/v1/templates [post]
+// @param params body models.TaskTemplate true "template"
+// @Success 200 {object} models.TaskTemplateResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) CreateTemplate(ginc *gin.Context) {
+	template := models.TaskTemplate{}
+	if err := ginc.BindJSON(&template); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if !ctrl.authorize(ginc, auth.ActionCreate, nil) {
+		return
+	}
+	if !ctrl.checkTemplate(ginc, &template) {
+		return
+	}
+
+	template.ID = 0
+	template.OwnerID = ctrl.principal(ginc).ID
+	if err := ctrl.templateMgr.CreateTemplate(ginc, &template); err != nil {
+		ctrl.handleTemplateError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	ctrl.respondTemplate(ginc, template)
+}
+
+// @Summary replace a task template, only its owner or an admin may change it
+// @router /task-service/api/v1/templates/{templateId} [put]
+// @Param templateId path int true "template ID"
+// @param params body models.TaskTemplate true "template"
+// @Success 200 {object} models.TaskTemplateResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) UpdateTemplate(ginc *gin.Context) {
+	template := models.TaskTemplate{}
+	if err := ginc.BindJSON(&template); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	current, ok := ctrl.loadTemplate(ginc, true)
+	if !ok {
+		return
+	}
+	if !ctrl.checkTemplate(ginc, &template) {
+		return
+	}
+
+	template.ID = current.ID
+	template.OwnerID = current.OwnerID
+	template.CreatedAt = current.CreatedAt
+	if err := ctrl.templateMgr.UpdateTemplate(ginc, &template); err != nil {
+		ctrl.handleTemplateError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+	ctrl.respondTemplate(ginc, template)
+}
+
+// @Summary delete a task template, the tasks created from it are kept
+// @router /task-service/api/v1/templates/{templateId} [delete]
+// @Param templateId path int true "template ID"
+// @Success 200 {object} models.TaskTemplateResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) DeleteTemplate(ginc *gin.Context) {
+	template, ok := ctrl.loadTemplate(ginc, true)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.templateMgr.DeleteTemplate(ginc, template.ID); err != nil {
+		ctrl.handleTemplateError(ginc, err)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+
+	ginc.JSON(http.StatusOK, models.TaskTemplateResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+	})
+}
+
+// @Summary create a task and its subtasks from a template, all of them or none
+// @router /task-service/api/v1/templates/{templateId}/instantiate [post]
+// @Param templateId path int true "template ID"
+// @param params body InstantiateRequest true "variable values"
+// @Success 200 {object} models.Response
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) InstantiateTemplate(ginc *gin.Context) {
+	release, ok := ctrl.lock(ginc)
+	if !ok {
+		return
+	}
+	defer release()
+
+	request := InstantiateRequest{}
+	if err := ginc.BindJSON(&request); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if !ctrl.authorize(ginc, auth.ActionCreate, nil) {
+		return
+	}
+	template, ok := ctrl.loadTemplate(ginc, false)
+	if !ok {
+		return
+	}
+	if !ctrl.checkTaskQuota(ginc, int64(1+len(template.Subtasks))) {
+		return
+	}
+
+	task, ok := ctrl.renderTemplate(ginc, template, request)
+	if !ok {
+		return
+	}
+	if !ctrl.checkNewTaskNames(ginc, task) {
+		return
+	}
+
+	tasks := []models.Task{task}
+	if err := ctrl.mysqlMgr.CreateTask(ginc, tasks); err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error":      err,
+			"templateId": template.ID,
+		}).Error("instantiate template fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+	ctrl.pinPrimary(ginc)
+
+	// read the tasks back for the values set by MySQL, parent first
+	ids := []uint64{tasks[0].ID}
+	for _, subtask := range tasks[0].Subtasks {
+		ids = append(ids, subtask.ID)
+	}
+	created := make([]models.Task, 0, len(ids))
+	for _, id := range ids {
+		createdTask, err := ctrl.mysqlMgr.GetTaskById(data.WithPrimary(ginc), id)
+		if err != nil {
+			ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+			return
+		}
+		created = append(created, createdTask)
+	}
+	if err := ctrl.cacheMgr.CreateTask(ginc, created); err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("insert task into cache fail")
+		ctrl.ResetCache()
+	}
+
+	ginc.JSON(http.StatusOK, models.Response{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    created,
+	})
+}
+
+// checkTemplate validates template and sets its defaults, responding with
+// 400 when it is invalid.
+func (ctrl *Controller) checkTemplate(ginc *gin.Context, template *models.TaskTemplate) bool {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" || len([]rune(template.Name)) > maxTaskName {
+		ctrl.handleError(ginc, fmt.Errorf("template name is required and at most %d characters", maxTaskName), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+	if len(template.Subtasks) > maxTemplateSubtasks {
+		ctrl.handleError(ginc, fmt.Errorf("a template has at most %d subtasks", maxTemplateSubtasks), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+
+	var ok bool
+	patterns := []string{template.TaskName, template.TaskContent}
+	if !ctrl.checkTemplateTask(ginc, template.TaskName, &template.Status, template.Priority) {
+		return false
+	}
+	if template.Tags, ok = ctrl.checkTags(ginc, template.Tags); !ok {
+		return false
+	}
+	for i := range template.Subtasks {
+		subtask := &template.Subtasks[i]
+		if !ctrl.checkTemplateTask(ginc, subtask.Name, &subtask.Status, subtask.Priority) {
+			return false
+		}
+		if subtask.Tags, ok = ctrl.checkTags(ginc, subtask.Tags); !ok {
+			return false
+		}
+		patterns = append(patterns, subtask.Name, subtask.Content)
+	}
+
+	if _, err := utils.TemplateVariables(patterns...); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+	return true
+}
+
+// checkTemplateTask checks the task or a subtask of a template, an unset
+// status defaults to open.
+func (ctrl *Controller) checkTemplateTask(ginc *gin.Context, name string, status *int, priority int) bool {
+	if strings.TrimSpace(name) == "" {
+		ctrl.handleError(ginc, fmt.Errorf("the tasks of a template need a name"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+	if *status == 0 {
+		*status = models.TaskStatusOpen
+	}
+	if *status < models.TaskStatusOpen || *status > models.TaskStatusDone {
+		ctrl.handleError(ginc, fmt.Errorf("invalid status: %d", *status), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return false
+	}
+	return ctrl.checkPriority(ginc, priority)
+}
+
+// renderTemplate builds the task of template, with its subtasks, from the
+// variables of request.
+func (ctrl *Controller) renderTemplate(ginc *gin.Context, template models.TaskTemplate, request InstantiateRequest) (models.Task, bool) {
+	principal := ctrl.principal(ginc)
+	task, ok := ctrl.renderTemplateTask(ginc, template.TaskName, template.TaskContent, request.Variables)
+	if !ok {
+		return models.Task{}, false
+	}
+	task.Tags = template.Tags
+	task.Status = template.Status
+	task.Priority = template.Priority
+	task.OwnerID = principal.ID
+	task.AssigneeID = request.AssigneeID
+	if task.Fields, ok = ctrl.checkFields(ginc, maps.Clone(template.Fields)); !ok {
+		return models.Task{}, false
+	}
+
+	for _, templateSubtask := range template.Subtasks {
+		subtask, ok := ctrl.renderTemplateTask(ginc, templateSubtask.Name, templateSubtask.Content, request.Variables)
+		if !ok {
+			return models.Task{}, false
+		}
+		subtask.Tags = templateSubtask.Tags
+		subtask.Status = templateSubtask.Status
+		subtask.Priority = templateSubtask.Priority
+		subtask.OwnerID = principal.ID
+		subtask.AssigneeID = request.AssigneeID
+		subtask.Progress = utils.Progress(subtask.Status, nil)
+		if subtask.Fields, ok = ctrl.checkFields(ginc, maps.Clone(templateSubtask.Fields)); !ok {
+			return models.Task{}, false
+		}
+		task.Subtasks = append(task.Subtasks, subtask)
+	}
+	task.Progress = utils.Progress(task.Status, task.Subtasks)
+	return task, true
+}
+
+// renderTemplateTask renders the name and content of a task, responding with
+// 400 when a variable is missing or the result is too long.
+func (ctrl *Controller) renderTemplateTask(ginc *gin.Context, namePattern, contentPattern string, variables map[string]string) (models.Task, bool) {
+	name, err := utils.RenderTemplate(namePattern, variables)
+	if err == nil {
+		name = strings.TrimSpace(name)
+		if name == "" || len([]rune(name)) > maxTaskName {
+			err = fmt.Errorf("task name %q must be 1 to %d characters", name, maxTaskName)
+		}
+	}
+	content := ""
+	if err == nil {
+		content, err = utils.RenderTemplate(contentPattern, variables)
+	}
+	if err == nil && len([]rune(content)) > maxTaskContent {
+		err = fmt.Errorf("content of task %q is longer than %d characters", name, maxTaskContent)
+	}
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return models.Task{}, false
+	}
+	return models.Task{Name: name, Content: content}, true
+}
+
+// checkNewTaskNames responds with 400 when task or one of its subtasks would
+// reuse the name and tags of a task of the tenant or of another one of them.
+func (ctrl *Controller) checkNewTaskNames(ginc *gin.Context, task models.Task) bool {
+	names := map[string]bool{}
+	for _, t := range append([]models.Task{task}, task.Subtasks...) {
+		existing, err := ctrl.findTask(ginc, t.Name, t.Tags)
+		if err != nil {
+			ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+			return false
+		}
+		key := t.Name + "\x00" + strings.Join(t.Tags, "\x00")
+		if existing.ID != 0 || names[key] {
+			ctrl.handleError(ginc, fmt.Errorf("task %q with tags %v is exist", t.Name, t.Tags), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+			return false
+		}
+		names[key] = true
+	}
+	return true
+}
+
+// loadTemplate reads the template of the templateId path parameter from the
+// primary. With modify, only the owner of the template or an admin passes.
+func (ctrl *Controller) loadTemplate(ginc *gin.Context, modify bool) (models.TaskTemplate, bool) {
+	templateId, err := strconv.ParseUint(ginc.Param("templateId"), 10, 64)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return models.TaskTemplate{}, false
+	}
+	template, err := ctrl.templateMgr.GetTemplate(ginc, templateId)
+	if err != nil {
+		ctrl.handleTemplateError(ginc, err)
+		return models.TaskTemplate{}, false
+	}
+
+	principal := ctrl.principal(ginc)
+	if modify && template.OwnerID != principal.ID && !principal.IsAdmin() {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"principal":  principal.ID,
+			"templateId": template.ID,
+		}).Warn("permission denied")
+		ctrl.handleError(ginc, fmt.Errorf("permission denied: only the owner may change a template"), http.StatusForbidden, code.Code_PERMISSION_DENIED)
+		return models.TaskTemplate{}, false
+	}
+	return template, true
+}
+
+func (ctrl *Controller) handleTemplateError(ginc *gin.Context, err error) {
+	switch {
+	case errors.Is(err, data.ErrTemplateExists):
+		ctrl.handleError(ginc, err, http.StatusConflict, code.Code_ALREADY_EXISTS)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		ctrl.handleError(ginc, err, http.StatusNotFound, code.Code_NOT_FOUND)
+	default:
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("template operation fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+	}
+}
+
+func (ctrl *Controller) respondTemplate(ginc *gin.Context, template models.TaskTemplate) {
+	ginc.JSON(http.StatusOK, models.TaskTemplateResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.TaskTemplate{template},
+	})
+}
@@ -0,0 +1,286 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"gorm.io/gorm"
+)
+
+// tenant ids are part of the redis hash tag, so braces and the like are not allowed.
+var tenantIdPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// WithTenantManager enables the tenant admin endpoints and the task quota.
+func WithTenantManager(tenantMgr data.TenantManager) Option {
+	return func(ctrl *Controller) {
+		ctrl.tenantMgr = tenantMgr
+	}
+}
+
+// @Summary create tenant, superadmin only
+// @router /task-service/api/v1/tenants [post]
+// @param params body models.Tenant true "tenant"
+// @Success 200 {object} models.TenantResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) CreateTenant(ginc *gin.Context) {
+	if !ctrl.requireSuperAdmin(ginc) {
+		return
+	}
+
+	tenant := models.Tenant{}
+	if err := ginc.BindJSON(&tenant); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if !tenantIdPattern.MatchString(tenant.ID) {
+		ctrl.handleError(ginc, fmt.Errorf("invalid tenant id %q", tenant.ID), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if tenant.Name == "" {
+		tenant.Name = tenant.ID
+	}
+	if tenant.MaxTasks < 0 {
+		ctrl.handleError(ginc, fmt.Errorf("max_tasks must not be negative"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	tenant.Status = models.TenantStatusActive
+
+	if _, err := ctrl.tenantMgr.GetTenantById(data.WithPrimary(ginc), tenant.ID); err == nil {
+		ctrl.handleError(ginc, fmt.Errorf("tenant is exist"), http.StatusBadRequest, code.Code_ALREADY_EXISTS)
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	if err := ctrl.tenantMgr.CreateTenant(ginc, &tenant); err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error":    err,
+			"tenantId": tenant.ID,
+		}).Error("CreateTenant fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.TenantResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.Tenant{tenant},
+	})
+}
+
+// @Summary list tenants, superadmin only
+// @router /task-service/api/v1/tenants [get]
+// @Param limit query int false "limit"
+// @Param offset query int false "offset"
+// @Success 200 {object} models.TenantResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) ListTenant(ginc *gin.Context) {
+	if !ctrl.requireSuperAdmin(ginc) {
+		return
+	}
+
+	limit, offset := ctrl.extractPaginationParams(ginc)
+	tenants, err := ctrl.tenantMgr.ListTenant(ginc, limit, offset)
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("ListTenant fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.TenantResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    tenants,
+	})
+}
+
+// @Summary get tenant, superadmin only
+// @router /task-service/api/v1/tenants/{tenantId} [get]
+// @Param tenantId path string true "tenant ID"
+// @Success 200 {object} models.TenantResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) GetTenant(ginc *gin.Context) {
+	if !ctrl.requireSuperAdmin(ginc) {
+		return
+	}
+
+	tenant, ok := ctrl.loadTenant(ginc)
+	if !ok {
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.TenantResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.Tenant{tenant},
+	})
+}
+
+// @Summary update tenant name and quota, superadmin only
+// @router /task-service/api/v1/tenants/{tenantId} [put]
+// @Param tenantId path string true "tenant ID"
+// @param params body models.Tenant true "tenant"
+// @Success 200 {object} models.TenantResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) UpdateTenant(ginc *gin.Context) {
+	if !ctrl.requireSuperAdmin(ginc) {
+		return
+	}
+
+	req := models.Tenant{}
+	if err := ginc.BindJSON(&req); err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	if req.MaxTasks < 0 {
+		ctrl.handleError(ginc, fmt.Errorf("max_tasks must not be negative"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	tenant, ok := ctrl.loadTenant(ginc)
+	if !ok {
+		return
+	}
+	if req.Name != "" {
+		tenant.Name = req.Name
+	}
+	tenant.MaxTasks = req.MaxTasks
+
+	ctrl.saveTenant(ginc, &tenant)
+}
+
+// @Summary suspend tenant, its requests are rejected until it is resumed
+// @router /task-service/api/v1/tenants/{tenantId}/suspend [post]
+// @Param tenantId path string true "tenant ID"
+// @Success 200 {object} models.TenantResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) SuspendTenant(ginc *gin.Context) {
+	ctrl.setTenantStatus(ginc, models.TenantStatusSuspended)
+}
+
+// @Summary resume suspended tenant
+// @router /task-service/api/v1/tenants/{tenantId}/resume [post]
+// @Param tenantId path string true "tenant ID"
+// @Success 200 {object} models.TenantResponse
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) ResumeTenant(ginc *gin.Context) {
+	ctrl.setTenantStatus(ginc, models.TenantStatusActive)
+}
+
+func (ctrl *Controller) setTenantStatus(ginc *gin.Context, status string) {
+	if !ctrl.requireSuperAdmin(ginc) {
+		return
+	}
+
+	tenant, ok := ctrl.loadTenant(ginc)
+	if !ok {
+		return
+	}
+	if tenant.ID == c.DefaultTenant && status == models.TenantStatusSuspended {
+		ctrl.handleError(ginc, fmt.Errorf("default tenant can not be suspended"), http.StatusBadRequest, code.Code_FAILED_PRECONDITION)
+		return
+	}
+	tenant.Status = status
+
+	ctrl.saveTenant(ginc, &tenant)
+}
+
+func (ctrl *Controller) saveTenant(ginc *gin.Context, tenant *models.Tenant) {
+	if err := ctrl.tenantMgr.UpdateTenant(ginc, tenant); err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error":    err,
+			"tenantId": tenant.ID,
+		}).Error("UpdateTenant fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.TenantResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.Tenant{*tenant},
+	})
+}
+
+func (ctrl *Controller) loadTenant(ginc *gin.Context) (models.Tenant, bool) {
+	tenant, err := ctrl.tenantMgr.GetTenantById(data.WithPrimary(ginc), ginc.Param("tenantId"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctrl.handleError(ginc, err, http.StatusNotFound, code.Code_NOT_FOUND)
+		return models.Tenant{}, false
+	}
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return models.Tenant{}, false
+	}
+	return tenant, true
+}
+
+func (ctrl *Controller) requireSuperAdmin(ginc *gin.Context) bool {
+	principal := ctrl.principal(ginc)
+	if principal.IsSuperAdmin() {
+		return true
+	}
+
+	logger.GetLoggerWithContext(ginc, map[string]interface{}{
+		"principal": principal.ID,
+		"path":      ginc.FullPath(),
+	}).Warn("permission denied")
+	ctrl.handleError(ginc, fmt.Errorf("permission denied: superadmin required"), http.StatusForbidden, code.Code_PERMISSION_DENIED)
+	return false
+}
+
+// errTaskQuota is returned by taskQuota when the tenant has no room for the
+// new tasks.
+var errTaskQuota = errors.New("task quota exceeded")
+
+// checkTaskQuota responds with 429 when creating count more tasks would
+// exceed the task quota of the tenant of the request.
+func (ctrl *Controller) checkTaskQuota(ginc *gin.Context, count int64) bool {
+	if err := ctrl.taskQuota(ginc, count); err != nil {
+		if errors.Is(err, errTaskQuota) {
+			ctrl.handleError(ginc, err, http.StatusTooManyRequests, code.Code_RESOURCE_EXHAUSTED)
+			return false
+		}
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return false
+	}
+	return true
+}
+
+// taskQuota fails with errTaskQuota when creating count more tasks would
+// exceed the task quota of the tenant of ctx.
+func (ctrl *Controller) taskQuota(ctx context.Context, count int64) error {
+	if ctrl.tenantMgr == nil {
+		return nil
+	}
+
+	tenantId := data.TenantFromContext(ctx)
+	tenant, err := ctrl.tenantMgr.GetTenantById(ctx, tenantId)
+	if err != nil {
+		return err
+	}
+	if tenant.MaxTasks <= 0 {
+		return nil
+	}
+
+	existing, err := ctrl.tenantMgr.CountTenantTask(ctx, tenantId)
+	if err != nil {
+		return err
+	}
+	if existing+count > tenant.MaxTasks {
+		return fmt.Errorf("%w: tenant %s reached its quota of %d tasks", errTaskQuota, tenantId, tenant.MaxTasks)
+	}
+	return nil
+}
@@ -0,0 +1,554 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"task_service/pkg/transfer"
+	"task_service/pkg/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"gorm.io/gorm"
+)
+
+const (
+	// exportPageSize is the number of tasks read from MySQL at a time by an
+	// export.
+	exportPageSize = 500
+	// importBatchSize is the number of rows imported under one task lock,
+	// the progress of the job is saved after each batch.
+	importBatchSize      = 100
+	defaultImportMaxSize = 10 << 20
+	defaultImportMaxRows = 10000
+	// importLockRetry is how long an import waits before trying again to
+	// take the task lock held by a request.
+	importLockRetry = 100 * time.Millisecond
+	// maxImportJobError is the size of the error column of the ImportJob table.
+	maxImportJobError = 500
+)
+
+// WithImports enables the import of tasks, keeping the progress of the
+// import jobs in importMgr. An import reads at most maxSize bytes and
+// maxRows rows.
+func WithImports(importMgr data.ImportManager, maxSize int64, maxRows int) Option {
+	return func(ctrl *Controller) {
+		if maxSize <= 0 {
+			maxSize = defaultImportMaxSize
+		}
+		if maxRows <= 0 {
+			maxRows = defaultImportMaxRows
+		}
+		ctrl.importMgr = importMgr
+		ctrl.importMaxSize = maxSize
+		ctrl.importMaxRows = maxRows
+		ctrl.importCtx, ctrl.stopImports = context.WithCancel(context.Background())
+	}
+}
+
+// @Summary export the visible tasks as a stream of csv, json or ndjson
+// @router /task-service/api/v1/tasks/export [get]
+// @Param format query string false "csv, json (default) or ndjson"
+// @Param order query string false "order"
+// @Param due_before query string false "only tasks due before this RFC3339 time"
+// @Param due_after query string false "only tasks due at or after this RFC3339 time"
+// @Param overdue query bool false "only open tasks past their due date"
+// @Param tags query string false "comma separated tag names"
+// @Param tag_mode query string false "all (default) or any of the tags"
+// @Param field.{key} query string false "only tasks whose custom field key has this value"
+// @Success 200 {file} file
+// @Failure 400 {object} models.HttpError
+func (ctrl *Controller) ExportTasks(ginc *gin.Context) {
+	format, err := transfer.ParseFormat(ginc.Query("format"), transfer.FormatJSON)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	order, err := ctrl.extractOrder(ginc)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	filter, err := ctrl.extractTaskFilter(ginc, order)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	// the export does not take the task lock, which would hold up every
+	// write while it streams, so it is not a snapshot of the tasks
+	ctx := ctrl.readContext(ginc)
+	tasks, err := ctrl.mysqlMgr.ListTask(ctx, exportPageSize, 0, order, filter)
+	if err != nil {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error": err,
+		}).Error("ExportTasks fail")
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	encoder, err := transfer.NewEncoder(format, ginc.Writer)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+	ginc.Header("Content-Type", transfer.ContentType(format))
+	ginc.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="tasks.%s"`, format))
+	ginc.Status(http.StatusOK)
+
+	logError := func(err error, exported int) {
+		// the status is sent already, the output simply ends early
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"error":    err,
+			"exported": exported,
+		}).Error("ExportTasks fail")
+	}
+	exported := 0
+	for {
+		for _, task := range tasks {
+			if err := encoder.Encode(task); err != nil {
+				logError(err, exported)
+				return
+			}
+			exported++
+		}
+		ginc.Writer.Flush()
+		if len(tasks) < exportPageSize {
+			break
+		}
+		if tasks, err = ctrl.mysqlMgr.ListTask(ctx, exportPageSize, exported, order, filter); err != nil {
+			logError(err, exported)
+			return
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		logError(err, exported)
+	}
+}
+
+// @Summary import tasks in the background, creating new tasks and updating the tasks with the same name
+// @router /task-service/api/v1/tasks/import [post]
+// @Param format query string false "csv, json or ndjson, taken from the Content-Type by default"
+// @Param dry_run query bool false "only validate the rows"
+// @Success 202 {object} models.ImportJobResponse
+// @Failure 400 {object} models.HttpError
+// @Failure 413 {object} models.HttpError
+func (ctrl *Controller) ImportTasks(ginc *gin.Context) {
+	if !ctrl.authorize(ginc, auth.ActionCreate, nil) {
+		return
+	}
+	format, err := transfer.ParseFormat(ginc.Query("format"), transfer.FormatOf(ginc.ContentType()))
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	dryRun, err := strconv.ParseBool(ginc.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		ctrl.handleError(ginc, fmt.Errorf("invalid dry_run: %v", err), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	body := http.MaxBytesReader(ginc.Writer, ginc.Request.Body, ctrl.importMaxSize)
+	rows, err := transfer.ReadAll(format, body, ctrl.importMaxRows)
+	if err != nil {
+		ctrl.handleImportError(ginc, err)
+		return
+	}
+	if len(rows) == 0 {
+		ctrl.handleError(ginc, fmt.Errorf("no tasks to import"), http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+
+	principal := ctrl.principal(ginc)
+	job := models.ImportJob{
+		OwnerID: principal.ID,
+		Format:  format,
+		DryRun:  dryRun,
+		Status:  models.ImportStatusPending,
+		Total:   len(rows),
+		Errors:  []models.ErrorDetails{},
+	}
+	if err := ctrl.importMgr.CreateImportJob(ginc, &job); err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	// the job outlives the request, it keeps the tenant, principal and
+	// logger of the request but is only cancelled by Shutdown
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ginc.Request.Context()))
+	stop := context.AfterFunc(ctrl.importCtx, cancel)
+	ctrl.imports.Add(1)
+	go func() {
+		defer ctrl.imports.Done()
+		defer cancel()
+		defer stop()
+		ctrl.runImport(ctx, principal, job, rows)
+	}()
+
+	ginc.JSON(http.StatusAccepted, models.ImportJobResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.ImportJob{job},
+	})
+}
+
+// @Summary get the progress and row errors of an import
+// @router /task-service/api/v1/tasks/import/{jobId} [get]
+// @Param jobId path int true "import job ID"
+// @Success 200 {object} models.ImportJobResponse
+// @Failure 404 {object} models.HttpError
+func (ctrl *Controller) GetImportJob(ginc *gin.Context) {
+	jobId, err := strconv.ParseUint(ginc.Param("jobId"), 10, 64)
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+		return
+	}
+	job, err := ctrl.importMgr.GetImportJob(ginc, jobId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ctrl.handleError(ginc, err, http.StatusNotFound, code.Code_NOT_FOUND)
+		return
+	}
+	if err != nil {
+		ctrl.handleError(ginc, err, http.StatusInternalServerError, code.Code_INTERNAL)
+		return
+	}
+
+	principal := ctrl.principal(ginc)
+	if job.OwnerID != principal.ID && !principal.IsAdmin() {
+		logger.GetLoggerWithContext(ginc, map[string]interface{}{
+			"principal": principal.ID,
+			"jobId":     job.ID,
+		}).Warn("permission denied")
+		ctrl.handleError(ginc, fmt.Errorf("permission denied: only the owner may read an import"), http.StatusForbidden, code.Code_PERMISSION_DENIED)
+		return
+	}
+
+	ginc.JSON(http.StatusOK, models.ImportJobResponse{
+		Code:    code.Code_OK,
+		Message: c.Success,
+		Data:    []models.ImportJob{job},
+	})
+}
+
+// runImport imports rows batch by batch, saving the progress of job after
+// each of them.
+func (ctrl *Controller) runImport(ctx context.Context, principal *auth.Principal, job models.ImportJob, rows []transfer.Row) {
+	job.Status = models.ImportStatusRunning
+	ctrl.saveImportJob(ctx, &job)
+
+	imp, err := ctrl.newImporter(ctx, principal, job.DryRun)
+	for start := 0; start < len(rows) && err == nil; start += importBatchSize {
+		err = ctrl.importBatch(ctx, imp, &job, rows[start:min(start+importBatchSize, len(rows))])
+		if err == nil {
+			ctrl.saveImportJob(ctx, &job)
+		}
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = models.ImportStatusDone
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("the import was interrupted by a shutdown of the service")
+		}
+		logger.GetLoggerWithContext(ctx, map[string]interface{}{
+			"error": err,
+			"jobId": job.ID,
+		}).Error("import tasks fail")
+		job.Status = models.ImportStatusFailed
+		job.Error = err.Error()
+		if runes := []rune(job.Error); len(runes) > maxImportJobError {
+			job.Error = string(runes[:maxImportJobError])
+		}
+	}
+	ctrl.saveImportJob(context.WithoutCancel(ctx), &job)
+}
+
+// importBatch imports rows under the task lock, a dry run reads only and
+// goes without it. Row errors are recorded in job, any other error stops
+// the import.
+func (ctrl *Controller) importBatch(ctx context.Context, imp *importer, job *models.ImportJob, rows []transfer.Row) error {
+	if !imp.dryRun {
+		release, err := ctrl.waitImportLock(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	for _, row := range rows {
+		created, err := imp.importRow(ctx, row)
+		var rowErr rowError
+		switch {
+		case errors.As(err, &rowErr):
+			job.AddError(row.Line, err)
+		case err != nil:
+			return fmt.Errorf("row %d: %v", row.Line, err)
+		case created:
+			job.Created++
+		default:
+			job.Updated++
+		}
+		job.Processed++
+	}
+	return nil
+}
+
+// waitImportLock takes the global task lock for a batch of an import,
+// waiting while a request holds it.
+func (ctrl *Controller) waitImportLock(ctx context.Context) (release func(), err error) {
+	for {
+		release, ok, err := data.TryLock(ctx, c.LockKey, c.LockExpiration, ctrl.cacheMgr, ctrl.mysqlMgr)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return release, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(importLockRetry):
+		}
+	}
+}
+
+// saveImportJob saves the progress of job, a failure is only logged as the
+// import itself goes on.
+func (ctrl *Controller) saveImportJob(ctx context.Context, job *models.ImportJob) {
+	if err := ctrl.importMgr.UpdateImportJob(ctx, job); err != nil {
+		logger.GetLoggerWithContext(ctx, map[string]interface{}{
+			"error": err,
+			"jobId": job.ID,
+		}).Error("save import job fail")
+	}
+}
+
+// handleImportError responds to a failure to read the body of an import,
+// which is the doing of the client unless the body is too large.
+func (ctrl *Controller) handleImportError(ginc *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		ctrl.handleError(ginc, fmt.Errorf("import is larger than %d bytes", ctrl.importMaxSize), http.StatusRequestEntityTooLarge, code.Code_INVALID_ARGUMENT)
+	case errors.Is(err, transfer.ErrTooManyRows):
+		ctrl.handleError(ginc, err, http.StatusRequestEntityTooLarge, code.Code_INVALID_ARGUMENT)
+	default:
+		ctrl.handleError(ginc, err, http.StatusBadRequest, code.Code_INVALID_ARGUMENT)
+	}
+}
+
+// rowError is an error of a single row of an import, the following rows
+// are still imported.
+type rowError struct {
+	error
+}
+
+func invalidRow(format string, args ...interface{}) error {
+	return rowError{fmt.Errorf(format, args...)}
+}
+
+// importer imports the rows of a job one at a time. Each row creates a
+// task, or replaces the attributes of the task of the tenant with the same
+// name like UpdateTask. A dry run stops short of writing.
+type importer struct {
+	ctrl      *Controller
+	principal *auth.Principal
+	dryRun    bool
+	// fields are the custom fields of the tenant when the import starts
+	fields []models.CustomField
+	// names holds the line of each task name and tag set imported so far,
+	// a pair used twice is most likely a mistake rather than a request to
+	// update the task just imported
+	names map[string]int
+	// created counts the tasks created so far, a dry run checks the quota
+	// for them as well
+	created int64
+}
+
+func (ctrl *Controller) newImporter(ctx context.Context, principal *auth.Principal, dryRun bool) (*importer, error) {
+	imp := &importer{
+		ctrl:      ctrl,
+		principal: principal,
+		dryRun:    dryRun,
+		names:     map[string]int{},
+	}
+	if ctrl.fieldMgr != nil {
+		fields, err := ctrl.fieldMgr.ListFields(data.WithPrimary(ctx))
+		if err != nil {
+			return nil, err
+		}
+		imp.fields = fields
+	}
+	return imp, nil
+}
+
+// importRow validates row and creates or updates its task, reporting
+// whether the task was created.
+func (imp *importer) importRow(ctx context.Context, row transfer.Row) (created bool, err error) {
+	if row.Err != nil {
+		return false, rowError{row.Err}
+	}
+	task, err := imp.check(row.Task)
+	if err != nil {
+		return false, err
+	}
+	key := task.Name + "\x00" + strings.Join(task.Tags, "\x00")
+	if line, ok := imp.names[key]; ok {
+		return false, invalidRow("task %q with tags %v is imported by row %d already", task.Name, task.Tags, line)
+	}
+	imp.names[key] = row.Line
+
+	existing, err := imp.ctrl.findTask(ctx, task.Name, task.Tags)
+	if err != nil {
+		return false, err
+	}
+	if existing.ID == 0 {
+		return true, imp.create(ctx, task)
+	}
+	return false, imp.update(ctx, existing, task)
+}
+
+// check validates the attributes of task and sets their defaults.
+func (imp *importer) check(task models.Task) (models.Task, error) {
+	task.Name = strings.TrimSpace(task.Name)
+	if task.Name == "" || len([]rune(task.Name)) > maxTaskName {
+		return task, invalidRow("name is required and at most %d characters", maxTaskName)
+	}
+	if len([]rune(task.Content)) > maxTaskContent {
+		return task, invalidRow("content is longer than %d characters", maxTaskContent)
+	}
+	if task.Status == 0 {
+		task.Status = models.TaskStatusOpen
+	}
+	if task.Status < models.TaskStatusOpen || task.Status > models.TaskStatusDone {
+		return task, invalidRow("invalid status: %d", task.Status)
+	}
+	if !models.ValidPriority(task.Priority) {
+		return task, invalidRow("invalid priority: %d", task.Priority)
+	}
+
+	var err error
+	if task.Tags, err = normalizeTags(task.Tags); err != nil {
+		return task, rowError{err}
+	}
+	if imp.ctrl.fieldMgr == nil {
+		if len(task.Fields) > 0 {
+			return task, invalidRow("custom fields are not enabled")
+		}
+		return task, nil
+	}
+	if task.Fields, err = models.CheckFields(imp.fields, task.Fields); err != nil {
+		return task, rowError{err}
+	}
+	return task, nil
+}
+
+func (imp *importer) create(ctx context.Context, task models.Task) error {
+	ctrl := imp.ctrl
+	if !auth.Authorize(imp.principal, auth.ActionCreate, nil) {
+		return invalidRow("permission denied: %s", auth.ActionCreate)
+	}
+	count := int64(1)
+	if imp.dryRun {
+		count += imp.created
+	}
+	if err := ctrl.taskQuota(ctx, count); err != nil {
+		if errors.Is(err, errTaskQuota) {
+			return rowError{err}
+		}
+		return err
+	}
+	// only admins may create tasks on behalf of another owner
+	if task.OwnerID == "" || !imp.principal.IsAdmin() {
+		task.OwnerID = imp.principal.ID
+	}
+	task.Progress = utils.Progress(task.Status, nil)
+	imp.created++
+	if imp.dryRun {
+		return nil
+	}
+
+	if err := ctrl.mysqlMgr.CreateTask(ctx, []models.Task{task}); err != nil {
+		return err
+	}
+	task, err := ctrl.findTask(ctx, task.Name, task.Tags)
+	if err != nil {
+		return err
+	}
+	if err := ctrl.cacheMgr.CreateTask(ctx, []models.Task{task}); err != nil {
+		logger.GetLoggerWithContext(ctx, map[string]interface{}{
+			"error": err,
+		}).Error("insert task into cache fail")
+		ctrl.ResetCache()
+	}
+	return nil
+}
+
+func (imp *importer) update(ctx context.Context, existing, task models.Task) error {
+	ctrl := imp.ctrl
+	if !auth.Authorize(imp.principal, auth.ActionUpdate, &existing) {
+		return invalidRow("permission denied: %s", auth.ActionUpdate)
+	}
+	if task.OwnerID != "" && task.OwnerID != existing.OwnerID {
+		if !auth.Authorize(imp.principal, auth.ActionChangeOwner, &existing) {
+			return invalidRow("permission denied: %s", auth.ActionChangeOwner)
+		}
+		existing.OwnerID = task.OwnerID
+	}
+	if ctrl.dependencyMgr != nil {
+		if err := ctrl.blockedBy(ctx, existing, task.Status); err != nil {
+			if errors.Is(err, errTaskBlocked) {
+				return rowError{err}
+			}
+			return err
+		}
+	}
+
+	wasOpen := existing.Status == models.TaskStatusOpen
+	existing.Content = task.Content
+	existing.Status = task.Status
+	existing.Priority = task.Priority
+	existing.Tags = task.Tags
+	existing.Fields = task.Fields
+	existing.AssigneeID = task.AssigneeID
+	existing.DueAt = task.DueAt
+	existing.RemindAt = task.RemindAt
+	existing.Version += 1
+	if ctrl.hierarchyMgr != nil {
+		children, err := ctrl.hierarchyMgr.ListChildren(ctx, existing.ID)
+		if err != nil {
+			return err
+		}
+		existing.Progress = utils.Progress(existing.Status, children)
+	}
+	if imp.dryRun {
+		return nil
+	}
+
+	if err := ctrl.mysqlMgr.UpdateTask(updateContext(ctx, existing, nil), &existing); err != nil {
+		return err
+	}
+	if err := ctrl.cacheMgr.UpdateTask(ctx, &existing); err != nil {
+		logger.GetLoggerWithContext(ctx, map[string]interface{}{
+			"error": err,
+		}).Error("update task from cache fail")
+		ctrl.ResetCache()
+	}
+	if ctrl.hierarchyMgr != nil {
+		ctrl.rollupProgress(ctx, existing.ParentID)
+	}
+	if existing.SeriesID != nil && ctrl.seriesMgr != nil && wasOpen && existing.Status != models.TaskStatusOpen {
+		ctrl.materializeAfterClose(ctx, existing)
+	}
+	return nil
+}
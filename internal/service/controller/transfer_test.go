@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/models"
+	"task_service/pkg/transfer"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportRowUpsertsByNameAndTags(t *testing.T) {
+	store := newFakeTaskStore(
+		models.Task{ID: 1, TenantID: c.DefaultTenant, Name: "report", Tags: []string{"finance"}, OwnerID: "alice", Status: models.TaskStatusOpen, Version: 1},
+		models.Task{ID: 2, TenantID: c.DefaultTenant, Name: "report", Tags: []string{"finance", "q3"}, OwnerID: "alice", Status: models.TaskStatusOpen, Version: 1},
+	)
+	ctrl := newTestController(t, store)
+	ctx := data.WithTenant(context.Background(), c.DefaultTenant)
+	principal := &auth.Principal{ID: "alice", Type: auth.PrincipalTypeUser, Roles: []string{auth.RoleEditor}}
+	imp, err := ctrl.newImporter(ctx, principal, false)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		task        models.Task
+		wantCreated bool
+		wantId      uint64
+		wantErr     bool
+	}{
+		{
+			name:   "same name and tags updates the task",
+			task:   models.Task{Name: "report", Tags: []string{"q3", " finance"}, Content: "updated"},
+			wantId: 2,
+		},
+		{
+			name:        "same name with other tags creates a task",
+			task:        models.Task{Name: "report", Tags: []string{"audit"}, Content: "created"},
+			wantCreated: true,
+			wantId:      3,
+		},
+		{
+			name:    "same name and tags twice in one import is an error",
+			task:    models.Task{Name: "report", Tags: []string{"finance", "q3"}},
+			wantErr: true,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created, err := imp.importRow(ctx, transfer.Row{Line: i + 1, Task: tt.task})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCreated, created)
+			assert.Equal(t, tt.task.Content, store.tasks[tt.wantId].Content)
+		})
+	}
+	assert.Empty(t, store.tasks[1].Content)
+}
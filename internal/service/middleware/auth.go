@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"task_service/c"
+	"task_service/config"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// Authenticate resolves the principal of the request from a bearer JWT or an
+// API key and stores it in the request context. When authentication is
+// disabled every request runs as auth.Anonymous.
+func Authenticate(option config.AuthOption, verifier *auth.Verifier, apiKeyMgr data.ApiKeyManager) gin.HandlerFunc {
+	apiKeyHeader := option.ApiKeyHeader
+	if apiKeyHeader == "" {
+		apiKeyHeader = c.HeaderApiKey
+	}
+
+	return func(ginc *gin.Context) {
+		if !option.Enable {
+			setPrincipal(ginc, auth.Anonymous())
+			ginc.Next()
+			return
+		}
+
+		var principal *auth.Principal
+		var err error
+
+		if key := ginc.GetHeader(apiKeyHeader); key != "" {
+			principal, err = authenticateApiKey(ginc, apiKeyMgr, key)
+		} else if token, ok := bearerToken(ginc.GetHeader("Authorization")); ok {
+			principal, err = verifier.Verify(token)
+		} else {
+			err = fmt.Errorf("missing credentials")
+		}
+
+		if err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+				"path":  ginc.FullPath(),
+			}).Warn("Authenticate fail")
+			ginc.Header("WWW-Authenticate", `Bearer realm="task-service"`)
+			ginc.AbortWithStatusJSON(http.StatusUnauthorized, models.HttpError{
+				Code:    code.Code_UNAUTHENTICATED,
+				Message: "unauthenticated",
+			})
+			return
+		}
+
+		setPrincipal(ginc, principal)
+		ginc.Next()
+	}
+}
+
+func authenticateApiKey(ginc *gin.Context, apiKeyMgr data.ApiKeyManager, key string) (*auth.Principal, error) {
+	apiKey, err := apiKeyMgr.GetApiKeyByHash(ginc, auth.HashApiKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if !apiKey.Active(time.Now()) {
+		return nil, fmt.Errorf("api key %d is revoked or expired", apiKey.ID)
+	}
+
+	return &auth.Principal{
+		ID:       apiKey.OwnerID,
+		Name:     apiKey.Name,
+		Type:     auth.PrincipalTypeApiKey,
+		ApiKeyID: apiKey.ID,
+		TenantID: apiKey.TenantID,
+		Roles:    apiKey.Roles,
+	}, nil
+}
+
+// AuthenticateCalendarFeed resolves the principal of a calendar app from the
+// token query parameter of a feed, as calendar apps cannot send headers.
+// The request runs as the owner of the feed in the tenant of the feed with
+// the viewer role only, so the feed never sees more than the tasks owned by
+// or assigned to its owner, whatever roles the owner holds or loses later.
+// The feed itself is stored under c.ContextKeyCalendarFeed.
+func AuthenticateCalendarFeed(feedMgr data.CalendarFeedManager) gin.HandlerFunc {
+	return func(ginc *gin.Context) {
+		feed, err := authenticateCalendarToken(ginc, feedMgr, ginc.Query("token"))
+		if err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+				"path":  ginc.FullPath(),
+			}).Warn("AuthenticateCalendarFeed fail")
+			ginc.AbortWithStatusJSON(http.StatusUnauthorized, models.HttpError{
+				Code:    code.Code_UNAUTHENTICATED,
+				Message: "unauthenticated",
+			})
+			return
+		}
+
+		// the token only grants access to the tenant of the feed
+		ginc.Request.Header.Del(c.HeaderTenantID)
+		ginc.Set(c.ContextKeyCalendarFeed, feed)
+		setPrincipal(ginc, &auth.Principal{
+			ID:             feed.OwnerID,
+			Name:           feed.Name,
+			Type:           auth.PrincipalTypeCalendarFeed,
+			CalendarFeedID: feed.ID,
+			TenantID:       feed.TenantID,
+			Roles:          []string{auth.RoleViewer},
+		})
+		ginc.Next()
+	}
+}
+
+func authenticateCalendarToken(ginc *gin.Context, feedMgr data.CalendarFeedManager, token string) (models.CalendarFeed, error) {
+	if token == "" {
+		return models.CalendarFeed{}, fmt.Errorf("missing token")
+	}
+	feed, err := feedMgr.GetCalendarFeedByHash(ginc, auth.HashApiKey(token))
+	if err != nil {
+		return models.CalendarFeed{}, err
+	}
+	if feed.RevokedAt != nil {
+		return models.CalendarFeed{}, fmt.Errorf("calendar feed %d is revoked", feed.ID)
+	}
+	return feed, nil
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// setPrincipal makes the principal available through both gin keys and
+// auth.FromContext on the request context.
+func setPrincipal(ginc *gin.Context, principal *auth.Principal) {
+	ginc.Set(c.ContextKeyPrincipal, principal)
+	ginc.Request = ginc.Request.WithContext(auth.NewContext(ginc.Request.Context(), principal))
+	AddLoggerFields(ginc, principalFields(principal)...)
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"strconv"
+	"task_service/pkg/metrics"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records the duration of every request by route and status.
+// Requests that match no route share one label to bound the cardinality.
+func Metrics() gin.HandlerFunc {
+	return func(ginc *gin.Context) {
+		start := time.Now()
+		ginc.Next()
+
+		route := ginc.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(ginc.Request.Method, route, strconv.Itoa(ginc.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"task_service/c"
+	"task_service/config"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"task_service/pkg/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+type rateLimitRule struct {
+	// scope separates the counters of routes with their own limit
+	scope string
+	keyBy string
+	rule  ratelimit.Rule
+}
+
+// RateLimit limits the requests of every API key, IP or tenant according to
+// option, rejected requests get 429 with RESOURCE_EXHAUSTED. The middleware
+// must run after Authenticate and ResolveTenant. Requests are let through
+// when the limiter itself fails, so a redis outage doesn't block the API.
+func RateLimit(option config.RateLimitOption, limiter ratelimit.Limiter) (gin.HandlerFunc, error) {
+	defaultRule := rateLimitRule{
+		scope: "all",
+		keyBy: option.KeyBy,
+		rule: ratelimit.Rule{
+			Limit:  option.Limit,
+			Period: option.Period,
+			Burst:  option.Burst,
+		},
+	}
+	if defaultRule.keyBy == "" {
+		defaultRule.keyBy = c.RateLimitKeyByApiKey
+	}
+	if err := checkRateLimitRule(defaultRule); err != nil {
+		return nil, fmt.Errorf("RateLimit: %v", err)
+	}
+
+	routes := map[string]rateLimitRule{}
+	for _, route := range option.Routes {
+		method := strings.ToUpper(route.Method)
+		if method == "" {
+			method = "*"
+		}
+		routeRule := rateLimitRule{
+			scope: method + " " + route.Path,
+			keyBy: route.KeyBy,
+			rule: ratelimit.Rule{
+				Limit:  route.Limit,
+				Period: route.Period,
+				Burst:  route.Burst,
+			},
+		}
+		if routeRule.keyBy == "" {
+			routeRule.keyBy = defaultRule.keyBy
+		}
+		if routeRule.rule.Period == 0 {
+			routeRule.rule.Period = defaultRule.rule.Period
+		}
+		// the default burst belongs to the default limit
+		if routeRule.rule.Limit == 0 {
+			routeRule.rule.Limit = defaultRule.rule.Limit
+			if routeRule.rule.Burst == 0 {
+				routeRule.rule.Burst = defaultRule.rule.Burst
+			}
+		}
+		if err := checkRateLimitRule(routeRule); err != nil {
+			return nil, fmt.Errorf("RateLimit: route %s: %v", routeRule.scope, err)
+		}
+		routes[routeRule.scope] = routeRule
+	}
+
+	return func(ginc *gin.Context) {
+		limitRule, ok := routes[ginc.Request.Method+" "+ginc.FullPath()]
+		if !ok {
+			limitRule, ok = routes["* "+ginc.FullPath()]
+		}
+		if !ok {
+			limitRule = defaultRule
+		}
+
+		key := limitRule.scope + ":" + rateLimitSubject(ginc, limitRule.keyBy)
+		result, err := limiter.Allow(ginc, key, limitRule.rule)
+		if err != nil {
+			logger.GetLoggerWithContext(ginc, map[string]interface{}{
+				"error": err,
+				"key":   key,
+			}).Warn("RateLimit: limiter fail, request is let through")
+			ginc.Next()
+			return
+		}
+
+		ratelimit.SetHeaders(ginc.Writer.Header(), limitRule.rule, result)
+		if !result.Allowed {
+			ginc.AbortWithStatusJSON(http.StatusTooManyRequests, models.HttpError{
+				Code:    code.Code_RESOURCE_EXHAUSTED,
+				Message: "rate limit exceeded",
+			})
+			return
+		}
+		ginc.Next()
+	}, nil
+}
+
+func checkRateLimitRule(limitRule rateLimitRule) error {
+	switch limitRule.keyBy {
+	case c.RateLimitKeyByApiKey, c.RateLimitKeyByIP, c.RateLimitKeyByTenant:
+	default:
+		return fmt.Errorf("unknown key by %s", limitRule.keyBy)
+	}
+	if limitRule.rule.Limit <= 0 || limitRule.rule.Period <= 0 {
+		return fmt.Errorf("limit and period must be positive")
+	}
+	return nil
+}
+
+// rateLimitSubject identifies who the request is counted against. Callers
+// without an identity are counted by IP.
+func rateLimitSubject(ginc *gin.Context, keyBy string) string {
+	switch keyBy {
+	case c.RateLimitKeyByTenant:
+		return "tenant:" + data.TenantFromContext(ginc)
+	case c.RateLimitKeyByApiKey:
+		principal, ok := auth.FromContext(ginc)
+		if ok && principal.ApiKeyID != 0 {
+			return "key:" + strconv.FormatUint(principal.ApiKeyID, 10)
+		}
+		if ok && principal.Type != auth.PrincipalTypeAnonymous {
+			return "user:" + principal.ID
+		}
+	}
+	return "ip:" + ginc.ClientIP()
+}
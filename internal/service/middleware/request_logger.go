@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"task_service/c"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const maxRequestIDLength = 128
+
+// RequestLogger assigns every request an X-Request-ID, taken from the
+// request when the caller sent a usable one, and stores a logger carrying
+// the request id, method, route and task id in the request context. When
+// accessLog is set one line per request is written after it completes,
+// except for the paths in skipPaths.
+func RequestLogger(accessLog bool, skipPaths ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, path := range skipPaths {
+		skip[path] = true
+	}
+
+	return func(ginc *gin.Context) {
+		start := time.Now()
+
+		requestId := ginc.GetHeader(c.HeaderRequestID)
+		if !validRequestID(requestId) {
+			requestId = newRequestID()
+		}
+		ginc.Set(c.ContextKeyRequestID, requestId)
+		ginc.Header(c.HeaderRequestID, requestId)
+
+		fields := []zap.Field{
+			zap.String("request_id", requestId),
+			zap.String("method", ginc.Request.Method),
+			zap.String("route", ginc.FullPath()),
+		}
+		if taskId := ginc.Param("taskId"); taskId != "" {
+			fields = append(fields, zap.String("task_id", taskId))
+		}
+		AddLoggerFields(ginc, fields...)
+
+		ginc.Next()
+
+		if !accessLog || skip[ginc.Request.URL.Path] {
+			return
+		}
+
+		keys := map[string]interface{}{
+			"path":       ginc.Request.URL.Path,
+			"status":     ginc.Writer.Status(),
+			"latency_ms": time.Since(start).Milliseconds(),
+			"size":       ginc.Writer.Size(),
+			"client_ip":  ginc.ClientIP(),
+			"user_agent": ginc.Request.UserAgent(),
+		}
+		if len(ginc.Errors) > 0 {
+			keys["errors"] = ginc.Errors.String()
+		}
+
+		accessLogger := logger.GetLoggerWithContext(ginc, keys)
+		switch status := ginc.Writer.Status(); {
+		case status >= 500:
+			accessLogger.Error("access")
+		case status >= 400:
+			accessLogger.Warn("access")
+		default:
+			accessLogger.Info("access")
+		}
+	}
+}
+
+// AddLoggerFields adds fields to the request-scoped logger, so every later
+// log line of the request carries them.
+func AddLoggerFields(ginc *gin.Context, fields ...zap.Field) {
+	ctx := ginc.Request.Context()
+	ginc.Request = ginc.Request.WithContext(logger.NewContext(ctx, logger.FromContext(ctx).With(fields...)))
+}
+
+// principalFields are the logger fields identifying the caller.
+func principalFields(principal *auth.Principal) []zap.Field {
+	fields := []zap.Field{
+		zap.String("principal", principal.ID),
+		zap.String("principal_type", principal.Type),
+	}
+	if principal.ApiKeyID != 0 {
+		fields = append(fields, zap.Uint64("api_key_id", principal.ApiKeyID))
+	}
+	if principal.CalendarFeedID != 0 {
+		fields = append(fields, zap.Uint64("calendar_feed_id", principal.CalendarFeedID))
+	}
+	return fields
+}
+
+func validRequestID(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIDLength {
+		return false
+	}
+	// the id ends up in headers and logs, only printable ascii is kept
+	for i := 0; i < len(requestId); i++ {
+		if requestId[i] < 0x21 || requestId[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id)
+}
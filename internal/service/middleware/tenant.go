@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"task_service/c"
+	"task_service/internal/data"
+	"task_service/pkg/auth"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"gorm.io/gorm"
+)
+
+const tenantCacheTTL = 10 * time.Second
+
+// ResolveTenant scopes the request to the tenant of the principal. The
+// X-Tenant-ID header selects another tenant, which only superadmins may do
+// when their principal is bound to a tenant. Requests of unknown or
+// suspended tenants are rejected.
+func ResolveTenant(tenantMgr data.TenantManager) gin.HandlerFunc {
+	tenants := &tenantCache{
+		tenantMgr: tenantMgr,
+		entries:   map[string]tenantCacheEntry{},
+	}
+
+	return func(ginc *gin.Context) {
+		principal, ok := auth.FromContext(ginc)
+		if !ok {
+			principal = auth.Anonymous()
+		}
+
+		tenantId := principal.TenantID
+		if header := ginc.GetHeader(c.HeaderTenantID); header != "" && header != tenantId {
+			if !principal.IsSuperAdmin() {
+				abortTenant(ginc, http.StatusForbidden, code.Code_PERMISSION_DENIED,
+					fmt.Errorf("principal %s may not access tenant %s", principal.ID, header))
+				return
+			}
+			tenantId = header
+		}
+		if tenantId == "" {
+			tenantId = c.DefaultTenant
+		}
+
+		tenant, err := tenants.get(ginc, tenantId)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			abortTenant(ginc, http.StatusForbidden, code.Code_PERMISSION_DENIED, fmt.Errorf("unknown tenant %s", tenantId))
+			return
+		}
+		if err != nil {
+			abortTenant(ginc, http.StatusInternalServerError, code.Code_INTERNAL, err)
+			return
+		}
+		if !tenant.Active() {
+			abortTenant(ginc, http.StatusForbidden, code.Code_PERMISSION_DENIED, fmt.Errorf("tenant %s is %s", tenantId, tenant.Status))
+			return
+		}
+
+		ginc.Set(c.ContextKeyTenant, tenantId)
+		ginc.Request = ginc.Request.WithContext(data.WithTenant(ginc.Request.Context(), tenantId))
+		AddLoggerFields(ginc, zap.String("tenant", tenantId))
+		ginc.Next()
+	}
+}
+
+func abortTenant(ginc *gin.Context, httpCode int, errorCode code.Code, err error) {
+	logger.GetLoggerWithContext(ginc, map[string]interface{}{
+		"error": err,
+		"path":  ginc.FullPath(),
+	}).Warn("ResolveTenant fail")
+	ginc.AbortWithStatusJSON(httpCode, models.HttpError{
+		Code:    errorCode,
+		Message: err.Error(),
+	})
+}
+
+type tenantCacheEntry struct {
+	tenant  models.Tenant
+	expires time.Time
+}
+
+// tenantCache keeps tenants for a short while so that a suspension takes
+// effect within tenantCacheTTL without a query per request.
+type tenantCache struct {
+	tenantMgr data.TenantManager
+
+	mu      sync.Mutex
+	entries map[string]tenantCacheEntry
+}
+
+func (cache *tenantCache) get(ctx context.Context, tenantId string) (models.Tenant, error) {
+	now := time.Now()
+
+	cache.mu.Lock()
+	entry, ok := cache.entries[tenantId]
+	cache.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.tenant, nil
+	}
+
+	tenant, err := cache.tenantMgr.GetTenantById(ctx, tenantId)
+	if err != nil {
+		return models.Tenant{}, err
+	}
+
+	cache.mu.Lock()
+	cache.entries[tenantId] = tenantCacheEntry{tenant: tenant, expires: now.Add(tenantCacheTTL)}
+	cache.mu.Unlock()
+	return tenant, nil
+}
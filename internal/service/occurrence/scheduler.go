@@ -0,0 +1,161 @@
+// Package occurrence creates the occurrences of recurring task series.
+package occurrence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task_service/c"
+	"task_service/config"
+	"task_service/internal/data"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"task_service/pkg/recurrence"
+	"time"
+)
+
+const (
+	defaultInterval  = 30 * time.Second
+	defaultBatchSize = 100
+)
+
+// Materialize creates the occurrence of series starting at series.NextAt
+// and moves the series on to the following occurrence. It reports false when
+// the series has ended or another replica created the occurrence first.
+func Materialize(ctx context.Context, store data.SeriesManager, series models.TaskSeries) (models.Task, bool, error) {
+	if series.NextAt == nil {
+		return models.Task{}, false, nil
+	}
+
+	rule, err := recurrence.Parse(series.RRule, series.Timezone, series.DTStart)
+	if err != nil {
+		return models.Task{}, false, fmt.Errorf("Materialize: %v", err)
+	}
+
+	start := *series.NextAt
+	var next *time.Time
+	if t, ok := rule.Next(start, series.Exdates); ok {
+		next = &t
+	}
+
+	task := series.Occurrence(start)
+	created, err := store.Materialize(ctx, series, &task, next)
+	if err != nil || !created {
+		return models.Task{}, false, err
+	}
+	return task, true, nil
+}
+
+// Scheduler creates the occurrences whose start time has arrived. Like the
+// reminder scheduler, replicas compete for a lock on every tick, and
+// Materialize only succeeds once per occurrence.
+type Scheduler struct {
+	store    data.SeriesManager
+	cacheMgr data.DataManager
+	// onCacheError is called when a new occurrence could not be cached, so
+	// lists stop being served from the now incomplete cache.
+	onCacheError func()
+	mysqlMgr     data.DataManager
+	interval     time.Duration
+	batchSize    int
+
+	cancel context.CancelFunc
+	donec  chan struct{}
+}
+
+func NewScheduler(store data.SeriesManager, cacheMgr data.DataManager, onCacheError func(), option config.RecurrenceOption, mysqlMgr data.DataManager) *Scheduler {
+	interval := option.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	batchSize := option.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &Scheduler{
+		store:        store,
+		cacheMgr:     cacheMgr,
+		onCacheError: onCacheError,
+		mysqlMgr:     mysqlMgr,
+		interval:     interval,
+		batchSize:    batchSize,
+		donec:        make(chan struct{}),
+	}
+}
+
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.run(ctx)
+}
+
+// Stop interrupts a running scan and waits for the scheduler to exit.
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.donec
+}
+
+func (s *Scheduler) run(ctx context.Context) {
+	defer close(s.donec)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+				logger.GetLoggerWithKeys(map[string]interface{}{
+					"error": err,
+				}).Error("recurrence scan fail")
+			}
+		}
+	}
+}
+
+// RunOnce creates at most one occurrence per due series, unless another
+// replica holds the lock. Series which fell behind catch up one occurrence
+// per tick.
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	release, ok, err := data.TryLock(ctx, c.RecurrenceLockKey, c.LockExpiration, s.cacheMgr, s.mysqlMgr)
+	if err != nil {
+		return fmt.Errorf("RunOnce: %v", err)
+	}
+	if !ok {
+		return nil
+	}
+	defer release()
+
+	due, err := s.store.ListDueSeries(ctx, time.Now(), s.batchSize)
+	if err != nil {
+		return fmt.Errorf("RunOnce: %v", err)
+	}
+
+	var errs []error
+	for _, series := range due {
+		task, created, err := Materialize(ctx, s.store, series)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !created {
+			continue
+		}
+
+		tenantCtx := data.WithTenant(ctx, series.TenantID)
+		if err := s.cacheMgr.CreateTask(tenantCtx, []models.Task{task}); err != nil {
+			logger.GetLoggerWithContext(tenantCtx, map[string]interface{}{
+				"error":  err,
+				"taskId": task.ID,
+			}).Error("insert occurrence into cache fail")
+			if s.onCacheError != nil {
+				s.onCacheError()
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,142 @@
+// Package reminder fires reminder and overdue events for tasks.
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"task_service/c"
+	"task_service/config"
+	"task_service/internal/data"
+	"task_service/pkg/events"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"time"
+)
+
+const (
+	defaultInterval  = 30 * time.Second
+	defaultBatchSize = 100
+)
+
+// Scheduler scans for due reminders and newly overdue tasks every interval.
+// Replicas compete for a lock on every tick so a single one scans at a time,
+// and each event is claimed in MySQL before it is published, so a scan
+// overlapping with another never fires the same event twice.
+type Scheduler struct {
+	store     data.ReminderManager
+	sink      events.Sink
+	cacheMgr  data.DataManager
+	mysqlMgr  data.DataManager
+	interval  time.Duration
+	batchSize int
+
+	cancel context.CancelFunc
+	donec  chan struct{}
+}
+
+// NewScheduler returns a scheduler publishing to sink. The lock is taken
+// from cacheMgr, or mysqlMgr while redis is unavailable, see data.TryLock.
+func NewScheduler(store data.ReminderManager, sink events.Sink, option config.ReminderOption, cacheMgr, mysqlMgr data.DataManager) *Scheduler {
+	interval := option.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	batchSize := option.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &Scheduler{
+		store:     store,
+		sink:      sink,
+		cacheMgr:  cacheMgr,
+		mysqlMgr:  mysqlMgr,
+		interval:  interval,
+		batchSize: batchSize,
+		donec:     make(chan struct{}),
+	}
+}
+
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.run(ctx)
+}
+
+// Stop interrupts a running scan and waits for the scheduler to exit.
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.donec
+}
+
+func (s *Scheduler) run(ctx context.Context) {
+	defer close(s.donec)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+				logger.GetLoggerWithKeys(map[string]interface{}{
+					"error": err,
+				}).Error("reminder scan fail")
+			}
+		}
+	}
+}
+
+// RunOnce scans once, unless another replica holds the lock.
+func (s *Scheduler) RunOnce(ctx context.Context) error {
+	release, ok, err := data.TryLock(ctx, c.ReminderLockKey, c.LockExpiration, s.cacheMgr, s.mysqlMgr)
+	if err != nil {
+		return fmt.Errorf("RunOnce: %v", err)
+	}
+	if !ok {
+		return nil
+	}
+	defer release()
+
+	now := time.Now()
+	return errors.Join(
+		s.fire(ctx, events.TypeTaskReminder, now, s.store.ListDueReminders, s.store.ClaimReminder),
+		s.fire(ctx, events.TypeTaskOverdue, now, s.store.ListNewlyOverdue, s.store.ClaimOverdue),
+	)
+}
+
+func (s *Scheduler) fire(ctx context.Context, eventType string, now time.Time,
+	list func(context.Context, time.Time, int) ([]models.Task, error),
+	claim func(context.Context, models.Task) (bool, error)) error {
+
+	tasks, err := list(ctx, now, s.batchSize)
+	if err != nil {
+		return fmt.Errorf("fire %s: %v", eventType, err)
+	}
+
+	for _, task := range tasks {
+		claimed, err := claim(ctx, task)
+		if err != nil {
+			return fmt.Errorf("fire %s: %v", eventType, err)
+		}
+		if !claimed {
+			continue
+		}
+
+		event := events.NewTaskEvent(eventType, task, now)
+		if err := s.sink.Publish(ctx, event); err != nil {
+			// the event is claimed already, a retry would fire it twice
+			logger.GetLoggerWithContext(ctx, map[string]interface{}{
+				"error":      err,
+				"event_id":   event.ID,
+				"event_type": eventType,
+				"task_id":    task.ID,
+			}).Error("publish task event fail")
+		}
+	}
+	return nil
+}
@@ -33,10 +33,17 @@ func main() {
 
 	server := app.Default()
 
+	server.AddInitHook(app.InitTracingHook)
 	server.AddInitHook(app.InitDatabaseHook)
 	server.AddInitHook(app.InitCacheHook)
+	server.AddInitHook(app.InitMetricsHook)
+	server.AddInitHook(app.InitHealthHook)
 	server.AddInitHook(app.InitGinApplicationHook)
 
+	server.AddDestroyHook(app.DestroyTracingHook)
+	server.AddDestroyHook(app.DestroyWriteBehindHook)
+	server.AddDestroyHook(app.DestroyReminderHook)
+	server.AddDestroyHook(app.DestroyRecurrenceHook)
 	server.AddDestroyHook(app.DestroyGinApplicationHook)
 
 	go handleSignals(server)
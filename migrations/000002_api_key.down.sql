@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `ApiKey`;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS ApiKey (
+    `id` BIGINT AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(100) NOT NULL,
+    `prefix` VARCHAR(20) NOT NULL,
+    `key_hash` CHAR(64) NOT NULL,
+    `owner_id` VARCHAR(100) NOT NULL,
+    `roles` VARCHAR(255) NOT NULL DEFAULT '[]',
+    `expires_at` TIMESTAMP NULL DEFAULT NULL,
+    `revoked_at` TIMESTAMP NULL DEFAULT NULL,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY `uk_api_key_hash` (`key_hash`),
+    KEY `idx_api_key_owner` (`owner_id`)
+);
@@ -0,0 +1,5 @@
+ALTER TABLE Task
+    DROP INDEX `idx_task_assignee`,
+    DROP INDEX `idx_task_owner`,
+    DROP COLUMN `assignee_id`,
+    DROP COLUMN `owner_id`;
@@ -0,0 +1,5 @@
+ALTER TABLE Task
+    ADD COLUMN `owner_id` VARCHAR(100) NOT NULL DEFAULT '' AFTER `tag`,
+    ADD COLUMN `assignee_id` VARCHAR(100) NOT NULL DEFAULT '' AFTER `owner_id`,
+    ADD INDEX `idx_task_owner` (`owner_id`),
+    ADD INDEX `idx_task_assignee` (`assignee_id`);
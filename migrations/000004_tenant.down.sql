@@ -0,0 +1,10 @@
+ALTER TABLE ApiKey
+    DROP INDEX `idx_api_key_tenant_owner`,
+    ADD INDEX `idx_api_key_owner` (`owner_id`),
+    DROP COLUMN `tenant_id`;
+
+ALTER TABLE Task
+    DROP INDEX `idx_task_tenant_name_tag`,
+    DROP COLUMN `tenant_id`;
+
+DROP TABLE IF EXISTS `Tenant`;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS Tenant (
+    `id` VARCHAR(64) PRIMARY KEY,
+    `name` VARCHAR(200) NOT NULL,
+    `status` VARCHAR(20) NOT NULL DEFAULT 'active',
+    `max_tasks` BIGINT NOT NULL DEFAULT 0,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+);
+
+INSERT IGNORE INTO Tenant (`id`, `name`) VALUES ('default', 'default');
+
+ALTER TABLE Task
+    ADD COLUMN `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default' AFTER `id`,
+    ADD INDEX `idx_task_tenant_name_tag` (`tenant_id`, `name`, `tag`);
+
+ALTER TABLE ApiKey
+    ADD COLUMN `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default' AFTER `id`,
+    DROP INDEX `idx_api_key_owner`,
+    ADD INDEX `idx_api_key_tenant_owner` (`tenant_id`, `owner_id`);
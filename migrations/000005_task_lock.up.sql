@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS TaskLock (
+    `name` VARCHAR(191) PRIMARY KEY,
+    `owner` VARCHAR(64) NOT NULL DEFAULT '',
+    `token` BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    `expires_at` DATETIME(6) NOT NULL
+);
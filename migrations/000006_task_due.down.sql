@@ -0,0 +1,8 @@
+ALTER TABLE Task
+    DROP INDEX `idx_task_remind`,
+    DROP INDEX `idx_task_due`,
+    DROP INDEX `idx_task_tenant_due`,
+    DROP COLUMN `fired_remind_at`,
+    DROP COLUMN `fired_due_at`,
+    DROP COLUMN `remind_at`,
+    DROP COLUMN `due_at`;
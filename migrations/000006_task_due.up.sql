@@ -0,0 +1,8 @@
+ALTER TABLE Task
+    ADD COLUMN `due_at` DATETIME NULL AFTER `version`,
+    ADD COLUMN `remind_at` DATETIME NULL AFTER `due_at`,
+    ADD COLUMN `fired_due_at` DATETIME NULL AFTER `remind_at`,
+    ADD COLUMN `fired_remind_at` DATETIME NULL AFTER `fired_due_at`,
+    ADD INDEX `idx_task_tenant_due` (`tenant_id`, `due_at`),
+    ADD INDEX `idx_task_due` (`due_at`),
+    ADD INDEX `idx_task_remind` (`remind_at`);
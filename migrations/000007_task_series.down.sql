@@ -0,0 +1,6 @@
+ALTER TABLE Task
+    DROP INDEX `uniq_task_series_occurrence`,
+    DROP COLUMN `occurrence_at`,
+    DROP COLUMN `series_id`;
+
+DROP TABLE IF EXISTS `TaskSeries`;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS TaskSeries (
+    `id` BIGINT AUTO_INCREMENT PRIMARY KEY,
+    `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+    `rrule` VARCHAR(500) NOT NULL,
+    `timezone` VARCHAR(64) NOT NULL,
+    `dtstart` DATETIME NOT NULL,
+    `next_at` DATETIME NULL,
+    `exdates` TEXT NULL,
+    `name` VARCHAR(200) NOT NULL,
+    `content` VARCHAR(500) NOT NULL,
+    `tag` VARCHAR(50) NOT NULL DEFAULT '',
+    `owner_id` VARCHAR(100) NOT NULL DEFAULT '',
+    `assignee_id` VARCHAR(100) NOT NULL DEFAULT '',
+    `due_offset` BIGINT NULL,
+    `remind_offset` BIGINT NULL,
+    `version` INT NOT NULL DEFAULT 1,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX `idx_task_series_next` (`next_at`)
+);
+
+ALTER TABLE Task
+    ADD COLUMN `series_id` BIGINT NULL AFTER `fired_remind_at`,
+    ADD COLUMN `occurrence_at` DATETIME NULL AFTER `series_id`,
+    ADD UNIQUE INDEX `uniq_task_series_occurrence` (`series_id`, `occurrence_at`);
@@ -0,0 +1,4 @@
+ALTER TABLE Task
+    DROP INDEX `idx_task_tenant_parent`,
+    DROP COLUMN `progress`,
+    DROP COLUMN `parent_id`;
@@ -0,0 +1,4 @@
+ALTER TABLE Task
+    ADD COLUMN `parent_id` BIGINT NULL AFTER `occurrence_at`,
+    ADD COLUMN `progress` TINYINT NOT NULL DEFAULT 0 AFTER `parent_id`,
+    ADD INDEX `idx_task_tenant_parent` (`tenant_id`, `parent_id`);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS TaskDependency (
+    `id` BIGINT AUTO_INCREMENT PRIMARY KEY,
+    `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+    `task_id` BIGINT NOT NULL,
+    `blocker_id` BIGINT NOT NULL,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX `uniq_task_dependency` (`task_id`, `blocker_id`),
+    INDEX `idx_task_dependency_blocker` (`blocker_id`)
+);
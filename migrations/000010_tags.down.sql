@@ -0,0 +1,16 @@
+ALTER TABLE Task
+    ADD COLUMN `tag` VARCHAR(50) DEFAULT '' AFTER `content`,
+    DROP INDEX `idx_task_tenant_name`,
+    ADD INDEX `idx_task_tenant_name_tag` (`tenant_id`, `name`, `tag`);
+-- a task keeps the first of its tags
+UPDATE Task SET `tag` = COALESCE((
+    SELECT MIN(Tag.`name`) FROM TaskTag JOIN Tag ON Tag.`id` = TaskTag.`tag_id`
+    WHERE TaskTag.`task_id` = Task.`id`
+), '');
+
+ALTER TABLE TaskSeries ADD COLUMN `tag` VARCHAR(50) NOT NULL DEFAULT '' AFTER `content`;
+UPDATE TaskSeries SET `tag` = COALESCE(JSON_UNQUOTE(JSON_EXTRACT(`tags`, '$[0]')), '');
+ALTER TABLE TaskSeries DROP COLUMN `tags`;
+
+DROP TABLE IF EXISTS `TaskTag`;
+DROP TABLE IF EXISTS `Tag`;
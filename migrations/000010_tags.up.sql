@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS Tag (
+    `id` BIGINT AUTO_INCREMENT PRIMARY KEY,
+    `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+    `name` VARCHAR(50) NOT NULL,
+    `color` VARCHAR(20) NOT NULL DEFAULT '',
+    `description` VARCHAR(500) NOT NULL DEFAULT '',
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE INDEX `uniq_tag_tenant_name` (`tenant_id`, `name`)
+);
+
+CREATE TABLE IF NOT EXISTS TaskTag (
+    `task_id` BIGINT NOT NULL,
+    `tag_id` BIGINT NOT NULL,
+    PRIMARY KEY (`task_id`, `tag_id`),
+    INDEX `idx_task_tag_tag` (`tag_id`)
+);
+
+INSERT IGNORE INTO Tag (`tenant_id`, `name`)
+    SELECT DISTINCT `tenant_id`, `tag` FROM Task WHERE `tag` <> '';
+INSERT IGNORE INTO Tag (`tenant_id`, `name`)
+    SELECT DISTINCT `tenant_id`, `tag` FROM TaskSeries WHERE `tag` <> '';
+INSERT IGNORE INTO TaskTag (`task_id`, `tag_id`)
+    SELECT Task.`id`, Tag.`id` FROM Task
+    JOIN Tag ON Tag.`tenant_id` = Task.`tenant_id` AND Tag.`name` = Task.`tag`;
+
+ALTER TABLE TaskSeries ADD COLUMN `tags` TEXT NULL AFTER `content`;
+UPDATE TaskSeries SET `tags` = IF(`tag` = '', JSON_ARRAY(), JSON_ARRAY(`tag`));
+ALTER TABLE TaskSeries DROP COLUMN `tag`;
+
+ALTER TABLE Task
+    DROP INDEX `idx_task_tenant_name_tag`,
+    DROP COLUMN `tag`,
+    ADD INDEX `idx_task_tenant_name` (`tenant_id`, `name`);
@@ -0,0 +1,4 @@
+ALTER TABLE Task DROP COLUMN `comment_count`;
+
+DROP TABLE IF EXISTS `CommentRevision`;
+DROP TABLE IF EXISTS `Comment`;
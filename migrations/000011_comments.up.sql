@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS Comment (
+    `id` BIGINT AUTO_INCREMENT PRIMARY KEY,
+    `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+    `task_id` BIGINT NOT NULL,
+    `author_id` VARCHAR(100) NOT NULL,
+    `body` TEXT NOT NULL,
+    `mentions` TEXT NULL,
+    `edited_at` TIMESTAMP NULL DEFAULT NULL,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX `idx_comment_tenant_task` (`tenant_id`, `task_id`, `id`)
+);
+
+CREATE TABLE IF NOT EXISTS CommentRevision (
+    `id` BIGINT AUTO_INCREMENT PRIMARY KEY,
+    `comment_id` BIGINT NOT NULL,
+    `body` TEXT NOT NULL,
+    `edited_by` VARCHAR(100) NOT NULL,
+    `edited_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX `idx_comment_revision_comment` (`comment_id`, `id`)
+);
+
+ALTER TABLE Task ADD COLUMN `comment_count` INT NOT NULL DEFAULT 0 AFTER `progress`;
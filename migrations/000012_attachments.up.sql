@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS Attachment (
+    `id` BIGINT AUTO_INCREMENT PRIMARY KEY,
+    `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+    `task_id` BIGINT NOT NULL,
+    `name` VARCHAR(255) NOT NULL,
+    `content_type` VARCHAR(255) NOT NULL,
+    `size` BIGINT NOT NULL,
+    `sha256` CHAR(64) NOT NULL,
+    `uploader_id` VARCHAR(100) NOT NULL,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX `uniq_attachment_task_sha256` (`task_id`, `sha256`),
+    INDEX `idx_attachment_tenant_sha256` (`tenant_id`, `sha256`)
+);
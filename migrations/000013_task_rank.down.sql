@@ -0,0 +1,6 @@
+ALTER TABLE TaskSeries DROP COLUMN `priority`;
+
+ALTER TABLE Task
+    DROP INDEX `idx_task_tenant_rank`,
+    DROP COLUMN `rank`,
+    DROP COLUMN `priority`;
@@ -0,0 +1,12 @@
+ALTER TABLE Task
+    ADD COLUMN `priority` TINYINT NOT NULL DEFAULT 0 AFTER `content`,
+    ADD COLUMN `rank` VARCHAR(64) CHARACTER SET ascii COLLATE ascii_bin NOT NULL DEFAULT '' AFTER `priority`,
+    ADD INDEX `idx_task_tenant_rank` (`tenant_id`, `rank`);
+
+ALTER TABLE TaskSeries ADD COLUMN `priority` TINYINT NOT NULL DEFAULT 0 AFTER `content`;
+
+-- existing tasks keep their creation order, spaced like appended tasks (36^5 apart)
+UPDATE Task JOIN (
+    SELECT `id`, ROW_NUMBER() OVER (PARTITION BY `tenant_id` ORDER BY `id`) AS `position` FROM Task
+) ranked ON ranked.`id` = Task.`id`
+SET Task.`rank` = LPAD(LOWER(CONV(ranked.`position` * 60466176, 10, 36)), 10, '0');
@@ -0,0 +1,4 @@
+ALTER TABLE TaskSeries DROP COLUMN `fields`;
+ALTER TABLE Task DROP COLUMN `fields`;
+
+DROP TABLE IF EXISTS CustomField;
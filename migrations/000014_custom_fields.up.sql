@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS CustomField (
+    `id` BIGINT AUTO_INCREMENT PRIMARY KEY,
+    `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+    `field_key` VARCHAR(50) NOT NULL,
+    `name` VARCHAR(100) NOT NULL,
+    `type` VARCHAR(20) NOT NULL,
+    `options` TEXT NULL,
+    `required` TINYINT(1) NOT NULL DEFAULT 0,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE INDEX `uniq_custom_field_tenant_key` (`tenant_id`, `field_key`)
+);
+
+ALTER TABLE Task ADD COLUMN `fields` TEXT NULL AFTER `progress`;
+ALTER TABLE TaskSeries ADD COLUMN `fields` TEXT NULL AFTER `tags`;
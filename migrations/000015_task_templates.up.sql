@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS TaskTemplate (
+    `id` BIGINT AUTO_INCREMENT PRIMARY KEY,
+    `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+    `name` VARCHAR(200) NOT NULL,
+    `owner_id` VARCHAR(100) NOT NULL DEFAULT '',
+    `task_name` VARCHAR(500) NOT NULL,
+    `task_content` TEXT NOT NULL,
+    `tags` TEXT NULL,
+    `status` TINYINT NOT NULL DEFAULT 1,
+    `priority` TINYINT NOT NULL DEFAULT 0,
+    `fields` TEXT NULL,
+    `subtasks` TEXT NULL,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE INDEX `uniq_task_template_tenant_name` (`tenant_id`, `name`)
+);
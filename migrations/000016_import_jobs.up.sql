@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS ImportJob (
+    `id` BIGINT AUTO_INCREMENT PRIMARY KEY,
+    `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+    `owner_id` VARCHAR(100) NOT NULL DEFAULT '',
+    `format` VARCHAR(10) NOT NULL,
+    `dry_run` BOOLEAN NOT NULL DEFAULT FALSE,
+    `status` VARCHAR(20) NOT NULL,
+    `total` INT NOT NULL DEFAULT 0,
+    `processed` INT NOT NULL DEFAULT 0,
+    `created` INT NOT NULL DEFAULT 0,
+    `updated` INT NOT NULL DEFAULT 0,
+    `failed` INT NOT NULL DEFAULT 0,
+    `errors` MEDIUMTEXT NULL,
+    `error` VARCHAR(500) NOT NULL DEFAULT '',
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    `finished_at` TIMESTAMP NULL,
+    INDEX `idx_import_job_tenant` (`tenant_id`, `id`)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS CalendarFeed (
+    `id` BIGINT AUTO_INCREMENT PRIMARY KEY,
+    `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+    `name` VARCHAR(100) NOT NULL,
+    `prefix` VARCHAR(20) NOT NULL,
+    `token_hash` CHAR(64) NOT NULL,
+    `owner_id` VARCHAR(100) NOT NULL,
+    `query` VARCHAR(1000) NOT NULL DEFAULT '',
+    `component` VARCHAR(10) NOT NULL,
+    `etag` VARCHAR(70) NOT NULL DEFAULT '',
+    `modified_at` TIMESTAMP NULL DEFAULT NULL,
+    `revoked_at` TIMESTAMP NULL DEFAULT NULL,
+    `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY `uk_calendar_feed_token_hash` (`token_hash`),
+    KEY `idx_calendar_feed_owner` (`tenant_id`, `owner_id`)
+);
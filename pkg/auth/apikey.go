@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	apiKeyPrefix        = "tsk_"
+	calendarTokenPrefix = "tcal_"
+	apiKeySecretSize    = 32
+	// ApiKeyDisplayLength is how many leading characters of a key are kept
+	// in clear so that users can tell their keys apart.
+	ApiKeyDisplayLength = 12
+)
+
+// GenerateApiKey returns a new random API key together with its hash.
+// Only the hash is stored, the key itself is shown to the user once.
+func GenerateApiKey() (key, hash string, err error) {
+	key, err = generateSecret(apiKeyPrefix)
+	if err != nil {
+		return "", "", fmt.Errorf("GenerateApiKey: %v", err)
+	}
+	return key, HashApiKey(key), nil
+}
+
+// GenerateCalendarToken returns a new random calendar feed token together
+// with its hash, stored like API keys. The prefix tells both apart.
+func GenerateCalendarToken() (token, hash string, err error) {
+	token, err = generateSecret(calendarTokenPrefix)
+	if err != nil {
+		return "", "", fmt.Errorf("GenerateCalendarToken: %v", err)
+	}
+	return token, HashApiKey(token), nil
+}
+
+func generateSecret(prefix string) (string, error) {
+	secret := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// HashApiKey returns the SHA-256 hex digest under which key is stored.
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func signHMAC(t *testing.T, secret string, claims Claims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.Nil(t, err)
+	return token
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	verifier, err := NewVerifier("secret", "", "task-service", "")
+	assert.Nil(t, err)
+
+	valid := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			Issuer:    "task-service",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Name:  "alice",
+		Roles: []string{"editor"},
+	}
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	wrongIssuer := valid
+	wrongIssuer.Issuer = "other"
+	noSubject := valid
+	noSubject.Subject = ""
+
+	tests := []struct {
+		token    string
+		isErr    bool
+		expected *Principal
+	}{
+		{
+			signHMAC(t, "secret", valid),
+			false,
+			&Principal{ID: "user-1", Name: "alice", Type: PrincipalTypeUser, Roles: []string{"editor"}},
+		},
+		{signHMAC(t, "other-secret", valid), true, nil},
+		{signHMAC(t, "secret", expired), true, nil},
+		{signHMAC(t, "secret", wrongIssuer), true, nil},
+		{signHMAC(t, "secret", noSubject), true, nil},
+		{"not-a-token", true, nil},
+	}
+
+	for _, testItem := range tests {
+		principal, err := verifier.Verify(testItem.token)
+		if testItem.isErr {
+			assert.ErrorIs(t, err, ErrInvalidToken)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, testItem.expected, principal)
+	}
+}
+
+func TestVerifyJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+
+	jwks := fmt.Sprintf(`{"keys":[{"kid":"k1","kty":"RSA","use":"sig","n":"%s","e":"%s"}]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+	keys, err := ParseJWKS([]byte(jwks))
+	assert.Nil(t, err)
+
+	verifier := &Verifier{keys: keys}
+	claims := Claims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   "user-2",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(key)
+	assert.Nil(t, err)
+
+	principal, err := verifier.Verify(signed)
+	assert.Nil(t, err)
+	assert.Equal(t, "user-2", principal.ID)
+
+	token.Header["kid"] = "unknown"
+	signed, err = token.SignedString(key)
+	assert.Nil(t, err)
+	_, err = verifier.Verify(signed)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// An HMAC token must not be accepted when only JWKS keys are configured.
+	_, err = verifier.Verify(signHMAC(t, "secret", claims))
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestApiKey(t *testing.T) {
+	key, hash, err := GenerateApiKey()
+	assert.Nil(t, err)
+	assert.Equal(t, HashApiKey(key), hash)
+	assert.NotEqual(t, key, hash)
+
+	other, _, err := GenerateApiKey()
+	assert.Nil(t, err)
+	assert.NotEqual(t, key, other)
+}
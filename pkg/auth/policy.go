@@ -0,0 +1,96 @@
+package auth
+
+import "task_service/pkg/models"
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+	// RoleSuperAdmin manages tenants and is admin inside every tenant.
+	RoleSuperAdmin = "superadmin"
+)
+
+// Action is an operation a principal performs on a task.
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+	// ActionChangeOwner hands a task over to another owner.
+	ActionChangeOwner Action = "change_owner"
+	// ActionManageTags renames, merges and deletes tags of the tag catalog.
+	ActionManageTags Action = "manage_tags"
+	// ActionManageFields defines, changes and deletes custom fields.
+	ActionManageFields Action = "manage_fields"
+)
+
+var roleLevels = map[string]int{
+	RoleViewer:     1,
+	RoleEditor:     2,
+	RoleAdmin:      3,
+	RoleSuperAdmin: 4,
+}
+
+// HasRole reports whether the principal holds role or a role above it.
+// A principal without any known role is treated as a viewer.
+func (p *Principal) HasRole(role string) bool {
+	level := roleLevels[RoleViewer]
+	for _, r := range p.Roles {
+		if roleLevels[r] > level {
+			level = roleLevels[r]
+		}
+	}
+	return level >= roleLevels[role]
+}
+
+// CanGrant reports whether the principal may hand role on, e.g. to an API
+// key: role must be known and not above the roles of the principal.
+func (p *Principal) CanGrant(role string) bool {
+	_, known := roleLevels[role]
+	return known && p.HasRole(role)
+}
+
+// IsAdmin reports whether the principal may act on every task.
+func (p *Principal) IsAdmin() bool {
+	return p.HasRole(RoleAdmin)
+}
+
+// IsSuperAdmin reports whether the principal may manage tenants.
+func (p *Principal) IsSuperAdmin() bool {
+	return p.HasRole(RoleSuperAdmin)
+}
+
+// Authorize decides whether p may perform action on task. task is nil for
+// ActionCreate. Viewers read the tasks assigned to them, editors also create
+// tasks and change the ones they own or are assigned to, and only owners
+// delete their tasks. Admins may do anything.
+func Authorize(p *Principal, action Action, task *models.Task) bool {
+	if p == nil {
+		return false
+	}
+	if p.IsAdmin() {
+		return true
+	}
+
+	switch action {
+	case ActionCreate:
+		return p.HasRole(RoleEditor)
+	case ActionRead:
+		return task != nil && (task.OwnerID == p.ID || task.AssigneeID == p.ID)
+	case ActionUpdate:
+		return task != nil && p.HasRole(RoleEditor) && (task.OwnerID == p.ID || task.AssigneeID == p.ID)
+	case ActionDelete:
+		return task != nil && p.HasRole(RoleEditor) && task.OwnerID == p.ID
+	}
+	return false
+}
+
+// VisibleFilter returns the filter restricting ListTask to the tasks p may read.
+func VisibleFilter(p *Principal) models.TaskFilter {
+	if p.IsAdmin() {
+		return models.TaskFilter{}
+	}
+	return models.TaskFilter{VisibleTo: p.ID}
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"task_service/pkg/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorize(t *testing.T) {
+	viewer := &Principal{ID: "viewer", Roles: []string{RoleViewer}}
+	editor := &Principal{ID: "editor", Roles: []string{RoleEditor}}
+	admin := &Principal{ID: "admin", Roles: []string{RoleViewer, RoleAdmin}}
+	noRole := &Principal{ID: "nobody"}
+
+	owned := &models.Task{ID: 1, OwnerID: "editor"}
+	assigned := &models.Task{ID: 2, OwnerID: "someone", AssigneeID: "editor"}
+	assignedToViewer := &models.Task{ID: 3, OwnerID: "someone", AssigneeID: "viewer"}
+	other := &models.Task{ID: 4, OwnerID: "someone"}
+
+	tests := []struct {
+		principal *Principal
+		action    Action
+		task      *models.Task
+		expected  bool
+	}{
+		{editor, ActionCreate, nil, true},
+		{viewer, ActionCreate, nil, false},
+		{noRole, ActionCreate, nil, false},
+		{editor, ActionRead, owned, true},
+		{editor, ActionRead, assigned, true},
+		{editor, ActionRead, other, false},
+		{viewer, ActionRead, assignedToViewer, true},
+		{viewer, ActionUpdate, assignedToViewer, false},
+		{editor, ActionUpdate, assigned, true},
+		{editor, ActionDelete, assigned, false},
+		{editor, ActionDelete, owned, true},
+		{editor, ActionChangeOwner, owned, false},
+		{admin, ActionDelete, other, true},
+		{admin, ActionChangeOwner, other, true},
+		{editor, ActionManageTags, nil, false},
+		{admin, ActionManageTags, nil, true},
+		{editor, ActionManageFields, nil, false},
+		{admin, ActionManageFields, nil, true},
+		{Anonymous(), ActionDelete, other, true},
+		{&Principal{ID: "root", Roles: []string{RoleSuperAdmin}}, ActionChangeOwner, other, true},
+		{nil, ActionRead, owned, false},
+	}
+
+	for _, testItem := range tests {
+		assert.Equal(t, testItem.expected, Authorize(testItem.principal, testItem.action, testItem.task),
+			"principal = %v, action = %s", testItem.principal, testItem.action)
+	}
+}
+
+func TestVisibleFilter(t *testing.T) {
+	assert.Equal(t, models.TaskFilter{}, VisibleFilter(&Principal{ID: "admin", Roles: []string{RoleAdmin}}))
+
+	filter := VisibleFilter(&Principal{ID: "editor", Roles: []string{RoleEditor}})
+	assert.True(t, filter.Match(models.Task{OwnerID: "editor"}))
+	assert.True(t, filter.Match(models.Task{AssigneeID: "editor"}))
+	assert.False(t, filter.Match(models.Task{OwnerID: "someone"}))
+}
+
+func TestIsSuperAdmin(t *testing.T) {
+	tests := []struct {
+		principal *Principal
+		expected  bool
+	}{
+		{&Principal{ID: "root", Roles: []string{RoleSuperAdmin}}, true},
+		{&Principal{ID: "admin", Roles: []string{RoleAdmin}}, false},
+		{&Principal{ID: "nobody"}, false},
+		{Anonymous(), true},
+	}
+
+	for _, testItem := range tests {
+		assert.Equal(t, testItem.expected, testItem.principal.IsSuperAdmin(), "principal = %v", testItem.principal)
+		assert.True(t, !testItem.expected || testItem.principal.IsAdmin())
+	}
+}
+
+func TestCanGrant(t *testing.T) {
+	tests := []struct {
+		principal *Principal
+		role      string
+		expected  bool
+	}{
+		{&Principal{ID: "editor", Roles: []string{RoleEditor}}, RoleEditor, true},
+		{&Principal{ID: "editor", Roles: []string{RoleEditor}}, RoleViewer, true},
+		{&Principal{ID: "editor", Roles: []string{RoleEditor}}, RoleAdmin, false},
+		{&Principal{ID: "admin", Roles: []string{RoleAdmin}}, RoleSuperAdmin, false},
+		{&Principal{ID: "nobody"}, RoleViewer, true},
+		{&Principal{ID: "admin", Roles: []string{RoleAdmin}}, "owner", false},
+	}
+
+	for _, testItem := range tests {
+		assert.Equal(t, testItem.expected, testItem.principal.CanGrant(testItem.role),
+			"principal = %v, role = %s", testItem.principal, testItem.role)
+	}
+}
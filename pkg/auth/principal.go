@@ -0,0 +1,49 @@
+package auth
+
+import "context"
+
+const (
+	PrincipalTypeUser         = "user"
+	PrincipalTypeApiKey       = "api_key"
+	PrincipalTypeCalendarFeed = "calendar_feed"
+	PrincipalTypeAnonymous    = "anonymous"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	Type string `json:"type"`
+	// TenantID is the tenant the principal belongs to, empty means the
+	// default tenant.
+	TenantID string `json:"tenant_id,omitempty"`
+	// ApiKeyID is set when the caller authenticated with an API key.
+	ApiKeyID uint64 `json:"api_key_id,omitempty"`
+	// CalendarFeedID is set when the caller is a calendar app reading a
+	// feed with its token.
+	CalendarFeedID uint64   `json:"calendar_feed_id,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+}
+
+// Anonymous is the principal of requests when authentication is disabled.
+// It keeps full access, as the service had before authentication existed.
+func Anonymous() *Principal {
+	return &Principal{
+		ID:    PrincipalTypeAnonymous,
+		Type:  PrincipalTypeAnonymous,
+		Roles: []string{RoleSuperAdmin},
+	}
+}
+
+type principalCtxKey struct{}
+
+// NewContext returns a copy of ctx carrying p.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx by NewContext.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalCtxKey{}).(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,46 @@
+// Package blob stores the content of task attachments on the local
+// filesystem or in an S3 compatible object store.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"task_service/config"
+)
+
+const (
+	StoreLocal = "local"
+	StoreS3    = "s3"
+)
+
+// ErrNotFound is returned by Get when the key has no blob.
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs by key. Putting a key again replaces its blob, deleting a
+// missing key is not an error.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// NewStore returns the store selected by option.Store, local by default.
+func NewStore(option config.AttachmentOption) (Store, error) {
+	switch option.Store {
+	case StoreLocal, "":
+		if option.LocalDir == "" {
+			return nil, fmt.Errorf("NewStore: directory of the local store is required")
+		}
+		return NewLocalStore(option.LocalDir), nil
+	case StoreS3:
+		store, err := NewS3Store(option.S3)
+		if err != nil {
+			return nil, fmt.Errorf("NewStore: %v", err)
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("NewStore: unknown store %s", option.Store)
+	}
+}
@@ -0,0 +1,32 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore(t *testing.T) {
+	store := NewLocalStore(t.TempDir())
+	ctx := context.Background()
+
+	assert.Nil(t, store.Put(ctx, "t1/abc", strings.NewReader("hello"), 5, "text/plain"))
+	r, err := store.Get(ctx, "t1/abc")
+	if assert.Nil(t, err) {
+		body, _ := io.ReadAll(r)
+		r.Close()
+		assert.Equal(t, "hello", string(body))
+	}
+
+	assert.Nil(t, store.Delete(ctx, "t1/abc"))
+	_, err = store.Get(ctx, "t1/abc")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Nil(t, store.Delete(ctx, "t1/abc"))
+
+	for _, key := range []string{"", "/etc/passwd", "../x", "t1/../../x", "t1//x", `t1\x`} {
+		assert.NotNil(t, store.Put(ctx, key, strings.NewReader(""), 0, ""), key)
+	}
+}
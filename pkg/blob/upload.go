@@ -0,0 +1,113 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// DefaultMaxSize is the size limit of an upload when none is configured.
+const DefaultMaxSize = 10 << 20
+
+// sniffLength is the number of leading bytes http.DetectContentType reads.
+const sniffLength = 512
+
+// ErrTooLarge is returned by Spool when the content exceeds the size limit.
+var ErrTooLarge = errors.New("upload is too large")
+
+// Upload is received content spooled to a temporary file, with its size,
+// SHA-256 digest and the content type sniffed from its first bytes.
+type Upload struct {
+	Size        int64
+	SHA256      string
+	ContentType string
+	file        *os.File
+}
+
+// Spool copies r to a temporary file, failing with ErrTooLarge once more
+// than maxSize bytes were read. The caller closes the upload.
+func Spool(r io.Reader, maxSize int64) (*Upload, error) {
+	file, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("Spool: %v", err)
+	}
+	upload := &Upload{file: file}
+
+	digest := sha256.New()
+	sniff := &sniffWriter{}
+	size, err := io.Copy(io.MultiWriter(file, digest, sniff), io.LimitReader(r, maxSize+1))
+	if err != nil {
+		upload.Close()
+		return nil, fmt.Errorf("Spool: %v", err)
+	}
+	if size > maxSize {
+		upload.Close()
+		return nil, ErrTooLarge
+	}
+
+	upload.Size = size
+	upload.SHA256 = hexDigest(digest)
+	upload.ContentType = http.DetectContentType(sniff.buf)
+	return upload, nil
+}
+
+// Reader returns the spooled content from its start.
+func (u *Upload) Reader() (io.Reader, error) {
+	if _, err := u.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("Upload: %v", err)
+	}
+	return u.file, nil
+}
+
+// Close removes the temporary file.
+func (u *Upload) Close() error {
+	u.file.Close()
+	return os.Remove(u.file.Name())
+}
+
+// TypeAllowed reports whether the media type of contentType matches one of
+// allowed, where type/* matches every subtype. Everything is allowed when
+// allowed is empty.
+func TypeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffWriter keeps the first sniffLength bytes written to it.
+type sniffWriter struct {
+	buf []byte
+}
+
+func (w *sniffWriter) Write(p []byte) (int, error) {
+	if missing := sniffLength - len(w.buf); missing > 0 {
+		w.buf = append(w.buf, p[:min(missing, len(p))]...)
+	}
+	return len(p), nil
+}
+
+func hexDigest(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
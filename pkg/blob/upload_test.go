@@ -0,0 +1,66 @@
+package blob
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpool(t *testing.T) {
+	png := append([]byte("\x89PNG\x0d\x0a\x1a\x0a"), bytes.Repeat([]byte{0}, 600)...)
+
+	upload, err := Spool(bytes.NewReader(png), int64(len(png)))
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, int64(len(png)), upload.Size)
+	assert.Equal(t, "image/png", upload.ContentType)
+	assert.Len(t, upload.SHA256, 64)
+
+	r, err := upload.Reader()
+	assert.Nil(t, err)
+	content, _ := io.ReadAll(r)
+	assert.Equal(t, png, content)
+
+	name := upload.file.Name()
+	assert.Nil(t, upload.Close())
+	_, err = os.Stat(name)
+	assert.True(t, os.IsNotExist(err))
+
+	upload, err = Spool(strings.NewReader("hello"), 5)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "text/plain; charset=utf-8", upload.ContentType)
+		assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", upload.SHA256)
+		upload.Close()
+	}
+
+	_, err = Spool(strings.NewReader("hello"), 4)
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
+
+func TestTypeAllowed(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		allowed     []string
+		expected    bool
+	}{
+		{"no limit", "application/octet-stream", nil, true},
+		{"exact", "application/pdf", []string{"application/pdf"}, true},
+		{"parameters ignored", "text/plain; charset=utf-8", []string{"text/plain"}, true},
+		{"wildcard", "image/png", []string{"image/*"}, true},
+		{"wildcard other type", "text/plain", []string{"image/*"}, false},
+		{"not listed", "application/zip", []string{"image/*", "text/plain"}, false},
+		{"invalid", "", []string{"text/plain"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, TypeAllowed(tt.contentType, tt.allowed))
+		})
+	}
+}
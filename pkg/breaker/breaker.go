@@ -0,0 +1,115 @@
+package breaker
+
+import (
+	"sync"
+)
+
+type State int
+
+const (
+	// StateClosed lets every call through.
+	StateClosed State = iota
+	// StateOpen rejects every call until the dependency is reported healthy.
+	StateOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+const defaultFailureThreshold = 5
+
+// Breaker opens after threshold consecutive failures. It stays open until
+// Close is called, typically by a prober that found the dependency healthy
+// again, so callers don't each pay a timeout to find out.
+type Breaker struct {
+	threshold int
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	onChange func(from, to State)
+}
+
+func New(threshold int) *Breaker {
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	return &Breaker{
+		threshold: threshold,
+	}
+}
+
+// OnStateChange registers fn to be called after every state change. fn
+// runs with the breaker locked and must not call back into it.
+func (b *Breaker) OnStateChange(fn func(from, to State)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onChange = fn
+}
+
+// Allow reports whether a call may go to the dependency.
+func (b *Breaker) Allow() bool {
+	return b.State() == StateClosed
+}
+
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Success resets the consecutive failure count.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateClosed {
+		b.failures = 0
+	}
+}
+
+// Failure counts a failed call and opens the breaker at the threshold.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != StateClosed {
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.setState(StateOpen)
+	}
+}
+
+// Open opens the breaker regardless of the failure count.
+func (b *Breaker) Open() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.setState(StateOpen)
+}
+
+// Close closes the breaker and resets the failure count.
+func (b *Breaker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.setState(StateClosed)
+}
+
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	if b.onChange != nil {
+		b.onChange(from, state)
+	}
+}
@@ -0,0 +1,61 @@
+package breaker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	b := New(3)
+	changes := []State{}
+	b.OnStateChange(func(from, to State) {
+		changes = append(changes, to)
+	})
+
+	assert.True(t, b.Allow())
+	b.Failure()
+	b.Failure()
+	// a success in between resets the count
+	b.Success()
+	b.Failure()
+	b.Failure()
+	assert.True(t, b.Allow())
+	assert.Equal(t, StateClosed, b.State())
+
+	b.Failure()
+	assert.False(t, b.Allow())
+	assert.Equal(t, StateOpen, b.State())
+
+	// only Close brings it back
+	b.Success()
+	assert.False(t, b.Allow())
+	b.Close()
+	assert.True(t, b.Allow())
+
+	// the count starts over after closing
+	b.Failure()
+	assert.True(t, b.Allow())
+
+	b.Open()
+	b.Open()
+	assert.False(t, b.Allow())
+
+	assert.Equal(t, []State{StateOpen, StateClosed, StateOpen}, changes)
+}
+
+func TestNew(t *testing.T) {
+	b := New(0)
+	for i := 0; i < defaultFailureThreshold-1; i++ {
+		b.Failure()
+	}
+	assert.True(t, b.Allow())
+	b.Failure()
+	assert.False(t, b.Allow())
+}
+
+func TestStateString(t *testing.T) {
+	assert.Equal(t, "closed", StateClosed.String())
+	assert.Equal(t, "open", StateOpen.String())
+	assert.Equal(t, "unknown", State(9).String())
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// migrationFilePattern matches the up files of golang-migrate, e.g. 000001_init.up.sql
+var migrationFilePattern = regexp.MustCompile(`^([0-9]+)_.*\.up\.sql$`)
+
+// LatestMigrationVersion returns the highest version among the up
+// migrations in dir, the version the database has after migrating.
+func LatestMigrationVersion(dir string) (uint, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("LatestMigrationVersion: %v", err)
+	}
+
+	var latest uint
+	for _, entry := range entries {
+		matches := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || matches == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(matches[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("LatestMigrationVersion: %v", err)
+		}
+		if uint(version) > latest {
+			latest = uint(version)
+		}
+	}
+	return latest, nil
+}
+
+// CheckMigrationVersion fails when the schema of db is not at expected or a
+// migration was left dirty.
+func CheckMigrationVersion(ctx context.Context, db *sql.DB, expected uint) error {
+	var version uint
+	var dirty bool
+	row := db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1")
+	if err := row.Scan(&version, &dirty); err != nil {
+		return fmt.Errorf("CheckMigrationVersion: %v", err)
+	}
+	if dirty {
+		return fmt.Errorf("CheckMigrationVersion: migration %d is dirty", version)
+	}
+	if version != expected {
+		return fmt.Errorf("CheckMigrationVersion: schema version %d, expected %d", version, expected)
+	}
+	return nil
+}
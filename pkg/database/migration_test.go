@@ -0,0 +1,36 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatestMigrationVersion(t *testing.T) {
+	version, err := LatestMigrationVersion("../../migrations")
+	assert.Nil(t, err)
+	assert.NotZero(t, version)
+
+	dir := t.TempDir()
+	for _, name := range []string{
+		"000001_init.up.sql",
+		"000001_init.down.sql",
+		"000012_tag.up.sql",
+		"000013_comment.down.sql",
+		"readme.md",
+	} {
+		assert.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(""), 0644))
+	}
+	version, err = LatestMigrationVersion(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(12), version)
+
+	version, err = LatestMigrationVersion(t.TempDir())
+	assert.Nil(t, err)
+	assert.Equal(t, uint(0), version)
+
+	_, err = LatestMigrationVersion(filepath.Join(dir, "not-exist"))
+	assert.NotNil(t, err)
+}
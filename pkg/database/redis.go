@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"task_service/c"
+	"task_service/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func OpenRedisClient(option *config.CacheOption) (redis.UniversalClient, error) {
+	redisOpt, err := GetRedisOptions(option)
+	if err != nil {
+		return nil, fmt.Errorf("OpenRedisClient: %v", err)
+	}
+
+	var client redis.UniversalClient
+	switch strings.ToLower(option.Mode) {
+	case c.CacheModeCluster:
+		client = redis.NewClusterClient(redisOpt.Cluster())
+	case c.CacheModeSentinel:
+		client = redis.NewFailoverClient(redisOpt.Failover())
+	default:
+		client = redis.NewClient(redisOpt.Simple())
+	}
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("OpenRedisClient: %v", err)
+	}
+	return client, nil
+}
+
+func GetRedisOptions(option *config.CacheOption) (*redis.UniversalOptions, error) {
+	mode := strings.ToLower(option.Mode)
+	switch mode {
+	case "", c.CacheModeStandalone, c.CacheModeCluster, c.CacheModeSentinel:
+	default:
+		return nil, fmt.Errorf("GetRedisOptions: unknown mode %q", option.Mode)
+	}
+
+	addrs := option.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{fmt.Sprintf("%s:%d", option.Host, option.Port)}
+	}
+	if mode == c.CacheModeSentinel && option.MasterName == "" {
+		return nil, fmt.Errorf("GetRedisOptions: sentinel mode requires MASTER_NAME")
+	}
+
+	redisOpt := &redis.UniversalOptions{
+		Addrs:            addrs,
+		MasterName:       option.MasterName,
+		DB:               option.DB,
+		Username:         option.Username,
+		Password:         option.Password,
+		SentinelUsername: option.SentinelUsername,
+		SentinelPassword: option.SentinelPassword,
+		PoolSize:         option.PoolSize,
+		MinIdleConns:     option.MinIdleConns,
+		DialTimeout:      option.DialTimeout,
+		ReadTimeout:      option.ReadTimeout,
+		WriteTimeout:     option.WriteTimeout,
+	}
+
+	if option.TLS.Enable {
+		tlsConfig, err := GetTLSConfig(&option.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("GetRedisOptions: %v", err)
+		}
+		redisOpt.TLSConfig = tlsConfig
+	}
+
+	return redisOpt, nil
+}
+
+func GetTLSConfig(option *config.TLSOption) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         option.ServerName,
+		InsecureSkipVerify: option.InsecureSkipVerify,
+	}
+
+	if option.CAFile != "" {
+		pem, err := os.ReadFile(option.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("GetTLSConfig: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("GetTLSConfig: no certificate found in %s", option.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if option.CertFile != "" || option.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(option.CertFile, option.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("GetTLSConfig: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
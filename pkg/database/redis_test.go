@@ -0,0 +1,70 @@
+package database
+
+import (
+	"task_service/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRedisOptions(t *testing.T) {
+	tests := []struct {
+		opt           config.CacheOption
+		isErr         bool
+		expectedAddrs []string
+	}{
+		{
+			config.CacheOption{
+				Host: "127.0.0.1",
+				Port: 6379,
+			},
+			false,
+			[]string{"127.0.0.1:6379"},
+		},
+		{
+			config.CacheOption{
+				Mode:  "cluster",
+				Addrs: []string{"10.0.0.1:6379", "10.0.0.2:6379"},
+			},
+			false,
+			[]string{"10.0.0.1:6379", "10.0.0.2:6379"},
+		},
+		{
+			config.CacheOption{
+				Mode:  "sentinel",
+				Addrs: []string{"10.0.0.1:26379"},
+			},
+			true,
+			nil,
+		},
+		{
+			config.CacheOption{
+				Mode: "unknown",
+			},
+			true,
+			nil,
+		},
+		{
+			config.CacheOption{
+				Host: "127.0.0.1",
+				Port: 6379,
+				TLS: config.TLSOption{
+					Enable: true,
+					CAFile: "./not-exist-ca.pem",
+				},
+			},
+			true,
+			nil,
+		},
+	}
+
+	for _, testItem := range tests {
+		redisOpt, err := GetRedisOptions(&testItem.opt)
+		if testItem.isErr {
+			assert.NotNil(t, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, testItem.expectedAddrs, redisOpt.Addrs)
+	}
+}
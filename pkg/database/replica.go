@@ -0,0 +1,277 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"task_service/config"
+	"task_service/pkg/logger"
+
+	gormMysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+const (
+	defaultReplicaMaxLag        = 5 * time.Second
+	defaultReplicaCheckInterval = 5 * time.Second
+)
+
+// Replica is a read replica opened from config.DatabaseOption.Replicas.
+type Replica struct {
+	Addr string
+	DB   *sql.DB
+}
+
+// OpenMysqlReplicas opens every replica of option. Fields left empty on a
+// replica are taken from the primary.
+func OpenMysqlReplicas(option *config.DatabaseOption) ([]Replica, error) {
+	replicas := make([]Replica, 0, len(option.Replicas))
+	for _, replicaOption := range option.Replicas {
+		merged := MergeReplicaOption(option, &replicaOption)
+		db, err := OpenMysqlDatabase(&merged)
+		if err != nil {
+			for _, replica := range replicas {
+				replica.DB.Close()
+			}
+			return nil, fmt.Errorf("OpenMysqlReplicas: %v", err)
+		}
+		replicas = append(replicas, Replica{
+			Addr: fmt.Sprintf("%s:%d", merged.Host, merged.Port),
+			DB:   db,
+		})
+	}
+	return replicas, nil
+}
+
+// MergeReplicaOption fills the empty fields of replica with the primary's.
+func MergeReplicaOption(primary, replica *config.DatabaseOption) config.DatabaseOption {
+	merged := *replica
+	merged.Replicas = nil
+	if merged.Driver == "" {
+		merged.Driver = primary.Driver
+	}
+	if merged.Host == "" {
+		merged.Host = primary.Host
+	}
+	if merged.Port == 0 {
+		merged.Port = primary.Port
+	}
+	if merged.Username == "" {
+		merged.Username = primary.Username
+		merged.Password = primary.Password
+	}
+	if merged.DBName == "" {
+		merged.DBName = primary.DBName
+	}
+	if merged.Timezone == "" {
+		merged.Timezone = primary.Timezone
+	}
+	if merged.Charset == "" {
+		merged.Charset = primary.Charset
+	}
+	if merged.PoolSize == 0 {
+		merged.PoolSize = primary.PoolSize
+	}
+	if merged.Timeout == 0 {
+		merged.Timeout = primary.Timeout
+	}
+	if merged.ReadTimeout == 0 {
+		merged.ReadTimeout = primary.ReadTimeout
+	}
+	if merged.WriteTimeout == 0 {
+		merged.WriteTimeout = primary.WriteTimeout
+	}
+	return merged
+}
+
+// ReplicaPolicy picks a random healthy replica for reads and falls back to
+// the primary when none is healthy.
+type ReplicaPolicy struct {
+	primary gorm.ConnPool
+
+	mu      sync.RWMutex
+	healthy map[gorm.ConnPool]bool
+}
+
+func (p *ReplicaPolicy) Resolve(connPools []gorm.ConnPool) gorm.ConnPool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	candidates := make([]gorm.ConnPool, 0, len(connPools))
+	for _, connPool := range connPools {
+		if connPool != p.primary && p.healthy[connPool] {
+			candidates = append(candidates, connPool)
+		}
+	}
+	if len(candidates) == 0 {
+		return p.primary
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
+
+func (p *ReplicaPolicy) setHealthy(connPool gorm.ConnPool, healthy bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changed := p.healthy[connPool] != healthy
+	p.healthy[connPool] = healthy
+	return changed
+}
+
+// UseReplicas routes the reads of gormClient to replicas and writes to primary,
+// and keeps checking replicas until ctx is done.
+func UseReplicas(ctx context.Context, gormClient *gorm.DB, primary *sql.DB, replicas []Replica, option *config.DatabaseOption) error {
+	if len(replicas) == 0 {
+		return nil
+	}
+
+	policy := &ReplicaPolicy{
+		primary: primary,
+		healthy: map[gorm.ConnPool]bool{},
+	}
+
+	// The primary is always listed so that the resolver consults the policy
+	// even with a single replica, which lets reads fall back to it.
+	dialectors := []gorm.Dialector{gormMysql.New(gormMysql.Config{
+		SkipInitializeWithVersion: true,
+		Conn:                      primary,
+	})}
+	for _, replica := range replicas {
+		dialectors = append(dialectors, gormMysql.New(gormMysql.Config{
+			SkipInitializeWithVersion: true,
+			Conn:                      replica.DB,
+		}))
+	}
+
+	err := gormClient.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   policy,
+	}))
+	if err != nil {
+		return fmt.Errorf("UseReplicas: %v", err)
+	}
+
+	maxLag := option.ReplicaMaxLag
+	if maxLag <= 0 {
+		maxLag = defaultReplicaMaxLag
+	}
+	interval := option.ReplicaCheckInterval
+	if interval <= 0 {
+		interval = defaultReplicaCheckInterval
+	}
+
+	checkReplicas(ctx, policy, replicas, maxLag, interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkReplicas(ctx, policy, replicas, maxLag, interval)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func checkReplicas(ctx context.Context, policy *ReplicaPolicy, replicas []Replica, maxLag, timeout time.Duration) {
+	for _, replica := range replicas {
+		lag, err := replicaLag(ctx, replica.DB, timeout)
+		healthy := err == nil && lag <= maxLag
+		if !policy.setHealthy(replica.DB, healthy) {
+			continue
+		}
+
+		keys := map[string]interface{}{
+			"replica": replica.Addr,
+			"lag":     lag,
+		}
+		if err != nil {
+			keys["error"] = err
+		}
+		if healthy {
+			logger.GetLoggerWithKeys(keys).Info("replica back in rotation")
+		} else {
+			logger.GetLoggerWithKeys(keys).Warn("replica taken out of rotation")
+		}
+	}
+}
+
+// replicaLag pings the replica and returns how far it lags behind its source.
+// A server which is not replicating reports no lag.
+func replicaLag(ctx context.Context, db *sql.DB, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		return 0, fmt.Errorf("replicaLag: %v", err)
+	}
+
+	status, err := queryRow(ctx, db, "SHOW REPLICA STATUS")
+	if err != nil {
+		// MySQL before 8.0.22 only knows the old syntax.
+		if status, err = queryRow(ctx, db, "SHOW SLAVE STATUS"); err != nil {
+			return 0, fmt.Errorf("replicaLag: %v", err)
+		}
+	}
+	if status == nil {
+		return 0, nil
+	}
+
+	for _, column := range []string{"Seconds_Behind_Source", "Seconds_Behind_Master"} {
+		value, ok := status[column]
+		if !ok {
+			continue
+		}
+		if !value.Valid {
+			return 0, fmt.Errorf("replicaLag: replication is not running")
+		}
+		seconds, err := strconv.ParseInt(value.String, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("replicaLag: %v", err)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, nil
+}
+
+// queryRow returns the first row of query by column name, or nil without rows.
+func queryRow(ctx context.Context, db *sql.DB, query string) (map[string]sql.NullString, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]sql.NullString, len(columns))
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+	return row, nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"database/sql"
+	"task_service/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestMergeReplicaOption(t *testing.T) {
+	primary := config.DatabaseOption{
+		Driver:   "mysql",
+		Host:     "10.0.0.1",
+		Port:     3306,
+		Username: "user",
+		Password: "pass",
+		DBName:   "test",
+		PoolSize: 10,
+		Replicas: []config.DatabaseOption{{Host: "10.0.0.2"}},
+	}
+
+	merged := MergeReplicaOption(&primary, &primary.Replicas[0])
+	assert.Equal(t, config.DatabaseOption{
+		Driver:   "mysql",
+		Host:     "10.0.0.2",
+		Port:     3306,
+		Username: "user",
+		Password: "pass",
+		DBName:   "test",
+		PoolSize: 10,
+	}, merged)
+
+	merged = MergeReplicaOption(&primary, &config.DatabaseOption{Username: "reader"})
+	assert.Equal(t, "reader", merged.Username)
+	assert.Equal(t, "", merged.Password)
+}
+
+func TestReplicaPolicyResolve(t *testing.T) {
+	primary, _ := sql.Open("mysql", "user:pass@tcp(10.0.0.1:3306)/test")
+	replica1, _ := sql.Open("mysql", "user:pass@tcp(10.0.0.2:3306)/test")
+	replica2, _ := sql.Open("mysql", "user:pass@tcp(10.0.0.3:3306)/test")
+	pools := []gorm.ConnPool{primary, replica1, replica2}
+
+	policy := &ReplicaPolicy{
+		primary: primary,
+		healthy: map[gorm.ConnPool]bool{},
+	}
+	assert.Equal(t, gorm.ConnPool(primary), policy.Resolve(pools))
+
+	policy.setHealthy(replica2, true)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, gorm.ConnPool(replica2), policy.Resolve(pools))
+	}
+
+	policy.setHealthy(replica2, false)
+	assert.Equal(t, gorm.ConnPool(primary), policy.Resolve(pools))
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+const defaultBrokerBuffer = 16
+
+// Broker is a Sink fanning events out to in-process subscribers, such as
+// server-sent event streams. A subscriber which falls behind misses events
+// rather than blocking the publisher.
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[*subscription]struct{}
+	buffer int
+	closed bool
+}
+
+type subscription struct {
+	ch     chan Event
+	filter func(Event) bool
+}
+
+func NewBroker(buffer int) *Broker {
+	if buffer <= 0 {
+		buffer = defaultBrokerBuffer
+	}
+	return &Broker{
+		subs:   make(map[*subscription]struct{}),
+		buffer: buffer,
+	}
+}
+
+// Subscribe returns a channel receiving the events accepted by filter. The
+// channel is closed by cancel or Close.
+func (b *Broker) Subscribe(filter func(Event) bool) (<-chan Event, func()) {
+	sub := &subscription{ch: make(chan Event, b.buffer), filter: filter}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	b.subs[sub] = struct{}{}
+
+	return sub.ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[sub]; ok {
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+func (b *Broker) Publish(ctx context.Context, event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+// Close ends every subscription, so long-lived streams return before the
+// http server shuts down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
@@ -0,0 +1,42 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker(1)
+	ctx := context.Background()
+
+	all, cancelAll := broker.Subscribe(nil)
+	acme, _ := broker.Subscribe(func(event Event) bool { return event.TenantID == "acme" })
+
+	assert.NoError(t, broker.Publish(ctx, Event{ID: "1", TenantID: "acme"}))
+	assert.Equal(t, "1", (<-all).ID)
+	assert.Equal(t, "1", (<-acme).ID)
+
+	assert.NoError(t, broker.Publish(ctx, Event{ID: "2", TenantID: "other"}))
+	assert.Equal(t, "2", (<-all).ID)
+	assert.Empty(t, acme)
+
+	// a full subscriber drops events instead of blocking
+	assert.NoError(t, broker.Publish(ctx, Event{ID: "3"}))
+	assert.NoError(t, broker.Publish(ctx, Event{ID: "4"}))
+	assert.Equal(t, "3", (<-all).ID)
+
+	cancelAll()
+	_, open := <-all
+	assert.False(t, open)
+	cancelAll()
+
+	broker.Close()
+	_, open = <-acme
+	assert.False(t, open)
+
+	late, _ := broker.Subscribe(nil)
+	_, open = <-late
+	assert.False(t, open)
+}
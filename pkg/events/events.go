@@ -0,0 +1,99 @@
+// Package events delivers task events such as reminders to webhooks, the log
+// and server-sent event streams.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"task_service/pkg/logger"
+	"task_service/pkg/models"
+	"time"
+)
+
+const (
+	TypeTaskReminder = "task.reminder"
+	TypeTaskOverdue  = "task.overdue"
+	TypeTaskComment  = "task.comment"
+)
+
+type Event struct {
+	ID       string      `json:"id"`
+	Type     string      `json:"type"`
+	TenantID string      `json:"tenant_id"`
+	Time     time.Time   `json:"time"`
+	Task     models.Task `json:"task"`
+	// Comment is the new comment of task.comment events.
+	Comment *models.Comment `json:"comment,omitempty"`
+}
+
+// NewTaskEvent returns an event of eventType about task with a random id.
+func NewTaskEvent(eventType string, task models.Task, now time.Time) Event {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return Event{
+		ID:       hex.EncodeToString(b),
+		Type:     eventType,
+		TenantID: task.TenantID,
+		Time:     now,
+		Task:     task,
+	}
+}
+
+// NewCommentEvent returns the task.comment event of a comment added to task.
+func NewCommentEvent(task models.Task, comment models.Comment, now time.Time) Event {
+	event := NewTaskEvent(TypeTaskComment, task, now)
+	event.Comment = &comment
+	return event
+}
+
+// Sink receives published events.
+type Sink interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, event Event) error
+
+func (f SinkFunc) Publish(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// Bus publishes every event to all of its sinks.
+type Bus struct {
+	sinks []Sink
+}
+
+func NewBus(sinks ...Sink) *Bus {
+	return &Bus{sinks: sinks}
+}
+
+// Publish hands event to every sink, a failing sink does not stop the
+// others.
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	var errs []error
+	for _, sink := range b.sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type logSink struct{}
+
+// NewLogSink returns a Sink writing each event to the service log.
+func NewLogSink() Sink {
+	return logSink{}
+}
+
+func (logSink) Publish(ctx context.Context, event Event) error {
+	logger.GetLoggerWithContext(ctx, map[string]interface{}{
+		"event_id":   event.ID,
+		"event_type": event.Type,
+		"tenant":     event.TenantID,
+		"task_id":    event.Task.ID,
+	}).Info("task event")
+	return nil
+}
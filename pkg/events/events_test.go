@@ -0,0 +1,59 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"task_service/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewTaskEvent(t *testing.T) {
+	now := time.Now()
+	task := models.Task{ID: 1, TenantID: "acme"}
+
+	event := NewTaskEvent(TypeTaskReminder, task, now)
+	assert.Len(t, event.ID, 16)
+	assert.Equal(t, TypeTaskReminder, event.Type)
+	assert.Equal(t, "acme", event.TenantID)
+	assert.Equal(t, now, event.Time)
+	assert.Equal(t, task, event.Task)
+
+	assert.NotEqual(t, event.ID, NewTaskEvent(TypeTaskReminder, task, now).ID)
+}
+
+func TestNewCommentEvent(t *testing.T) {
+	now := time.Now()
+	task := models.Task{ID: 1, TenantID: "acme", CommentCount: 1}
+	comment := models.Comment{ID: 2, TaskID: 1, AuthorID: "u1", Body: "hi @u2", Mentions: []string{"u2"}}
+
+	event := NewCommentEvent(task, comment, now)
+	assert.Equal(t, TypeTaskComment, event.Type)
+	assert.Equal(t, "acme", event.TenantID)
+	assert.Equal(t, task, event.Task)
+	if assert.NotNil(t, event.Comment) {
+		assert.Equal(t, comment, *event.Comment)
+	}
+}
+
+func TestBus(t *testing.T) {
+	var received []string
+	record := func(name string, err error) Sink {
+		return SinkFunc(func(ctx context.Context, event Event) error {
+			received = append(received, name)
+			return err
+		})
+	}
+
+	failure := errors.New("sink down")
+	bus := NewBus(record("a", nil), record("b", failure), record("c", nil))
+
+	err := bus.Publish(context.Background(), Event{ID: "1"})
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, []string{"a", "b", "c"}, received)
+
+	assert.NoError(t, NewBus().Publish(context.Background(), Event{}))
+	assert.NoError(t, NewBus(NewLogSink()).Publish(context.Background(), Event{}))
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"task_service/pkg/logger"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type redisSink struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+// NewRedisSink returns a Sink publishing events to a redis channel, so that
+// every replica can relay them to its own subscribers.
+func NewRedisSink(client redis.UniversalClient, channel string) Sink {
+	return &redisSink{client: client, channel: channel}
+}
+
+func (s *redisSink) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("redis sink: %v", err)
+	}
+	if err := s.client.Publish(ctx, s.channel, payload).Err(); err != nil {
+		return fmt.Errorf("redis sink: %v", err)
+	}
+	return nil
+}
+
+// Relay forwards the events published to channel to sink until ctx is done.
+// ready, if not nil, is closed once the subscription is active.
+func Relay(ctx context.Context, client redis.UniversalClient, channel string, sink Sink, ready chan<- struct{}) error {
+	pubsub := client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return fmt.Errorf("Relay: %v", err)
+	}
+	if ready != nil {
+		close(ready)
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				logger.GetLoggerWithKeys(map[string]interface{}{
+					"error": err,
+				}).Error("Relay: invalid event")
+				continue
+			}
+			if err := sink.Publish(ctx, event); err != nil {
+				logger.GetLoggerWithKeys(map[string]interface{}{
+					"error": err,
+				}).Error("Relay: publish fail")
+			}
+		}
+	}
+}
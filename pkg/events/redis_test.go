@@ -0,0 +1,43 @@
+package events
+
+import (
+	"context"
+	"task_service/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisRelay(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	broker := NewBroker(1)
+	received, _ := broker.Subscribe(nil)
+
+	ready := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- Relay(ctx, client, "events", broker, ready)
+	}()
+	<-ready
+
+	event := Event{ID: "1", Type: TypeTaskReminder, Task: models.Task{ID: 3}}
+	assert.NoError(t, NewRedisSink(client, "events").Publish(ctx, event))
+
+	select {
+	case got := <-received:
+		assert.Equal(t, event.ID, got.ID)
+		assert.Equal(t, uint64(3), got.Task.ID)
+	case <-time.After(time.Second):
+		t.Fatal("event was not relayed")
+	}
+
+	cancel()
+	assert.NoError(t, <-done)
+}
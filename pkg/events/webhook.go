@@ -0,0 +1,77 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	HeaderEventType = "X-Task-Event"
+	HeaderEventID   = "X-Task-Event-ID"
+	// HeaderSignature carries "sha256=<hex hmac of the body>" when the
+	// webhook has a secret.
+	HeaderSignature = "X-Task-Signature"
+)
+
+const defaultWebhookTimeout = 5 * time.Second
+
+type webhookSink struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// NewWebhookSink returns a Sink posting each event as JSON to url.
+func NewWebhookSink(url, secret string, timeout time.Duration) Sink {
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &webhookSink{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *webhookSink) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("webhook: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventType, event.Type)
+	req.Header.Set(HeaderEventID, event.ID)
+	if len(s.secret) > 0 {
+		req.Header.Set(HeaderSignature, Sign(s.secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature header value of body, receivers compute the
+// same value to verify a delivery.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
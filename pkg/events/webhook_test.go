@@ -0,0 +1,52 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"task_service/pkg/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookSink(t *testing.T) {
+	var (
+		body    []byte
+		headers http.Header
+		status  = http.StatusNoContent
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		headers = r.Header
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	event := Event{ID: "abc", Type: TypeTaskOverdue, TenantID: "acme", Task: models.Task{ID: 7}}
+
+	sink := NewWebhookSink(server.URL, "secret", 0)
+	assert.NoError(t, sink.Publish(context.Background(), event))
+
+	var decoded Event
+	assert.NoError(t, json.Unmarshal(body, &decoded))
+	assert.Equal(t, event.ID, decoded.ID)
+	assert.Equal(t, uint64(7), decoded.Task.ID)
+	assert.Equal(t, TypeTaskOverdue, headers.Get(HeaderEventType))
+	assert.Equal(t, "abc", headers.Get(HeaderEventID))
+	assert.Equal(t, Sign([]byte("secret"), body), headers.Get(HeaderSignature))
+
+	assert.NoError(t, NewWebhookSink(server.URL, "", 0).Publish(context.Background(), event))
+	assert.Empty(t, headers.Get(HeaderSignature))
+
+	status = http.StatusInternalServerError
+	assert.Error(t, sink.Publish(context.Background(), event))
+}
+
+func TestSign(t *testing.T) {
+	assert.Equal(t,
+		"sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		Sign([]byte("key"), []byte("The quick brown fox jumps over the lazy dog")))
+}
@@ -0,0 +1,135 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+const defaultTimeout = time.Second
+
+// Check probes one dependency. A failing critical check makes the service
+// unready, a failing optional one only degrades it.
+type Check struct {
+	Name     string
+	Critical bool
+	Probe    func(ctx context.Context) error
+}
+
+// ComponentStatus is the result of one check.
+type ComponentStatus struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// Report is the readiness of the service and of each of its dependencies.
+type Report struct {
+	Status     string                     `json:"status"`
+	Draining   bool                       `json:"draining,omitempty"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// Ready reports whether the service should receive traffic.
+func (r Report) Ready() bool {
+	return r.Status != StatusDown
+}
+
+// Checker runs the checks concurrently, each bounded by timeout.
+type Checker struct {
+	checks   []Check
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Checker{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+// SetDraining makes the service unready regardless of its dependencies, so
+// load balancers stop routing to it before it shuts down.
+func (checker *Checker) SetDraining() {
+	checker.draining.Store(true)
+}
+
+func (checker *Checker) Draining() bool {
+	return checker.draining.Load()
+}
+
+func (checker *Checker) Check(ctx context.Context) Report {
+	report := Report{
+		Status:     StatusUp,
+		Draining:   checker.Draining(),
+		Components: make(map[string]ComponentStatus, len(checker.checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checker.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			status := checker.probe(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[check.Name] = status
+			if status.Status == StatusUp {
+				return
+			}
+			if check.Critical {
+				report.Status = StatusDown
+			} else if report.Status == StatusUp {
+				report.Status = StatusDegraded
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	if report.Draining {
+		report.Status = StatusDown
+	}
+	return report
+}
+
+func (checker *Checker) probe(ctx context.Context, check Check) ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, checker.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- check.Probe(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		// probes that ignore the context must not hold the report
+		err = ctx.Err()
+	}
+
+	status := ComponentStatus{
+		Status:    StatusUp,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		status.Status = StatusDown
+		status.Error = err.Error()
+	}
+	return status
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func probeOk(ctx context.Context) error {
+	return nil
+}
+
+func probeFail(ctx context.Context) error {
+	return fmt.Errorf("connection refused")
+}
+
+func probeHang(ctx context.Context) error {
+	time.Sleep(time.Second)
+	return nil
+}
+
+func TestChecker(t *testing.T) {
+	tests := []struct {
+		checks   []Check
+		expected string
+	}{
+		{
+			[]Check{{"mysql", true, probeOk}, {"redis", false, probeOk}},
+			StatusUp,
+		},
+		{
+			[]Check{{"mysql", true, probeOk}, {"redis", false, probeFail}},
+			StatusDegraded,
+		},
+		{
+			[]Check{{"mysql", true, probeFail}, {"redis", false, probeFail}},
+			StatusDown,
+		},
+		{
+			[]Check{{"mysql", true, probeHang}, {"redis", false, probeOk}},
+			StatusDown,
+		},
+		{
+			nil,
+			StatusUp,
+		},
+	}
+
+	for _, testItem := range tests {
+		checker := NewChecker(50*time.Millisecond, testItem.checks...)
+		report := checker.Check(context.Background())
+		assert.Equal(t, testItem.expected, report.Status, "checks = %v", testItem.checks)
+		assert.Equal(t, testItem.expected != StatusDown, report.Ready())
+		assert.Equal(t, len(testItem.checks), len(report.Components))
+	}
+}
+
+func TestCheckerComponents(t *testing.T) {
+	checker := NewChecker(50*time.Millisecond,
+		Check{"mysql", true, probeOk},
+		Check{"redis", false, probeFail},
+		Check{"migration", true, probeHang},
+	)
+	report := checker.Check(context.Background())
+
+	assert.Equal(t, StatusUp, report.Components["mysql"].Status)
+	assert.Equal(t, StatusDown, report.Components["redis"].Status)
+	assert.Equal(t, "connection refused", report.Components["redis"].Error)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["migration"].Error)
+}
+
+func TestCheckerDraining(t *testing.T) {
+	checker := NewChecker(0, Check{"mysql", true, probeOk})
+	assert.True(t, checker.Check(context.Background()).Ready())
+
+	checker.SetDraining()
+	report := checker.Check(context.Background())
+	assert.False(t, report.Ready())
+	assert.True(t, report.Draining)
+	assert.Equal(t, StatusUp, report.Components["mysql"].Status)
+}
@@ -0,0 +1,375 @@
+// Package ical renders tasks as an RFC 5545 iCalendar feed for calendar
+// apps.
+package ical
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"task_service/pkg/models"
+	"time"
+)
+
+// Components the tasks of a feed are rendered as. Most calendar apps only
+// show events, apps with a task list also read to-dos.
+const (
+	ComponentEvent = "event"
+	ComponentTodo  = "todo"
+)
+
+const (
+	prodId    = "-//task-service//tasks//EN"
+	uidDomain = "task-service"
+	// maxLineLength is the length of a content line in octets, longer lines
+	// are folded.
+	maxLineLength = 75
+	utcFormat     = "20060102T150405Z"
+	localFormat   = "20060102T150405"
+)
+
+// ValidComponent reports whether component is one of the components.
+func ValidComponent(component string) bool {
+	return component == ComponentEvent || component == ComponentTodo
+}
+
+// Calendar is the content of a feed. Tasks without a due date are left
+// out. A task of a series listed in Series is rendered as an instance of
+// the recurring entry of the series, the series themselves are rendered
+// once with their RRULE and EXDATEs.
+type Calendar struct {
+	// Name is shown by calendar apps as the name of the feed.
+	Name      string
+	Component string
+	Tasks     []models.Task
+	Series    map[uint64]models.TaskSeries
+}
+
+// Render writes cal as an iCalendar object. The output only depends on cal,
+// so it can be compared to tell whether a feed changed: DTSTAMP is the last
+// change of an entry rather than the time of the rendering.
+func Render(w io.Writer, cal Calendar) error {
+	out := &writer{w: w}
+	out.line("BEGIN", "VCALENDAR")
+	out.line("VERSION", "2.0")
+	out.line("PRODID", prodId)
+	out.line("CALSCALE", "GREGORIAN")
+	if cal.Name != "" {
+		out.text("X-WR-CALNAME", cal.Name)
+	}
+
+	rendered := map[uint64]bool{}
+	for _, task := range cal.Tasks {
+		if task.DueAt == nil {
+			continue
+		}
+		series, ok := recurringSeries(cal, task)
+		if !ok {
+			out.task(cal.Component, task, taskUID(task), nil)
+			continue
+		}
+		if !rendered[series.ID] {
+			rendered[series.ID] = true
+			out.series(cal.Component, series)
+		}
+		recurrenceId := anchor(cal.Component, series, *task.OccurrenceAt)
+		out.task(cal.Component, task, seriesUID(series), &recurrenceId)
+	}
+
+	out.line("END", "VCALENDAR")
+	return out.err
+}
+
+// recurringSeries returns the series task is an instance of. Occurrences of
+// series which ended, or which started before the rule of their series was
+// changed, are not instances of the rule and are rendered on their own.
+func recurringSeries(cal Calendar, task models.Task) (models.TaskSeries, bool) {
+	if task.SeriesID == nil || task.OccurrenceAt == nil {
+		return models.TaskSeries{}, false
+	}
+	series, ok := cal.Series[*task.SeriesID]
+	if !ok || series.NextAt == nil || series.DueOffset == nil || task.OccurrenceAt.Before(series.DTStart) {
+		return models.TaskSeries{}, false
+	}
+	return series, true
+}
+
+func taskUID(task models.Task) string {
+	return fmt.Sprintf("task-%d-%s@%s", task.ID, task.TenantID, uidDomain)
+}
+
+func seriesUID(series models.TaskSeries) string {
+	return fmt.Sprintf("series-%d-%s@%s", series.ID, series.TenantID, uidDomain)
+}
+
+// anchorOffset is the offset from the start of an occurrence to the
+// DTSTART of its instance. An event starts when the task is due. A to-do
+// starts with its occurrence and is due later, unless the series puts the
+// due time at or before the start, which a to-do cannot express.
+func anchorOffset(component string, series models.TaskSeries) time.Duration {
+	offset := time.Duration(*series.DueOffset) * time.Second
+	if component == ComponentTodo && offset > 0 {
+		return 0
+	}
+	return offset
+}
+
+// anchor returns the DTSTART of the instance of the occurrence of series
+// starting at start, which is also its RECURRENCE-ID.
+func anchor(component string, series models.TaskSeries, start time.Time) time.Time {
+	return start.Add(anchorOffset(component, series))
+}
+
+// task writes the component of task. recurrenceId is set for an instance
+// of a recurring entry.
+func (out *writer) task(component string, task models.Task, uid string, recurrenceId *time.Time) {
+	name := componentName(component)
+	out.line("BEGIN", name)
+	out.line("UID", uid)
+	out.utc("DTSTAMP", task.UpdatedAt)
+	if !task.CreatedAt.IsZero() {
+		out.utc("CREATED", task.CreatedAt)
+	}
+	out.utc("LAST-MODIFIED", task.UpdatedAt)
+
+	var location *time.Location
+	if recurrenceId != nil {
+		location = out.location(task.SeriesID)
+		out.time("RECURRENCE-ID", *recurrenceId, location)
+	}
+	switch {
+	case component == ComponentEvent:
+		out.time("DTSTART", *task.DueAt, location)
+	case recurrenceId != nil && recurrenceId.Before(*task.DueAt):
+		out.time("DTSTART", *recurrenceId, location)
+		out.time("DUE", *task.DueAt, location)
+	case recurrenceId != nil:
+		out.time("DTSTART", *task.DueAt, location)
+	default:
+		out.time("DUE", *task.DueAt, location)
+	}
+
+	out.text("SUMMARY", task.Name)
+	if task.Content != "" {
+		out.text("DESCRIPTION", task.Content)
+	}
+	out.line("STATUS", status(component, task.Status))
+	if component == ComponentTodo {
+		out.line("PERCENT-COMPLETE", strconv.Itoa(task.Progress))
+		if task.Status == models.TaskStatusDone {
+			out.utc("COMPLETED", task.UpdatedAt)
+		}
+	}
+	out.common(task.Priority, task.Tags)
+	if task.RemindAt != nil {
+		out.alarm(task.Name, "TRIGGER;VALUE=DATE-TIME", task.RemindAt.UTC().Format(utcFormat))
+	}
+	out.line("END", name)
+}
+
+// series writes the recurring entry of series, the instances it has as
+// tasks are written by task.
+func (out *writer) series(component string, series models.TaskSeries) {
+	location, err := time.LoadLocation(series.Timezone)
+	if err != nil || series.Timezone == "" {
+		location = time.UTC
+	}
+	if out.locations == nil {
+		out.locations = map[uint64]*time.Location{}
+	}
+	out.locations[series.ID] = location
+
+	name := componentName(component)
+	offset := anchorOffset(component, series)
+	start := series.DTStart.Add(offset)
+	out.line("BEGIN", name)
+	out.line("UID", seriesUID(series))
+	out.utc("DTSTAMP", series.UpdatedAt)
+	out.utc("CREATED", series.CreatedAt)
+	out.utc("LAST-MODIFIED", series.UpdatedAt)
+	out.time("DTSTART", start, location)
+	if due := time.Duration(*series.DueOffset) * time.Second; component == ComponentTodo && due > offset {
+		out.time("DUE", series.DTStart.Add(due), location)
+	}
+	out.line("RRULE", strings.TrimPrefix(series.RRule, "RRULE:"))
+	exdates := make([]time.Time, 0, len(series.Exdates))
+	for _, exdate := range series.Exdates {
+		exdates = append(exdates, exdate.Add(offset))
+	}
+	sort.Slice(exdates, func(i, j int) bool { return exdates[i].Before(exdates[j]) })
+	for _, exdate := range exdates {
+		out.time("EXDATE", exdate, location)
+	}
+
+	out.text("SUMMARY", series.Name)
+	if series.Content != "" {
+		out.text("DESCRIPTION", series.Content)
+	}
+	out.line("STATUS", status(component, models.TaskStatusOpen))
+	out.common(series.Priority, series.Tags)
+	if series.RemindOffset != nil {
+		trigger := time.Duration(*series.RemindOffset)*time.Second - offset
+		out.alarm(series.Name, "TRIGGER;RELATED=START", formatDuration(trigger))
+	}
+	out.line("END", name)
+}
+
+// location returns the time zone of the series of an instance, written by
+// series before its instances.
+func (out *writer) location(seriesId *uint64) *time.Location {
+	if location, ok := out.locations[*seriesId]; ok {
+		return location
+	}
+	return time.UTC
+}
+
+func (out *writer) common(priority int, tags []string) {
+	if p := icalPriority(priority); p != 0 {
+		out.line("PRIORITY", strconv.Itoa(p))
+	}
+	if len(tags) > 0 {
+		escaped := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			escaped = append(escaped, escapeText(tag))
+		}
+		out.line("CATEGORIES", strings.Join(escaped, ","))
+	}
+}
+
+func (out *writer) alarm(summary, trigger, value string) {
+	out.line("BEGIN", "VALARM")
+	out.line("ACTION", "DISPLAY")
+	out.text("DESCRIPTION", summary)
+	out.line(trigger, value)
+	out.line("END", "VALARM")
+}
+
+func componentName(component string) string {
+	if component == ComponentTodo {
+		return "VTODO"
+	}
+	return "VEVENT"
+}
+
+// status maps the status of a task. Events have no notion of progress, they
+// are confirmed whatever the status of the task.
+func status(component string, taskStatus int) string {
+	if component != ComponentTodo {
+		return "CONFIRMED"
+	}
+	switch taskStatus {
+	case models.TaskStatusInProgress:
+		return "IN-PROCESS"
+	case models.TaskStatusDone:
+		return "COMPLETED"
+	}
+	return "NEEDS-ACTION"
+}
+
+// icalPriority maps a task priority to the 1 (highest) to 9 (lowest) scale
+// of iCalendar, 0 is undefined.
+func icalPriority(priority int) int {
+	switch priority {
+	case models.TaskPriorityUrgent:
+		return 1
+	case models.TaskPriorityHigh:
+		return 3
+	case models.TaskPriorityMedium:
+		return 5
+	case models.TaskPriorityLow:
+		return 9
+	}
+	return 0
+}
+
+// formatTime returns the value of a DATE-TIME property, in UTC or in the
+// local time of location.
+func formatTime(t time.Time, location *time.Location) string {
+	if location == nil || location == time.UTC {
+		return t.UTC().Format(utcFormat)
+	}
+	return t.In(location).Format(localFormat)
+}
+
+// formatDuration returns d as an iCalendar DURATION such as -PT15M.
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	seconds := int64(d / time.Second)
+	days := seconds / 86400
+	seconds %= 86400
+	b := strings.Builder{}
+	b.WriteString(sign + "P")
+	if days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+	}
+	if seconds > 0 || days == 0 {
+		b.WriteString("T")
+		if h := seconds / 3600; h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+		}
+		if m := seconds % 3600 / 60; m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+		}
+		if s := seconds % 60; s > 0 || seconds == 0 {
+			fmt.Fprintf(&b, "%dS", s)
+		}
+	}
+	return b.String()
+}
+
+// escapeText escapes a TEXT value.
+func escapeText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(value)
+}
+
+// writer writes content lines, keeping the first error.
+type writer struct {
+	w   io.Writer
+	err error
+	// locations are the time zones of the series written so far
+	locations map[uint64]*time.Location
+}
+
+func (out *writer) text(name, value string) {
+	out.line(name, escapeText(value))
+}
+
+func (out *writer) utc(name string, t time.Time) {
+	out.line(name, t.UTC().Format(utcFormat))
+}
+
+// time writes a DATE-TIME property, with a TZID parameter unless location
+// is UTC.
+func (out *writer) time(name string, t time.Time, location *time.Location) {
+	if location != nil && location != time.UTC {
+		name += ";TZID=" + location.String()
+	}
+	out.line(name, formatTime(t, location))
+}
+
+// line writes a content line, folded after maxLineLength octets without
+// splitting a UTF-8 sequence.
+func (out *writer) line(name, value string) {
+	if out.err != nil {
+		return
+	}
+	line := name + ":" + value
+	b := strings.Builder{}
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > maxLineLength {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+	_, out.err = io.WriteString(out.w, b.String())
+}
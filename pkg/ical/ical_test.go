@@ -0,0 +1,152 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"task_service/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func render(t *testing.T, cal Calendar) string {
+	buf := &bytes.Buffer{}
+	assert.Nil(t, Render(buf, cal))
+	return buf.String()
+}
+
+func TestRender(t *testing.T) {
+	due := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	remind := due.Add(-time.Hour)
+	updated := time.Date(2024, 4, 20, 8, 30, 0, 0, time.UTC)
+	task := models.Task{
+		ID: 7, TenantID: "acme", Name: "Ship, then celebrate", Content: "line one\nline two; done",
+		Status: models.TaskStatusDone, Priority: models.TaskPriorityHigh, Tags: []string{"backend", "release"},
+		Progress: 100, DueAt: &due, RemindAt: &remind, CreatedAt: updated, UpdatedAt: updated,
+	}
+	undated := models.Task{ID: 8, TenantID: "acme", Name: "Someday"}
+
+	tests := []struct {
+		component string
+		expected  []string
+	}{
+		{ComponentEvent, []string{
+			"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//task-service//tasks//EN", "CALSCALE:GREGORIAN", "X-WR-CALNAME:My tasks",
+			"BEGIN:VEVENT", "UID:task-7-acme@task-service", "DTSTAMP:20240420T083000Z", "CREATED:20240420T083000Z",
+			"LAST-MODIFIED:20240420T083000Z", "DTSTART:20240501T090000Z", "SUMMARY:Ship\\, then celebrate",
+			"DESCRIPTION:line one\\nline two\\; done", "STATUS:CONFIRMED", "PRIORITY:3", "CATEGORIES:backend,release",
+			"BEGIN:VALARM", "ACTION:DISPLAY", "DESCRIPTION:Ship\\, then celebrate", "TRIGGER;VALUE=DATE-TIME:20240501T080000Z",
+			"END:VALARM", "END:VEVENT", "END:VCALENDAR", "",
+		}},
+		{ComponentTodo, []string{
+			"BEGIN:VCALENDAR", "VERSION:2.0", "PRODID:-//task-service//tasks//EN", "CALSCALE:GREGORIAN", "X-WR-CALNAME:My tasks",
+			"BEGIN:VTODO", "UID:task-7-acme@task-service", "DTSTAMP:20240420T083000Z", "CREATED:20240420T083000Z",
+			"LAST-MODIFIED:20240420T083000Z", "DUE:20240501T090000Z", "SUMMARY:Ship\\, then celebrate",
+			"DESCRIPTION:line one\\nline two\\; done", "STATUS:COMPLETED", "PERCENT-COMPLETE:100", "COMPLETED:20240420T083000Z",
+			"PRIORITY:3", "CATEGORIES:backend,release",
+			"BEGIN:VALARM", "ACTION:DISPLAY", "DESCRIPTION:Ship\\, then celebrate", "TRIGGER;VALUE=DATE-TIME:20240501T080000Z",
+			"END:VALARM", "END:VTODO", "END:VCALENDAR", "",
+		}},
+	}
+
+	for _, testItem := range tests {
+		output := render(t, Calendar{Name: "My tasks", Component: testItem.component, Tasks: []models.Task{undated, task}})
+		assert.Equal(t, strings.Join(testItem.expected, "\r\n"), output, testItem.component)
+	}
+}
+
+func TestRenderStatus(t *testing.T) {
+	due := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status   int
+		expected string
+	}{
+		{models.TaskStatusOpen, "STATUS:NEEDS-ACTION"},
+		{models.TaskStatusInProgress, "STATUS:IN-PROCESS"},
+		{models.TaskStatusDone, "STATUS:COMPLETED"},
+	}
+
+	for _, testItem := range tests {
+		task := models.Task{ID: 1, Name: "a", Status: testItem.status, DueAt: &due}
+		output := render(t, Calendar{Component: ComponentTodo, Tasks: []models.Task{task}})
+		assert.Contains(t, output, "\r\n"+testItem.expected+"\r\n")
+		assert.Equal(t, testItem.status == models.TaskStatusDone, strings.Contains(output, "\r\nCOMPLETED:"))
+		assert.NotContains(t, output, "PRIORITY")
+	}
+}
+
+func TestRenderSeries(t *testing.T) {
+	location, _ := time.LoadLocation("Europe/Paris")
+	dtstart := time.Date(2024, 4, 1, 9, 0, 0, 0, location)
+	next := dtstart.AddDate(0, 0, 14)
+	dueOffset := int64(3600)
+	remindOffset := int64(-900)
+	series := models.TaskSeries{
+		ID: 3, TenantID: "acme", RRule: "FREQ=WEEKLY;BYDAY=MO", Timezone: "Europe/Paris", DTStart: dtstart.UTC(),
+		NextAt: &next, Exdates: []time.Time{dtstart.AddDate(0, 0, 7).UTC()}, Name: "Weekly report",
+		DueOffset: &dueOffset, RemindOffset: &remindOffset,
+	}
+	old := dtstart.AddDate(0, 0, -7).UTC()
+	seriesId := series.ID
+	first := series.Occurrence(dtstart.UTC())
+	first.ID = 11
+	stale := series.Occurrence(old)
+	stale.ID = 10
+
+	output := render(t, Calendar{Component: ComponentEvent, Tasks: []models.Task{first, stale},
+		Series: map[uint64]models.TaskSeries{seriesId: series}})
+	assert.Contains(t, output, "BEGIN:VEVENT\r\nUID:series-3-acme@task-service\r\n")
+	assert.Contains(t, output, "\r\nDTSTART;TZID=Europe/Paris:20240401T100000\r\nRRULE:FREQ=WEEKLY;BYDAY=MO\r\n"+
+		"EXDATE;TZID=Europe/Paris:20240408T100000\r\n")
+	assert.Contains(t, output, "\r\nTRIGGER;RELATED=START:-PT1H15M\r\n")
+	assert.Contains(t, output, "\r\nRECURRENCE-ID;TZID=Europe/Paris:20240401T100000\r\nDTSTART;TZID=Europe/Paris:20240401T100000\r\n")
+	// occurrences before the rule are not instances of it
+	assert.Contains(t, output, "UID:task-10-acme@task-service\r\n")
+	assert.Equal(t, 1, strings.Count(output, "RRULE:"))
+
+	output = render(t, Calendar{Component: ComponentTodo, Tasks: []models.Task{first},
+		Series: map[uint64]models.TaskSeries{seriesId: series}})
+	assert.Contains(t, output, "\r\nDTSTART;TZID=Europe/Paris:20240401T090000\r\nDUE;TZID=Europe/Paris:20240401T100000\r\n")
+	assert.Contains(t, output, "\r\nRECURRENCE-ID;TZID=Europe/Paris:20240401T090000\r\nDTSTART;TZID=Europe/Paris:20240401T090000\r\n"+
+		"DUE;TZID=Europe/Paris:20240401T100000\r\n")
+	assert.Contains(t, output, "\r\nTRIGGER;RELATED=START:-PT15M\r\n")
+
+	// ended series are not recurring anymore
+	series.NextAt = nil
+	output = render(t, Calendar{Component: ComponentEvent, Tasks: []models.Task{first},
+		Series: map[uint64]models.TaskSeries{seriesId: series}})
+	assert.NotContains(t, output, "RRULE")
+	assert.Contains(t, output, "UID:task-11-acme@task-service\r\n")
+}
+
+func TestFold(t *testing.T) {
+	buf := &bytes.Buffer{}
+	out := &writer{w: buf}
+	out.line("SUMMARY", strings.Repeat("a", 70)+"ééé")
+	assert.Equal(t, "SUMMARY:"+strings.Repeat("a", 67)+"\r\n "+strings.Repeat("a", 3)+"ééé\r\n", buf.String())
+
+	buf.Reset()
+	out.line("SUMMARY", strings.Repeat("é", 40))
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	for _, line := range lines {
+		assert.LessOrEqual(t, len(line), maxLineLength)
+	}
+	assert.Equal(t, "SUMMARY:"+strings.Repeat("é", 33), lines[0])
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		expected string
+	}{
+		{0, "PT0S"},
+		{-15 * time.Minute, "-PT15M"},
+		{26*time.Hour + 30*time.Second, "P1DT2H30S"},
+		{48 * time.Hour, "P2D"},
+	}
+
+	for _, testItem := range tests {
+		assert.Equal(t, testItem.expected, formatDuration(testItem.duration))
+	}
+}
@@ -0,0 +1,39 @@
+// Package lock provides distributed locks with expiring leases and fencing
+// tokens, backed by redis or a MySQL lease table.
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Lease is a lock held by a single owner until it is released or expires.
+type Lease struct {
+	Key   string
+	Owner string
+	// Token increases every time the key is acquired, so a resource can
+	// reject writes carrying a token older than the last one it has seen.
+	Token uint64
+}
+
+// Locker is implemented by every lock backend. Implementations must pass
+// the conformance suite in lock_test.go.
+type Locker interface {
+	// Acquire tries once to take key for ttl. ok is false when the key is
+	// held by another owner.
+	Acquire(ctx context.Context, key string, ttl time.Duration) (lease Lease, ok bool, err error)
+	// Release gives up lease. Releasing a lease which has expired does not
+	// affect whoever holds the key now.
+	Release(ctx context.Context, lease Lease) error
+}
+
+func newOwner() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("newOwner: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,143 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// harness adapts a backend to the conformance suite. expire lets the lease
+// of ttl run out, which a fake server may do without sleeping.
+type harness struct {
+	locker Locker
+	ttl    time.Duration
+	expire func(t *testing.T)
+}
+
+// testLocker is the conformance suite every Locker must pass.
+func testLocker(t *testing.T, newHarness func(t *testing.T) harness) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, h harness)
+	}{
+		{
+			name: "held key cannot be acquired",
+			run: func(t *testing.T, h harness) {
+				_, ok, err := h.locker.Acquire(ctx, "task", h.ttl)
+				require.NoError(t, err)
+				assert.True(t, ok)
+
+				_, ok, err = h.locker.Acquire(ctx, "task", h.ttl)
+				require.NoError(t, err)
+				assert.False(t, ok)
+			},
+		},
+		{
+			name: "keys are independent",
+			run: func(t *testing.T, h harness) {
+				_, ok, err := h.locker.Acquire(ctx, "task-1", h.ttl)
+				require.NoError(t, err)
+				assert.True(t, ok)
+
+				_, ok, err = h.locker.Acquire(ctx, "task-2", h.ttl)
+				require.NoError(t, err)
+				assert.True(t, ok)
+			},
+		},
+		{
+			name: "released key can be acquired with a newer token",
+			run: func(t *testing.T, h harness) {
+				first, ok, err := h.locker.Acquire(ctx, "task", h.ttl)
+				require.NoError(t, err)
+				require.True(t, ok)
+				require.NoError(t, h.locker.Release(ctx, first))
+
+				second, ok, err := h.locker.Acquire(ctx, "task", h.ttl)
+				require.NoError(t, err)
+				require.True(t, ok)
+				assert.Greater(t, second.Token, first.Token)
+			},
+		},
+		{
+			name: "expired key can be acquired with a newer token",
+			run: func(t *testing.T, h harness) {
+				first, ok, err := h.locker.Acquire(ctx, "task", h.ttl)
+				require.NoError(t, err)
+				require.True(t, ok)
+				h.expire(t)
+
+				second, ok, err := h.locker.Acquire(ctx, "task", h.ttl)
+				require.NoError(t, err)
+				require.True(t, ok)
+				assert.Greater(t, second.Token, first.Token)
+			},
+		},
+		{
+			name: "releasing an expired lease keeps the new owner",
+			run: func(t *testing.T, h harness) {
+				stale, ok, err := h.locker.Acquire(ctx, "task", h.ttl)
+				require.NoError(t, err)
+				require.True(t, ok)
+				h.expire(t)
+
+				_, ok, err = h.locker.Acquire(ctx, "task", h.ttl)
+				require.NoError(t, err)
+				require.True(t, ok)
+				require.NoError(t, h.locker.Release(ctx, stale))
+
+				_, ok, err = h.locker.Acquire(ctx, "task", h.ttl)
+				require.NoError(t, err)
+				assert.False(t, ok)
+			},
+		},
+		{
+			name: "concurrent acquire has a single winner",
+			run: func(t *testing.T, h harness) {
+				var (
+					wg   sync.WaitGroup
+					mu   sync.Mutex
+					wins int
+				)
+				for i := 0; i < 10; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						_, ok, err := h.locker.Acquire(ctx, "task", h.ttl)
+						assert.NoError(t, err)
+						if ok {
+							mu.Lock()
+							wins++
+							mu.Unlock()
+						}
+					}()
+				}
+				wg.Wait()
+				assert.Equal(t, 1, wins)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.run(t, newHarness(t))
+		})
+	}
+}
+
+func TestNewOwner(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		owner, err := newOwner()
+		assert.NoError(t, err)
+		assert.Len(t, owner, 32)
+		assert.False(t, seen[owner], fmt.Sprintf("duplicate owner %s", owner))
+		seen[owner] = true
+	}
+}
@@ -0,0 +1,74 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultTable is the lease table created by the migrations.
+const DefaultTable = "TaskLock"
+
+type mysqlLocker struct {
+	db    *gorm.DB
+	table string
+}
+
+// NewMysqlLocker returns a Locker keeping one row per key in table. Expiry
+// is checked against the database clock so it does not depend on the clocks
+// of the service instances. Rows are never deleted, which keeps the fencing
+// token increasing across releases.
+func NewMysqlLocker(db *gorm.DB, table string) Locker {
+	if table == "" {
+		table = DefaultTable
+	}
+	return &mysqlLocker{db: db, table: table}
+}
+
+func (l *mysqlLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, bool, error) {
+	owner, err := newOwner()
+	if err != nil {
+		return Lease{}, false, fmt.Errorf("Acquire: %v", err)
+	}
+
+	lease := Lease{Key: key, Owner: owner}
+	acquired := false
+	// The transaction keeps both statements on the primary. The assignments
+	// run left to right, so expires_at has to be the last one.
+	err = l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(fmt.Sprintf("INSERT INTO `%s` (`name`, `owner`, `token`, `expires_at`) "+
+			"VALUES (?, ?, 1, NOW(6) + INTERVAL ? MICROSECOND) ON DUPLICATE KEY UPDATE "+
+			"`token` = IF(`expires_at` <= NOW(6), `token` + 1, `token`), "+
+			"`owner` = IF(`expires_at` <= NOW(6), VALUES(`owner`), `owner`), "+
+			"`expires_at` = IF(`expires_at` <= NOW(6), VALUES(`expires_at`), `expires_at`)", l.table),
+			key, owner, ttl.Microseconds())
+		if result.Error != nil {
+			return result.Error
+		}
+		// 1 for an insert, 2 for a takeover and 0 when the lease is still held.
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		acquired = true
+		return tx.Raw(fmt.Sprintf("SELECT `token` FROM `%s` WHERE `name` = ?", l.table), key).
+			Scan(&lease.Token).Error
+	})
+	if err != nil {
+		return Lease{}, false, fmt.Errorf("Acquire: %v", err)
+	}
+	if !acquired {
+		return Lease{}, false, nil
+	}
+	return lease, true, nil
+}
+
+func (l *mysqlLocker) Release(ctx context.Context, lease Lease) error {
+	if err := l.db.WithContext(ctx).Exec(fmt.Sprintf("UPDATE `%s` SET `expires_at` = NOW(6) "+
+		"WHERE `name` = ? AND `owner` = ? AND `token` = ?", l.table),
+		lease.Key, lease.Owner, lease.Token).Error; err != nil {
+		return fmt.Errorf("Release: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package lock
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TEST_MYSQL_DSN points the MySQL tests at a disposable database, e.g.
+// "root:pass@tcp(127.0.0.1:3306)/task-service-test".
+const testMysqlDSNEnv = "TEST_MYSQL_DSN"
+
+func TestMysqlLocker(t *testing.T) {
+	dsn := os.Getenv(testMysqlDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testMysqlDSNEnv)
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+
+	schema, err := os.ReadFile("../../migrations/000005_task_lock.up.sql")
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(string(schema)).Error)
+
+	testLocker(t, func(t *testing.T) harness {
+		require.NoError(t, db.Exec("DELETE FROM `TaskLock`").Error)
+
+		ttl := 200 * time.Millisecond
+		return harness{
+			locker: NewMysqlLocker(db, DefaultTable),
+			ttl:    ttl,
+			expire: func(t *testing.T) { time.Sleep(ttl + 50*time.Millisecond) },
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// acquireScript sets the lock only when it is free and bumps the fencing
+// counter, which outlives the lock itself.
+var acquireScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+
+// releaseScript deletes the lock only if it still belongs to the caller.
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type redisLocker struct {
+	client redis.Scripter
+}
+
+// NewRedisLocker returns a Locker storing the lock of key in
+// "lock:{key}" and its fencing counter in "lock:{key}:fence". The hash tag
+// keeps both in the same cluster slot.
+func NewRedisLocker(client redis.Scripter) Locker {
+	return &redisLocker{client: client}
+}
+
+func (l *redisLocker) keys(key string) []string {
+	lockKey := fmt.Sprintf("lock:{%s}", key)
+	return []string{lockKey, lockKey + ":fence"}
+}
+
+func (l *redisLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, bool, error) {
+	owner, err := newOwner()
+	if err != nil {
+		return Lease{}, false, fmt.Errorf("Acquire: %v", err)
+	}
+
+	token, err := acquireScript.Run(ctx, l.client, l.keys(key), owner, ttl.Milliseconds()).Uint64()
+	if err != nil {
+		return Lease{}, false, fmt.Errorf("Acquire: %v", err)
+	}
+	if token == 0 {
+		return Lease{}, false, nil
+	}
+	return Lease{Key: key, Owner: owner, Token: token}, true, nil
+}
+
+func (l *redisLocker) Release(ctx context.Context, lease Lease) error {
+	if err := releaseScript.Run(ctx, l.client, l.keys(lease.Key)[:1], lease.Owner).Err(); err != nil {
+		return fmt.Errorf("Release: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisLocker(t *testing.T) {
+	testLocker(t, func(t *testing.T) harness {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+
+		ttl := time.Minute
+		return harness{
+			locker: NewRedisLocker(client),
+			ttl:    ttl,
+			expire: func(t *testing.T) { server.FastForward(ttl) },
+		}
+	})
+}
+
+func TestRedisLockerKeys(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	lease, ok, err := NewRedisLocker(client).Acquire(context.Background(), "task", time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	owner, err := server.Get("lock:{task}")
+	assert.NoError(t, err)
+	assert.Equal(t, lease.Owner, owner)
+	assert.Equal(t, time.Minute, server.TTL("lock:{task}"))
+
+	fence, err := server.Get("lock:{task}:fence")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", fence)
+}
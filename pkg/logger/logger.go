@@ -1,8 +1,10 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -123,6 +125,10 @@ func LoadExtra(extras map[string]interface{}) *zap.Logger {
 }
 
 func GetLoggerWithKeys(keys ...map[string]interface{}) *zap.Logger {
+	return withKeys(defaultLogger, keys...)
+}
+
+func withKeys(base *zap.Logger, keys ...map[string]interface{}) *zap.Logger {
 	extras := map[string]interface{}{}
 	for _, inputKeys := range keys {
 		for k, v := range inputKeys {
@@ -137,5 +143,36 @@ func GetLoggerWithKeys(keys ...map[string]interface{}) *zap.Logger {
 		args[i+1] = v
 		i += 2
 	}
-	return With(args...)
+	return base.Sugar().With(args...).Desugar()
+}
+
+type loggerCtxKey struct{}
+
+// NewContext returns a copy of ctx carrying the request-scoped logger l.
+func NewContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, l)
+}
+
+// FromContext returns the logger set by NewContext, or the default logger.
+func FromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(loggerCtxKey{}).(*zap.Logger); ok && l != nil {
+		return l
+	}
+	return defaultLogger
+}
+
+// GetLoggerWithContext is GetLoggerWithKeys on top of the request-scoped
+// logger of ctx, plus the trace_id and span_id of the span in ctx, so log
+// lines can be joined with their request and trace.
+func GetLoggerWithContext(ctx context.Context, keys ...map[string]interface{}) *zap.Logger {
+	base := FromContext(ctx)
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return withKeys(base, keys...)
+	}
+
+	return withKeys(base, append(keys, map[string]interface{}{
+		"trace_id": spanContext.TraceID().String(),
+		"span_id":  spanContext.SpanID().String(),
+	})...)
 }
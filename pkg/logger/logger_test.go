@@ -1,12 +1,15 @@
 package logger
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 func TestNew(t *testing.T) {
@@ -152,6 +155,39 @@ func TestLogger(t *testing.T) {
 			"extra_info": "extra_info",
 		})
 
+		clean()
+
+		traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceId,
+			SpanID:     spanId,
+			TraceFlags: trace.FlagsSampled,
+		}))
+		GetLoggerWithContext(ctx, map[string]interface{}{
+			"extra_info": "extra_info",
+		}).Debug(msg)
+
+		assertLogContains(map[string]interface{}{
+			"level":      "debug",
+			"message":    msg,
+			"extra_info": "extra_info",
+			"trace_id":   "4bf92f3577b34da6a3ce929d0e0e4736",
+			"span_id":    "00f067aa0ba902b7",
+		})
+
+		clean()
+
+		requestCtx := NewContext(ctx, GetLogger().With(zap.String("request_id", "req-1")))
+		GetLoggerWithContext(requestCtx).Debug(msg)
+
+		assertLogContains(map[string]interface{}{
+			"level":      "debug",
+			"message":    msg,
+			"request_id": "req-1",
+			"trace_id":   "4bf92f3577b34da6a3ce929d0e0e4736",
+		})
+
 		ext := make(map[string]interface{})
 		ext["time"] = time.Now()
 		LoadExtra(ext)
@@ -163,3 +199,10 @@ func TestLogger(t *testing.T) {
 func TestGetLogger(t *testing.T) {
 	assert.NotNil(t, GetLogger())
 }
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, GetLogger(), FromContext(context.Background()))
+
+	l := GetLogger().With(zap.String("request_id", "req-1"))
+	assert.Equal(t, l, FromContext(NewContext(context.Background(), l)))
+}
@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "task_service"
+
+const (
+	CacheResultHit   = "hit"
+	CacheResultMiss  = "miss"
+	CacheResultError = "error"
+
+	LockResultAcquired = "acquired"
+	LockResultHeld     = "held"
+	LockResultError    = "error"
+)
+
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Cache lookups by operation and result.",
+	}, []string{"operation", "result"})
+
+	LockWaitDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "lock",
+		Name:      "wait_seconds",
+		Help:      "Time spent acquiring locks by key and result.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"key", "result"})
+
+	LockFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "lock",
+		Name:      "failures_total",
+		Help:      "Lock acquisitions that failed because the lock was held or of an error.",
+	}, []string{"key", "reason"})
+
+	CacheBreakerOpen = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "breaker_open",
+		Help:      "1 while the cache circuit breaker is open and requests bypass redis.",
+	})
+
+	TaskVersionMismatches = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "task",
+		Name:      "version_mismatches_total",
+		Help:      "Cached tasks whose version did not match the database.",
+	})
+)
+
+// Register registers the collectors of the package.
+func Register(registerer prometheus.Registerer) error {
+	collectors := []prometheus.Collector{
+		HTTPRequestDuration,
+		CacheRequests,
+		LockWaitDuration,
+		LockFailures,
+		CacheBreakerOpen,
+		TaskVersionMismatches,
+	}
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ObserveCache counts a cache lookup.
+func ObserveCache(operation, result string) {
+	CacheRequests.WithLabelValues(operation, result).Inc()
+}
+
+// ObserveLock records the time spent on a lock acquisition.
+func ObserveLock(key, result string, wait time.Duration) {
+	LockWaitDuration.WithLabelValues(key, result).Observe(wait.Seconds())
+	if result != LockResultAcquired {
+		LockFailures.WithLabelValues(key, result).Inc()
+	}
+}
+
+// TaskStatusCounter returns the number of tasks per status.
+type TaskStatusCounter func(ctx context.Context) (map[int]int64, error)
+
+type taskStatusCollector struct {
+	count   TaskStatusCounter
+	ttl     time.Duration
+	timeout time.Duration
+	desc    *prometheus.Desc
+	errDesc *prometheus.Desc
+
+	mu       sync.Mutex
+	counts   map[int]int64
+	expires  time.Time
+	failures float64
+}
+
+// NewTaskStatusCollector exports the tasks per status gauge. Counting tasks
+// is a table scan, so the result is reused for ttl across scrapes.
+func NewTaskStatusCollector(count TaskStatusCounter, ttl time.Duration) prometheus.Collector {
+	return &taskStatusCollector{
+		count:   count,
+		ttl:     ttl,
+		timeout: 5 * time.Second,
+		desc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "task", "status_total"),
+			"Number of tasks per status.", []string{"status"}, nil),
+		errDesc: prometheus.NewDesc(prometheus.BuildFQName(namespace, "task", "status_count_failures_total"),
+			"Failed attempts to count the tasks per status.", nil, nil),
+	}
+}
+
+func (collector *taskStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.desc
+	ch <- collector.errDesc
+}
+
+func (collector *taskStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+
+	if now := time.Now(); !now.Before(collector.expires) {
+		ctx, cancel := context.WithTimeout(context.Background(), collector.timeout)
+		counts, err := collector.count(ctx)
+		cancel()
+		if err != nil {
+			// keep exporting the last known counts
+			collector.failures++
+		} else {
+			collector.counts = counts
+			collector.expires = now.Add(collector.ttl)
+		}
+	}
+
+	for status, count := range collector.counts {
+		ch <- prometheus.MustNewConstMetric(collector.desc, prometheus.GaugeValue, float64(count), strconv.Itoa(status))
+	}
+	ch <- prometheus.MustNewConstMetric(collector.errDesc, prometheus.CounterValue, collector.failures)
+}
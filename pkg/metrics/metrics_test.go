@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskStatusCollector(t *testing.T) {
+	calls := 0
+	var err error
+	counts := map[int]int64{1: 3, 2: 5}
+	collector := NewTaskStatusCollector(func(ctx context.Context) (map[int]int64, error) {
+		calls++
+		return counts, err
+	}, time.Hour)
+
+	expected := `
+# HELP task_service_task_status_total Number of tasks per status.
+# TYPE task_service_task_status_total gauge
+task_service_task_status_total{status="1"} 3
+task_service_task_status_total{status="2"} 5
+`
+	assert.Nil(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "task_service_task_status_total"))
+	assert.Nil(t, testutil.CollectAndCompare(collector, strings.NewReader(expected), "task_service_task_status_total"))
+	// the counts are cached for the ttl
+	assert.Equal(t, 1, calls)
+
+	collector.(*taskStatusCollector).expires = time.Time{}
+	err = fmt.Errorf("database is down")
+	counts = nil
+	failures := `
+# HELP task_service_task_status_count_failures_total Failed attempts to count the tasks per status.
+# TYPE task_service_task_status_count_failures_total counter
+task_service_task_status_count_failures_total 1
+`
+	assert.Nil(t, testutil.CollectAndCompare(collector, strings.NewReader(expected+failures)))
+	assert.Equal(t, 2, calls)
+}
+
+func TestObserveLock(t *testing.T) {
+	ObserveLock("test", LockResultAcquired, time.Millisecond)
+	ObserveLock("test", LockResultHeld, time.Millisecond)
+	ObserveLock("test", LockResultHeld, time.Millisecond)
+
+	assert.Equal(t, float64(0), testutil.ToFloat64(LockFailures.WithLabelValues("test", LockResultAcquired)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(LockFailures.WithLabelValues("test", LockResultHeld)))
+	assert.Equal(t, 2, testutil.CollectAndCount(LockWaitDuration, "task_service_lock_wait_seconds"))
+}
+
+func TestRegister(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	assert.Nil(t, Register(registry))
+	// registering twice is an error
+	assert.NotNil(t, Register(registry))
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+type ApiKey struct {
+	ID       uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID string `json:"tenant_id" gorm:"size:64;not null;default:default"`
+	Name     string `json:"name" gorm:"size:100;not null"`
+	// Prefix is the leading part of the key kept in clear for display.
+	Prefix    string     `json:"prefix" gorm:"size:20;not null"`
+	KeyHash   string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	OwnerID   string     `json:"owner_id" gorm:"size:100;not null;index"`
+	Roles     []string   `json:"roles" gorm:"size:255;serializer:json"`
+	Key       string     `json:"key,omitempty" gorm:"-"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (ApiKey) TableName() string {
+	return "ApiKey"
+}
+
+// Active reports whether the key can still be used at now.
+func (k ApiKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+type ApiKeyResponse struct {
+	Code    code.Code
+	Message string
+	Data    []ApiKey
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// Attachment is a file attached to a task. The content is kept in the blob
+// store under the SHA-256 of the content, so attachments with the same
+// content share one blob.
+type Attachment struct {
+	ID       uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID string `json:"tenant_id" gorm:"size:64;not null;default:default"`
+	TaskID   uint64 `json:"task_id" gorm:"not null"`
+	// Name is the file name given by the uploader.
+	Name string `json:"name" gorm:"size:255;not null"`
+	// ContentType is sniffed from the content, not taken from the upload.
+	ContentType string    `json:"content_type" gorm:"size:255;not null"`
+	Size        int64     `json:"size" gorm:"not null"`
+	SHA256      string    `json:"sha256" gorm:"column:sha256;size:64;not null"`
+	UploaderID  string    `json:"uploader_id" gorm:"size:100;not null"`
+	CreatedAt   time.Time `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (Attachment) TableName() string {
+	return "Attachment"
+}
+
+type AttachmentResponse struct {
+	Code    code.Code
+	Message string
+	Data    []Attachment
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// CalendarFeed is a calendar of the tasks of its owner, read by calendar
+// apps with the secret token of the feed.
+type CalendarFeed struct {
+	ID       uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID string `json:"tenant_id" gorm:"size:64;not null;default:default"`
+	Name     string `json:"name" gorm:"size:100;not null"`
+	// Prefix is the leading part of the token kept in clear for display.
+	Prefix    string `json:"prefix" gorm:"size:20;not null"`
+	TokenHash string `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	OwnerID   string `json:"owner_id" gorm:"size:100;not null;index"`
+	// Query holds the filter parameters of GET /tasks the feed is narrowed
+	// by, e.g. "tags=release&due_after=2024-01-01T00:00:00Z".
+	Query string `json:"query" gorm:"size:1000;not null;default:''"`
+	// Component is "event" or "todo", see package ical.
+	Component string `json:"component" gorm:"size:10;not null"`
+	Token     string `json:"token,omitempty" gorm:"-"`
+	// ETag and ModifiedAt describe the last content served, ModifiedAt
+	// changes with the ETag.
+	ETag       string     `json:"-" gorm:"column:etag;size:70;not null;default:''"`
+	ModifiedAt *time.Time `json:"modified_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (CalendarFeed) TableName() string {
+	return "CalendarFeed"
+}
+
+type CalendarFeedResponse struct {
+	Code    code.Code
+	Message string
+	Data    []CalendarFeed
+}
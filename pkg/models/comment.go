@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// Comment is a markdown message in the discussion thread of a task.
+type Comment struct {
+	ID       uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID string `json:"tenant_id" gorm:"size:64;not null;default:default"`
+	TaskID   uint64 `json:"task_id" gorm:"not null"`
+	AuthorID string `json:"author_id" gorm:"size:100;not null"`
+	Body     string `json:"body" gorm:"type:text;not null"`
+	// Mentions are the ids of the known principals mentioned in Body as @id.
+	Mentions []string `json:"mentions" gorm:"type:text;serializer:json"`
+	// EditedAt is the time of the last edit, see CommentRevision.
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time  `json:"updated_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (Comment) TableName() string {
+	return "Comment"
+}
+
+// CommentRevision keeps the body a comment had before EditedBy changed it at
+// EditedAt.
+type CommentRevision struct {
+	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	CommentID uint64    `json:"comment_id" gorm:"not null"`
+	Body      string    `json:"body" gorm:"type:text;not null"`
+	EditedBy  string    `json:"edited_by" gorm:"size:100;not null"`
+	EditedAt  time.Time `json:"edited_at" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (CommentRevision) TableName() string {
+	return "CommentRevision"
+}
+
+type CommentResponse struct {
+	Code    code.Code
+	Message string
+	Data    []Comment
+}
+
+type CommentRevisionResponse struct {
+	Code    code.Code
+	Message string
+	Data    []CommentRevision
+}
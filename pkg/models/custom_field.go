@@ -0,0 +1,220 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// Custom field types.
+const (
+	FieldTypeString = "string"
+	FieldTypeNumber = "number"
+	FieldTypeEnum   = "enum"
+	FieldTypeDate   = "date"
+	FieldTypeBool   = "bool"
+)
+
+const (
+	// FieldDateLayout is the format of the values of date fields.
+	FieldDateLayout = "2006-01-02"
+	// MaxFieldLength is the longest value of a string field, in characters.
+	MaxFieldLength = 1000
+)
+
+var fieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// CustomField defines a typed field the tasks of a tenant may carry. The
+// values are kept in Task.Fields by key: a string for string, enum and date
+// fields, a float64 for number fields and a bool for bool fields.
+type CustomField struct {
+	ID       uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID string `json:"tenant_id" gorm:"size:64;not null;default:default"`
+	// Key names the field in Task.Fields, it can not be changed.
+	Key  string `json:"key" gorm:"column:field_key;size:50;not null"`
+	Name string `json:"name" gorm:"size:100;not null"`
+	// Type is one of the FieldType constants, it can not be changed.
+	Type string `json:"type" gorm:"size:20;not null"`
+	// Options are the allowed values of an enum field.
+	Options []string `json:"options,omitempty" gorm:"type:text;serializer:json"`
+	// Required fields must be set on every task created or updated.
+	Required  bool      `json:"required" gorm:"not null;default:false"`
+	CreatedAt time.Time `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (CustomField) TableName() string {
+	return "CustomField"
+}
+
+type CustomFieldResponse struct {
+	Code    code.Code
+	Message string
+	Data    []CustomField
+}
+
+// ValidFieldKey reports whether key may name a custom field.
+func ValidFieldKey(key string) bool {
+	return fieldKeyPattern.MatchString(key)
+}
+
+// Check validates the definition of the field.
+func (f CustomField) Check() error {
+	if !ValidFieldKey(f.Key) {
+		return fmt.Errorf("invalid field key %q: use lowercase letters, digits and _, starting with a letter", f.Key)
+	}
+	if strings.TrimSpace(f.Name) == "" || len([]rune(f.Name)) > 100 {
+		return fmt.Errorf("field name is required and at most 100 characters")
+	}
+	switch f.Type {
+	case FieldTypeEnum:
+		if len(f.Options) == 0 {
+			return fmt.Errorf("enum field %s needs options", f.Key)
+		}
+		for i, option := range f.Options {
+			if option == "" || len([]rune(option)) > MaxFieldLength {
+				return fmt.Errorf("invalid option %q of field %s", option, f.Key)
+			}
+			if slices.Contains(f.Options[:i], option) {
+				return fmt.Errorf("duplicate option %q of field %s", option, f.Key)
+			}
+		}
+	case FieldTypeString, FieldTypeNumber, FieldTypeDate, FieldTypeBool:
+		if len(f.Options) > 0 {
+			return fmt.Errorf("only enum fields have options")
+		}
+	default:
+		return fmt.Errorf("invalid field type %q", f.Type)
+	}
+	return nil
+}
+
+// Value checks a value of the field decoded from JSON and returns it in the
+// form kept in Task.Fields.
+func (f CustomField) Value(value interface{}) (interface{}, error) {
+	switch f.Type {
+	case FieldTypeNumber:
+		switch v := value.(type) {
+		case json.Number:
+			number, err := v.Float64()
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", f.Key, err)
+			}
+			return number, nil
+		case float64:
+			return v, nil
+		}
+		return nil, fmt.Errorf("field %s must be a number", f.Key)
+	case FieldTypeBool:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("field %s must be a bool", f.Key)
+	}
+
+	v, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("field %s must be a string", f.Key)
+	}
+	switch f.Type {
+	case FieldTypeEnum:
+		if !slices.Contains(f.Options, v) {
+			return nil, fmt.Errorf("field %s must be one of %s", f.Key, strings.Join(f.Options, ", "))
+		}
+	case FieldTypeDate:
+		if _, err := time.Parse(FieldDateLayout, v); err != nil {
+			return nil, fmt.Errorf("field %s must be a date like %s", f.Key, FieldDateLayout)
+		}
+	default:
+		if len([]rune(v)) > MaxFieldLength {
+			return nil, fmt.Errorf("field %s is longer than %d characters", f.Key, MaxFieldLength)
+		}
+	}
+	return v, nil
+}
+
+// ParseValue reads a value of the field from its text form, as found in a
+// query parameter.
+func (f CustomField) ParseValue(text string) (interface{}, error) {
+	switch f.Type {
+	case FieldTypeNumber:
+		number, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("field %s must be a number", f.Key)
+		}
+		return number, nil
+	case FieldTypeBool:
+		v, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("field %s must be a bool", f.Key)
+		}
+		return v, nil
+	}
+	return f.Value(text)
+}
+
+// CheckFields validates the custom field values of a task against the
+// fields of its tenant. A nil value unsets the field.
+func CheckFields(fields []CustomField, values map[string]interface{}) (map[string]interface{}, error) {
+	checked := map[string]interface{}{}
+	for key, value := range values {
+		i := slices.IndexFunc(fields, func(f CustomField) bool { return f.Key == key })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown field %s", key)
+		}
+		if value == nil {
+			continue
+		}
+		v, err := fields[i].Value(value)
+		if err != nil {
+			return nil, err
+		}
+		checked[key] = v
+	}
+	for _, field := range fields {
+		if _, ok := checked[field.Key]; field.Required && !ok {
+			return nil, fmt.Errorf("field %s is required", field.Key)
+		}
+	}
+	if len(checked) == 0 {
+		return nil, nil
+	}
+	return checked, nil
+}
+
+// FieldText returns the text form of a custom field value, the way MySQL
+// unquotes it from the JSON column.
+func FieldText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
+
+// CompareFieldValues orders two custom field values, numbers by value and
+// the others by their text form.
+func CompareFieldValues(a, b interface{}) int {
+	numberA, okA := a.(float64)
+	numberB, okB := b.(float64)
+	if okA && okB {
+		switch {
+		case numberA < numberB:
+			return -1
+		case numberA > numberB:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(FieldText(a), FieldText(b))
+}
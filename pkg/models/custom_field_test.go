@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomFieldCheck(t *testing.T) {
+	tests := []struct {
+		field CustomField
+		isErr bool
+	}{
+		{CustomField{Key: "story_points", Name: "Story points", Type: FieldTypeNumber}, false},
+		{CustomField{Key: "env", Name: "Environment", Type: FieldTypeEnum, Options: []string{"dev", "prod"}}, false},
+		{CustomField{Key: "Env", Name: "Environment", Type: FieldTypeString}, true},
+		{CustomField{Key: "1env", Name: "Environment", Type: FieldTypeString}, true},
+		{CustomField{Key: "env", Name: " ", Type: FieldTypeString}, true},
+		{CustomField{Key: "env", Name: "Environment", Type: "list"}, true},
+		{CustomField{Key: "env", Name: "Environment", Type: FieldTypeEnum}, true},
+		{CustomField{Key: "env", Name: "Environment", Type: FieldTypeEnum, Options: []string{"dev", "dev"}}, true},
+		{CustomField{Key: "env", Name: "Environment", Type: FieldTypeString, Options: []string{"dev"}}, true},
+	}
+
+	for _, testItem := range tests {
+		err := testItem.field.Check()
+		assert.Equal(t, testItem.isErr, err != nil, testItem.field.Key)
+	}
+}
+
+func TestCheckFields(t *testing.T) {
+	fields := []CustomField{
+		{Key: "points", Type: FieldTypeNumber, Required: true},
+		{Key: "env", Type: FieldTypeEnum, Options: []string{"dev", "prod"}},
+		{Key: "release", Type: FieldTypeDate},
+		{Key: "billable", Type: FieldTypeBool},
+		{Key: "customer", Type: FieldTypeString},
+	}
+
+	tests := []struct {
+		values   map[string]interface{}
+		isErr    bool
+		expected map[string]interface{}
+	}{
+		{
+			map[string]interface{}{"points": json.Number("3"), "env": "prod", "release": "2024-05-01", "billable": true, "customer": "acme"},
+			false,
+			map[string]interface{}{"points": 3.0, "env": "prod", "release": "2024-05-01", "billable": true, "customer": "acme"},
+		},
+		{map[string]interface{}{"points": 1.5, "env": nil}, false, map[string]interface{}{"points": 1.5}},
+		{map[string]interface{}{"env": "dev"}, true, nil},
+		{map[string]interface{}{"points": "3"}, true, nil},
+		{map[string]interface{}{"points": 3.0, "env": "qa"}, true, nil},
+		{map[string]interface{}{"points": 3.0, "release": "01/05/2024"}, true, nil},
+		{map[string]interface{}{"points": 3.0, "billable": "yes"}, true, nil},
+		{map[string]interface{}{"points": 3.0, "unknown": "x"}, true, nil},
+	}
+
+	for _, testItem := range tests {
+		checked, err := CheckFields(fields, testItem.values)
+		if testItem.isErr {
+			assert.NotNil(t, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, testItem.expected, checked)
+	}
+
+	checked, err := CheckFields(nil, nil)
+	assert.Nil(t, err)
+	assert.Nil(t, checked)
+}
+
+func TestCustomFieldParseValue(t *testing.T) {
+	value, err := CustomField{Key: "points", Type: FieldTypeNumber}.ParseValue("2.5")
+	assert.Nil(t, err)
+	assert.Equal(t, 2.5, value)
+
+	value, err = CustomField{Key: "billable", Type: FieldTypeBool}.ParseValue("true")
+	assert.Nil(t, err)
+	assert.Equal(t, true, value)
+
+	_, err = CustomField{Key: "env", Type: FieldTypeEnum, Options: []string{"dev"}}.ParseValue("prod")
+	assert.NotNil(t, err)
+}
+
+func TestTaskFilterMatchFields(t *testing.T) {
+	task := Task{Fields: map[string]interface{}{"points": 3.0, "env": "prod", "billable": false}}
+
+	assert.True(t, TaskFilter{Fields: map[string]interface{}{"points": 3.0, "env": "prod"}}.Match(task))
+	assert.True(t, TaskFilter{Fields: map[string]interface{}{"billable": false}}.Match(task))
+	assert.False(t, TaskFilter{Fields: map[string]interface{}{"points": 2.0}}.Match(task))
+	assert.False(t, TaskFilter{Fields: map[string]interface{}{"customer": "acme"}}.Match(task))
+}
+
+func TestCompareFieldValues(t *testing.T) {
+	assert.Equal(t, -1, CompareFieldValues(2.0, 10.0))
+	assert.Equal(t, 1, CompareFieldValues("b", "a"))
+	assert.Equal(t, -1, CompareFieldValues(false, true))
+	assert.Equal(t, 0, CompareFieldValues("2024-05-01", "2024-05-01"))
+}
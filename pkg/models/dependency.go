@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// TaskDependency says the task can not start until the blocker is done.
+type TaskDependency struct {
+	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID  string    `json:"tenant_id" gorm:"size:64;not null;default:default"`
+	TaskID    uint64    `json:"task_id" gorm:"not null"`
+	BlockerID uint64    `json:"blocker_id" gorm:"not null"`
+	CreatedAt time.Time `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (TaskDependency) TableName() string {
+	return "TaskDependency"
+}
+
+// TaskGraph is the part of the dependency graph connected to a task: every
+// task it transitively depends on or that transitively depends on it.
+type TaskGraph struct {
+	Tasks []Task           `json:"tasks"`
+	Edges []TaskDependency `json:"edges"`
+	// Order lists the task ids so that every blocker precedes the tasks it
+	// blocks.
+	Order []uint64 `json:"order"`
+}
+
+type TaskDependencyResponse struct {
+	Code    code.Code
+	Message string
+	Data    []TaskDependency
+}
+
+type TaskGraphResponse struct {
+	Code    code.Code
+	Message string
+	Data    []TaskGraph
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// Statuses of an ImportJob. A job ends done even when some of its rows
+// failed, it fails when it cannot go on at all.
+const (
+	ImportStatusPending = "pending"
+	ImportStatusRunning = "running"
+	ImportStatusDone    = "done"
+	ImportStatusFailed  = "failed"
+)
+
+// MaxImportErrors is the number of row errors kept by an ImportJob, the
+// following failures are only counted.
+const MaxImportErrors = 1000
+
+// ImportJob tracks an import of tasks running in the background. Each row
+// creates a task or updates the task of the tenant with the same name; a dry
+// run only validates the rows and counts what would be created or updated.
+type ImportJob struct {
+	ID       uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID string `json:"tenant_id" gorm:"size:64;not null;default:default"`
+	// OwnerID started the import, only the owner or an admin may read it.
+	OwnerID string `json:"owner_id" gorm:"size:100;not null;default:''"`
+	Format  string `json:"format" gorm:"size:10;not null"`
+	DryRun  bool   `json:"dry_run" gorm:"not null;default:false"`
+	Status  string `json:"status" gorm:"size:20;not null"`
+	// Total is the number of rows, Processed the number of rows handled so
+	// far, each of them either Created, Updated or Failed.
+	Total     int `json:"total" gorm:"not null;default:0"`
+	Processed int `json:"processed" gorm:"not null;default:0"`
+	Created   int `json:"created" gorm:"not null;default:0"`
+	Updated   int `json:"updated" gorm:"not null;default:0"`
+	Failed    int `json:"failed" gorm:"not null;default:0"`
+	// Errors holds the first MaxImportErrors row errors, Row being the line
+	// of the row in the input.
+	Errors []ErrorDetails `json:"errors" gorm:"type:mediumtext;serializer:json"`
+	// Error is why a failed job stopped.
+	Error      string     `json:"error,omitempty" gorm:"size:500;not null;default:''"`
+	CreatedAt  time.Time  `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt  time.Time  `json:"updated_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+func (ImportJob) TableName() string {
+	return "ImportJob"
+}
+
+// AddError counts a failed row, keeping its error unless MaxImportErrors
+// errors are kept already.
+func (job *ImportJob) AddError(row int, err error) {
+	job.Failed++
+	if len(job.Errors) < MaxImportErrors {
+		job.Errors = append(job.Errors, ErrorDetails{Row: row, ErrorMsg: err.Error()})
+	}
+}
+
+type ImportJobResponse struct {
+	Code    code.Code
+	Message string
+	Data    []ImportJob
+}
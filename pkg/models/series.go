@@ -0,0 +1,108 @@
+package models
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// Recurrence makes a new task the first occurrence of a series.
+type Recurrence struct {
+	// RRule is an RFC 5545 RRULE value, e.g. "FREQ=WEEKLY;BYDAY=MO"
+	RRule string `json:"rrule"`
+	// Timezone is the IANA time zone the rule is evaluated in, default UTC
+	Timezone string `json:"timezone"`
+	// StartAt anchors the rule, default now
+	StartAt *time.Time `json:"start_at,omitempty"`
+}
+
+// TaskSeries holds the rule and the template of a recurring task. Its
+// occurrences are Tasks linked by SeriesID, created one at a time.
+type TaskSeries struct {
+	ID       uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID string `json:"tenant_id" gorm:"size:64;not null;default:default"`
+	RRule    string `json:"rrule" gorm:"column:rrule;size:500;not null"`
+	Timezone string `json:"timezone" gorm:"size:64;not null"`
+	// DTStart anchors the rule. NextAt is the start of the next occurrence
+	// to create, nil once the rule has ended.
+	DTStart time.Time  `json:"dtstart" gorm:"column:dtstart;not null"`
+	NextAt  *time.Time `json:"next_at,omitempty"`
+	// Exdates are the skipped occurrences.
+	Exdates  []time.Time `json:"exdates" gorm:"type:text;serializer:json"`
+	Name     string      `json:"name" gorm:"size:200;not null"`
+	Content  string      `json:"content" gorm:"size:500;not null"`
+	Priority int         `json:"priority" gorm:"type:tinyint;not null;default:0"`
+	Tags     []string    `json:"tags" gorm:"type:text;serializer:json"`
+	// Fields are the custom field values of every occurrence.
+	Fields     map[string]interface{} `json:"fields,omitempty" gorm:"type:text;serializer:json"`
+	OwnerID    string                 `json:"owner_id" gorm:"size:100;not null;default:''"`
+	AssigneeID string                 `json:"assignee_id" gorm:"size:100;not null;default:''"`
+	// DueOffset and RemindOffset place due_at and remind_at of every
+	// occurrence relative to its start, in seconds.
+	DueOffset    *int64    `json:"due_offset,omitempty"`
+	RemindOffset *int64    `json:"remind_offset,omitempty"`
+	Version      int       `json:"version" gorm:"not null;default:1"`
+	CreatedAt    time.Time `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (TaskSeries) TableName() string {
+	return "TaskSeries"
+}
+
+// Occurrence returns the task of the occurrence starting at start.
+func (s TaskSeries) Occurrence(start time.Time) Task {
+	seriesId := s.ID
+	task := Task{
+		TenantID:     s.TenantID,
+		Name:         s.Name,
+		Content:      s.Content,
+		Priority:     s.Priority,
+		Tags:         slices.Clone(s.Tags),
+		Fields:       maps.Clone(s.Fields),
+		Status:       TaskStatusOpen,
+		OwnerID:      s.OwnerID,
+		AssigneeID:   s.AssigneeID,
+		SeriesID:     &seriesId,
+		OccurrenceAt: &start,
+	}
+	if s.DueOffset != nil {
+		dueAt := start.Add(time.Duration(*s.DueOffset) * time.Second)
+		task.DueAt = &dueAt
+	}
+	if s.RemindOffset != nil {
+		remindAt := start.Add(time.Duration(*s.RemindOffset) * time.Second)
+		task.RemindAt = &remindAt
+	}
+	return task
+}
+
+// SetTemplate copies the fields of task which every future occurrence
+// inherits, placing its due and remind times relative to start.
+func (s *TaskSeries) SetTemplate(task Task, start time.Time) {
+	s.Name = task.Name
+	s.Content = task.Content
+	s.Priority = task.Priority
+	s.Tags = slices.Clone(task.Tags)
+	s.Fields = maps.Clone(task.Fields)
+	s.OwnerID = task.OwnerID
+	s.AssigneeID = task.AssigneeID
+	s.DueOffset = offsetSeconds(task.DueAt, start)
+	s.RemindOffset = offsetSeconds(task.RemindAt, start)
+}
+
+func offsetSeconds(t *time.Time, start time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	offset := int64(t.Sub(start) / time.Second)
+	return &offset
+}
+
+type TaskSeriesResponse struct {
+	Code    code.Code
+	Message string
+	Data    []TaskSeries
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskSeriesOccurrence(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	hour := int64(3600)
+	series := TaskSeries{
+		ID:         7,
+		TenantID:   "t1",
+		Name:       "standup",
+		Tags:       []string{"daily"},
+		OwnerID:    "u1",
+		AssigneeID: "u2",
+		DueOffset:  &hour,
+	}
+
+	task := series.Occurrence(start)
+	assert.Equal(t, "t1", task.TenantID)
+	assert.Equal(t, "standup", task.Name)
+	assert.Equal(t, []string{"daily"}, task.Tags)
+	assert.Equal(t, "u1", task.OwnerID)
+	assert.Equal(t, "u2", task.AssigneeID)
+	assert.Equal(t, TaskStatusOpen, task.Status)
+	assert.Equal(t, uint64(7), *task.SeriesID)
+	assert.Equal(t, start, *task.OccurrenceAt)
+	assert.Equal(t, start.Add(time.Hour), *task.DueAt)
+	assert.Nil(t, task.RemindAt)
+}
+
+func TestTaskSeriesSetTemplate(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	dueAt := start.Add(2 * time.Hour)
+	remindAt := start.Add(-30 * time.Minute)
+
+	tests := []struct {
+		name         string
+		task         Task
+		dueOffset    *int64
+		remindOffset *int64
+	}{
+		{"without times", Task{Name: "a"}, nil, nil},
+		{"due after start", Task{Name: "a", DueAt: &dueAt}, ptr(int64(7200)), nil},
+		{"remind before start", Task{Name: "a", RemindAt: &remindAt}, nil, ptr(int64(-1800))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := TaskSeries{}
+			series.SetTemplate(tt.task, start)
+			assert.Equal(t, tt.task.Name, series.Name)
+			assert.Equal(t, tt.dueOffset, series.DueOffset)
+			assert.Equal(t, tt.remindOffset, series.RemindOffset)
+
+			// the offsets move with the occurrence
+			next := start.Add(24 * time.Hour)
+			task := series.Occurrence(next)
+			if tt.task.DueAt != nil {
+				assert.Equal(t, tt.task.DueAt.Add(24*time.Hour), *task.DueAt)
+			}
+			if tt.task.RemindAt != nil {
+				assert.Equal(t, tt.task.RemindAt.Add(24*time.Hour), *task.RemindAt)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// Tag is an entry of the tag catalog of a tenant. Tasks refer to tags by
+// name, a tag is added to the catalog the first time a task uses it.
+type Tag struct {
+	ID          uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID    string `json:"tenant_id" gorm:"size:64;not null;default:default"`
+	Name        string `json:"name" gorm:"size:50;not null"`
+	Color       string `json:"color" gorm:"size:20;not null;default:''"`
+	Description string `json:"description" gorm:"size:500;not null;default:''"`
+	// TaskCount is the number of tasks having the tag, only set by reads.
+	TaskCount int64     `json:"task_count" gorm:"-"`
+	CreatedAt time.Time `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (Tag) TableName() string {
+	return "Tag"
+}
+
+// TaskTag links a task to one of its tags.
+type TaskTag struct {
+	TaskID uint64 `gorm:"primaryKey"`
+	TagID  uint64 `gorm:"primaryKey"`
+}
+
+func (TaskTag) TableName() string {
+	return "TaskTag"
+}
+
+type TagResponse struct {
+	Code    code.Code
+	Message string
+	Data    []Tag
+}
@@ -1,20 +1,152 @@
 package models
 
 import (
+	"encoding/json"
+	"slices"
 	"time"
 )
 
+// Task statuses. A new task is open, only open tasks get reminders and count
+// as overdue. Done tasks count as complete in the progress of their parents.
+const (
+	TaskStatusOpen       = 1
+	TaskStatusInProgress = 2
+	TaskStatusDone       = 3
+)
+
+// Task priorities, a higher priority is more urgent. A new task has none.
+const (
+	TaskPriorityNone   = 0
+	TaskPriorityLow    = 1
+	TaskPriorityMedium = 2
+	TaskPriorityHigh   = 3
+	TaskPriorityUrgent = 4
+)
+
+// ValidPriority reports whether priority is one of the task priorities.
+func ValidPriority(priority int) bool {
+	return priority >= TaskPriorityNone && priority <= TaskPriorityUrgent
+}
+
 type Task struct {
-	ID        uint64    `json:"id" gorm:"primaryKey;autoIncrement"`
-	Name      string    `json:"name" gorm:"size:200;not null"`
-	Status    int       `json:"status" gorm:"type:tinyint;not null;default:1"`
-	Content   string    `json:"content" gorm:"size:500;not null"`
-	Tag       string    `json:"tag" gorm:"size:50;not null;default:''"`
-	Version   int       `json:"version,omitempty"; gorm:"version:int;null"`
-	CreatedAt time.Time `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
-	UpdatedAt time.Time `json:"updated_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	ID       uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID string `json:"tenant_id" gorm:"size:64;not null;default:default"`
+	Name     string `json:"name" gorm:"size:200;not null"`
+	Status   int    `json:"status" gorm:"type:tinyint;not null;default:1"`
+	Content  string `json:"content" gorm:"size:500;not null"`
+	Priority int    `json:"priority" gorm:"type:tinyint;not null;default:0"`
+	// Rank orders the tasks of the tenant on a board, see utils.RankBetween.
+	// It is set when the task is created and only changed by moving it.
+	Rank string `json:"rank" gorm:"size:64;not null;default:''"`
+	// Tags are the names of the tags of the task, see Tag.
+	Tags       []string   `json:"tags" gorm:"-"`
+	OwnerID    string     `json:"owner_id" gorm:"size:100;not null;default:''"`
+	AssigneeID string     `json:"assignee_id" gorm:"size:100;not null;default:''"`
+	Version    int        `json:"version,omitempty" gorm:"version:int;null"`
+	DueAt      *time.Time `json:"due_at,omitempty"`
+	RemindAt   *time.Time `json:"remind_at,omitempty"`
+	// FiredDueAt and FiredRemindAt hold the due and remind times the overdue
+	// and reminder events were last fired for, so changing either time arms
+	// its event again.
+	FiredDueAt    *time.Time `json:"-"`
+	FiredRemindAt *time.Time `json:"-"`
+	// SeriesID and OccurrenceAt link an occurrence of a recurring task to
+	// its series and the start time it was created for.
+	SeriesID     *uint64    `json:"series_id,omitempty"`
+	OccurrenceAt *time.Time `json:"occurrence_at,omitempty"`
+	// ParentID makes the task a subtask. Progress is the percentage of the
+	// task that is done, rolled up from its children when it has any.
+	ParentID *uint64 `json:"parent_id,omitempty"`
+	Progress int     `json:"progress" gorm:"type:tinyint;not null;default:0"`
+	// Fields holds the values of the custom fields of the task by key, see
+	// CustomField.
+	Fields map[string]interface{} `json:"fields,omitempty" gorm:"type:text;serializer:json"`
+	// CommentCount is the number of comments of the task, see Comment.
+	CommentCount int `json:"comment_count" gorm:"not null;default:0"`
+	// Subtasks are created as children of the task by CreateTask, they are
+	// never loaded.
+	Subtasks []Task `json:"-" gorm:"-"`
+	// Recurrence is only read when creating a task, see TaskSeries.
+	Recurrence *Recurrence `json:"recurrence,omitempty" gorm:"-"`
+	CreatedAt  time.Time   `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt  time.Time   `json:"updated_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
 }
 
 func (Task) TableName() string {
 	return "Task"
 }
+
+// UnmarshalJSON adds the single tag older clients send in the tag field to
+// Tags.
+func (t *Task) UnmarshalJSON(data []byte) error {
+	type task Task
+	aux := struct {
+		*task
+		Tag string `json:"tag"`
+	}{task: (*task)(t)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Tag != "" {
+		t.Tags = append(t.Tags, aux.Tag)
+	}
+	return nil
+}
+
+// TaskFilter narrows the tasks returned by ListTask, zero values match everything
+type TaskFilter struct {
+	// Name keeps only the tasks with exactly this name
+	Name string
+	// VisibleTo keeps only the tasks owned by or assigned to this principal
+	VisibleTo string
+	// DueBefore and DueAfter keep only the tasks due in [DueAfter, DueBefore),
+	// tasks without a due date never match
+	DueBefore *time.Time
+	DueAfter  *time.Time
+	// OverdueAt keeps only the open tasks due at or before this time
+	OverdueAt *time.Time
+	// Tags keeps only the tasks having all of these tags, or any of them
+	// with AnyTag
+	Tags   []string
+	AnyTag bool
+	// Fields keeps only the tasks whose custom fields have these values,
+	// see CustomField.Value
+	Fields map[string]interface{}
+}
+
+// Match reports whether task passes the filter
+func (f TaskFilter) Match(task Task) bool {
+	if f.Name != "" && task.Name != f.Name {
+		return false
+	}
+	if f.VisibleTo != "" && task.OwnerID != f.VisibleTo && task.AssigneeID != f.VisibleTo {
+		return false
+	}
+	if f.DueBefore != nil && (task.DueAt == nil || !task.DueAt.Before(*f.DueBefore)) {
+		return false
+	}
+	if f.DueAfter != nil && (task.DueAt == nil || task.DueAt.Before(*f.DueAfter)) {
+		return false
+	}
+	if f.OverdueAt != nil && (task.DueAt == nil || task.DueAt.After(*f.OverdueAt) || task.Status != TaskStatusOpen) {
+		return false
+	}
+	if len(f.Tags) > 0 {
+		matched := 0
+		for _, tag := range f.Tags {
+			if slices.Contains(task.Tags, tag) {
+				matched++
+			}
+		}
+		if f.AnyTag && matched == 0 || !f.AnyTag && matched < len(f.Tags) {
+			return false
+		}
+	}
+	for key, value := range f.Fields {
+		v, ok := task.Fields[key]
+		if !ok || FieldText(v) != FieldText(value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskFilterMatch(t *testing.T) {
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+	tomorrow := now.Add(24 * time.Hour)
+
+	tests := []struct {
+		name     string
+		filter   TaskFilter
+		task     Task
+		expected bool
+	}{
+		{"empty filter", TaskFilter{}, Task{}, true},
+		{"same name", TaskFilter{Name: "report"}, Task{Name: "report"}, true},
+		{"other name", TaskFilter{Name: "report"}, Task{Name: "reports"}, false},
+		{"visible to owner", TaskFilter{VisibleTo: "u1"}, Task{OwnerID: "u1"}, true},
+		{"visible to assignee", TaskFilter{VisibleTo: "u1"}, Task{AssigneeID: "u1"}, true},
+		{"not visible", TaskFilter{VisibleTo: "u1"}, Task{OwnerID: "u2"}, false},
+		{"due before", TaskFilter{DueBefore: &now}, Task{DueAt: &yesterday}, true},
+		{"due before excludes the bound", TaskFilter{DueBefore: &now}, Task{DueAt: &now}, false},
+		{"due before without due date", TaskFilter{DueBefore: &now}, Task{}, false},
+		{"due after includes the bound", TaskFilter{DueAfter: &now}, Task{DueAt: &now}, true},
+		{"due after", TaskFilter{DueAfter: &now}, Task{DueAt: &yesterday}, false},
+		{"overdue", TaskFilter{OverdueAt: &now}, Task{DueAt: &yesterday, Status: TaskStatusOpen}, true},
+		{"overdue but closed", TaskFilter{OverdueAt: &now}, Task{DueAt: &yesterday, Status: 2}, false},
+		{"not yet due", TaskFilter{OverdueAt: &now}, Task{DueAt: &tomorrow, Status: TaskStatusOpen}, false},
+		{"overdue without due date", TaskFilter{OverdueAt: &now}, Task{Status: TaskStatusOpen}, false},
+		{"all tags", TaskFilter{Tags: []string{"a", "b"}}, Task{Tags: []string{"a", "b", "c"}}, true},
+		{"missing one of all tags", TaskFilter{Tags: []string{"a", "b"}}, Task{Tags: []string{"a"}}, false},
+		{"any tag", TaskFilter{Tags: []string{"a", "b"}, AnyTag: true}, Task{Tags: []string{"b"}}, true},
+		{"none of any tag", TaskFilter{Tags: []string{"a", "b"}, AnyTag: true}, Task{Tags: []string{"c"}}, false},
+		{"tags without tags", TaskFilter{Tags: []string{"a"}, AnyTag: true}, Task{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.Match(tt.task))
+		})
+	}
+}
+
+func TestTaskUnmarshalTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{"tags", `{"name":"a","tags":["x","y"]}`, []string{"x", "y"}},
+		{"tag", `{"name":"a","tag":"x"}`, []string{"x"}},
+		{"tag and tags", `{"name":"a","tag":"z","tags":["x"]}`, []string{"x", "z"}},
+		{"empty tag", `{"name":"a","tag":""}`, nil},
+	}
+	for _, tt := range tests {
+		var task Task
+		assert.NoError(t, json.Unmarshal([]byte(tt.body), &task), tt.name)
+		assert.Equal(t, "a", task.Name, tt.name)
+		assert.Equal(t, tt.expected, task.Tags, tt.name)
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+// TaskTemplate describes a task, with optional subtasks, created again and
+// again from variable values. The task names and contents may contain
+// {{variable}} placeholders.
+type TaskTemplate struct {
+	ID       uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	TenantID string `json:"tenant_id" gorm:"size:64;not null;default:default"`
+	// Name identifies the template within the tenant.
+	Name string `json:"name" gorm:"size:200;not null"`
+	// OwnerID created the template, only the owner or an admin may change it.
+	OwnerID     string `json:"owner_id" gorm:"size:100;not null;default:''"`
+	TaskName    string `json:"task_name" gorm:"size:500;not null"`
+	TaskContent string `json:"task_content" gorm:"type:text;not null"`
+	// Tags, Status and Priority are given to the created task.
+	Tags     []string               `json:"tags" gorm:"type:text;serializer:json"`
+	Status   int                    `json:"status" gorm:"type:tinyint;not null;default:1"`
+	Priority int                    `json:"priority" gorm:"type:tinyint;not null;default:0"`
+	Fields   map[string]interface{} `json:"fields,omitempty" gorm:"type:text;serializer:json"`
+	// Subtasks are created as children of the task.
+	Subtasks  []TemplateSubtask `json:"subtasks,omitempty" gorm:"type:text;serializer:json"`
+	CreatedAt time.Time         `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time         `json:"updated_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (TaskTemplate) TableName() string {
+	return "TaskTemplate"
+}
+
+// TemplateSubtask describes a subtask of a TaskTemplate.
+type TemplateSubtask struct {
+	Name     string                 `json:"name"`
+	Content  string                 `json:"content"`
+	Tags     []string               `json:"tags,omitempty"`
+	Status   int                    `json:"status"`
+	Priority int                    `json:"priority"`
+	Fields   map[string]interface{} `json:"fields,omitempty"`
+}
+
+type TaskTemplateResponse struct {
+	Code    code.Code
+	Message string
+	Data    []TaskTemplate
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+)
+
+const (
+	TenantStatusActive    = "active"
+	TenantStatusSuspended = "suspended"
+)
+
+type Tenant struct {
+	ID     string `json:"id" gorm:"primaryKey;size:64"`
+	Name   string `json:"name" gorm:"size:200;not null"`
+	Status string `json:"status" gorm:"size:20;not null;default:active"`
+	// MaxTasks caps the number of tasks of the tenant, 0 means unlimited.
+	MaxTasks  int64     `json:"max_tasks"`
+	CreatedAt time.Time `json:"created_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+func (Tenant) TableName() string {
+	return "Tenant"
+}
+
+func (t Tenant) Active() bool {
+	return t.Status == TenantStatusActive
+}
+
+type TenantResponse struct {
+	Code    code.Code
+	Message string
+	Data    []Tenant
+}
@@ -0,0 +1,229 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmSlidingWindow = "sliding_window"
+)
+
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderPolicy     = "RateLimit-Policy"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// Rule allows Limit requests per Period. Burst is the bucket capacity of
+// the token bucket and defaults to Limit, the sliding window ignores it.
+type Rule struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+func (r Rule) capacity() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Limit
+}
+
+// Policy formats the rule for the RateLimit-Policy header, e.g. "100;w=60".
+func (r Rule) Policy() string {
+	policy := fmt.Sprintf("%d;w=%d", r.Limit, ceilSeconds(r.Period))
+	if r.Burst > 0 && r.Burst != r.Limit {
+		policy += fmt.Sprintf(";burst=%d", r.Burst)
+	}
+	return policy
+}
+
+// Result is the outcome of a single Allow call.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the quota is fully restored.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when
+	// Allowed is true.
+	RetryAfter time.Duration
+}
+
+// SetHeaders writes the RateLimit-* headers, plus Retry-After when the
+// request was rejected.
+func SetHeaders(header http.Header, rule Rule, result Result) {
+	header.Set(HeaderLimit, strconv.Itoa(result.Limit))
+	header.Set(HeaderRemaining, strconv.Itoa(result.Remaining))
+	header.Set(HeaderReset, strconv.FormatInt(ceilSeconds(result.Reset), 10))
+	header.Set(HeaderPolicy, rule.Policy())
+	if !result.Allowed {
+		header.Set(HeaderRetryAfter, strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// Limiter counts requests per key in redis, so the limit is shared by every
+// replica of the service.
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// NewLimiter returns the limiter of the algorithm, keys are prefixed with prefix.
+func NewLimiter(client redis.Scripter, algorithm, prefix string) (Limiter, error) {
+	switch algorithm {
+	case AlgorithmTokenBucket, "":
+		return &tokenBucket{client: client, prefix: prefix}, nil
+	case AlgorithmSlidingWindow:
+		return &slidingWindow{client: client, prefix: prefix}, nil
+	default:
+		return nil, fmt.Errorf("NewLimiter: unknown algorithm %s", algorithm)
+	}
+}
+
+func validate(rule Rule) error {
+	if rule.Limit <= 0 || rule.Period <= 0 {
+		return fmt.Errorf("invalid rule, limit = %d, period = %s", rule.Limit, rule.Period)
+	}
+	return nil
+}
+
+// The scripts take the time from redis so that the clocks of the replicas
+// don't matter.
+
+// tokenBucketScript refills capacity tokens every period and takes one
+// token per request. Returns allowed, remaining, retry after ms, reset ms.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2]) / tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) / interval)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) * interval)
+end
+
+local reset = math.ceil((capacity - tokens) * interval)
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.max(1, reset))
+return {allowed, math.floor(tokens), retry, reset}
+`)
+
+type tokenBucket struct {
+	client redis.Scripter
+	prefix string
+}
+
+func (limiter *tokenBucket) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	if err := validate(rule); err != nil {
+		return Result{}, fmt.Errorf("Allow: %v", err)
+	}
+
+	capacity := rule.capacity()
+	values, err := tokenBucketScript.Run(ctx, limiter.client, []string{limiter.prefix + key},
+		capacity, rule.Period.Milliseconds(), rule.Limit).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("Allow: %v", err)
+	}
+	return newResult(capacity, values)
+}
+
+// slidingWindowScript keeps the timestamps of the requests of the last
+// period in a sorted set. Returns allowed, remaining, retry after ms, reset ms.
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+
+local allowed = 0
+local retry = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, now .. '-' .. ARGV[3])
+	count = count + 1
+	allowed = 1
+else
+	local oldest = redis.call('ZRANGE', KEYS[1], count - limit, count - limit, 'WITHSCORES')
+	retry = math.max(1, tonumber(oldest[2]) + window - now)
+end
+
+local reset = 0
+local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+if newest[2] ~= nil then
+	reset = math.max(0, tonumber(newest[2]) + window - now)
+end
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, limit - count, retry, reset}
+`)
+
+type slidingWindow struct {
+	client redis.Scripter
+	prefix string
+	seq    atomic.Uint64
+}
+
+func (limiter *slidingWindow) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	if err := validate(rule); err != nil {
+		return Result{}, fmt.Errorf("Allow: %v", err)
+	}
+
+	// the member only has to be unique among requests of the same millisecond
+	member := fmt.Sprintf("%d-%d", time.Now().UnixNano(), limiter.seq.Add(1))
+	values, err := slidingWindowScript.Run(ctx, limiter.client, []string{limiter.prefix + key},
+		rule.Limit, rule.Period.Milliseconds(), member).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("Allow: %v", err)
+	}
+	return newResult(rule.Limit, values)
+}
+
+func newResult(limit int, values []int64) (Result, error) {
+	if len(values) != 4 {
+		return Result{}, fmt.Errorf("newResult: unexpected reply %v", values)
+	}
+	remaining := int(values[1])
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Result{
+		Allowed:    values[0] == 1,
+		Limit:      limit,
+		Remaining:  remaining,
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		Reset:      time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
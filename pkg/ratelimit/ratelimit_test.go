@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLimiter(t *testing.T, algorithm string) (Limiter, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	server.SetTime(time.Unix(1700000000, 0))
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	limiter, err := NewLimiter(client, algorithm, "ratelimit:")
+	assert.Nil(t, err)
+	return limiter, server
+}
+
+func TestTokenBucket(t *testing.T) {
+	limiter, server := newTestLimiter(t, AlgorithmTokenBucket)
+	ctx := context.Background()
+	rule := Rule{Limit: 2, Period: time.Second, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		result, err := limiter.Allow(ctx, "client", rule)
+		assert.Nil(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 3, result.Limit)
+		assert.Equal(t, 2-i, result.Remaining)
+	}
+
+	result, err := limiter.Allow(ctx, "client", rule)
+	assert.Nil(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, result.Reset)
+
+	// other keys have their own bucket
+	result, err = limiter.Allow(ctx, "other", rule)
+	assert.Nil(t, err)
+	assert.True(t, result.Allowed)
+
+	server.SetTime(time.Unix(1700000000, 0).Add(500 * time.Millisecond))
+	result, err = limiter.Allow(ctx, "client", rule)
+	assert.Nil(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	limiter, server := newTestLimiter(t, AlgorithmSlidingWindow)
+	ctx := context.Background()
+	rule := Rule{Limit: 2, Period: time.Minute}
+	start := time.Unix(1700000000, 0)
+
+	result, err := limiter.Allow(ctx, "client", rule)
+	assert.Nil(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, result.Remaining)
+
+	server.SetTime(start.Add(10 * time.Second))
+	result, err = limiter.Allow(ctx, "client", rule)
+	assert.Nil(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	server.SetTime(start.Add(20 * time.Second))
+	result, err = limiter.Allow(ctx, "client", rule)
+	assert.Nil(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 40*time.Second, result.RetryAfter)
+	assert.Equal(t, 50*time.Second, result.Reset)
+
+	// the first request left the window
+	server.SetTime(start.Add(61 * time.Second))
+	result, err = limiter.Allow(ctx, "client", rule)
+	assert.Nil(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+}
+
+func TestNewLimiter(t *testing.T) {
+	_, err := NewLimiter(nil, "leaky_bucket", "")
+	assert.NotNil(t, err)
+
+	limiter, _ := newTestLimiter(t, AlgorithmSlidingWindow)
+	_, err = limiter.Allow(context.Background(), "client", Rule{Limit: 0, Period: time.Second})
+	assert.NotNil(t, err)
+}
+
+func TestSetHeaders(t *testing.T) {
+	tests := []struct {
+		rule     Rule
+		result   Result
+		expected map[string]string
+	}{
+		{
+			Rule{Limit: 100, Period: time.Minute},
+			Result{Allowed: true, Limit: 100, Remaining: 99, Reset: 600 * time.Millisecond},
+			map[string]string{
+				HeaderLimit:     "100",
+				HeaderRemaining: "99",
+				HeaderReset:     "1",
+				HeaderPolicy:    "100;w=60",
+			},
+		},
+		{
+			Rule{Limit: 10, Period: time.Second, Burst: 20},
+			Result{Allowed: false, Limit: 20, Remaining: 0, Reset: 2 * time.Second, RetryAfter: 100 * time.Millisecond},
+			map[string]string{
+				HeaderLimit:      "20",
+				HeaderRemaining:  "0",
+				HeaderReset:      "2",
+				HeaderPolicy:     "10;w=1;burst=20",
+				HeaderRetryAfter: "1",
+			},
+		},
+	}
+
+	for _, testItem := range tests {
+		header := http.Header{}
+		SetHeaders(header, testItem.rule, testItem.result)
+		assert.Equal(t, len(testItem.expected), len(header))
+		for key, value := range testItem.expected {
+			assert.Equal(t, value, header.Get(key), "header = %s", key)
+		}
+	}
+}
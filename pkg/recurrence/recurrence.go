@@ -0,0 +1,105 @@
+// Package recurrence computes the occurrences of RFC 5545 recurrence rules.
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/teambition/rrule-go"
+)
+
+// maxSkipped bounds the exception dates skipped by Next, so a rule whose
+// every occurrence is excluded cannot loop forever.
+const maxSkipped = 1000
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Rule is a recurrence rule anchored at its first occurrence. Occurrences
+// are computed in the rule's time zone, so "every Monday at 09:00" keeps its
+// wall clock time across daylight saving changes.
+type Rule struct {
+	rule     *rrule.RRule
+	location *time.Location
+}
+
+// Parse parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO", with or
+// without the "RRULE:" prefix. timezone is an IANA name, empty means UTC.
+// start is the first occurrence the rule counts from.
+func Parse(value, timezone string, start time.Time) (*Rule, error) {
+	location, err := LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" || strings.ContainsAny(value, "\r\n") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRule, value)
+	}
+	option, err := rrule.StrToROptionInLocation(value, location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	option.Dtstart = start.In(location).Truncate(time.Second)
+
+	rule, err := rrule.NewRRule(*option)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	return &Rule{rule: rule, location: location}, nil
+}
+
+// LoadLocation returns the location of an IANA time zone name, empty means
+// UTC.
+func LoadLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: timezone %q", ErrInvalidRule, timezone)
+	}
+	return location, nil
+}
+
+// String returns the rule in RRULE form, without DTSTART.
+func (r *Rule) String() string {
+	option := r.rule.OrigOptions
+	return option.RRuleString()
+}
+
+// First returns the first occurrence at or after the start of the rule.
+func (r *Rule) First(exdates []time.Time) (time.Time, bool) {
+	return r.next(r.rule.OrigOptions.Dtstart, true, exdates)
+}
+
+// Next returns the first occurrence after t which is not one of exdates,
+// false when the rule has ended.
+func (r *Rule) Next(t time.Time, exdates []time.Time) (time.Time, bool) {
+	return r.next(t, false, exdates)
+}
+
+func (r *Rule) next(t time.Time, inclusive bool, exdates []time.Time) (time.Time, bool) {
+	t = t.In(r.location)
+	for i := 0; i < maxSkipped; i++ {
+		next := r.rule.After(t, inclusive)
+		if next.IsZero() {
+			return time.Time{}, false
+		}
+		if !contains(exdates, next) {
+			return next, true
+		}
+		t, inclusive = next, false
+	}
+	return time.Time{}, false
+}
+
+func contains(times []time.Time, t time.Time) bool {
+	for _, candidate := range times {
+		if candidate.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
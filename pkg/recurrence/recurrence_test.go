@@ -0,0 +1,93 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		timezone string
+		isErr    bool
+	}{
+		{"FREQ=WEEKLY;BYDAY=MO", "", false},
+		{"RRULE:FREQ=DAILY;COUNT=3", "Asia/Taipei", false},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", "Europe/Berlin", false},
+		{"", "", true},
+		{"FREQ=HOURLY;INTERVAL=x", "", true},
+		{"BYDAY=MO", "", true},
+		{"DTSTART:20240101T090000Z\nFREQ=DAILY", "", true},
+		{"FREQ=DAILY", "Mars/Olympus", true},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.value, tt.timezone, start)
+		if tt.isErr {
+			assert.ErrorIs(t, err, ErrInvalidRule, tt.value)
+		} else {
+			assert.NoError(t, err, tt.value)
+		}
+	}
+}
+
+func TestRuleNext(t *testing.T) {
+	taipei, _ := time.LoadLocation("Asia/Taipei")
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, taipei) // a Monday
+
+	rule, err := Parse("FREQ=WEEKLY;BYDAY=MO,WE", "Asia/Taipei", start)
+	assert.NoError(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO,WE", rule.String())
+
+	first, ok := rule.First(nil)
+	assert.True(t, ok)
+	assert.True(t, start.Equal(first))
+
+	next, ok := rule.Next(first, nil)
+	assert.True(t, ok)
+	assert.True(t, time.Date(2024, 1, 3, 9, 0, 0, 0, taipei).Equal(next))
+
+	// exception dates are skipped
+	next, ok = rule.Next(first, []time.Time{time.Date(2024, 1, 3, 1, 0, 0, 0, time.UTC)})
+	assert.True(t, ok)
+	assert.True(t, time.Date(2024, 1, 8, 9, 0, 0, 0, taipei).Equal(next))
+}
+
+func TestRuleKeepsWallClockAcrossDST(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	start := time.Date(2024, 3, 30, 9, 0, 0, 0, berlin)
+
+	rule, err := Parse("FREQ=DAILY", "Europe/Berlin", start)
+	assert.NoError(t, err)
+
+	next, ok := rule.Next(start, nil)
+	assert.True(t, ok)
+	assert.Equal(t, 9, next.In(berlin).Hour())
+	assert.Equal(t, 23*time.Hour, next.Sub(start))
+}
+
+func TestRuleEnds(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	rule, err := Parse("FREQ=DAILY;COUNT=2", "", start)
+	assert.NoError(t, err)
+
+	second, ok := rule.Next(start, nil)
+	assert.True(t, ok)
+	_, ok = rule.Next(second, nil)
+	assert.False(t, ok)
+
+	// the first occurrence is the first date matching the rule
+	rule, err = Parse("FREQ=WEEKLY;BYDAY=FR", "", start)
+	assert.NoError(t, err)
+	first, ok := rule.First(nil)
+	assert.True(t, ok)
+	assert.Equal(t, time.Friday, first.Weekday())
+
+	_, ok = rule.First([]time.Time{first, first.AddDate(0, 0, 7)})
+	assert.True(t, ok)
+}
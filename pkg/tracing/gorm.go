@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	gormInstrumentationName = "task_service/pkg/tracing/gorm"
+	gormSpanKey             = "tracing:span"
+)
+
+// GormPlugin starts a client span for every statement GORM executes. The
+// span is a child of the span in the statement context, so queries have to
+// run with WithContext to be joined with their request.
+type GormPlugin struct {
+	tracer trace.Tracer
+}
+
+func NewGormPlugin() *GormPlugin {
+	return &GormPlugin{
+		tracer: otel.Tracer(gormInstrumentationName),
+	}
+}
+
+func (plugin *GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (plugin *GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	registers := []error{
+		callback.Create().Before("gorm:create").Register("tracing:before_create", plugin.before("create")),
+		callback.Create().After("gorm:create").Register("tracing:after_create", plugin.after),
+		callback.Query().Before("gorm:query").Register("tracing:before_query", plugin.before("query")),
+		callback.Query().After("gorm:query").Register("tracing:after_query", plugin.after),
+		callback.Update().Before("gorm:update").Register("tracing:before_update", plugin.before("update")),
+		callback.Update().After("gorm:update").Register("tracing:after_update", plugin.after),
+		callback.Delete().Before("gorm:delete").Register("tracing:before_delete", plugin.before("delete")),
+		callback.Delete().After("gorm:delete").Register("tracing:after_delete", plugin.after),
+		callback.Row().Before("gorm:row").Register("tracing:before_row", plugin.before("row")),
+		callback.Row().After("gorm:row").Register("tracing:after_row", plugin.after),
+		callback.Raw().Before("gorm:raw").Register("tracing:before_raw", plugin.before("raw")),
+		callback.Raw().After("gorm:raw").Register("tracing:after_raw", plugin.after),
+	}
+	return errors.Join(registers...)
+}
+
+func (plugin *GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			return
+		}
+		_, span := plugin.tracer.Start(ctx, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemMySQL,
+				semconv.DBOperation(operation),
+			))
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func (plugin *GormPlugin) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	attrs := []attribute.KeyValue{
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	}
+	if db.Statement.Table != "" {
+		attrs = append(attrs, semconv.DBSQLTable(db.Statement.Table))
+	}
+	if sql := db.Statement.SQL.String(); sql != "" {
+		// the statement keeps the placeholders, values are not recorded
+		attrs = append(attrs, semconv.DBStatement(sql))
+	}
+	span.SetAttributes(attrs...)
+
+	if err := db.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"task_service/config"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// NewTracerProvider builds a tracer provider that batches spans to the
+// exporter of option. Shutting the provider down flushes the spans and
+// closes the output file of the file exporter.
+func NewTracerProvider(ctx context.Context, option config.TracingOption, serviceName string) (*sdktrace.TracerProvider, error) {
+	exporter, err := newExporter(ctx, option)
+	if err != nil {
+		return nil, fmt.Errorf("NewTracerProvider: %v", err)
+	}
+
+	ratio := option.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("NewTracerProvider: %v", err)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	), nil
+}
+
+func newExporter(ctx context.Context, option config.TracingOption) (sdktrace.SpanExporter, error) {
+	switch option.Exporter {
+	case ExporterOTLP, "":
+		opts := []otlptracehttp.Option{}
+		if option.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(option.Endpoint))
+		}
+		if option.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		if option.FilePath == "" {
+			return nil, fmt.Errorf("file path of the file exporter is required")
+		}
+		file, err := os.OpenFile(option.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		return &closingExporter{SpanExporter: exporter, closer: file}, nil
+	default:
+		return nil, fmt.Errorf("unknown exporter %s", option.Exporter)
+	}
+}
+
+// closingExporter closes the output of the exporter on shutdown.
+type closingExporter struct {
+	sdktrace.SpanExporter
+	closer io.Closer
+}
+
+func (exporter *closingExporter) Shutdown(ctx context.Context) error {
+	err := exporter.SpanExporter.Shutdown(ctx)
+	if closeErr := exporter.closer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"task_service/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func TestNewTracerProvider(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "spans.json")
+
+	tests := []struct {
+		option config.TracingOption
+		isErr  bool
+	}{
+		{config.TracingOption{Exporter: ExporterStdout}, false},
+		{config.TracingOption{Exporter: ExporterFile, FilePath: filePath}, false},
+		{config.TracingOption{Exporter: ExporterFile}, true},
+		{config.TracingOption{Exporter: ExporterOTLP, Endpoint: "localhost:4318", Insecure: true}, false},
+		{config.TracingOption{Exporter: "zipkin"}, true},
+	}
+
+	for _, testItem := range tests {
+		provider, err := NewTracerProvider(context.Background(), testItem.option, "task-service")
+		if testItem.isErr {
+			assert.NotNil(t, err, "exporter = %s", testItem.option.Exporter)
+			continue
+		}
+		assert.Nil(t, err, "exporter = %s", testItem.option.Exporter)
+		if testItem.option.Exporter == ExporterOTLP {
+			// nothing listens on the endpoint
+			continue
+		}
+
+		_, span := provider.Tracer("test").Start(context.Background(), "span")
+		span.End()
+		assert.Nil(t, provider.Shutdown(context.Background()))
+	}
+
+	content, err := os.ReadFile(filePath)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), `"Name":"span"`)
+}
+
+func TestGormPlugin(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "user:password@tcp(127.0.0.1:3306)/task",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	assert.Nil(t, err)
+	assert.Nil(t, db.Use(NewGormPlugin()))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	var count int64
+	db.WithContext(ctx).Table("Task").Where("tenant_id = ?", "default").Count(&count)
+	parent.End()
+
+	spans := recorder.Ended()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, "gorm.query", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+
+	attrs := map[string]string{}
+	for _, attr := range spans[0].Attributes() {
+		attrs[string(attr.Key)] = attr.Value.Emit()
+	}
+	assert.Equal(t, "mysql", attrs["db.system"])
+	assert.Equal(t, "Task", attrs["db.sql.table"])
+	assert.Equal(t, "SELECT count(*) FROM `Task` WHERE tenant_id = ?", attrs["db.statement"])
+}
@@ -0,0 +1,276 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"task_service/pkg/models"
+	"time"
+)
+
+// maxLineSize is the size of the longest NDJSON line.
+const maxLineSize = 1 << 20
+
+// ErrTooManyRows is returned by ReadAll when the input has more rows than
+// allowed.
+var ErrTooManyRows = errors.New("too many rows")
+
+// Row is a task read by a Decoder. Line is the line of the row in a CSV or
+// NDJSON input, or its position in a JSON array, starting from 1. Err is set
+// when the row cannot be parsed, the following rows are still read.
+type Row struct {
+	Line int
+	Task models.Task
+	Err  error
+}
+
+// Decoder reads the tasks of an import one row at a time. Decode returns
+// io.EOF after the last row, any other error ends the input.
+type Decoder interface {
+	Decode() (Row, error)
+}
+
+// record holds the importable attributes of a task, the others are ignored.
+type record struct {
+	Name       string                 `json:"name"`
+	Content    string                 `json:"content"`
+	Status     int                    `json:"status"`
+	Priority   int                    `json:"priority"`
+	Tags       []string               `json:"tags"`
+	OwnerID    string                 `json:"owner_id"`
+	AssigneeID string                 `json:"assignee_id"`
+	DueAt      *time.Time             `json:"due_at"`
+	RemindAt   *time.Time             `json:"remind_at"`
+	Fields     map[string]interface{} `json:"fields"`
+}
+
+func (r record) task() models.Task {
+	return models.Task{
+		Name:       r.Name,
+		Content:    r.Content,
+		Status:     r.Status,
+		Priority:   r.Priority,
+		Tags:       r.Tags,
+		OwnerID:    r.OwnerID,
+		AssigneeID: r.AssigneeID,
+		DueAt:      r.DueAt,
+		RemindAt:   r.RemindAt,
+		Fields:     r.Fields,
+	}
+}
+
+// NewDecoder returns a decoder reading format from r.
+func NewDecoder(format string, r io.Reader) (Decoder, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVDecoder(r)
+	case FormatJSON:
+		return &jsonDecoder{decoder: json.NewDecoder(r)}, nil
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxLineSize)
+		return &ndjsonDecoder{scanner: scanner}, nil
+	}
+	return nil, fmt.Errorf("NewDecoder: unknown format %q", format)
+}
+
+// ReadAll reads all the rows of format from r, failing with ErrTooManyRows
+// after maxRows rows unless maxRows is 0.
+func ReadAll(format string, r io.Reader, maxRows int) ([]Row, error) {
+	decoder, err := NewDecoder(format, r)
+	if err != nil {
+		return nil, err
+	}
+	var rows []Row
+	for {
+		row, err := decoder.Decode()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if maxRows > 0 && len(rows) == maxRows {
+			return nil, fmt.Errorf("%w, at most %d rows may be imported", ErrTooManyRows, maxRows)
+		}
+		rows = append(rows, row)
+	}
+}
+
+// unmarshalRecord decodes a JSON object, keeping numbers of the custom
+// fields as json.Number like the request bodies.
+func unmarshalRecord(b []byte) (models.Task, error) {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	r := record{}
+	if err := decoder.Decode(&r); err != nil {
+		return models.Task{}, err
+	}
+	return r.task(), nil
+}
+
+type jsonDecoder struct {
+	decoder *json.Decoder
+	started bool
+	line    int
+}
+
+func (dec *jsonDecoder) Decode() (Row, error) {
+	if !dec.started {
+		dec.started = true
+		token, err := dec.decoder.Token()
+		if err != nil {
+			return Row{}, fmt.Errorf("invalid JSON: %w", err)
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return Row{}, fmt.Errorf("invalid JSON: expected an array of tasks")
+		}
+	}
+	if !dec.decoder.More() {
+		if _, err := dec.decoder.Token(); err != nil {
+			return Row{}, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return Row{}, io.EOF
+	}
+
+	var raw json.RawMessage
+	if err := dec.decoder.Decode(&raw); err != nil {
+		return Row{}, fmt.Errorf("invalid JSON after element %d: %w", dec.line, err)
+	}
+	dec.line++
+	task, err := unmarshalRecord(raw)
+	return Row{Line: dec.line, Task: task, Err: err}, nil
+}
+
+type ndjsonDecoder struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (dec *ndjsonDecoder) Decode() (Row, error) {
+	for dec.scanner.Scan() {
+		dec.line++
+		line := bytes.TrimSpace(dec.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		task, err := unmarshalRecord(line)
+		return Row{Line: dec.line, Task: task, Err: err}, nil
+	}
+	if err := dec.scanner.Err(); err != nil {
+		return Row{}, fmt.Errorf("invalid NDJSON after line %d: %w", dec.line, err)
+	}
+	return Row{}, io.EOF
+}
+
+type csvDecoder struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+// newCSVDecoder reads the header of r, its columns may be given in any
+// order and only name is required.
+func newCSVDecoder(r io.Reader) (*csvDecoder, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("invalid CSV: the header is missing")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	exported := map[string]bool{}
+	for _, column := range Columns {
+		exported[column] = true
+	}
+	hasName := false
+	for i, column := range header {
+		if i == 0 {
+			// spreadsheets often start the file with a byte order mark
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !exported[column] {
+			return nil, fmt.Errorf("invalid CSV: unknown column %q", column)
+		}
+		hasName = hasName || column == "name"
+		header[i] = column
+	}
+	if !hasName {
+		return nil, fmt.Errorf("invalid CSV: the name column is missing")
+	}
+	return &csvDecoder{reader: reader, columns: header}, nil
+}
+
+func (dec *csvDecoder) Decode() (Row, error) {
+	values, err := dec.reader.Read()
+	if err == io.EOF {
+		return Row{}, io.EOF
+	}
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+			return Row{Line: parseErr.StartLine, Err: fmt.Errorf("expected %d columns, got %d", len(dec.columns), len(values))}, nil
+		}
+		return Row{}, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	line, _ := dec.reader.FieldPos(0)
+	task, err := dec.parse(values)
+	return Row{Line: line, Task: task, Err: err}, nil
+}
+
+func (dec *csvDecoder) parse(values []string) (models.Task, error) {
+	task := models.Task{}
+	for i, column := range dec.columns {
+		value := values[i]
+		if !importColumns[column] || value == "" {
+			continue
+		}
+
+		var err error
+		switch column {
+		case "name":
+			task.Name = value
+		case "content":
+			task.Content = value
+		case "status":
+			task.Status, err = strconv.Atoi(value)
+		case "priority":
+			task.Priority, err = strconv.Atoi(value)
+		case "tags":
+			task.Tags = strings.Split(value, ",")
+		case "owner_id":
+			task.OwnerID = value
+		case "assignee_id":
+			task.AssigneeID = value
+		case "due_at":
+			task.DueAt, err = parseTime(value)
+		case "remind_at":
+			task.RemindAt, err = parseTime(value)
+		case "fields":
+			decoder := json.NewDecoder(strings.NewReader(value))
+			decoder.UseNumber()
+			err = decoder.Decode(&task.Fields)
+		}
+		if err != nil {
+			return models.Task{}, fmt.Errorf("invalid %s: %v", column, err)
+		}
+	}
+	return task, nil
+}
+
+func parseTime(value string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,130 @@
+package transfer
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"task_service/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		fallback string
+		isErr    bool
+		expected string
+	}{
+		{"", FormatJSON, false, FormatJSON},
+		{"CSV", FormatJSON, false, FormatCSV},
+		{"ndjson", "", false, FormatNDJSON},
+		{"xml", FormatJSON, true, ""},
+		{"", "", true, ""},
+	}
+
+	for _, testItem := range tests {
+		format, err := ParseFormat(testItem.format, testItem.fallback)
+		assert.Equal(t, testItem.isErr, err != nil, testItem.format)
+		assert.Equal(t, testItem.expected, format)
+	}
+
+	assert.Equal(t, FormatCSV, FormatOf("text/csv; charset=utf-8"))
+	assert.Equal(t, FormatNDJSON, FormatOf("application/x-ndjson"))
+	assert.Equal(t, "", FormatOf("multipart/form-data"))
+}
+
+func TestReadAllCSV(t *testing.T) {
+	input := "\ufeffName, Status ,tags,due_at,fields,id\n" +
+		"Write docs,2,\"docs, backend\",2024-05-01T09:00:00Z,\"{\"\"points\"\":3}\",12\n" +
+		"\n" +
+		"Bad status,open,,,,\n" +
+		"Too short,1\n" +
+		"Bad date,1,,tomorrow,,\n"
+
+	rows, err := ReadAll(FormatCSV, strings.NewReader(input), 0)
+	assert.Nil(t, err)
+	if !assert.Len(t, rows, 4) {
+		return
+	}
+
+	due := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	assert.Equal(t, 2, rows[0].Line)
+	assert.Nil(t, rows[0].Err)
+	assert.Equal(t, models.Task{
+		Name: "Write docs", Status: models.TaskStatusInProgress, Tags: []string{"docs", " backend"},
+		DueAt: &due, Fields: map[string]interface{}{"points": json.Number("3")},
+	}, rows[0].Task)
+
+	assert.Equal(t, 4, rows[1].Line)
+	assert.ErrorContains(t, rows[1].Err, "invalid status")
+	assert.Equal(t, 5, rows[2].Line)
+	assert.EqualError(t, rows[2].Err, "expected 6 columns, got 2")
+	assert.Equal(t, 6, rows[3].Line)
+	assert.ErrorContains(t, rows[3].Err, "invalid due_at")
+}
+
+func TestReadAllCSVHeader(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"", "invalid CSV: the header is missing"},
+		{"content,status\n", "invalid CSV: the name column is missing"},
+		{"name,colour\n", "invalid CSV: unknown column \"colour\""},
+		{"name\n\"unterminated\n", "invalid CSV: parse error on line 2, column 15: extraneous or missing \" in quoted-field"},
+	}
+
+	for _, testItem := range tests {
+		_, err := ReadAll(FormatCSV, strings.NewReader(testItem.input), 0)
+		assert.EqualError(t, err, testItem.err, testItem.input)
+	}
+
+	rows, err := ReadAll(FormatCSV, strings.NewReader("name\n"), 0)
+	assert.Nil(t, err)
+	assert.Empty(t, rows)
+}
+
+func TestReadAllJSON(t *testing.T) {
+	input := `[
+		{"id": 3, "name": "Write docs", "priority": 2, "fields": {"points": 1.5}},
+		{"name": "Bad status", "status": "open"}
+	]`
+
+	rows, err := ReadAll(FormatJSON, strings.NewReader(input), 0)
+	assert.Nil(t, err)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, 1, rows[0].Line)
+		assert.Nil(t, rows[0].Err)
+		assert.Equal(t, models.Task{Name: "Write docs", Priority: models.TaskPriorityMedium,
+			Fields: map[string]interface{}{"points": json.Number("1.5")}}, rows[0].Task)
+		assert.Equal(t, 2, rows[1].Line)
+		assert.NotNil(t, rows[1].Err)
+	}
+
+	for _, input := range []string{``, `{"name": "a"}`, `[{"name": "a"}`, `[{"name": "a"} {"name": "b"}]`} {
+		_, err := ReadAll(FormatJSON, strings.NewReader(input), 0)
+		assert.NotNil(t, err, input)
+	}
+}
+
+func TestReadAllNDJSON(t *testing.T) {
+	input := "{\"name\": \"a\"}\n\n{\"name\": \"b\", \"due_at\": \"soon\"}\n{\"name\": \"c\"}"
+
+	rows, err := ReadAll(FormatNDJSON, strings.NewReader(input), 0)
+	assert.Nil(t, err)
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, []int{1, 3, 4}, []int{rows[0].Line, rows[1].Line, rows[2].Line})
+		assert.Nil(t, rows[0].Err)
+		assert.NotNil(t, rows[1].Err)
+		assert.Equal(t, "c", rows[2].Task.Name)
+	}
+
+	_, err = ReadAll(FormatNDJSON, strings.NewReader(input), 2)
+	assert.True(t, errors.Is(err, ErrTooManyRows))
+
+	_, err = ReadAll(FormatNDJSON, strings.NewReader(strings.Repeat("x", maxLineSize+1)), 0)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,140 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"task_service/pkg/models"
+	"time"
+)
+
+// Encoder writes tasks one at a time, Close completes the output and must
+// be called even when no task was written.
+type Encoder interface {
+	Encode(task models.Task) error
+	Close() error
+}
+
+// NewEncoder returns an encoder writing format to w.
+func NewEncoder(format string, w io.Writer) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return &csvEncoder{writer: csv.NewWriter(w)}, nil
+	case FormatJSON:
+		return &jsonEncoder{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonEncoder{encoder: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("NewEncoder: unknown format %q", format)
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+	header bool
+}
+
+func (enc *csvEncoder) Encode(task models.Task) error {
+	if err := enc.writeHeader(); err != nil {
+		return err
+	}
+	fields := ""
+	if len(task.Fields) > 0 {
+		b, err := json.Marshal(task.Fields)
+		if err != nil {
+			return fmt.Errorf("Encode: %s", err.Error())
+		}
+		fields = string(b)
+	}
+	parentId := ""
+	if task.ParentID != nil {
+		parentId = strconv.FormatUint(*task.ParentID, 10)
+	}
+	return enc.writer.Write([]string{
+		strconv.FormatUint(task.ID, 10),
+		task.Name,
+		task.Content,
+		strconv.Itoa(task.Status),
+		strconv.Itoa(task.Priority),
+		strings.Join(task.Tags, ","),
+		task.OwnerID,
+		task.AssigneeID,
+		formatTime(task.DueAt),
+		formatTime(task.RemindAt),
+		parentId,
+		strconv.Itoa(task.Progress),
+		task.Rank,
+		fields,
+		formatTime(&task.CreatedAt),
+		formatTime(&task.UpdatedAt),
+	})
+}
+
+func (enc *csvEncoder) Close() error {
+	if err := enc.writeHeader(); err != nil {
+		return err
+	}
+	enc.writer.Flush()
+	return enc.writer.Error()
+}
+
+func (enc *csvEncoder) writeHeader() error {
+	if enc.header {
+		return nil
+	}
+	enc.header = true
+	return enc.writer.Write(Columns)
+}
+
+// jsonEncoder writes a single JSON array, one element at a time.
+type jsonEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (enc *jsonEncoder) Encode(task models.Task) error {
+	b, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("Encode: %s", err.Error())
+	}
+	separator := ",\n"
+	if enc.count == 0 {
+		separator = "[\n"
+	}
+	enc.count++
+	if _, err := io.WriteString(enc.w, separator); err != nil {
+		return err
+	}
+	_, err = enc.w.Write(b)
+	return err
+}
+
+func (enc *jsonEncoder) Close() error {
+	end := "\n]\n"
+	if enc.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(enc.w, end)
+	return err
+}
+
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (enc *ndjsonEncoder) Encode(task models.Task) error {
+	return enc.encoder.Encode(task)
+}
+
+func (enc *ndjsonEncoder) Close() error {
+	return nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
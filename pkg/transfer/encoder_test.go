@@ -0,0 +1,77 @@
+package transfer
+
+import (
+	"bytes"
+	"task_service/pkg/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoder(t *testing.T) {
+	due := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	parentId := uint64(1)
+	task := models.Task{
+		ID: 2, Name: "Ship, then celebrate", Content: "say \"done\"", Status: models.TaskStatusOpen,
+		Priority: models.TaskPriorityHigh, Tags: []string{"backend", "release"}, OwnerID: "alice",
+		DueAt: &due, ParentID: &parentId, Rank: "0000100000", Fields: map[string]interface{}{"points": 3.0},
+		CreatedAt: due, UpdatedAt: due,
+	}
+
+	tests := []struct {
+		format   string
+		tasks    []models.Task
+		expected string
+	}{
+		{FormatCSV, nil, "id,name,content,status,priority,tags,owner_id,assignee_id,due_at,remind_at,parent_id,progress,rank,fields,created_at,updated_at\n"},
+		{FormatCSV, []models.Task{task}, "id,name,content,status,priority,tags,owner_id,assignee_id,due_at,remind_at,parent_id,progress,rank,fields,created_at,updated_at\n" +
+			"2,\"Ship, then celebrate\",\"say \"\"done\"\"\",1,3,\"backend,release\",alice,,2024-05-01T09:00:00Z,,1,0,0000100000,\"{\"\"points\"\":3}\",2024-05-01T09:00:00Z,2024-05-01T09:00:00Z\n"},
+		{FormatJSON, nil, "[]\n"},
+		{FormatJSON, []models.Task{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}, "[\n" +
+			`{"id":1,"tenant_id":"","name":"a","status":0,"content":"","priority":0,"rank":"","tags":null,"owner_id":"","assignee_id":"","progress":0,"comment_count":0,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"},` + "\n" +
+			`{"id":2,"tenant_id":"","name":"b","status":0,"content":"","priority":0,"rank":"","tags":null,"owner_id":"","assignee_id":"","progress":0,"comment_count":0,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n]\n"},
+		{FormatNDJSON, nil, ""},
+		{FormatNDJSON, []models.Task{{ID: 1, Name: "a"}}, `{"id":1,"tenant_id":"","name":"a","status":0,"content":"","priority":0,"rank":"","tags":null,"owner_id":"","assignee_id":"","progress":0,"comment_count":0,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}` + "\n"},
+	}
+
+	for _, testItem := range tests {
+		buf := &bytes.Buffer{}
+		encoder, err := NewEncoder(testItem.format, buf)
+		assert.Nil(t, err)
+		for _, task := range testItem.tasks {
+			assert.Nil(t, encoder.Encode(task))
+		}
+		assert.Nil(t, encoder.Close())
+		assert.Equal(t, testItem.expected, buf.String(), testItem.format)
+	}
+
+	_, err := NewEncoder("xml", &bytes.Buffer{})
+	assert.NotNil(t, err)
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	due := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	task := models.Task{
+		ID: 7, Name: "Release", Content: "notes", Status: models.TaskStatusInProgress, Priority: models.TaskPriorityLow,
+		Tags: []string{"a", "b"}, OwnerID: "alice", AssigneeID: "bob", DueAt: &due, Rank: "0000100000",
+	}
+	expected := models.Task{
+		Name: "Release", Content: "notes", Status: models.TaskStatusInProgress, Priority: models.TaskPriorityLow,
+		Tags: []string{"a", "b"}, OwnerID: "alice", AssigneeID: "bob", DueAt: &due,
+	}
+
+	for _, format := range []string{FormatCSV, FormatJSON, FormatNDJSON} {
+		buf := &bytes.Buffer{}
+		encoder, _ := NewEncoder(format, buf)
+		assert.Nil(t, encoder.Encode(task))
+		assert.Nil(t, encoder.Close())
+
+		rows, err := ReadAll(format, buf, 0)
+		assert.Nil(t, err, format)
+		if assert.Len(t, rows, 1, format) {
+			assert.Nil(t, rows[0].Err, format)
+			assert.Equal(t, expected, rows[0].Task, format)
+		}
+	}
+}
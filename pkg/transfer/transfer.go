@@ -0,0 +1,66 @@
+// Package transfer reads and writes tasks as CSV, JSON or NDJSON for the
+// bulk import and export of tasks.
+package transfer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Formats of an import or export.
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+var contentTypes = map[string]string{
+	FormatCSV:    "text/csv",
+	FormatJSON:   "application/json",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// Columns are the CSV columns of an export. The columns which are not
+// importable, such as id or rank, are ignored by an import so an export can
+// be imported again.
+var Columns = []string{
+	"id", "name", "content", "status", "priority", "tags", "owner_id", "assignee_id",
+	"due_at", "remind_at", "parent_id", "progress", "rank", "fields", "created_at", "updated_at",
+}
+
+// importColumns are the columns read by an import.
+var importColumns = map[string]bool{
+	"name": true, "content": true, "status": true, "priority": true, "tags": true,
+	"owner_id": true, "assignee_id": true, "due_at": true, "remind_at": true, "fields": true,
+}
+
+// ParseFormat checks format, which may be empty to use the default format
+// fallback.
+func ParseFormat(format, fallback string) (string, error) {
+	if format == "" {
+		format = fallback
+	}
+	format = strings.ToLower(format)
+	if _, ok := contentTypes[format]; !ok {
+		return "", fmt.Errorf("invalid format %q, use csv, json or ndjson", format)
+	}
+	return format, nil
+}
+
+// FormatOf returns the format of a Content-Type, or "" when it is none of
+// the formats.
+func FormatOf(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for format, formatType := range contentTypes {
+		if mediaType == formatType {
+			return format
+		}
+	}
+	return ""
+}
+
+// ContentType returns the Content-Type of format.
+func ContentType(format string) string {
+	return contentTypes[format]
+}
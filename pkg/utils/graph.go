@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"slices"
+	"task_service/pkg/models"
+)
+
+// TopologicalOrder orders the tasks of ids and edges so that every blocker
+// comes before the tasks it blocks, the smallest id first among the tasks
+// that are ready. When the edges contain a cycle it returns nil and the
+// cycle instead, as ids each depending on the next, the first id repeated at
+// the end.
+func TopologicalOrder(ids []uint64, edges []models.TaskDependency) (order []uint64, cycle []uint64) {
+	blockers := make(map[uint64]int)
+	dependents := make(map[uint64][]uint64)
+	for _, id := range ids {
+		blockers[id] += 0
+	}
+	for _, edge := range edges {
+		blockers[edge.TaskID]++
+		blockers[edge.BlockerID] += 0
+		dependents[edge.BlockerID] = append(dependents[edge.BlockerID], edge.TaskID)
+	}
+
+	var ready []uint64
+	for id, count := range blockers {
+		if count == 0 {
+			ready = append(ready, id)
+		}
+	}
+	slices.Sort(ready)
+
+	order = make([]uint64, 0, len(blockers))
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+		for _, dependent := range dependents[id] {
+			blockers[dependent]--
+			if blockers[dependent] == 0 {
+				index, _ := slices.BinarySearch(ready, dependent)
+				ready = slices.Insert(ready, index, dependent)
+			}
+		}
+	}
+	if len(order) == len(blockers) {
+		return order, nil
+	}
+	return nil, findCycle(blockers, edges)
+}
+
+// findCycle walks from a task left with blockers to one of its remaining
+// blockers until a task repeats. Every such task has a remaining blocker, so
+// the walk always ends on a cycle.
+func findCycle(blockers map[uint64]int, edges []models.TaskDependency) []uint64 {
+	blockedBy := make(map[uint64][]uint64)
+	var start uint64
+	found := false
+	for _, edge := range edges {
+		if blockers[edge.TaskID] > 0 && blockers[edge.BlockerID] > 0 {
+			blockedBy[edge.TaskID] = append(blockedBy[edge.TaskID], edge.BlockerID)
+			if !found || edge.TaskID < start {
+				start, found = edge.TaskID, true
+			}
+		}
+	}
+	for id := range blockedBy {
+		slices.Sort(blockedBy[id])
+	}
+
+	visited := make(map[uint64]int)
+	var path []uint64
+	for id := start; ; id = blockedBy[id][0] {
+		if index, ok := visited[id]; ok {
+			return append(path[index:], id)
+		}
+		visited[id] = len(path)
+		path = append(path, id)
+	}
+}
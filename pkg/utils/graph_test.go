@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"task_service/pkg/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func dependsOn(taskId, blockerId uint64) models.TaskDependency {
+	return models.TaskDependency{TaskID: taskId, BlockerID: blockerId}
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	tests := []struct {
+		name          string
+		ids           []uint64
+		edges         []models.TaskDependency
+		expected      []uint64
+		expectedCycle []uint64
+	}{
+		{"no edges", []uint64{3, 1, 2}, nil, []uint64{1, 2, 3}, nil},
+		{"chain", nil, []models.TaskDependency{dependsOn(1, 2), dependsOn(2, 3)}, []uint64{3, 2, 1}, nil},
+		{
+			"diamond",
+			nil,
+			[]models.TaskDependency{dependsOn(4, 2), dependsOn(4, 3), dependsOn(2, 1), dependsOn(3, 1)},
+			[]uint64{1, 2, 3, 4},
+			nil,
+		},
+		{"isolated task", []uint64{9}, []models.TaskDependency{dependsOn(5, 6)}, []uint64{6, 5, 9}, nil},
+		{"self loop", nil, []models.TaskDependency{dependsOn(1, 1)}, nil, []uint64{1, 1}},
+		{
+			"cycle",
+			nil,
+			[]models.TaskDependency{dependsOn(1, 2), dependsOn(2, 3), dependsOn(3, 1), dependsOn(4, 1)},
+			nil,
+			[]uint64{1, 2, 3, 1},
+		},
+		{
+			"cycle behind a chain",
+			nil,
+			[]models.TaskDependency{dependsOn(1, 5), dependsOn(5, 6), dependsOn(6, 5)},
+			nil,
+			[]uint64{5, 6, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, cycle := TopologicalOrder(tt.ids, tt.edges)
+			assert.Equal(t, tt.expected, order)
+			assert.Equal(t, tt.expectedCycle, cycle)
+		})
+	}
+}
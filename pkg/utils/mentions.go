@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	fencedCodePattern = regexp.MustCompile("(?s)```.*?(```|$)")
+	inlineCodePattern = regexp.MustCompile("`[^`\n]*`")
+	// a mention starts the text or follows a character which can not be
+	// part of an id, so e-mail addresses are not mentions
+	mentionPattern = regexp.MustCompile(`(?:^|[^\w.@-])@([\w][\w.-]*)`)
+)
+
+// ParseMentions returns the sorted ids mentioned as @id in a markdown body,
+// ignoring the ones inside code.
+func ParseMentions(body string) []string {
+	body = fencedCodePattern.ReplaceAllString(body, " ")
+	body = inlineCodePattern.ReplaceAllString(body, " ")
+
+	mentions := []string{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		// a trailing dot ends the sentence rather than the id
+		if id := strings.TrimRight(match[1], ".-"); id != "" {
+			mentions = append(mentions, id)
+		}
+	}
+	slices.Sort(mentions)
+	return slices.Compact(mentions)
+}
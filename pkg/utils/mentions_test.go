@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{"none", "no mentions here", []string{}},
+		{"start of body", "@alice please review", []string{"alice"}},
+		{"several sorted", "thanks @bob and @alice,@carol", []string{"alice", "bob", "carol"}},
+		{"duplicates", "@bob @bob", []string{"bob"}},
+		{"trailing dot", "ask @bob.", []string{"bob"}},
+		{"dotted id", "ask @bob.smith now", []string{"bob.smith"}},
+		{"markdown", "**@alice** see [docs](http://x) (@bob)", []string{"alice", "bob"}},
+		{"e-mail", "mail bob@example.com", []string{}},
+		{"inline code", "run `@alice` as @bob", []string{"bob"}},
+		{"fenced code", "```\n@alice\n```\n@bob", []string{"bob"}},
+		{"unclosed fence", "@bob\n```\n@alice", []string{"bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ParseMentions(tt.body))
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Ranks order the tasks of a board. A rank is a base 36 fraction written
+// without the leading "0.", so comparing two ranks as strings compares them
+// as numbers and there is always room for a rank between two others.
+const (
+	// RankWidth is the number of digits of the ranks handed out when a task
+	// is appended, prepended or the ranks are spread again.
+	RankWidth = 10
+	// MaxRankLength is the longest rank RankBetween returns before asking
+	// for the ranks to be spread again.
+	MaxRankLength = 32
+)
+
+const (
+	rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+	rankBase   = uint64(len(rankDigits))
+	// rankSpace is the number of ranks of RankWidth digits, 36^10
+	rankSpace = uint64(3656158440062976)
+	// rankStep is the gap left between appended tasks, 36^5
+	rankStep = uint64(60466176)
+)
+
+// RankBetween returns a rank sorting after lower and before upper. An empty
+// lower or upper leaves that side open. It returns false when lower does not
+// sort before upper or the rank is longer than MaxRankLength, the ranks
+// should then be spread again with SpreadRanks.
+func RankBetween(lower, upper string) (string, bool) {
+	if upper != "" && strings.TrimRight(lower, "0") >= strings.TrimRight(upper, "0") {
+		return "", false
+	}
+	if upper == "" {
+		if v := rankValue(lower) + rankStep; v < rankSpace {
+			return formatRank(v), true
+		}
+	} else if lower == "" {
+		if v := rankValue(upper); v > rankStep {
+			return formatRank(v - rankStep), true
+		}
+	}
+
+	rank := midRank(lower, upper)
+	return rank, len(rank) <= MaxRankLength
+}
+
+// SpreadRanks returns n ascending ranks of RankWidth digits evenly spread
+// over the whole range.
+func SpreadRanks(n int) []string {
+	ranks := make([]string, n)
+	gap := rankSpace / uint64(n+1)
+	for i := range ranks {
+		ranks[i] = formatRank(gap * uint64(i+1))
+	}
+	return ranks
+}
+
+// midRank returns the shortest rank halfway between lower and upper,
+// digit by digit. An empty upper is one past the largest rank.
+func midRank(lower, upper string) string {
+	var rank strings.Builder
+	i := 0
+	for ; ; i++ {
+		low := rankDigit(lower, i)
+		high := rankBase
+		if upper != "" {
+			high = rankDigit(upper, i)
+		}
+		if low == high {
+			rank.WriteByte(rankDigits[low])
+			continue
+		}
+		if high-low > 1 {
+			rank.WriteByte(rankDigits[(low+high)/2])
+			return rank.String()
+		}
+		// no digit fits between, keep low and find a rank after the rest
+		// of lower
+		rank.WriteByte(rankDigits[low])
+		break
+	}
+	for i++; ; i++ {
+		low := rankDigit(lower, i)
+		if low < rankBase-1 {
+			rank.WriteByte(rankDigits[(low+rankBase)/2])
+			return rank.String()
+		}
+		rank.WriteByte(rankDigits[low])
+	}
+}
+
+// rankDigit returns the i-th digit of rank, ranks are padded with zeros.
+func rankDigit(rank string, i int) uint64 {
+	if i >= len(rank) {
+		return 0
+	}
+	return uint64(strings.IndexByte(rankDigits, rank[i]))
+}
+
+// rankValue returns the first RankWidth digits of rank as a number.
+func rankValue(rank string) uint64 {
+	v := uint64(0)
+	for i := 0; i < RankWidth; i++ {
+		v = v*rankBase + rankDigit(rank, i)
+	}
+	return v
+}
+
+func formatRank(v uint64) string {
+	rank := strconv.FormatUint(v, int(rankBase))
+	return strings.Repeat("0", RankWidth-len(rank)) + rank
+}
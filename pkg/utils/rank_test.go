@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		lower    string
+		upper    string
+		expected string
+		ok       bool
+	}{
+		{"", "", "0000100000", true},
+		{"0000100000", "", "0000200000", true},
+		{"", "0000200000", "0000100000", true},
+		{"", "0000000001", "0000000000i", true},
+		{"0000100000", "0000200000", "00001i", true},
+		{"1", "2", "1i", true},
+		{"1", "11", "10i", true},
+		{"1z", "2", "1zi", true},
+		{"zzzzzzzzzz", "", "zzzzzzzzzzi", true},
+		{"2", "1", "", false},
+		{"1", "10", "", false},
+		{"", "000", "", false},
+		{strings.Repeat("1", MaxRankLength), strings.Repeat("1", MaxRankLength-1) + "2", strings.Repeat("1", MaxRankLength) + "i", false},
+	}
+
+	for _, testItem := range tests {
+		rank, ok := RankBetween(testItem.lower, testItem.upper)
+		assert.Equal(t, testItem.ok, ok, "%q %q", testItem.lower, testItem.upper)
+		assert.Equal(t, testItem.expected, rank, "%q %q", testItem.lower, testItem.upper)
+	}
+}
+
+func TestRankBetweenRepeated(t *testing.T) {
+	// keep inserting right after the first rank until precision runs out
+	lower, upper := "", ""
+	ranks := []string{}
+	for i := 0; ; i++ {
+		rank, ok := RankBetween(lower, upper)
+		if !ok {
+			assert.Greater(t, i, 100)
+			break
+		}
+		if lower != "" {
+			assert.Less(t, lower, rank)
+		}
+		if upper != "" {
+			assert.Less(t, rank, upper)
+		}
+		ranks = append(ranks, rank)
+		if lower == "" {
+			lower = rank
+			continue
+		}
+		upper = rank
+	}
+	assert.True(t, len(ranks) > 100)
+}
+
+func TestSpreadRanks(t *testing.T) {
+	ranks := SpreadRanks(1000)
+	assert.Len(t, ranks, 1000)
+	assert.True(t, sort.StringsAreSorted(ranks))
+	for i, rank := range ranks {
+		assert.Len(t, rank, RankWidth)
+		if i > 0 {
+			assert.NotEqual(t, ranks[i-1], rank)
+		}
+	}
+	assert.Empty(t, SpreadRanks(0))
+}
@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+)
+
+// NormalizeTags trims the tag names, drops the empty ones and returns them
+// sorted without duplicates.
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			normalized = append(normalized, tag)
+		}
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		expected []string
+	}{
+		{"nil", nil, []string{}},
+		{"sorted", []string{"b", "a"}, []string{"a", "b"}},
+		{"trimmed", []string{" a ", "b"}, []string{"a", "b"}},
+		{"empty dropped", []string{"", "  ", "a"}, []string{"a"}},
+		{"duplicates dropped", []string{"a", "b", " a"}, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, NormalizeTags(tt.tags))
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// templateVariable matches a {{variable}} placeholder of a task template,
+// spaces inside the braces are ignored.
+var templateVariable = regexp.MustCompile(`{{\s*([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+// TemplateVariables returns the sorted names of the variables used in
+// patterns. It fails on a "{{" which does not start a valid placeholder.
+func TemplateVariables(patterns ...string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		rest := templateVariable.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+			seen[templateVariable.FindStringSubmatch(placeholder)[1]] = true
+			return ""
+		})
+		if strings.Contains(rest, "{{") {
+			return nil, fmt.Errorf("invalid placeholder in %q, use {{name}}", pattern)
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// RenderTemplate replaces the placeholders of pattern by their values. It
+// fails when a variable has no value.
+func RenderTemplate(pattern string, values map[string]string) (string, error) {
+	var missing []string
+	rendered := templateVariable.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		name := templateVariable.FindStringSubmatch(placeholder)[1]
+		value, ok := values[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing template variables: %s", strings.Join(missing, ", "))
+	}
+	return rendered, nil
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateVariables(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		isErr    bool
+		expected []string
+	}{
+		{[]string{"Onboard {{customer}}", "Contact {{ contact_name }} at {{customer}}"}, false, []string{"contact_name", "customer"}},
+		{[]string{"no variables"}, false, []string{}},
+		{[]string{"Onboard {{customer"}, true, nil},
+		{[]string{"Onboard {{1customer}}"}, true, nil},
+	}
+
+	for _, testItem := range tests {
+		names, err := TemplateVariables(testItem.patterns...)
+		if testItem.isErr {
+			assert.NotNil(t, err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, testItem.expected, names)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	rendered, err := RenderTemplate("Onboard {{ customer }} ({{plan}})", map[string]string{"customer": "Acme", "plan": "pro", "unused": "x"})
+	assert.Nil(t, err)
+	assert.Equal(t, "Onboard Acme (pro)", rendered)
+
+	// values are not rendered again
+	rendered, err = RenderTemplate("{{a}}", map[string]string{"a": "{{b}}"})
+	assert.Nil(t, err)
+	assert.Equal(t, "{{b}}", rendered)
+
+	_, err = RenderTemplate("Onboard {{customer}}", map[string]string{})
+	assert.EqualError(t, err, "missing template variables: customer")
+}
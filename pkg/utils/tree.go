@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"sort"
+	"task_service/pkg/models"
+)
+
+// Subtree returns the descendants of rootId among tasks down to depth levels,
+// depth 1 being the children. They are ordered level by level and by id
+// within a level, the order of the MySQL query.
+func Subtree(tasks []models.Task, rootId uint64, depth int) []models.Task {
+	children := make(map[uint64][]models.Task)
+	for _, task := range tasks {
+		if task.ParentID != nil {
+			children[*task.ParentID] = append(children[*task.ParentID], task)
+		}
+	}
+
+	subtree := []models.Task{}
+	level := []uint64{rootId}
+	for d := 0; d < depth && len(level) > 0; d++ {
+		var next []models.Task
+		for _, id := range level {
+			next = append(next, children[id]...)
+		}
+		sort.Slice(next, func(i, j int) bool { return next[i].ID < next[j].ID })
+
+		level = level[:0]
+		for _, task := range next {
+			level = append(level, task.ID)
+		}
+		subtree = append(subtree, next...)
+	}
+	return subtree
+}
+
+// TreeHeight returns the number of levels below rootId in subtree, as
+// returned by Subtree, 0 when it has no descendants.
+func TreeHeight(rootId uint64, subtree []models.Task) int {
+	depth := map[uint64]int{rootId: 0}
+	height := 0
+	for _, task := range subtree {
+		if task.ParentID == nil {
+			continue
+		}
+		parentDepth, ok := depth[*task.ParentID]
+		if !ok {
+			continue
+		}
+		depth[task.ID] = parentDepth + 1
+		height = max(height, parentDepth+1)
+	}
+	return height
+}
+
+// Progress returns the progress of a task from its status when it has no
+// children, otherwise the average progress of its children.
+func Progress(status int, children []models.Task) int {
+	if len(children) == 0 {
+		if status == models.TaskStatusDone {
+			return 100
+		}
+		return 0
+	}
+
+	total := 0
+	for _, child := range children {
+		total += child.Progress
+	}
+	return total / len(children)
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"task_service/pkg/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func child(id, parentId uint64) models.Task {
+	return models.Task{ID: id, ParentID: &parentId}
+}
+
+func ids(tasks []models.Task) []uint64 {
+	result := []uint64{}
+	for _, task := range tasks {
+		result = append(result, task.ID)
+	}
+	return result
+}
+
+func TestSubtree(t *testing.T) {
+	// 1 ─┬─ 3 ── 4
+	//    └─ 2 ── 5 ── 6
+	tasks := []models.Task{
+		{ID: 1}, child(3, 1), child(2, 1), child(4, 3), child(5, 2), child(6, 5), {ID: 7},
+	}
+
+	tests := []struct {
+		name     string
+		root     uint64
+		depth    int
+		expected []uint64
+	}{
+		{"children", 1, 1, []uint64{2, 3}},
+		{"two levels", 1, 2, []uint64{2, 3, 4, 5}},
+		{"whole tree", 1, 10, []uint64{2, 3, 4, 5, 6}},
+		{"inner node", 2, 10, []uint64{5, 6}},
+		{"leaf", 7, 10, []uint64{}},
+		{"zero depth", 1, 0, []uint64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ids(Subtree(tasks, tt.root, tt.depth)))
+		})
+	}
+}
+
+func TestTreeHeight(t *testing.T) {
+	tasks := []models.Task{child(2, 1), child(3, 1), child(4, 3), child(5, 4)}
+
+	assert.Equal(t, 3, TreeHeight(1, Subtree(tasks, 1, 10)))
+	assert.Equal(t, 2, TreeHeight(3, Subtree(tasks, 3, 10)))
+	assert.Equal(t, 0, TreeHeight(2, Subtree(tasks, 2, 10)))
+}
+
+func TestProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		children []models.Task
+		expected int
+	}{
+		{"open leaf", models.TaskStatusOpen, nil, 0},
+		{"in progress leaf", models.TaskStatusInProgress, nil, 0},
+		{"done leaf", models.TaskStatusDone, nil, 100},
+		{"children override status", models.TaskStatusDone, []models.Task{{Progress: 0}, {Progress: 100}}, 50},
+		{"rounds down", models.TaskStatusOpen, []models.Task{{Progress: 100}, {Progress: 0}, {Progress: 0}}, 33},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Progress(tt.status, tt.children))
+		})
+	}
+}
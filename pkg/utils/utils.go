@@ -1,10 +1,12 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"task_service/pkg/models"
 	"time"
 )
@@ -12,11 +14,15 @@ import (
 var fildMap = map[string]string{
 	"id":         "ID",
 	"name":       "Name",
-	"status":     "Stauts",
+	"status":     "Status",
 	"content":    "Content",
-	"tag":        "Tag",
 	"created_at": "CreatedAt",
 	"updated_at": "UpdatedAt",
+	"due_at":     "DueAt",
+	"remind_at":  "RemindAt",
+	"progress":   "Progress",
+	"priority":   "Priority",
+	"rank":       "Rank",
 }
 
 func ConvertTask(result map[string]string) (models.Task, error) {
@@ -29,6 +35,8 @@ func ConvertTask(result map[string]string) (models.Task, error) {
 				return task, fmt.Errorf("ConvertTask: %v", err)
 			}
 			task.ID = id
+		case "tenant_id":
+			task.TenantID = value
 		case "name":
 			task.Name = value
 		case "status":
@@ -39,8 +47,26 @@ func ConvertTask(result map[string]string) (models.Task, error) {
 			task.Status = status
 		case "content":
 			task.Content = value
-		case "tag":
-			task.Tag = value
+		case "priority":
+			priority, err := strconv.Atoi(value)
+			if err != nil {
+				return task, fmt.Errorf("ConvertTask: %v", err)
+			}
+			task.Priority = priority
+		case "rank":
+			task.Rank = value
+		case "tags":
+			if err := json.Unmarshal([]byte(value), &task.Tags); err != nil {
+				return task, fmt.Errorf("ConvertTask: %v", err)
+			}
+		case "fields":
+			if err := json.Unmarshal([]byte(value), &task.Fields); err != nil {
+				return task, fmt.Errorf("ConvertTask: %v", err)
+			}
+		case "owner_id":
+			task.OwnerID = value
+		case "assignee_id":
+			task.AssigneeID = value
 		case "version":
 			version, err := strconv.Atoi(value)
 			if err != nil {
@@ -59,18 +85,98 @@ func ConvertTask(result map[string]string) (models.Task, error) {
 				return task, fmt.Errorf("ConvertTask: %v", err)
 			}
 			task.UpdatedAt = updatedAt
+		case "due_at":
+			dueAt, err := parseOptionalTime(value)
+			if err != nil {
+				return task, fmt.Errorf("ConvertTask: %v", err)
+			}
+			task.DueAt = dueAt
+		case "remind_at":
+			remindAt, err := parseOptionalTime(value)
+			if err != nil {
+				return task, fmt.Errorf("ConvertTask: %v", err)
+			}
+			task.RemindAt = remindAt
+		case "series_id":
+			seriesId, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return task, fmt.Errorf("ConvertTask: %v", err)
+			}
+			task.SeriesID = &seriesId
+		case "occurrence_at":
+			occurrenceAt, err := parseOptionalTime(value)
+			if err != nil {
+				return task, fmt.Errorf("ConvertTask: %v", err)
+			}
+			task.OccurrenceAt = occurrenceAt
+		case "parent_id":
+			parentId, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return task, fmt.Errorf("ConvertTask: %v", err)
+			}
+			task.ParentID = &parentId
+		case "progress":
+			progress, err := strconv.Atoi(value)
+			if err != nil {
+				return task, fmt.Errorf("ConvertTask: %v", err)
+			}
+			task.Progress = progress
+		case "comment_count":
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				return task, fmt.Errorf("ConvertTask: %v", err)
+			}
+			task.CommentCount = count
 		}
 	}
 	return task, nil
 }
 
+// parseOptionalTime parses a time stored by the cache, where an empty string
+// means no time.
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ParseOrder checks that order is a field SortByField sorts by, or
+// field.<key> of a custom field, optionally followed by asc or desc, and
+// returns it with a single space before the direction.
+func ParseOrder(order string) (string, error) {
+	parts := strings.Fields(order)
+	if len(parts) == 0 || len(parts) > 2 {
+		return "", fmt.Errorf("invalid order %q", order)
+	}
+	if key, ok := strings.CutPrefix(parts[0], "field."); ok {
+		if !models.ValidFieldKey(key) {
+			return "", fmt.Errorf("invalid order: invalid field key %q", key)
+		}
+	} else if _, ok := fildMap[parts[0]]; !ok {
+		return "", fmt.Errorf("invalid order: cannot sort by %q", parts[0])
+	}
+	if len(parts) == 2 && parts[1] != "asc" && parts[1] != "desc" {
+		return "", fmt.Errorf("invalid order: direction %q is neither asc nor desc", parts[1])
+	}
+	return strings.Join(parts, " "), nil
+}
+
 func SortByField(tasks []models.Task, fieldName string, desc bool) {
+	if key, ok := strings.CutPrefix(fieldName, "field."); ok {
+		sortByCustomField(tasks, key, desc)
+		return
+	}
 	if _, ok := fildMap[fieldName]; !ok {
 		return
 	}
 
 	if desc {
-		sort.Slice(tasks, func(i, j int) bool {
+		sort.SliceStable(tasks, func(i, j int) bool {
 			fieldI := reflect.ValueOf(tasks[i]).FieldByName(fildMap[fieldName])
 			fieldJ := reflect.ValueOf(tasks[j]).FieldByName(fildMap[fieldName])
 
@@ -91,13 +197,22 @@ func SortByField(tasks []models.Task, fieldName string, desc bool) {
 				return fieldI.Interface().(int) > fieldJ.Interface().(int)
 			case uint64:
 				return fieldI.Interface().(uint64) > fieldJ.Interface().(uint64)
+			case time.Time:
+				return fieldI.Interface().(time.Time).After(fieldJ.Interface().(time.Time))
+			case *time.Time:
+				valI := fieldI.Interface().(*time.Time)
+				valJ := fieldJ.Interface().(*time.Time)
+				if valI == nil || valJ == nil {
+					return valJ == nil && valI != nil // 沒有時間的排在後面
+				}
+				return valI.After(*valJ)
 			default:
 				// Handle other types if needed
 				return false
 			}
 		})
 	} else {
-		sort.Slice(tasks, func(i, j int) bool {
+		sort.SliceStable(tasks, func(i, j int) bool {
 			fieldI := reflect.ValueOf(tasks[i]).FieldByName(fildMap[fieldName])
 			fieldJ := reflect.ValueOf(tasks[j]).FieldByName(fildMap[fieldName])
 
@@ -118,6 +233,15 @@ func SortByField(tasks []models.Task, fieldName string, desc bool) {
 				return fieldI.Interface().(int) < fieldJ.Interface().(int)
 			case uint64:
 				return fieldI.Interface().(uint64) < fieldJ.Interface().(uint64)
+			case time.Time:
+				return fieldI.Interface().(time.Time).Before(fieldJ.Interface().(time.Time))
+			case *time.Time:
+				valI := fieldI.Interface().(*time.Time)
+				valJ := fieldJ.Interface().(*time.Time)
+				if valI == nil || valJ == nil {
+					return valJ == nil && valI != nil // 沒有時間的排在後面
+				}
+				return valI.Before(*valJ)
 			default:
 				// Handle other types if needed
 				return false
@@ -126,3 +250,19 @@ func SortByField(tasks []models.Task, fieldName string, desc bool) {
 	}
 
 }
+
+// sortByCustomField sorts tasks by the value of a custom field, tasks
+// without it come last.
+func sortByCustomField(tasks []models.Task, key string, desc bool) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		valI, okI := tasks[i].Fields[key]
+		valJ, okJ := tasks[j].Fields[key]
+		if !okI || !okJ {
+			return okI && !okJ
+		}
+		if desc {
+			return models.CompareFieldValues(valI, valJ) > 0
+		}
+		return models.CompareFieldValues(valI, valJ) < 0
+	})
+}
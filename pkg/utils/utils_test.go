@@ -21,7 +21,8 @@ func TestConvertTask(t *testing.T) {
 				"status":     "1",
 				"content":    "test content",
 				"version":    "1",
-				"Tag":        "123",
+				"tag":        "123",
+				"tags":       `["a","b"]`,
 				"created_at": "2006-01-02T15:04:05+08:00",
 				"updated_at": "2006-01-02T15:04:05+08:00",
 			},
@@ -31,7 +32,7 @@ func TestConvertTask(t *testing.T) {
 				Name:    "test task",
 				Status:  1,
 				Content: "test content",
-
+				Tags:    []string{"a", "b"},
 				Version: 1,
 			},
 		},
@@ -64,7 +65,6 @@ func TestConvertTask(t *testing.T) {
 
 		testItem.expected.CreatedAt = createdAt
 		testItem.expected.UpdatedAt = updatedAt
-		testItem.expected.Tag = testItem.result["tag"]
 
 		assert.Nil(t, err)
 		assert.Equal(t, testItem.expected, task)
@@ -88,7 +88,7 @@ func TestSortByField(t *testing.T) {
 			ID:        1,
 			Name:      "b",
 			Content:   "content1",
-			Tag:       "tag2",
+			Tags:      []string{"tag2"},
 			CreatedAt: createdAt1,
 			UpdatedAt: updateAt1,
 		},
@@ -96,7 +96,7 @@ func TestSortByField(t *testing.T) {
 			ID:        2,
 			Name:      "a",
 			Content:   "content2",
-			Tag:       "tag1",
+			Tags:      []string{"tag1"},
 			CreatedAt: createdAt2,
 			UpdatedAt: updateAt2,
 		},
@@ -104,7 +104,6 @@ func TestSortByField(t *testing.T) {
 			ID:        3,
 			Name:      "c",
 			Content:   "content1",
-			Tag:       "",
 			CreatedAt: createdAt3,
 			UpdatedAt: updateAt3,
 		},
@@ -128,7 +127,6 @@ func TestSortByField(t *testing.T) {
 					ID:        3,
 					Name:      "c",
 					Content:   "content1",
-					Tag:       "",
 					CreatedAt: createdAt3,
 					UpdatedAt: updateAt3,
 				},
@@ -136,7 +134,7 @@ func TestSortByField(t *testing.T) {
 					ID:        2,
 					Name:      "a",
 					Content:   "content2",
-					Tag:       "tag1",
+					Tags:      []string{"tag1"},
 					CreatedAt: createdAt2,
 					UpdatedAt: updateAt2,
 				},
@@ -144,7 +142,7 @@ func TestSortByField(t *testing.T) {
 					ID:        1,
 					Name:      "b",
 					Content:   "content1",
-					Tag:       "tag2",
+					Tags:      []string{"tag2"},
 					CreatedAt: createdAt1,
 					UpdatedAt: updateAt1,
 				},
@@ -158,7 +156,7 @@ func TestSortByField(t *testing.T) {
 					ID:        1,
 					Name:      "b",
 					Content:   "content1",
-					Tag:       "tag2",
+					Tags:      []string{"tag2"},
 					CreatedAt: createdAt1,
 					UpdatedAt: updateAt1,
 				},
@@ -166,7 +164,7 @@ func TestSortByField(t *testing.T) {
 					ID:        2,
 					Name:      "a",
 					Content:   "content2",
-					Tag:       "tag1",
+					Tags:      []string{"tag1"},
 					CreatedAt: createdAt2,
 					UpdatedAt: updateAt2,
 				},
@@ -174,7 +172,6 @@ func TestSortByField(t *testing.T) {
 					ID:        3,
 					Name:      "c",
 					Content:   "content1",
-					Tag:       "",
 					CreatedAt: createdAt3,
 					UpdatedAt: updateAt3,
 				},
@@ -188,7 +185,7 @@ func TestSortByField(t *testing.T) {
 					ID:        2,
 					Name:      "a",
 					Content:   "content2",
-					Tag:       "tag1",
+					Tags:      []string{"tag1"},
 					CreatedAt: createdAt2,
 					UpdatedAt: updateAt2,
 				},
@@ -196,7 +193,7 @@ func TestSortByField(t *testing.T) {
 					ID:        1,
 					Name:      "b",
 					Content:   "content1",
-					Tag:       "tag2",
+					Tags:      []string{"tag2"},
 					CreatedAt: createdAt1,
 					UpdatedAt: updateAt1,
 				},
@@ -204,71 +201,39 @@ func TestSortByField(t *testing.T) {
 					ID:        3,
 					Name:      "c",
 					Content:   "content1",
-					Tag:       "",
 					CreatedAt: createdAt3,
 					UpdatedAt: updateAt3,
 				},
 			},
 		},
 		{
+			// tags are not sortable, the order is kept
 			"tag",
-			true,
+			false,
 			[]models.Task{
-				models.Task{
-					ID:        1,
-					Name:      "b",
-					Content:   "content1",
-					Tag:       "tag2",
-					CreatedAt: createdAt1,
-					UpdatedAt: updateAt1,
-				},
 				models.Task{
 					ID:        2,
 					Name:      "a",
 					Content:   "content2",
-					Tag:       "tag1",
+					Tags:      []string{"tag1"},
 					CreatedAt: createdAt2,
 					UpdatedAt: updateAt2,
 				},
 				models.Task{
-					ID:        3,
-					Name:      "c",
+					ID:        1,
+					Name:      "b",
 					Content:   "content1",
-					Tag:       "",
-					CreatedAt: createdAt3,
-					UpdatedAt: updateAt3,
+					Tags:      []string{"tag2"},
+					CreatedAt: createdAt1,
+					UpdatedAt: updateAt1,
 				},
-			},
-		},
-		{
-			"tag",
-			false,
-			[]models.Task{
-
 				models.Task{
 					ID:        3,
 					Name:      "c",
 					Content:   "content1",
-					Tag:       "",
 					CreatedAt: createdAt3,
 					UpdatedAt: updateAt3,
 				},
-				models.Task{
-					ID:        2,
-					Name:      "a",
-					Content:   "content2",
-					Tag:       "tag1",
-					CreatedAt: createdAt2,
-					UpdatedAt: updateAt2,
-				},
-				models.Task{
-					ID:        1,
-					Name:      "b",
-					Content:   "content1",
-					Tag:       "tag2",
-					CreatedAt: createdAt1,
-					UpdatedAt: updateAt1,
-				},
 			},
 		},
 	}
@@ -279,3 +244,205 @@ func TestSortByField(t *testing.T) {
 	}
 
 }
+
+func TestConvertTaskDueAt(t *testing.T) {
+	task, err := ConvertTask(map[string]string{
+		"due_at":    "2006-01-02T15:04:05.5+08:00",
+		"remind_at": "",
+	})
+	assert.Nil(t, err)
+	if assert.NotNil(t, task.DueAt) {
+		expected, _ := time.Parse(time.RFC3339, "2006-01-02T15:04:05.5+08:00")
+		assert.True(t, expected.Equal(*task.DueAt))
+	}
+	assert.Nil(t, task.RemindAt)
+
+	_, err = ConvertTask(map[string]string{"due_at": "tomorrow"})
+	assert.NotNil(t, err)
+}
+
+func TestConvertTaskOccurrence(t *testing.T) {
+	task, err := ConvertTask(map[string]string{
+		"series_id":     "4",
+		"occurrence_at": "2006-01-02T15:04:05Z",
+	})
+	assert.Nil(t, err)
+	if assert.NotNil(t, task.SeriesID) {
+		assert.Equal(t, uint64(4), *task.SeriesID)
+	}
+	if assert.NotNil(t, task.OccurrenceAt) {
+		assert.Equal(t, time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC), *task.OccurrenceAt)
+	}
+
+	_, err = ConvertTask(map[string]string{"series_id": "x"})
+	assert.NotNil(t, err)
+}
+
+func TestConvertTaskParent(t *testing.T) {
+	task, err := ConvertTask(map[string]string{
+		"parent_id": "9",
+		"progress":  "50",
+	})
+	assert.Nil(t, err)
+	if assert.NotNil(t, task.ParentID) {
+		assert.Equal(t, uint64(9), *task.ParentID)
+	}
+	assert.Equal(t, 50, task.Progress)
+
+	_, err = ConvertTask(map[string]string{"parent_id": "x"})
+	assert.NotNil(t, err)
+}
+
+func TestSortByDueAt(t *testing.T) {
+	early, _ := time.Parse(time.RFC3339, "2006-01-02T15:04:05+08:00")
+	late, _ := time.Parse(time.RFC3339, "2006-01-03T15:04:05+08:00")
+
+	tests := []struct {
+		desc     bool
+		expected []uint64
+	}{
+		{false, []uint64{1, 3, 2}},
+		{true, []uint64{3, 1, 2}},
+	}
+
+	for _, testItem := range tests {
+		tasks := []models.Task{
+			{ID: 1, DueAt: &early},
+			{ID: 2},
+			{ID: 3, DueAt: &late},
+		}
+		SortByField(tasks, "due_at", testItem.desc)
+
+		ids := []uint64{}
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		assert.Equal(t, testItem.expected, ids)
+	}
+}
+
+func TestConvertTaskTags(t *testing.T) {
+	task, err := ConvertTask(map[string]string{"tags": `["a","b"]`})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, task.Tags)
+
+	_, err = ConvertTask(map[string]string{"tags": "a"})
+	assert.NotNil(t, err)
+}
+
+func TestConvertTaskCommentCount(t *testing.T) {
+	task, err := ConvertTask(map[string]string{"comment_count": "3"})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, task.CommentCount)
+
+	_, err = ConvertTask(map[string]string{"comment_count": "x"})
+	assert.NotNil(t, err)
+}
+
+func TestSortByStatusPriorityRank(t *testing.T) {
+	tasks := []models.Task{
+		{ID: 1, Status: models.TaskStatusDone, Priority: models.TaskPriorityLow, Rank: "0000300000"},
+		{ID: 2, Status: models.TaskStatusOpen, Priority: models.TaskPriorityUrgent, Rank: "00002i"},
+		{ID: 3, Status: models.TaskStatusInProgress, Priority: models.TaskPriorityNone, Rank: "0000100000"},
+	}
+
+	tests := []struct {
+		col      string
+		desc     bool
+		expected []uint64
+	}{
+		{"status", false, []uint64{2, 3, 1}},
+		{"priority", true, []uint64{2, 1, 3}},
+		{"rank", false, []uint64{3, 2, 1}},
+		{"rank", true, []uint64{1, 2, 3}},
+	}
+
+	for _, testItem := range tests {
+		SortByField(tasks, testItem.col, testItem.desc)
+		ids := []uint64{}
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		assert.Equal(t, testItem.expected, ids, testItem.col)
+	}
+}
+
+func TestConvertTaskPriorityRank(t *testing.T) {
+	task, err := ConvertTask(map[string]string{
+		"priority": "3",
+		"rank":     "00001i",
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, models.TaskPriorityHigh, task.Priority)
+	assert.Equal(t, "00001i", task.Rank)
+
+	_, err = ConvertTask(map[string]string{"priority": "high"})
+	assert.NotNil(t, err)
+}
+
+func TestSortByCustomField(t *testing.T) {
+	tasks := []models.Task{
+		{ID: 1, Fields: map[string]interface{}{"points": 10.0}},
+		{ID: 2},
+		{ID: 3, Fields: map[string]interface{}{"points": 2.0}},
+	}
+
+	tests := []struct {
+		desc     bool
+		expected []uint64
+	}{
+		{false, []uint64{3, 1, 2}},
+		{true, []uint64{1, 3, 2}},
+	}
+
+	for _, testItem := range tests {
+		SortByField(tasks, "field.points", testItem.desc)
+		ids := []uint64{}
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		assert.Equal(t, testItem.expected, ids)
+	}
+}
+
+func TestConvertTaskFields(t *testing.T) {
+	task, err := ConvertTask(map[string]string{
+		"fields": `{"points":3,"env":"prod","billable":true}`,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"points": 3.0, "env": "prod", "billable": true}, task.Fields)
+
+	task, err = ConvertTask(map[string]string{"fields": "null"})
+	assert.Nil(t, err)
+	assert.Nil(t, task.Fields)
+}
+
+func TestParseOrder(t *testing.T) {
+	tests := []struct {
+		order    string
+		expected string
+		isErr    bool
+	}{
+		{"id", "id", false},
+		{"id desc", "id desc", false},
+		{" due_at   asc ", "due_at asc", false},
+		{"rank", "rank", false},
+		{"field.env desc", "field.env desc", false},
+		{"", "", true},
+		{"tenant_id", "", true},
+		{"tag", "", true},
+		{"id DESC", "", true},
+		{"id desc, (SELECT 1)", "", true},
+		{"id;DROP TABLE Task", "", true},
+		{"field.a'b", "", true},
+	}
+	for _, tt := range tests {
+		order, err := ParseOrder(tt.order)
+		if tt.isErr {
+			assert.Error(t, err, tt.order)
+			continue
+		}
+		assert.NoError(t, err, tt.order)
+		assert.Equal(t, tt.expected, order)
+	}
+}